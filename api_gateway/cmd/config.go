@@ -0,0 +1,284 @@
+package main
+
+import (
+	"api_gateway/internal/upstream"
+	"common/config"
+	"common/logging"
+	"common/servicetoken"
+	"common/tracing"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config - настройки api-gateway. Переменные окружения выводятся из
+// префикса GATEWAY_: GATEWAY_SERVER_PORT, GATEWAY_RATE_LIMIT_BURST и т.д.
+type Config struct {
+	Server struct {
+		Port            int           `yaml:"port" usage:"HTTP port"`
+		ShutdownTimeout time.Duration `yaml:"shutdownTimeout" usage:"graceful shutdown timeout"`
+	} `yaml:"server"`
+
+	Upstreams struct {
+		Users  UpstreamConfig `yaml:"users"`
+		Orders UpstreamConfig `yaml:"orders"`
+		// общий таймаут HTTP-клиента на запрос к сервису
+		Timeout           time.Duration `yaml:"timeout" usage:"upstream request timeout"`
+		DiscoveryInterval time.Duration `yaml:"discoveryInterval" usage:"how often to re-read discovery files, 0 - only at start"`
+
+		// активная проверка: GET healthPath каждого экземпляра
+		HealthCheck struct {
+			Interval           time.Duration `yaml:"interval" usage:"how often to probe instances, 0 - off"`
+			Timeout            time.Duration `yaml:"timeout" usage:"probe timeout"`
+			HealthyThreshold   int           `yaml:"healthyThreshold" usage:"successful probes in a row to mark an instance healthy"`
+			UnhealthyThreshold int           `yaml:"unhealthyThreshold" usage:"failed probes in a row to mark an instance unhealthy"`
+		} `yaml:"healthCheck"`
+
+		// экземпляр с подряд идущими ошибками выводится из балансировки
+		Outlier struct {
+			ConsecutiveFailures int           `yaml:"consecutiveFailures" usage:"failures in a row that eject an instance, 0 - off"`
+			EjectionTime        time.Duration `yaml:"ejectionTime" usage:"how long an instance stays ejected"`
+			MaxEjectedPercent   int           `yaml:"maxEjectedPercent" usage:"max share of instances ejected at once"`
+		} `yaml:"outlier"`
+
+		// повтор идемпотентных запросов (GET, PUT, DELETE, POST с Idempotency-Key); PATCH не повторяется
+		Retry struct {
+			MaxAttempts int           `yaml:"maxAttempts" usage:"attempts per request including the first, 1 - no retries"`
+			BaseDelay   time.Duration `yaml:"baseDelay" usage:"backoff before the first retry, doubled after each"`
+			MaxDelay    time.Duration `yaml:"maxDelay" usage:"backoff cap; longer Retry-After is not waited for"`
+			// повторов к сервису за окно - не больше budgetMinRetries + budgetRatio * запросов
+			BudgetRatio      float64 `yaml:"budgetRatio" usage:"retries allowed per request to an upstream"`
+			BudgetMinRetries int     `yaml:"budgetMinRetries" usage:"retries always allowed per budget window"`
+		} `yaml:"retry"`
+	} `yaml:"upstreams"`
+
+	JWKS struct {
+		TTL time.Duration `yaml:"ttl" usage:"how long fetched JWKS is cached"`
+		// отозванный при logout токен проходит через gateway не дольше этого интервала
+		RevocationInterval time.Duration `yaml:"revocationInterval" usage:"how often to re-read revoked access tokens, 0 - do not check revocation"`
+	} `yaml:"jwks"`
+
+	// один на все сервисы: с ним gateway читает отозванные токены и
+	// пользователей users-service, закрытые для внешних вызовов
+	ServiceToken string `yaml:"serviceToken" env:"SERVICE_TOKEN" secret:"true" usage:"shared token for service-to-service calls"`
+
+	CircuitBreaker struct {
+		// пока запросов меньше, "пробка" не открывается
+		MinRequests  uint32        `yaml:"minRequests" usage:"requests before the breaker may trip"`
+		FailureRatio float64       `yaml:"failureRatio" usage:"failure ratio that trips the breaker"`
+		OpenTimeout  time.Duration `yaml:"openTimeout" usage:"how long the breaker stays open"`
+	} `yaml:"circuitBreaker"`
+
+	// класс default - для маршрутов без своего класса
+	RateLimit struct {
+		Rate  float64       `yaml:"rate" usage:"requests per second per client"`
+		Burst int           `yaml:"burst" usage:"bucket size per client"`
+		TTL   time.Duration `yaml:"ttl" usage:"how long idle clients are remembered"`
+	} `yaml:"rateLimit"`
+	RateLimitClasses []RateLimitClass `yaml:"rateLimitClasses"`
+
+	// Перезагрузка без рестарта: по SIGHUP и при изменении файла конфигурации.
+	Reload struct {
+		PollInterval time.Duration `yaml:"pollInterval" usage:"how often to check the config file, 0 - only on SIGHUP"`
+	} `yaml:"reload"`
+
+	// Таблица проксируемых маршрутов; в файле заменяется целиком.
+	Routes []Route `yaml:"routes"`
+
+	// log и tracing применяются только при рестарте
+	Log     logging.Config `yaml:"log"`
+	Tracing tracing.Config `yaml:"tracing"`
+}
+
+// UpstreamConfig - экземпляры одного сервиса. Список берётся из instances
+// или, если задан discoveryFile, из файла (один URL на строку).
+type UpstreamConfig struct {
+	Instances     []string `yaml:"instances" usage:"instance base URLs, comma separated"`
+	DiscoveryFile string   `yaml:"discoveryFile" usage:"file with instance URLs, one per line"`
+	Balancer      string   `yaml:"balancer" usage:"round-robin, least-in-flight or user-hash"`
+	HealthPath    string   `yaml:"healthPath" usage:"path probed by health checks, empty - no probing"`
+	// без обязательного сервиса /health/ready отвечает 503
+	Required bool `yaml:"required" usage:"gateway is not ready without this upstream"`
+}
+
+// defaultConfig - значения по умолчанию для профиля. В development сервисы
+// запускаются на одной машине, поэтому адреса - localhost с разными портами.
+func defaultConfig(profile string) *Config {
+	cfg := &Config{}
+	cfg.Server.Port = 8000
+	cfg.Server.ShutdownTimeout = 5 * time.Second
+	cfg.Upstreams.Users = UpstreamConfig{
+		Instances:  []string{"http://localhost:8001"},
+		Balancer:   upstream.BalancerRoundRobin,
+		HealthPath: "/users/health",
+		Required:   true,
+	}
+	cfg.Upstreams.Orders = UpstreamConfig{
+		Instances:  []string{"http://localhost:8002"},
+		Balancer:   upstream.BalancerRoundRobin,
+		HealthPath: "/orders/health",
+		Required:   true,
+	}
+	cfg.Upstreams.Timeout = 3 * time.Second
+	cfg.Upstreams.DiscoveryInterval = 5 * time.Second
+	cfg.Upstreams.Outlier.ConsecutiveFailures = 5
+	cfg.Upstreams.Outlier.EjectionTime = 30 * time.Second
+	cfg.Upstreams.Outlier.MaxEjectedPercent = 50
+	cfg.Upstreams.Retry.MaxAttempts = 3
+	cfg.Upstreams.Retry.BaseDelay = 50 * time.Millisecond
+	cfg.Upstreams.Retry.MaxDelay = time.Second
+	cfg.Upstreams.Retry.BudgetRatio = 0.2
+	cfg.Upstreams.Retry.BudgetMinRetries = 10
+	cfg.Upstreams.HealthCheck.Interval = 5 * time.Second
+	cfg.Upstreams.HealthCheck.Timeout = time.Second
+	cfg.Upstreams.HealthCheck.HealthyThreshold = 2
+	cfg.Upstreams.HealthCheck.UnhealthyThreshold = 3
+	cfg.JWKS.TTL = 5 * time.Minute
+	cfg.JWKS.RevocationInterval = 5 * time.Second
+	cfg.CircuitBreaker.MinRequests = 5
+	cfg.CircuitBreaker.FailureRatio = 0.5
+	cfg.CircuitBreaker.OpenTimeout = 3 * time.Second
+	cfg.RateLimit.Rate = 5
+	cfg.RateLimit.Burst = 10
+	cfg.RateLimit.TTL = time.Minute
+	cfg.Reload.PollInterval = 2 * time.Second
+	cfg.Routes = defaultRoutes()
+	cfg.Log.Level = "info"
+	cfg.Log.Format = logging.FormatJSON
+	cfg.Tracing.Exporter = tracing.ExporterNone
+	cfg.Tracing.SampleRatio = 1
+
+	// в production токен задаётся только окружением
+	if profile != config.ProfileProduction {
+		cfg.ServiceToken = servicetoken.DevToken
+	}
+
+	switch profile {
+	case config.ProfileDevelopment:
+		// локально текст читать удобнее, чем JSON
+		cfg.Log.Format = logging.FormatText
+	case config.ProfileProduction:
+		cfg.Upstreams.Users.Instances = []string{"http://service_users:8000"}
+		cfg.Upstreams.Orders.Instances = []string{"http://service_orders:8000"}
+	case config.ProfileTest:
+		// тесты гоняют много запросов с одного адреса
+		cfg.RateLimit.Rate = 1000
+		cfg.RateLimit.Burst = 1000
+	}
+
+	return cfg
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be in 1..65535, got %d", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	errs = append(errs, c.Upstreams.Users.validate("upstreams.users")...)
+	errs = append(errs, c.Upstreams.Orders.validate("upstreams.orders")...)
+	if c.Upstreams.Timeout <= 0 {
+		errs = append(errs, errors.New("upstreams.timeout must be positive"))
+	}
+	if c.Upstreams.DiscoveryInterval < 0 {
+		errs = append(errs, errors.New("upstreams.discoveryInterval must be non-negative"))
+	}
+	if hc := c.Upstreams.HealthCheck; hc.Interval < 0 || hc.Timeout <= 0 || hc.HealthyThreshold <= 0 || hc.UnhealthyThreshold <= 0 {
+		errs = append(errs, errors.New("upstreams.healthCheck: interval must be non-negative, timeout and thresholds positive"))
+	}
+	if r := c.Upstreams.Retry; r.MaxAttempts < 1 || r.BaseDelay < 0 || r.MaxDelay < r.BaseDelay || r.BudgetRatio < 0 || r.BudgetMinRetries < 0 {
+		errs = append(errs, errors.New("upstreams.retry: maxAttempts must be positive, delays and budget non-negative, maxDelay >= baseDelay"))
+	}
+	if od := c.Upstreams.Outlier; od.ConsecutiveFailures < 0 || od.EjectionTime < 0 || od.MaxEjectedPercent < 0 || od.MaxEjectedPercent > 100 {
+		errs = append(errs, errors.New("upstreams.outlier: values must be non-negative, maxEjectedPercent at most 100"))
+	}
+	if c.JWKS.TTL <= 0 {
+		errs = append(errs, errors.New("jwks.ttl must be positive"))
+	}
+	if c.JWKS.RevocationInterval < 0 {
+		errs = append(errs, errors.New("jwks.revocationInterval must be non-negative"))
+	}
+	if c.ServiceToken == "" {
+		errs = append(errs, errors.New("serviceToken is required"))
+	}
+	if c.CircuitBreaker.FailureRatio <= 0 || c.CircuitBreaker.FailureRatio > 1 {
+		errs = append(errs, fmt.Errorf("circuitBreaker.failureRatio must be in (0, 1], got %v", c.CircuitBreaker.FailureRatio))
+	}
+	if c.CircuitBreaker.OpenTimeout <= 0 {
+		errs = append(errs, errors.New("circuitBreaker.openTimeout must be positive"))
+	}
+	if c.RateLimit.Rate <= 0 || c.RateLimit.Burst <= 0 {
+		errs = append(errs, errors.New("rateLimit.rate and rateLimit.burst must be positive"))
+	}
+	if c.RateLimit.TTL <= 0 {
+		errs = append(errs, errors.New("rateLimit.ttl must be positive"))
+	}
+	if c.Reload.PollInterval < 0 {
+		errs = append(errs, errors.New("reload.pollInterval must be non-negative"))
+	}
+	errs = append(errs, validateRoutes(c.Routes, c.RateLimitClasses)...)
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (u UpstreamConfig) validate(key string) []error {
+	var errs []error
+	if len(u.Instances) == 0 && u.DiscoveryFile == "" {
+		errs = append(errs, fmt.Errorf("%s: instances or discoveryFile is required", key))
+	}
+	for i, raw := range u.Instances {
+		if err := validateURL(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s.instances[%d]: %w", key, i, err))
+		}
+	}
+	if u.HealthPath != "" && !strings.HasPrefix(u.HealthPath, "/") {
+		errs = append(errs, fmt.Errorf("%s.healthPath must start with /", key))
+	}
+	if _, err := upstream.NewBalancer(u.Balancer); err != nil {
+		errs = append(errs, fmt.Errorf("%s.balancer: %w", key, err))
+	}
+	return errs
+}
+
+// loadConfig при ошибке валидации всё равно возвращает прочитанную
+// конфигурацию - при перезагрузке по ней печатается отклонённый diff.
+func loadConfig(args []string) (*Config, string, error) {
+	profile, err := config.Profile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := defaultConfig(profile)
+	if err := config.Load(cfg, configOptions(args)); err != nil {
+		return cfg, profile, err
+	}
+	return cfg, profile, nil
+}
+
+func configOptions(args []string) config.Options {
+	return config.Options{
+		Name:      "api-gateway",
+		EnvPrefix: "GATEWAY_",
+		Args:      args,
+	}
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want absolute http(s) URL, got %q", raw)
+	}
+	return nil
+}
@@ -2,13 +2,21 @@ package main
 
 import (
 	"api_gateway/internal/handler"
+	"api_gateway/internal/metrics"
+	"api_gateway/internal/upstream"
+	"common/config"
+	"common/logging"
+	commonmetrics "common/metrics"
+	"common/policy"
+	"common/problem"
+	"common/tracing"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,118 +24,128 @@ import (
 	"github.com/sony/gobreaker"
 )
 
-const (
-	usersServiceURL  = "http://service_users:8000"
-	ordersServiceURL = "http://service_orders:8000"
-	port     = "8000"
-	shutdownTimeout = 5 * time.Second
-
-	jwtSecret = "super-secret-key"
-)
-
-var httpClient = &http.Client{
-	Timeout: 3 * time.Second,
-}
-
 func main() {
-	usersCB := newCircuitBreaker("users-service")
-	ordersCB := newCircuitBreaker("orders-service")
+	cfg, profile, err := loadConfig(os.Args[1:])
+	if err != nil {
+		logging.Fatal("failed to load config", err)
+	}
+	logging.Setup("api_gateway", cfg.Log)
+	slog.Info("effective config", "profile", profile, "config", config.Describe(cfg))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "api_gateway", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("failed to init tracing", err)
+	}
 
-	usersHandler := handler.NewUserHandler(httpClient, usersServiceURL, usersCB)
-	ordersHandler := handler.NewOrdersHandler(httpClient, ordersServiceURL, ordersCB)
-	aggHandler   := handler.NewAggregationHandler(httpClient, usersCB, ordersCB, usersServiceURL, ordersServiceURL)
-	healthHandler := handler.NewHealthHandler(usersCB, ordersCB)
+	gw, err := newGateway(cfg, profile, os.Args[1:])
+	if err != nil {
+		logging.Fatal("failed to init routes", err)
+	}
 
 	srv := &http.Server{
-		Addr: fmt.Sprintf(":%v", port),
-		Handler: initRouter(usersHandler, ordersHandler, aggHandler, healthHandler),
+		Addr: fmt.Sprintf(":%v", cfg.Server.Port),
+		Handler: gw,
 	}
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+
+	go gw.watch(ctx, config.File(configOptions(os.Args[1:])), cfg.Reload.PollInterval)
 	
 	go func() {
-		log.Println("starting api-gateway on port", port)
+		slog.InfoContext(ctx, "starting api-gateway", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server starting failed: %v", err)
+			logging.Fatal("server starting failed", err)
 		}
 	}() 
 	
 	<-ctx.Done()
 
-	shutDownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	shutDownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	
-	log.Printf("shutting down server gracefully")
+	slog.InfoContext(shutDownCtx, "shutting down server gracefully")
 	if err := srv.Shutdown(shutDownCtx); err != nil {
-		log.Println("error when shutting down:", err)
+		slog.ErrorContext(shutDownCtx, "error when shutting down", "error", err)
 	} else {
-		log.Println("server stopped")
+		slog.InfoContext(shutDownCtx, "server stopped")
+	}
+
+	if err := shutdownTracing(shutDownCtx); err != nil {
+		slog.ErrorContext(shutDownCtx, "error when flushing traces", "error", err)
 	}
 }
 
-func initRouter(users *handler.UsersHandler, orders *handler.OrdersHandler, agg *handler.AggregationHandler, health *handler.HealthHandler) *chi.Mux {
+func initRouter(cfg *Config, c *components, adminConfig http.HandlerFunc) (*chi.Mux, error) {
 	r := chi.NewRouter()
 
+	r.Use(handler.StripIdentityHeaders)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(commonmetrics.Middleware)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
-		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"},
+		ExposedHeaders:   []string{"Idempotent-Replayed", "X-Request-ID"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
 
-	rl := handler.NewRateLimiter(5, 10, time.Minute)
-	r.Use(rl.Middleware)
-
-	r.Get("/users/{userId}", users.GetUser)
-	r.Post("/users", users.CreateUser)
-	r.Get("/users", users.ListUsers)
-	r.Put("/users", users.UpdateUser)
-	r.Delete("/users/{userId}", users.DeleteUser)
-
-	r.Post("/auth/register", users.Register)
-	r.Post("/auth/login", users.Login)
+	p := limitedRouter{
+		Router:   policy.NewRouter(r, routePolicies, handler.Auth(c.jwks, c.revoked)),
+		limiters: c.limiters,
+	}
 
-	// Protected endpoint 
-	r.With(handler.JWTAuthMiddleware([]byte(jwtSecret))).Get("/orders", orders.ListOrders)
+	// проксируемые маршруты - из таблицы в конфиге
+	for _, rt := range cfg.Routes {
+		pol, err := policy.Parse(rt.Auth)
+		if err != nil {
+			return nil, err
+		}
+		p.route(rt.Method, rt.Path, pol, rt.RateLimit, newRouteHandler(rt, cfg, c))
+	}
 
-	r.Get("/orders/{orderId}", orders.GetOrder)
-	r.Post("/orders", orders.CreateOrder)
-	// r.Get("/orders", orders.ListOrders)
-	r.Put("/orders", orders.UpdateOrder)
-	r.Delete("/orders/{orderId}", orders.DeleteOrder)
-	r.Get("/orders/status", orders.OrdersStatus)
-	r.Get("/orders/health", orders.OrdersHealth)
+	p.handle(http.MethodGet, "/users/{userId}/details", c.agg.UserDetails)
 
-	r.Get("/users/{userId}/details", agg.UserDetails)
+	p.handle(http.MethodGet, "/health", c.health.Health)
+	p.handle(http.MethodGet, "/health/live", c.health.Live)
+	p.handle(http.MethodGet, "/health/ready", c.health.Ready)
+	p.handle(http.MethodGet, "/status", c.health.Status)
+	p.handle(http.MethodGet, "/metrics", commonmetrics.Handler().ServeHTTP)
+	p.handle(http.MethodGet, "/openapi.json", newSpecHandler(cfg, c))
+	p.handle(http.MethodGet, "/admin/config", adminConfig)
 
-	r.Get("/health", health.Health)
-	r.Get("/status", health.Status)
+	p.Verify()
 
-	return r
+	return r, nil
 }
 
-func newCircuitBreaker(name string) *gobreaker.CircuitBreaker {
+func newCircuitBreaker(name string, cfg *Config) *gobreaker.CircuitBreaker {
 	settings := gobreaker.Settings{
 		Name: name,
 		// Через сколько ошибок и при каком проценте фейлов открывать "пробку"
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			if counts.Requests < 5 {
+			if counts.Requests < cfg.CircuitBreaker.MinRequests {
 				return false
 			}
 			errorRate := float64(counts.TotalFailures) / float64(counts.Requests)
-			return errorRate >= 0.5
+			return errorRate >= cfg.CircuitBreaker.FailureRatio
 		},
-		Timeout: 3 * time.Second, // сколько ждать перед попыткой "полечить" сервис
+		Timeout: cfg.CircuitBreaker.OpenTimeout, // сколько ждать перед попыткой "полечить" сервис
+		// клиент ушёл, не дождавшись ответа, - не сбой сервиса
+		IsSuccessful: upstream.IsSuccessful,
 		OnStateChange: func(name string, from, to gobreaker.State) {
-			log.Printf("circuit %s changed from %s to %s", name, from.String(), to.String())
+			slog.Warn("circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+			metrics.BreakerState.WithLabelValues(name).Set(float64(to))
+			metrics.BreakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
 		},
 	}
+	metrics.BreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
 	return gobreaker.NewCircuitBreaker(settings)
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"common/config"
+	commonmetrics "common/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGateway_ExposesUpstreamMetrics(t *testing.T) {
+	var calls atomic.Int64
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer orders.Close()
+
+	cfg := defaultConfig(config.ProfileTest)
+	cfg.Upstreams.Orders.Instances = []string{orders.URL}
+	cfg.Upstreams.HealthCheck.Interval = 0
+	cfg.Upstreams.Retry.BaseDelay = time.Millisecond
+	cfg.Upstreams.Retry.MaxDelay = 10 * time.Millisecond
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	rt := Route{Method: http.MethodGet, Path: "/metrics-test/{orderId}", Rewrite: "/orders/{orderId}", Upstream: "orders", Auth: "public", Breaker: "metrics-test"}
+	r := chi.NewRouter()
+	r.Use(commonmetrics.Middleware)
+	r.Handle(rt.Path, newRouteProxy(rt, cfg, c))
+	r.Get("/metrics", commonmetrics.Handler().ServeHTTP)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics-test/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after one retry, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/metrics-test/{orderId}",status="200"} 1`,
+		`gateway_upstream_request_duration_seconds_count{outcome="5xx",upstream="orders"}`,
+		`gateway_upstream_request_duration_seconds_count{outcome="2xx",upstream="orders"}`,
+		`gateway_circuit_breaker_state{breaker="metrics-test/` + orders.URL + `"} 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
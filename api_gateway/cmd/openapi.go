@@ -0,0 +1,154 @@
+package main
+
+import (
+	"api_gateway/internal/handler"
+	"api_gateway/internal/model"
+	"common/openapi"
+	"common/policy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// gatewayRoutes описывает маршруты, которые gateway обслуживает сам;
+// аутентификация и роли берутся из routePolicies.
+var gatewayRoutes = []openapi.Route{
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "getOpenAPI", Summary: "Спецификация внешнего API", Tag: "meta", Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/metrics", ID: "getMetrics", Summary: "Метрики Prometheus", Tag: "meta", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/health", ID: "getHealth", Summary: "Состояние breaker'ов и экземпляров upstream'ов", Tag: "meta",
+		Response: map[string]any{"status": "", "circuits": map[string]any{}, "upstreams": map[string]any{}}},
+	{Method: http.MethodGet, Path: "/health/live", ID: "getLive", Tag: "meta", Response: map[string]any{"status": ""}},
+	{Method: http.MethodGet, Path: "/health/ready", ID: "getReady", Summary: "503, пока не готов обязательный upstream", Tag: "meta",
+		Response:  map[string]any{"status": "", "upstreams": map[string]string{}},
+		Responses: map[int]any{http.StatusServiceUnavailable: map[string]any{"status": "", "upstreams": map[string]string{}}}},
+	{Method: http.MethodGet, Path: "/status", ID: "getStatus", Tag: "meta", Response: map[string]any{"status": ""}},
+	{Method: http.MethodGet, Path: "/admin/config", ID: "getConfigVersion", Summary: "Активная версия конфигурации", Tag: "meta",
+		Response: map[string]any{"version": 0, "loadedAt": time.Time{}, "profile": "", "lastError": ""}},
+
+	{Method: http.MethodGet, Path: "/users/{userId}/details", ID: "getUserDetails", Summary: "Пользователь вместе с его заказами", Tag: "aggregation",
+		Params:   []openapi.Parameter{openapi.PathInt("userId", "id пользователя")},
+		Response: model.UserDetailsResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}},
+}
+
+// newSpecHandler - GET /openapi.json: собственные маршруты gateway и
+// маршруты таблицы. Описание проксируемого маршрута берётся из /openapi.json
+// его сервиса при каждом запросе, так что спецификация не отстаёт от
+// сервисов. Если сервис недоступен или маршрут у него не описан, маршрут
+// всё равно попадает в спецификацию, но без схем.
+func newSpecHandler(cfg *Config, c *components) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Upstreams.Timeout)
+		defer cancel()
+
+		upstreams := make(map[string]*openapi.Document, len(upstreamServices))
+		for name := range upstreamServices {
+			doc, err := fetchSpec(ctx, c.transport(name, ""))
+			if err != nil {
+				slog.WarnContext(ctx, "failed to fetch upstream spec", "upstream", name, "error", err)
+				continue
+			}
+			upstreams[name] = doc
+		}
+
+		gatewaySpec(ctx, cfg.Routes, upstreams).Handler().ServeHTTP(w, r)
+	}
+}
+
+// gatewaySpec собирает спецификацию; upstreams - спецификации сервисов по
+// именам из таблицы, недоступных сервисов в ней нет.
+func gatewaySpec(ctx context.Context, routes []Route, upstreams map[string]*openapi.Document) *openapi.Document {
+	doc := openapi.New("API Gateway", "1.0.0")
+	doc.Components.SecuritySchemes[openapi.BearerAuth] = openapi.BearerScheme
+
+	for _, rt := range gatewayRoutes {
+		pol := routePolicies[rt.Method+" "+rt.Path]
+		if !pol.Public {
+			rt.Security = openapi.BearerAuth
+			rt.Roles = pol.Roles
+			rt.Owner = pol.OwnerParam
+		}
+		rt.Errors = append(rt.Errors, http.StatusTooManyRequests)
+		doc.Add(rt)
+	}
+
+	for _, rt := range routes {
+		describeRoute(ctx, doc, rt, upstreams[rt.Upstream])
+	}
+	return doc
+}
+
+// describeRoute описывает маршрут таблицы по операции сервиса. Кто может
+// вызвать маршрут, решает gateway, поэтому требования сервиса заменяются
+// политикой маршрута.
+func describeRoute(ctx context.Context, doc *openapi.Document, rt Route, upstream *openapi.Document) {
+	rewrite := rt.Rewrite
+	if rewrite == "" {
+		rewrite = rt.Path
+	}
+	service := upstreamServices[rt.Upstream]
+
+	var op *openapi.Operation
+	if upstream != nil {
+		var err error
+		op, err = doc.Import(upstream, service, rt.Method, rewrite, rt.Path)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to import upstream operation", "route", rt.Method+" "+rt.Path, "error", err)
+		}
+	}
+	if op == nil {
+		doc.Add(openapi.Route{
+			Method:  rt.Method,
+			Path:    rt.Path,
+			Summary: fmt.Sprintf("Proxied to %s service %s", service, rewrite),
+			Tag:     rt.Upstream,
+		})
+		op = doc.Paths[rt.Path][strings.ToLower(rt.Method)]
+	}
+
+	pol, _ := policy.Parse(rt.Auth) // таблица уже проверена validateRoutes
+	op.Security, op.Roles, op.Owner = nil, nil, ""
+	if !pol.Public {
+		op.Security = []map[string][]string{{openapi.BearerAuth: {}}}
+		op.Roles = pol.Roles
+		op.Owner = pol.OwnerParam
+		doc.AddErrors(op, http.StatusUnauthorized)
+		if len(pol.Roles) > 0 {
+			doc.AddErrors(op, http.StatusForbidden)
+		}
+	}
+	if newBody, ok := handler.RequestBodies[rt.Body]; ok && op.RequestBody == nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: doc.SchemaOf(newBody())}},
+		}
+		doc.AddErrors(op, http.StatusBadRequest, http.StatusRequestEntityTooLarge)
+	}
+	doc.AddErrors(op, http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout)
+}
+
+// fetchSpec - /openapi.json сервиса через пул его экземпляров.
+func fetchSpec(ctx context.Context, transport http.RoundTripper) (*openapi.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/openapi.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
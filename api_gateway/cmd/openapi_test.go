@@ -0,0 +1,87 @@
+package main
+
+import (
+	"common/config"
+	"common/openapi"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPI_MatchesRouter(t *testing.T) {
+	cfg := defaultConfig(config.ProfileTest)
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	r, err := initRouter(cfg, c, func(http.ResponseWriter, *http.Request) {})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// без спецификаций сервисов маршруты таблицы описываются без схем, но описываются
+	if err := gatewaySpec(context.Background(), cfg.Routes, nil).CheckRoutes(r); err != nil {
+		t.Fatalf("spec and router disagree:\n%v", err)
+	}
+}
+
+type upstreamUser struct {
+	ID    int      `json:"id"`
+	Roles []string `json:"roles"`
+}
+
+func TestOpenAPI_MergesUpstreamSpecs(t *testing.T) {
+	spec := openapi.New("Users Service", "1.0.0")
+	spec.Add(openapi.Route{Method: http.MethodGet, Path: "/users/{id}", ID: "getUser", Response: upstreamUser{}})
+	users := httptest.NewServer(spec.Handler())
+	defer users.Close()
+	orders := httptest.NewServer(http.NotFoundHandler())
+	defer orders.Close()
+
+	cfg := defaultConfig(config.ProfileTest)
+	cfg.Upstreams.Users.Instances = []string{users.URL}
+	cfg.Upstreams.Orders.Instances = []string{orders.URL}
+	cfg.Upstreams.HealthCheck.Interval = 0
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+	r, err := initRouter(cfg, c, func(http.ResponseWriter, *http.Request) {})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc openapi.Document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected spec JSON, got %d: %v", w.Code, err)
+	}
+
+	// операция сервиса под путём gateway и с политикой gateway
+	get := doc.Paths["/users/{userId}"]["get"]
+	if get == nil || get.OperationID != "getUser" || get.Parameters[0].Name != "userId" {
+		t.Fatalf("expected imported getUser with renamed parameter, got: %+v", get)
+	}
+	if len(get.Security) == 0 || get.Owner != "userId" || get.Responses["401"] == nil || get.Responses["429"] == nil {
+		t.Fatalf("expected gateway auth and errors on imported operation, got: %+v", get)
+	}
+	ref := get.Responses["200"].Content["application/json"].Schema.Ref
+	if s := doc.Components.Schemas["upstreamUser"]; ref != "#/components/schemas/upstreamUser" || s == nil || s.Properties["roles"] == nil {
+		t.Fatalf("expected upstream schema to be merged, got ref %q", ref)
+	}
+
+	// orders-service спецификации не отдал: маршрут описан по таблице
+	create := doc.Paths["/orders"]["post"]
+	if create == nil || create.RequestBody == nil || create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/CreateOrderRequest" {
+		t.Fatalf("expected createOrder described from the route table, got: %+v", create)
+	}
+	if details := doc.Paths["/users/{userId}/details"]["get"]; details == nil || details.Owner != "userId" {
+		t.Fatalf("expected gateway's own aggregation route, got: %+v", details)
+	}
+}
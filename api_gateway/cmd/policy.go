@@ -0,0 +1,52 @@
+package main
+
+import (
+	"api_gateway/internal/handler"
+	"common/policy"
+	"fmt"
+	"net/http"
+)
+
+// routePolicies - кому доступны маршруты, которые gateway обслуживает сам.
+// Политики проксируемых маршрутов задаются в таблице маршрутов (см. Route.Auth).
+// Маршрут без записи здесь зарегистрировать нельзя (см. policy.Router.Handle).
+var routePolicies = map[string]policy.Policy{
+	"GET /users/{userId}/details": policy.OwnerOrAdmin("userId"),
+
+	"GET /health":       policy.PublicAccess,
+	"GET /health/live":  policy.PublicAccess,
+	"GET /health/ready": policy.PublicAccess,
+	"GET /status":       policy.PublicAccess,
+	// Prometheus ходит без токена; снаружи /metrics закрывают на уровне сети
+	"GET /metrics":      policy.PublicAccess,
+	"GET /openapi.json": policy.PublicAccess,
+
+	"GET /admin/config": policy.AdminOnly,
+}
+
+// limitedRouter ставит перед проверкой доступа rate limit класса маршрута.
+type limitedRouter struct {
+	*policy.Router
+	limiters map[string]*handler.RateLimiter
+}
+
+func (p limitedRouter) handle(method, pattern string, h http.HandlerFunc) {
+	p.Handle(method, pattern, h, p.limit(defaultRateLimitClass))
+}
+
+// route регистрирует маршрут с явной политикой и классом лимита - так
+// приходят записи из таблицы маршрутов.
+func (p limitedRouter) route(method, pattern string, pol policy.Policy, class string, h http.Handler) {
+	p.Route(method, pattern, pol, h, p.limit(class))
+}
+
+func (p limitedRouter) limit(class string) func(http.Handler) http.Handler {
+	if class == "" {
+		class = defaultRateLimitClass
+	}
+	rl, ok := p.limiters[class]
+	if !ok {
+		panic(fmt.Sprintf("no rate limiter for class %q", class))
+	}
+	return rl.Middleware
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"common/config"
+	"net/http"
+	"testing"
+)
+
+// initRouter паникует, если маршрут и таблица политик разошлись.
+func TestInitRouter_EveryRouteHasPolicy(t *testing.T) {
+	cfg := defaultConfig(config.ProfileTest)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected default config to be valid, got: %v", err)
+	}
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	_, err = initRouter(cfg, c, func(http.ResponseWriter, *http.Request) {})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"api_gateway/internal/handler"
+	"api_gateway/internal/upstream"
+	"bytes"
+	"common/config"
+	"common/deadline"
+	"common/retry"
+	"common/servicetoken"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// components - всё, что gateway собирает из конфигурации.
+type components struct {
+	upstreams map[string]*upstream.Pool // users, orders
+	budgets   map[string]*retry.Budget  // бюджет повторов на upstream
+	retry     retry.Policy
+	limiters  map[string]*handler.RateLimiter
+	jwks      *handler.JWKSCache
+	revoked   *handler.RevocationList // nil - отзыв не проверяется
+	agg       *handler.AggregationHandler
+	health    *handler.HealthHandler
+}
+
+// newComponents собирает компоненты для cfg. Пулы, бюджеты повторов, лимитеры,
+// кэш JWKS и список отозванных токенов берутся из prev, если их настройки не поменялись: перезагрузка не
+// должна сбрасывать счётчики breaker'ов, выброшенные экземпляры, ведёрки
+// клиентов и ключи.
+func newComponents(cfg *Config, prev *gatewayState) (*components, error) {
+	c := &components{
+		upstreams: make(map[string]*upstream.Pool),
+		budgets:   make(map[string]*retry.Budget),
+		limiters:  make(map[string]*handler.RateLimiter),
+		retry:     retryPolicy(cfg),
+	}
+	var old *Config
+	if prev != nil {
+		old = prev.cfg
+	}
+
+	for name := range upstreamServices {
+		uc := upstreamConfig(cfg, name)
+		if old != nil && samePool(old, cfg, name) {
+			c.upstreams[name] = prev.upstreams[name]
+			continue
+		}
+
+		pool, err := newPool(name, uc, cfg)
+		if err != nil {
+			c.close(prev)
+			return nil, err
+		}
+		c.upstreams[name] = pool
+	}
+
+	for name := range upstreamServices {
+		if old != nil && old.Upstreams.Retry == cfg.Upstreams.Retry {
+			c.budgets[name] = prev.budgets[name]
+			continue
+		}
+		c.budgets[name] = retry.NewBudget(cfg.Upstreams.Retry.BudgetRatio, cfg.Upstreams.Retry.BudgetMinRetries, retryBudgetWindow)
+	}
+
+	for class, limit := range rateLimitClasses(cfg) {
+		if old != nil && rateLimitClasses(old)[class] == limit && old.RateLimit.TTL == cfg.RateLimit.TTL {
+			c.limiters[class] = prev.limiters[class]
+			continue
+		}
+		c.limiters[class] = handler.NewRateLimiter(class, limit.Rate, limit.Burst, cfg.RateLimit.TTL)
+	}
+
+	httpClient := &http.Client{
+		Timeout: cfg.Upstreams.Timeout,
+	}
+	users := c.upstreams["users"]
+
+	if old != nil && users == prev.upstreams["users"] && old.JWKS == cfg.JWKS && old.Upstreams.Timeout == cfg.Upstreams.Timeout && old.ServiceToken == cfg.ServiceToken {
+		c.jwks = prev.jwks
+		c.revoked = prev.revoked
+	} else {
+		jwksClient := &http.Client{Transport: servicetoken.NewTransport(users.Transport("jwks"), cfg.ServiceToken), Timeout: cfg.Upstreams.Timeout}
+		c.jwks = handler.NewJWKSCache(jwksClient, "/.well-known/jwks.json", cfg.JWKS.TTL)
+		if cfg.JWKS.RevocationInterval > 0 {
+			c.revoked = handler.NewRevocationList(jwksClient, "/auth/revoked", cfg.JWKS.RevocationInterval)
+			c.revoked.Start()
+		}
+	}
+
+	// пользователя по id users-service отдаёт только сервисам
+	c.agg = handler.NewAggregationHandler(httpClient, servicetoken.NewTransport(c.transport("users", ""), cfg.ServiceToken), c.transport("orders", ""))
+	infos := make(map[string]handler.UpstreamInfo, len(c.upstreams))
+	for name, pool := range c.upstreams {
+		uc := upstreamConfig(cfg, name)
+		infos[name] = handler.UpstreamInfo{Pool: pool, Balancer: uc.Balancer, Required: uc.Required}
+	}
+	c.health = handler.NewHealthHandler(infos)
+
+	return c, nil
+}
+
+// retryBudgetWindow - окно, за которое считается бюджет повторов.
+const retryBudgetWindow = 10 * time.Second
+
+func retryPolicy(cfg *Config) retry.Policy {
+	return retry.Policy{
+		MaxAttempts: cfg.Upstreams.Retry.MaxAttempts,
+		BaseDelay:   cfg.Upstreams.Retry.BaseDelay,
+		MaxDelay:    cfg.Upstreams.Retry.MaxDelay,
+		// живых экземпляров нет - повтор через 50мс ничего не изменит
+		Retryable: func(resp *http.Response, err error) bool {
+			return !errors.Is(err, upstream.ErrNoAvailableInstance) && retry.DefaultRetryable(resp, err)
+		},
+	}
+}
+
+// transport - запросы к upstream'у name с повторами; каждая попытка заново
+// выбирает экземпляр и проходит через его breaker группы group.
+func (c *components) transport(name, group string) http.RoundTripper {
+	return retry.NewTransport(c.upstreams[name].Transport(group), c.retry, c.budgets[name])
+}
+
+func upstreamConfig(cfg *Config, name string) UpstreamConfig {
+	if name == "orders" {
+		return cfg.Upstreams.Orders
+	}
+	return cfg.Upstreams.Users
+}
+
+// samePool - можно ли оставить пул name из старой конфигурации.
+func samePool(old, cfg *Config, name string) bool {
+	return reflect.DeepEqual(upstreamConfig(old, name), upstreamConfig(cfg, name)) &&
+		old.Upstreams.Outlier == cfg.Upstreams.Outlier &&
+		old.Upstreams.DiscoveryInterval == cfg.Upstreams.DiscoveryInterval &&
+		old.Upstreams.HealthCheck == cfg.Upstreams.HealthCheck &&
+		old.CircuitBreaker == cfg.CircuitBreaker
+}
+
+func newPool(name string, uc UpstreamConfig, cfg *Config) (*upstream.Pool, error) {
+	balancer, err := upstream.NewBalancer(uc.Balancer)
+	if err != nil {
+		return nil, err
+	}
+	var discovery upstream.Discovery = upstream.Static(uc.Instances)
+	if uc.DiscoveryFile != "" {
+		discovery = upstream.File(uc.DiscoveryFile)
+	}
+
+	pool, err := upstream.NewPool(name, upstream.Options{
+		Balancer:  balancer,
+		Discovery: discovery,
+		Outlier: upstream.OutlierDetection{
+			ConsecutiveFailures: cfg.Upstreams.Outlier.ConsecutiveFailures,
+			EjectionTime:        cfg.Upstreams.Outlier.EjectionTime,
+			MaxEjectedPercent:   cfg.Upstreams.Outlier.MaxEjectedPercent,
+		},
+		HealthCheck: upstream.HealthCheck{
+			Path:               uc.HealthPath,
+			Interval:           cfg.Upstreams.HealthCheck.Interval,
+			Timeout:            cfg.Upstreams.HealthCheck.Timeout,
+			HealthyThreshold:   cfg.Upstreams.HealthCheck.HealthyThreshold,
+			UnhealthyThreshold: cfg.Upstreams.HealthCheck.UnhealthyThreshold,
+		},
+		NewBreaker: func(name string) *gobreaker.CircuitBreaker {
+			return newCircuitBreaker(name, cfg)
+		},
+		// каждая попытка сообщает сервису, сколько времени у неё осталось
+		Transport: deadline.NewTransport(nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pool.Start(cfg.Upstreams.DiscoveryInterval)
+	return pool, nil
+}
+
+// close останавливает пулы и опрос отозванных токенов c, которых нет в prev.
+// Вызывается для состояния, которое не стало активным, и (с новым состоянием
+// в prev) для заменённого.
+func (c *components) close(prev *gatewayState) {
+	for name, pool := range c.upstreams {
+		if prev == nil || prev.upstreams[name] != pool {
+			pool.Close()
+		}
+	}
+	if c.revoked != nil && (prev == nil || prev.revoked != c.revoked) {
+		c.revoked.Close()
+	}
+}
+
+// rateLimitClasses - настройки лимита по классам, включая default.
+func rateLimitClasses(cfg *Config) map[string]RateLimitClass {
+	classes := map[string]RateLimitClass{
+		defaultRateLimitClass: {Name: defaultRateLimitClass, Rate: cfg.RateLimit.Rate, Burst: cfg.RateLimit.Burst},
+	}
+	for _, c := range cfg.RateLimitClasses {
+		classes[c.Name] = c
+	}
+	return classes
+}
+
+// gatewayState - одна версия конфигурации вместе с роутером. Запрос берёт
+// состояние один раз и дорабатывает на нём, даже если конфиг успели заменить.
+type gatewayState struct {
+	*components
+	cfg      *Config
+	router   http.Handler
+	version  int
+	loadedAt time.Time
+}
+
+// gateway - корневой http.Handler с атомарно подменяемым состоянием.
+type gateway struct {
+	profile string
+	args    []string
+	state   atomic.Pointer[gatewayState]
+
+	mu        sync.Mutex   // перезагрузки идут по одной
+	lastError atomic.Value // string: почему отклонена последняя перезагрузка
+}
+
+func newGateway(cfg *Config, profile string, args []string) (*gateway, error) {
+	g := &gateway{profile: profile, args: args}
+
+	st, err := g.build(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	st.version = 1
+	g.state.Store(st)
+
+	return g, nil
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.state.Load().router.ServeHTTP(w, r)
+}
+
+func (g *gateway) build(cfg *Config, prev *gatewayState) (*gatewayState, error) {
+	c, err := newComponents(cfg, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := initRouter(cfg, c, g.ConfigVersion)
+	if err != nil {
+		c.close(prev)
+		return nil, err
+	}
+
+	return &gatewayState{
+		components: c,
+		cfg:        cfg,
+		router:     router,
+		loadedAt:   time.Now(),
+	}, nil
+}
+
+// reload перечитывает конфигурацию (файл, окружение, флаги) и подменяет
+// состояние. Невалидная конфигурация отклоняется, старая продолжает работать.
+func (g *gateway) reload(ctx context.Context, reason string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	prev := g.state.Load()
+	cfg, _, err := loadConfig(g.args)
+	if err != nil {
+		if cfg != nil {
+			logDiff(ctx, "rejected config diff", prev.cfg, cfg)
+		}
+		g.lastError.Store(err.Error())
+		slog.ErrorContext(ctx, "config reload rejected", "reason", reason, "version", prev.version, "error", err)
+		return err
+	}
+
+	// порт, логи, трассировка и параметры самой перезагрузки применяются только при рестарте
+	if cfg.Server != prev.cfg.Server || cfg.Reload != prev.cfg.Reload || cfg.Log != prev.cfg.Log || cfg.Tracing != prev.cfg.Tracing {
+		slog.WarnContext(ctx, "config reload: server.*, reload.*, log.* and tracing.* changes take effect after restart", "reason", reason)
+		cfg.Server = prev.cfg.Server
+		cfg.Reload = prev.cfg.Reload
+		cfg.Log = prev.cfg.Log
+		cfg.Tracing = prev.cfg.Tracing
+	}
+
+	if len(config.Diff(prev.cfg, cfg)) == 0 {
+		g.lastError.Store("")
+		slog.InfoContext(ctx, "config reload: nothing changed", "reason", reason, "version", prev.version)
+		return nil
+	}
+
+	st, err := g.build(cfg, prev)
+	if err != nil {
+		g.lastError.Store(err.Error())
+		slog.ErrorContext(ctx, "config reload rejected", "reason", reason, "version", prev.version, "error", err)
+		return err
+	}
+	st.version = prev.version + 1
+	g.state.Store(st)
+	g.lastError.Store("")
+	// пулы, которые не перешли в новое состояние, больше не опрашивают discovery;
+	// запросы, что ещё идут через старый роутер, дорабатывают на них
+	prev.close(st)
+
+	logDiff(ctx, "config diff", prev.cfg, cfg)
+	slog.InfoContext(ctx, "config reload: new version is active", "reason", reason, "version", st.version)
+	return nil
+}
+
+func logDiff(ctx context.Context, title string, old, new *Config) {
+	slog.InfoContext(ctx, title, "diff", strings.Join(config.Diff(old, new), "\n"))
+}
+
+// watch перезагружает конфигурацию по SIGHUP и при изменении файла path
+// (опрос раз в interval; 0 - только по сигналу).
+func (g *gateway) watch(ctx context.Context, path string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if path != "" && interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	sum := fileChecksum(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = g.reload(ctx, "SIGHUP")
+		case <-tick:
+			// сравниваем содержимое, а не mtime: редакторы часто пишут файл через rename
+			if s := fileChecksum(path); !bytes.Equal(s, sum) {
+				sum = s
+				_ = g.reload(ctx, "file changed")
+			}
+		}
+	}
+}
+
+func fileChecksum(path string) []byte {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// ConfigVersion - GET /admin/config: какая версия конфигурации сейчас активна.
+func (g *gateway) ConfigVersion(w http.ResponseWriter, r *http.Request) {
+	st := g.state.Load()
+	lastError, _ := g.lastError.Load().(string)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"version":   st.version,
+		"loadedAt":  st.loadedAt,
+		"profile":   g.profile,
+		"lastError": lastError,
+	})
+}
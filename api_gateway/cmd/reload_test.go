@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGatewayConfig(t *testing.T, path, upstream string, routes ...string) {
+	t.Helper()
+	content := fmt.Sprintf("upstreams:\n  users:\n    instances: [%s]\n  orders:\n    instances: [%s]\nroutes:\n", upstream, upstream)
+	for _, r := range routes {
+		content += "  - " + r + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestGateway_Reload(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/status" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("GATEWAY_CONFIG_FILE", path)
+
+	statusRoute := `{method: GET, path: /orders/status, upstream: orders, auth: public}`
+	healthRoute := `{method: GET, path: /orders/health, upstream: orders, auth: public}`
+	writeGatewayConfig(t, path, upstream.URL, statusRoute)
+
+	cfg, profile, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	g, err := newGateway(cfg, profile, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if code := get(t, g, "/orders/health"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 before reload, got %d", code)
+	}
+
+	// запрос, начатый на старой версии, должен на ней и закончиться
+	inFlight := make(chan int)
+	go func() { inFlight <- get(t, g, "/orders/status") }()
+	<-started
+
+	writeGatewayConfig(t, path, upstream.URL, healthRoute)
+	if err := g.reload(context.Background(), "test"); err != nil {
+		t.Fatalf("expected reload to succeed, got: %v", err)
+	}
+	if code := get(t, g, "/orders/health"); code != http.StatusOK {
+		t.Fatalf("expected new route after reload, got %d", code)
+	}
+	close(release)
+	if code := <-inFlight; code != http.StatusOK {
+		t.Fatalf("expected in-flight request to finish on the old config, got %d", code)
+	}
+	if code := get(t, g, "/orders/status"); code != http.StatusNotFound {
+		t.Fatalf("expected removed route to be gone, got %d", code)
+	}
+
+	// невалидная конфигурация отклоняется, старая продолжает работать
+	writeGatewayConfig(t, path, upstream.URL, `{method: GET, path: /x, upstream: billing, auth: public}`)
+	if err := g.reload(context.Background(), "test"); err == nil {
+		t.Fatalf("expected invalid config to be rejected")
+	}
+	if code := get(t, g, "/orders/health"); code != http.StatusOK {
+		t.Fatalf("expected previous config to stay active, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	g.ConfigVersion(w, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	var info struct {
+		Version   int    `json:"version"`
+		LastError string `json:"lastError"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if info.Version != 2 || info.LastError == "" {
+		t.Fatalf("expected version 2 with last error, got %+v", info)
+	}
+}
+
+func TestGateway_ReloadKeepsUnchangedPools(t *testing.T) {
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) }))
+	defer a.Close()
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) }))
+	defer b.Close()
+
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("GATEWAY_CONFIG_FILE", path)
+
+	statusRoute := `{method: GET, path: /orders/status, upstream: orders, auth: public}`
+	writeGatewayConfig(t, path, a.URL, statusRoute)
+	cfg, profile, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	g, err := newGateway(cfg, profile, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	first := g.state.Load()
+
+	// маршруты поменялись, экземпляры - нет: пулы со статистикой остаются
+	writeGatewayConfig(t, path, a.URL, statusRoute, `{method: GET, path: /orders/health, upstream: orders, auth: public}`)
+	if err := g.reload(context.Background(), "test"); err != nil {
+		t.Fatalf("expected reload to succeed, got: %v", err)
+	}
+	if g.state.Load().upstreams["orders"] != first.upstreams["orders"] {
+		t.Fatalf("expected orders pool to be kept")
+	}
+
+	t.Setenv("GATEWAY_UPSTREAMS_ORDERS_INSTANCES", a.URL+","+b.URL)
+	if err := g.reload(context.Background(), "test"); err != nil {
+		t.Fatalf("expected reload to succeed, got: %v", err)
+	}
+	st := g.state.Load()
+	if st.upstreams["orders"] == first.upstreams["orders"] || st.upstreams["users"] != first.upstreams["users"] {
+		t.Fatalf("expected only orders pool to be replaced")
+	}
+
+	for i := 0; i < 4; i++ {
+		if code := get(t, g, "/orders/status"); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Upstreams map[string]struct {
+			Balancer  string `json:"balancer"`
+			Instances []struct {
+				URL      string `json:"url"`
+				Requests int64  `json:"requests"`
+			} `json:"instances"`
+		} `json:"upstreams"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	orders := health.Upstreams["orders"]
+	if orders.Balancer != "round-robin" || len(orders.Instances) != 2 ||
+		orders.Instances[0].Requests != 2 || orders.Instances[1].Requests != 2 {
+		t.Fatalf("expected requests spread over both instances, got %+v", orders)
+	}
+}
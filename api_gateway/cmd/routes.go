@@ -0,0 +1,181 @@
+package main
+
+import (
+	"api_gateway/internal/handler"
+	"common/policy"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Route - запись таблицы проксируемых маршрутов. Чтобы открыть наружу новый
+// эндпоинт сервиса, достаточно добавить запись в конфиг.
+type Route struct {
+	Method   string `yaml:"method"`
+	Path     string `yaml:"path"`     // шаблон chi: /orders/{orderId}
+	Upstream string `yaml:"upstream"` // users или orders
+	Rewrite  string `yaml:"rewrite"`  // путь в upstream с теми же {param}; пусто - как path
+	Auth     string `yaml:"auth"`     // public, authenticated, admin или owner:<param>
+
+	Timeout   time.Duration `yaml:"timeout"`   // 0 - upstreams.timeout
+	Breaker   string        `yaml:"breaker"`   // своя группа breaker'ов экземпляров; пусто - общая
+	RateLimit string        `yaml:"rateLimit"` // класс из rateLimitClasses; пусто - default
+	Body      string        `yaml:"body"`      // схема тела из handler.RequestBodies; пусто - без проверки
+}
+
+// RateLimitClass - отдельный лимит для группы маршрутов. Время жизни записей
+// о клиентах общее, из rateLimit.ttl.
+type RateLimitClass struct {
+	Name  string  `yaml:"name"`
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+const defaultRateLimitClass = "default"
+
+// upstreamServices - имена upstream'ов в таблице маршрутов и как их называют
+// ошибки ("Users service temporarily unavailable").
+var upstreamServices = map[string]string{
+	"users":  "Users",
+	"orders": "Orders",
+}
+
+func defaultRoutes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/users", Upstream: "users", Auth: "admin"},
+		{Method: http.MethodPost, Path: "/users", Upstream: "users", Auth: "admin"},
+		{Method: http.MethodPut, Path: "/users", Upstream: "users", Auth: "admin"},
+		{Method: http.MethodGet, Path: "/users/{userId}", Upstream: "users", Auth: "owner:userId"},
+		{Method: http.MethodDelete, Path: "/users/{userId}", Upstream: "users", Auth: "admin"},
+		// профиль текущего пользователя: users-service сам проверяет токен
+		{Method: http.MethodGet, Path: "/users/me", Upstream: "users", Auth: "authenticated"},
+		{Method: http.MethodPut, Path: "/users/me", Upstream: "users", Auth: "authenticated", Body: "update_profile"},
+
+		{Method: http.MethodPost, Path: "/auth/register", Upstream: "users", Auth: "public", Body: "register"},
+		{Method: http.MethodPost, Path: "/auth/login", Upstream: "users", Auth: "public", Body: "login"},
+		{Method: http.MethodPost, Path: "/auth/refresh", Upstream: "users", Auth: "public", Body: "refresh"},
+		{Method: http.MethodPost, Path: "/auth/logout", Upstream: "users", Auth: "authenticated"},
+		{Method: http.MethodPost, Path: "/auth/logout-all", Upstream: "users", Auth: "authenticated"},
+
+		// чужие заказы отсекает сам orders-service по X-User-ID; он же не даёт
+		// не-админу отгрузить, доставить или вернуть заказ через PUT /orders
+		{Method: http.MethodGet, Path: "/orders", Upstream: "orders", Auth: "authenticated"},
+		{Method: http.MethodGet, Path: "/orders/{orderId}", Upstream: "orders", Auth: "authenticated"},
+		{Method: http.MethodPost, Path: "/orders", Upstream: "orders", Auth: "authenticated", Body: "create_order"},
+		{Method: http.MethodPut, Path: "/orders", Upstream: "orders", Auth: "authenticated", Body: "update_order"},
+		{Method: http.MethodDelete, Path: "/orders/{orderId}", Upstream: "orders", Auth: "authenticated"},
+		{Method: http.MethodPost, Path: "/orders/{orderId}/pay", Upstream: "orders", Auth: "authenticated"},
+		{Method: http.MethodPost, Path: "/orders/{orderId}/ship", Upstream: "orders", Auth: "admin"},
+		{Method: http.MethodPost, Path: "/orders/{orderId}/deliver", Upstream: "orders", Auth: "admin"},
+		{Method: http.MethodPost, Path: "/orders/{orderId}/cancel", Upstream: "orders", Auth: "authenticated"},
+		{Method: http.MethodPost, Path: "/orders/{orderId}/refund", Upstream: "orders", Auth: "admin"},
+		{Method: http.MethodGet, Path: "/orders/audit/user-deletions", Upstream: "orders", Auth: "admin"},
+		{Method: http.MethodGet, Path: "/orders/status", Upstream: "orders", Auth: "public"},
+		{Method: http.MethodGet, Path: "/orders/health", Upstream: "orders", Auth: "public"},
+	}
+}
+
+func validateRoutes(routes []Route, classes []RateLimitClass) []error {
+	known := map[string]bool{defaultRateLimitClass: true}
+	var errs []error
+	for i, c := range classes {
+		if c.Name == "" || known[c.Name] {
+			errs = append(errs, fmt.Errorf("rateLimitClasses[%d]: name must be unique and not %q", i, defaultRateLimitClass))
+		}
+		if c.Rate <= 0 || c.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimitClasses[%d]: rate and burst must be positive", i))
+		}
+		known[c.Name] = true
+	}
+
+	seen := make(map[string]bool)
+	for i, rt := range routes {
+		key := rt.Method + " " + rt.Path
+		_, builtin := routePolicies[key]
+		switch {
+		case !isHTTPMethod(rt.Method):
+			errs = append(errs, fmt.Errorf("routes[%d]: unknown method %q", i, rt.Method))
+		case !strings.HasPrefix(rt.Path, "/"):
+			errs = append(errs, fmt.Errorf("routes[%d]: path must start with /", i))
+		case seen[key]:
+			errs = append(errs, fmt.Errorf("routes[%d]: duplicate route %s", i, key))
+		case builtin:
+			errs = append(errs, fmt.Errorf("routes[%d]: %s is served by the gateway itself", i, key))
+		}
+		seen[key] = true
+
+		if _, ok := upstreamServices[rt.Upstream]; !ok {
+			errs = append(errs, fmt.Errorf("routes[%d]: unknown upstream %q", i, rt.Upstream))
+		}
+		if rt.Rewrite != "" && !strings.HasPrefix(rt.Rewrite, "/") {
+			errs = append(errs, fmt.Errorf("routes[%d]: rewrite must start with /", i))
+		}
+		for _, param := range pathParams(rt.Rewrite) {
+			if !slices.Contains(pathParams(rt.Path), param) {
+				errs = append(errs, fmt.Errorf("routes[%d]: rewrite uses {%s} that is not in path", i, param))
+			}
+		}
+		if _, err := policy.Parse(rt.Auth); err != nil {
+			errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+		}
+		if rt.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("routes[%d]: timeout must be non-negative", i))
+		}
+		if rt.RateLimit != "" && !known[rt.RateLimit] {
+			errs = append(errs, fmt.Errorf("routes[%d]: unknown rate limit class %q", i, rt.RateLimit))
+		}
+		if _, ok := handler.RequestBodies[rt.Body]; rt.Body != "" && !ok {
+			errs = append(errs, fmt.Errorf("routes[%d]: unknown body schema %q", i, rt.Body))
+		}
+	}
+	return errs
+}
+
+func pathParams(pattern string) []string {
+	var params []string
+	for _, s := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			params = append(params, s[1:len(s)-1])
+		}
+	}
+	return params
+}
+
+func isHTTPMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// newRouteHandler - прокси маршрута, перед которым при Route.Body стоит
+// проверка тела.
+func newRouteHandler(rt Route, cfg *Config, c *components) http.Handler {
+	proxy := newRouteProxy(rt, cfg, c)
+	if rt.Body == "" {
+		return proxy
+	}
+	return handler.ValidateBody(handler.RequestBodies[rt.Body])(proxy)
+}
+
+// newRouteProxy собирает прокси для записи таблицы поверх пула её upstream'а.
+func newRouteProxy(rt Route, cfg *Config, c *components) *handler.Proxy {
+	rewrite := rt.Rewrite
+	if rewrite == "" {
+		rewrite = rt.Path
+	}
+	timeout := rt.Timeout
+	if timeout == 0 {
+		timeout = cfg.Upstreams.Timeout
+	}
+
+	u := handler.Upstream{
+		Service:   upstreamServices[rt.Upstream],
+		Transport: c.transport(rt.Upstream, rt.Breaker),
+	}
+	return handler.NewProxy(u, rewrite, timeout)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"common/config"
+	"common/deadline"
+	"common/idempotency"
+	"common/problem"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestValidate_RejectsBadRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		want  string
+	}{
+		{name: "unknown upstream", route: Route{Method: "GET", Path: "/x", Upstream: "billing", Auth: "public"}, want: "unknown upstream"},
+		{name: "unknown auth", route: Route{Method: "GET", Path: "/x", Upstream: "users", Auth: "root"}, want: "unknown auth policy"},
+		{name: "gateway route", route: Route{Method: "GET", Path: "/health", Upstream: "users", Auth: "public"}, want: "served by the gateway"},
+		{name: "duplicate", route: Route{Method: "GET", Path: "/users", Upstream: "users", Auth: "admin"}, want: "duplicate route"},
+		{name: "rewrite param", route: Route{Method: "GET", Path: "/x", Rewrite: "/users/{id}", Upstream: "users", Auth: "public"}, want: "{id}"},
+		{name: "rate limit class", route: Route{Method: "GET", Path: "/x", Upstream: "users", Auth: "public", RateLimit: "strict"}, want: "rate limit class"},
+		{name: "body schema", route: Route{Method: "POST", Path: "/x", Upstream: "users", Auth: "public", Body: "invoice"}, want: "body schema"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig(config.ProfileTest)
+			cfg.Routes = append(cfg.Routes, tc.route)
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error mentioning %q, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRouteProxy_RetriesThroughInstanceBreaker(t *testing.T) {
+	var calls atomic.Int64
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer orders.Close()
+
+	cfg := defaultConfig(config.ProfileTest)
+	cfg.Upstreams.Orders.Instances = []string{orders.URL}
+	cfg.Upstreams.HealthCheck.Interval = 0
+	cfg.Upstreams.Retry.BaseDelay = time.Millisecond
+	cfg.Upstreams.Retry.MaxDelay = 10 * time.Millisecond
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	rt := Route{Method: http.MethodGet, Path: "/orders/{orderId}", Upstream: "orders", Auth: "public"}
+	r := chi.NewRouter()
+	r.Handle(rt.Path, newRouteProxy(rt, cfg, c))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	if w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected 200 after one retry, got %d after %d calls", w.Code, calls.Load())
+	}
+
+	// каждая попытка - отдельный запрос для breaker'а экземпляра
+	if counts := c.upstreams["orders"].Stats()[0].Stats; counts.Requests != 2 {
+		t.Fatalf("expected both attempts to be counted by the breaker, got %+v", counts)
+	}
+
+	// POST без Idempotency-Key не повторяется
+	calls.Store(0)
+	rt = Route{Method: http.MethodPost, Path: "/orders", Upstream: "orders", Auth: "public"}
+	r.Handle(rt.Path, newRouteProxy(rt, cfg, c))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`)))
+	if w.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected 503 without retry, got %d after %d calls", w.Code, calls.Load())
+	}
+
+	// с ключом сервис сам отдаст сохранённый ответ, повтор безопасен
+	calls.Store(0)
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+	req.Header.Set(idempotency.Header, "order-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected 200 after one retry, got %d after %d calls", w.Code, calls.Load())
+	}
+}
+
+func TestRouteProxy_PropagatesDeadlineAndCancellation(t *testing.T) {
+	var gotTimeout atomic.Value
+	block := make(chan struct{})
+	defer close(block)
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTimeout.Store(r.Header.Get(deadline.Header))
+		if r.URL.Path == "/orders/slow" {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer orders.Close()
+
+	cfg := defaultConfig(config.ProfileTest)
+	cfg.Upstreams.Orders.Instances = []string{orders.URL}
+	cfg.Upstreams.HealthCheck.Interval = 0
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	r := chi.NewRouter()
+	for _, path := range []string{"/orders/fast", "/orders/slow"} {
+		rt := Route{Method: http.MethodGet, Path: path, Upstream: "orders", Auth: "public", Timeout: 2 * time.Second}
+		r.Handle(rt.Path, newRouteProxy(rt, cfg, c))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/fast", nil))
+	ms, _ := strconv.Atoi(gotTimeout.Load().(string))
+	if w.Code != http.StatusOK || ms <= 0 || ms > 2000 {
+		t.Fatalf("expected remaining time within the route timeout, got %d and %q", w.Code, gotTimeout.Load())
+	}
+
+	// клиент уходит, не дождавшись ответа
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/slow", nil).WithContext(ctx))
+
+	if w.Code != deadline.StatusClientClosedRequest {
+		t.Fatalf("expected 499, got %d", w.Code)
+	}
+	stats := c.upstreams["orders"].Stats()[0]
+	if stats.Stats.TotalFailures != 0 || stats.Failures != 0 {
+		t.Fatalf("expected cancellation not to count as a failure, got %+v", stats)
+	}
+}
+
+func TestRouteHandler_ValidatesBodyBeforeProxying(t *testing.T) {
+	var calls atomic.Int64
+	var gotBody string
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer orders.Close()
+
+	cfg := defaultConfig(config.ProfileTest)
+	cfg.Upstreams.Orders.Instances = []string{orders.URL}
+	cfg.Upstreams.HealthCheck.Interval = 0
+
+	c, err := newComponents(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer c.close(nil)
+
+	rt := Route{Method: http.MethodPost, Path: "/orders", Upstream: "orders", Auth: "public", Body: "create_order"}
+	r := chi.NewRouter()
+	r.Handle(rt.Path, newRouteHandler(rt, cfg, c))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"items":[{"title":"Tea","quantity":0}]}`)))
+	var p problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("expected problem JSON, got: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 2 || calls.Load() != 0 {
+		t.Fatalf("expected 400 with name and items[0].quantity errors and no upstream call, got %d %+v after %d calls", w.Code, p, calls.Load())
+	}
+
+	valid := `{"name":"Breakfast","items":[{"title":"Tea","quantity":2,"unitPrice":150}]}`
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(valid)))
+	if w.Code != http.StatusCreated || gotBody != valid {
+		t.Fatalf("expected valid body to reach upstream unchanged, got %d %q", w.Code, gotBody)
+	}
+}
@@ -3,36 +3,40 @@ package handler
 import (
 	"api_gateway/internal/model"
 	"bytes"
+	"common/problem"
+	"common/tracing"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/sony/gobreaker"
 )
 
 type AggregationHandler struct {
-	client        *http.Client
-	usersCB       *gobreaker.CircuitBreaker
-	ordersCB      *gobreaker.CircuitBreaker
-	usersBaseURL  string
-	ordersBaseURL string
+	usersClient  *http.Client
+	ordersClient *http.Client
+	timeout      time.Duration
 }
 
+// NewAggregationHandler - users и orders выбирают экземпляр сервиса сами
+// (upstream.Pool), запросы к ним идут с одним путём. Таймаут берётся из
+// client и ограничивает контекст всей агрегации: если клиент ушёл или время
+// вышло, запросы к сервисам обрываются.
 func NewAggregationHandler(
 	client *http.Client,
-	usersCB *gobreaker.CircuitBreaker,
-	ordersCB *gobreaker.CircuitBreaker,
-	usersBaseURL string,
-	ordersBaseURL string,
+	users http.RoundTripper,
+	orders http.RoundTripper,
 ) *AggregationHandler {
 	return &AggregationHandler{
-		client:        client,
-		usersCB:       usersCB,
-		ordersCB:      ordersCB,
-		usersBaseURL:  usersBaseURL,
-		ordersBaseURL: ordersBaseURL,
+		usersClient:  &http.Client{Transport: users},
+		ordersClient: &http.Client{Transport: orders},
+		timeout:      client.Timeout,
 	}
 }
 
@@ -41,152 +45,131 @@ type result struct {
 	err  error
 }
 
-func (h *AggregationHandler) doUsersRequest(method, path string, body []byte, r *http.Request) (*http.Response, error) {
-	url := h.usersBaseURL + path
-
-	res, err := h.usersCB.Execute(func() (interface{}, error) {
-		var bodyReader io.Reader
-		if body != nil {
-			bodyReader = bytes.NewReader(body)
-		}
-
-		req, err := http.NewRequest(method, url, bodyReader)
-		if err != nil {
-			return nil, err
-		}
+type ordersResult struct {
+	orders []model.Order
+	resp   *http.Response // не nil, если orders-service ответил ошибкой
+	err    error
+}
 
-		req.Header.Set("Content-Type", "application/json")
-		if rid := r.Header.Get("X-Request-ID"); rid != "" {
-			req.Header.Set("X-Request-ID", rid)
-		}
+func (h *AggregationHandler) doUsersRequest(method, path string, body []byte, r *http.Request) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
 
-		return h.client.Do(req)
-	})
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
-	return res.(*http.Response), nil
+	req.Header.Set("Content-Type", "application/json")
+	if rid := r.Header.Get("X-Request-ID"); rid != "" {
+		req.Header.Set("X-Request-ID", rid)
+	}
+
+	return h.usersClient.Do(req)
 }
 
 func (h *AggregationHandler) doOrdersRequest(method, path string, body []byte, r *http.Request) (*http.Response, error) {
-	url := h.ordersBaseURL + path
-
-	res, err := h.ordersCB.Execute(func() (interface{}, error) {
-		var bodyReader io.Reader
-		if body != nil {
-			bodyReader = bytes.NewReader(body)
-		}
-
-		req, err := http.NewRequest(method, url, bodyReader)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		if rid := r.Header.Get("X-Request-ID"); rid != "" {
-			req.Header.Set("X-Request-ID", rid)
-		}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
 
-		return h.client.Do(req)
-	})
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
-	return res.(*http.Response), nil
+	req.Header.Set("Content-Type", "application/json")
+	if rid := r.Header.Get("X-Request-ID"); rid != "" {
+		req.Header.Set("X-Request-ID", rid)
+	}
+	setIdentityHeaders(req, r)
+
+	return h.ordersClient.Do(req)
 }
 
 func (h *AggregationHandler) UserDetails(w http.ResponseWriter, r *http.Request) {
 	userIDStr := chi.URLParam(r, "userId")
-	if userIDStr == "" {
-		http.Error(w, `{"error": "userId is required"}`, http.StatusBadRequest)
-		return
-	}
-
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, `{"error": "invalid userId"}`, http.StatusBadRequest)
+		problem.Write(w, r, problem.Invalid(problem.FieldError{Field: "userId", Code: "invalid", Message: "userId must be an integer"}))
 		return
 	}
 
+	if h.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
 	userPath := "/users/" + userIDStr
-	ordersPath := "/orders"
 
 	userCh := make(chan result, 1)
-	ordersCh := make(chan result, 1)
+	ordersCh := make(chan ordersResult, 1)
 
+	// у каждой ветки свой спан, запросы к сервисам - дочерние к нему
 	go func() {
-		resp, err := h.doUsersRequest(http.MethodGet, userPath, nil, r)
+		ctx, span := tracing.Start(r.Context(), "fetch user")
+		resp, err := h.doUsersRequest(http.MethodGet, userPath, nil, r.WithContext(ctx))
+		tracing.End(span, err)
 		userCh <- result{resp: resp, err: err}
 	}()
 
 	go func() {
-		resp, err := h.doOrdersRequest(http.MethodGet, ordersPath, nil, r)
-		ordersCh <- result{resp: resp, err: err}
+		ctx, span := tracing.Start(r.Context(), "fetch user orders")
+		orders, resp, err := h.fetchUserOrders(userIDStr, r.WithContext(ctx))
+		tracing.End(span, err)
+		ordersCh <- ordersResult{orders: orders, resp: resp, err: err}
 	}()
 
 	userRes := <-userCh
 	ordersRes := <-ordersCh
 
 	if userRes.err != nil {
-		handleCBError(w, userRes.err, "Users")
+		handleCBError(w, r, userRes.err, "Users")
 		return
 	}
 	if ordersRes.err != nil {
-		handleCBError(w, ordersRes.err, "Orders")
+		handleCBError(w, r, ordersRes.err, "Orders")
 		return
 	}
 
 	defer userRes.resp.Body.Close()
-	defer ordersRes.resp.Body.Close()
+	if ordersRes.resp != nil {
+		defer ordersRes.resp.Body.Close()
+	}
 
+	// 404 от users-service - уже problem с кодом user_not_found, отдаём как есть
 	if userRes.resp.StatusCode == http.StatusNotFound {
 		body, _ := io.ReadAll(userRes.resp.Body)
-		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", userRes.resp.Header.Get("Content-Type"))
 		w.WriteHeader(http.StatusNotFound)
 		_, _ = w.Write(body)
 		return
 	}
 
 	if userRes.resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(userRes.resp.Body)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		if len(body) > 0 {
-			_, _ = w.Write(body)
-		} else {
-			_, _ = w.Write([]byte(`{"error":"failed to fetch user"}`))
-		}
+		slog.WarnContext(r.Context(), "users service returned an error", "status", userRes.resp.StatusCode)
+		problem.Write(w, r, errBadGateway.WithDetail("failed to fetch user"))
 		return
 	}
 
-	if ordersRes.resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(ordersRes.resp.Body)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		if len(body) > 0 {
-			_, _ = w.Write(body)
-		} else {
-			_, _ = w.Write([]byte(`{"error":"failed to fetch orders"}`))
-		}
+	if ordersRes.resp != nil {
+		slog.WarnContext(r.Context(), "orders service returned an error", "status", ordersRes.resp.StatusCode)
+		problem.Write(w, r, errBadGateway.WithDetail("failed to fetch orders"))
 		return
 	}
 
 	var user model.User
 	if err := json.NewDecoder(userRes.resp.Body).Decode(&user); err != nil {
-		http.Error(w, `{"error": "failed to parse user"}`, http.StatusInternalServerError)
-		return
-	}
-
-	var orders []model.Order
-	if err := json.NewDecoder(ordersRes.resp.Body).Decode(&orders); err != nil {
-		http.Error(w, `{"error": "failed to parse orders"}`, http.StatusInternalServerError)
+		problem.Write(w, r, errBadGateway.WithDetail("failed to parse user"))
 		return
 	}
 
 	filtered := make([]model.Order, 0)
-	for _, o := range orders {
+	for _, o := range ordersRes.orders {
 		if o.UserId == userID {
 			filtered = append(filtered, o)
 		}
@@ -198,4 +181,34 @@ func (h *AggregationHandler) UserDetails(w http.ResponseWriter, r *http.Request)
 	}
 
 	writeJSON(w, http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// fetchUserOrders проходит по всем страницам списка заказов пользователя.
+// Если orders-service ответил ошибкой, возвращается его ответ как есть.
+func (h *AggregationHandler) fetchUserOrders(userID string, r *http.Request) ([]model.Order, *http.Response, error) {
+	orders := make([]model.Order, 0)
+	query := url.Values{"userId": {userID}, "limit": {"100"}}
+
+	for {
+		resp, err := h.doOrdersRequest(http.MethodGet, "/orders?"+query.Encode(), nil, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.StatusCode >= 400 {
+			return nil, resp, nil
+		}
+
+		var page model.OrderPage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse orders: %w", err)
+		}
+
+		orders = append(orders, page.Items...)
+		if page.NextCursor == nil {
+			return orders, nil, nil
+		}
+		query.Set("cursor", *page.NextCursor)
+	}
+}
@@ -0,0 +1,98 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"api_gateway/internal/model"
+	"common/problem"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sony/gobreaker"
+)
+
+func TestUserDetails_CollectsAllOrderPages(t *testing.T) {
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 2, "name": "John"}`))
+	}))
+	defer users.Close()
+
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("userId") != "2" {
+			t.Errorf("expected userId filter, got %q", r.URL.RawQuery)
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"items": [{"id": 1, "userId": 2}, {"id": 2, "userId": 2}], "nextCursor": "page2", "total": 3}`))
+		case "page2":
+			w.Write([]byte(`{"items": [{"id": 5, "userId": 2}], "nextCursor": null, "total": 3}`))
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+	defer orders.Close()
+
+	agg := handler.NewAggregationHandler(http.DefaultClient,
+		newPool(t, users.URL, gobreaker.Settings{}), newPool(t, orders.URL, gobreaker.Settings{}))
+
+	r := chi.NewRouter()
+	r.Get("/users/{userId}/details", agg.UserDetails)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/2/details", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp model.UserDetailsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.User.ID != 2 || len(resp.Orders) != 3 || resp.Orders[2].ID != 5 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestUserDetails_ErrorsAreProblems(t *testing.T) {
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/404" {
+			problem.Write(w, r, problem.New(http.StatusNotFound, "user_not_found", "user not found"))
+			return
+		}
+		w.Write([]byte(`{"id": 2, "name": "John"}`))
+	}))
+	defer users.Close()
+
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer orders.Close()
+
+	agg := handler.NewAggregationHandler(http.DefaultClient,
+		newPool(t, users.URL, gobreaker.Settings{}), newPool(t, orders.URL, gobreaker.Settings{}))
+
+	r := chi.NewRouter()
+	r.Get("/users/{userId}/details", agg.UserDetails)
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{path: "/users/abc/details", status: http.StatusBadRequest, code: "validation_failed"},
+		{path: "/users/404/details", status: http.StatusNotFound, code: "user_not_found"},
+		{path: "/users/2/details", status: http.StatusBadGateway, code: "bad_gateway"},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		p := decodeProblem(t, w)
+		if w.Code != tc.status || p.Code != tc.code {
+			t.Fatalf("%s: expected %d %s, got %d %+v", tc.path, tc.status, tc.code, w.Code, p)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"common/logging"
+	"common/problem"
 	"context"
 	"fmt"
 	"net/http"
@@ -13,40 +15,61 @@ type contextKey string
 
 const (
 	ContextKeyUserID contextKey = "userID"
+	ContextKeyRoles  contextKey = "roles"
 )
 
-func JWTAuthMiddleware(secret []byte) func(http.Handler) http.Handler {
+// JWTAuthMiddleware проверяет подпись access-токена публичными ключами из JWKS
+// users-service. Никаких секретов gateway не знает. Токены из revoked (nil -
+// без проверки) отклоняются, даже если они ещё не истекли.
+func JWTAuthMiddleware(keys *JWKSCache, revoked *RevocationList) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"error": "missing Authorization header"}`, http.StatusUnauthorized)
+				problem.Write(w, r, problem.Unauthorized.WithDetail("missing Authorization header"))
 				return
 			}
 
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				http.Error(w, `{"error": "invalid Authorization header format"}`, http.StatusUnauthorized)
+				problem.Write(w, r, problem.Unauthorized.WithDetail("invalid Authorization header format"))
 				return
 			}
 
 			tokenStr := parts[1]
 
 			token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				if _, ok := t.Method.(*jwt.SigningMethodEd25519); !ok {
 					return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 				}
-				return secret, nil
-			})
+				kid, _ := t.Header["kid"].(string)
+				return keys.Key(r.Context(), kid)
+			}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
 			if err != nil || !token.Valid {
-				http.Error(w, `{"error": "invalid or expired token"}`, http.StatusUnauthorized)
+				problem.Write(w, r, problem.Unauthorized.WithDetail("invalid or expired token"))
 				return
 			}
 
-			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			claims, _ := token.Claims.(jwt.MapClaims)
+			if jti, _ := claims["jti"].(string); revoked != nil && jti != "" && revoked.Revoked(jti) {
+				problem.Write(w, r, problem.Unauthorized.WithDetail("token has been revoked"))
+				return
+			}
+
+			if claims != nil {
 				if uid, ok := claims["user_id"].(float64); ok {
 					ctx := context.WithValue(r.Context(), ContextKeyUserID, int(uid))
 					r = r.WithContext(ctx)
+					logging.SetUser(ctx, int(uid))
+				}
+				if raw, ok := claims["roles"].([]interface{}); ok {
+					roles := make([]string, 0, len(raw))
+					for _, role := range raw {
+						if s, ok := role.(string); ok {
+							roles = append(roles, s)
+						}
+					}
+					r = r.WithContext(context.WithValue(r.Context(), ContextKeyRoles, roles))
 				}
 			}
 
@@ -0,0 +1,248 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testKey struct {
+	kid  string
+	priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return testKey{kid: kid, priv: priv}
+}
+
+func (k testKey) sign(t *testing.T, userID int, roles ...string) string {
+	t.Helper()
+	return k.signJTI(t, "", userID, roles...)
+}
+
+func (k testKey) signJTI(t *testing.T, jti string, userID int, roles ...string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"roles":   roles,
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}
+	if jti != "" {
+		claims["jti"] = jti
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.priv)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func (k testKey) jwk() map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"alg": "EdDSA",
+		"kid": k.kid,
+		"x":   base64.RawURLEncoding.EncodeToString(k.priv.Public().(ed25519.PublicKey)),
+	}
+}
+
+// newJWKSServer отдаёт JWKS из keys; fetches считает обращения.
+func newJWKSServer(t *testing.T, keys *[]testKey, fetches *int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*fetches++
+		var body struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for _, k := range *keys {
+			body.Keys = append(body.Keys, k.jwk())
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runAuth(mw func(http.Handler) http.Handler, token string) (int, int) {
+	var userID int
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value(handler.ContextKeyUserID).(int)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code, userID
+}
+
+func TestJWTAuthMiddleware_VerifiesWithJWKS(t *testing.T) {
+	key := newTestKey(t, "k1")
+	keys := []testKey{key}
+	fetches := 0
+	srv := newJWKSServer(t, &keys, &fetches)
+
+	mw := handler.JWTAuthMiddleware(handler.NewJWKSCache(srv.Client(), srv.URL, time.Minute), nil)
+
+	code, userID := runAuth(mw, key.sign(t, 7))
+	if code != http.StatusOK || userID != 7 {
+		t.Fatalf("expected 200 with user 7, got %d / %d", code, userID)
+	}
+
+	// второй запрос обслуживается из кэша
+	if code, _ := runAuth(mw, key.sign(t, 7)); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if fetches != 1 {
+		t.Errorf("expected JWKS to be fetched once, got %d", fetches)
+	}
+}
+
+func TestJWTAuthMiddleware_RejectsBadTokens(t *testing.T) {
+	key := newTestKey(t, "k1")
+	keys := []testKey{key}
+	fetches := 0
+	srv := newJWKSServer(t, &keys, &fetches)
+
+	mw := handler.JWTAuthMiddleware(handler.NewJWKSCache(srv.Client(), srv.URL, time.Minute), nil)
+
+	forged := newTestKey(t, "k1").sign(t, 1)
+	unknownKid := newTestKey(t, "other").sign(t, 1)
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1}).SignedString([]byte("super-secret-key"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing header", token: ""},
+		{name: "signed by foreign key with known kid", token: forged},
+		{name: "unknown kid", token: unknownKid},
+		{name: "shared secret token", token: hmac},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _ := runAuth(mw, tc.token); code != http.StatusUnauthorized {
+				t.Fatalf("expected 401, got %d", code)
+			}
+		})
+	}
+}
+
+func TestJWTAuthMiddleware_RejectsRevokedTokens(t *testing.T) {
+	key := newTestKey(t, "k1")
+	keys := []testKey{key}
+	fetches := 0
+	jwks := newJWKSServer(t, &keys, &fetches)
+
+	revokedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"tokens": []map[string]any{
+			{"jti": "logged-out", "expiresAt": time.Now().Add(time.Minute)},
+			{"jti": "expired-anyway", "expiresAt": time.Now().Add(-time.Minute)},
+		}})
+	}))
+	t.Cleanup(revokedSrv.Close)
+
+	revoked := handler.NewRevocationList(revokedSrv.Client(), revokedSrv.URL, time.Hour)
+	revoked.Start()
+	defer revoked.Close()
+	for deadline := time.Now().Add(time.Second); !revoked.Revoked("logged-out"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected revocation list to be fetched")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if revoked.Revoked("expired-anyway") {
+		t.Fatalf("expected expired entry to be ignored")
+	}
+
+	mw := handler.JWTAuthMiddleware(handler.NewJWKSCache(jwks.Client(), jwks.URL, time.Minute), revoked)
+	if code, _ := runAuth(mw, key.signJTI(t, "logged-out", 7)); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for revoked token, got %d", code)
+	}
+	if code, userID := runAuth(mw, key.signJTI(t, "still-valid", 7)); code != http.StatusOK || userID != 7 {
+		t.Fatalf("expected 200 with user 7, got %d / %d", code, userID)
+	}
+}
+
+func TestJWKSCache_SharesRefreshAndSurvivesCanceledCaller(t *testing.T) {
+	key := newTestKey(t, "k1")
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{key.jwk()}})
+	}))
+	t.Cleanup(srv.Close)
+	cache := handler.NewJWKSCache(srv.Client(), srv.URL, time.Minute)
+
+	// клиент, из-за которого пошли за ключами, отключается посреди запроса
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := cache.Key(ctx, "k1")
+		canceled <- err
+	}()
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled caller to give up, got: %v", err)
+	}
+
+	// остальные ждут тот же запрос за ключами, и он не прерван
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Key(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected key after shared refresh, got: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected a single JWKS fetch, got %d", n)
+	}
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"common/policy"
+	"net/http"
+)
+
+// Auth - аутентификация gateway для policy.Router: JWT проверяется здесь же,
+// по ключам users-service.
+func Auth(keys *JWKSCache, revoked *RevocationList) policy.Auth {
+	return policy.Auth{Middleware: JWTAuthMiddleware(keys, revoked), Identity: identity}
+}
+
+// identity - личность, которую положил JWTAuthMiddleware.
+func identity(r *http.Request) (int, []string, bool) {
+	userID, ok := r.Context().Value(ContextKeyUserID).(int)
+	roles, _ := r.Context().Value(ContextKeyRoles).([]string)
+	return userID, roles, ok
+}
@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"common/policy"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPolicies_UnauthorizedVsForbidden(t *testing.T) {
+	key := newTestKey(t, "k1")
+	keys := []testKey{key}
+	fetches := 0
+	srv := newJWKSServer(t, &keys, &fetches)
+	auth := handler.Auth(handler.NewJWKSCache(srv.Client(), srv.URL, time.Minute), nil)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r := chi.NewRouter()
+	r.With(policy.AdminOnly.Middlewares(auth)...).Delete("/users/{userId}", ok)
+	r.With(policy.OwnerOrAdmin("userId").Middlewares(auth)...).Get("/users/{userId}/details", ok)
+	r.With(policy.OwnerOrAdmin("userId").Middlewares(auth)...).Get("/orders", ok)
+	r.With(policy.PublicAccess.Middlewares(auth)...).Get("/health", ok)
+
+	user := key.sign(t, 2, "user")
+	admin := key.sign(t, 3, "admin")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "public without token", method: http.MethodGet, path: "/health", want: http.StatusOK},
+		{name: "admin route anonymous", method: http.MethodDelete, path: "/users/1", want: http.StatusUnauthorized},
+		{name: "admin route as user", method: http.MethodDelete, path: "/users/1", token: user, want: http.StatusForbidden},
+		{name: "admin route as admin", method: http.MethodDelete, path: "/users/1", token: admin, want: http.StatusOK},
+		{name: "own details", method: http.MethodGet, path: "/users/2/details", token: user, want: http.StatusOK},
+		{name: "foreign details", method: http.MethodGet, path: "/users/1/details", token: user, want: http.StatusForbidden},
+		{name: "foreign details as admin", method: http.MethodGet, path: "/users/1/details", token: admin, want: http.StatusOK},
+		{name: "own orders by query", method: http.MethodGet, path: "/orders?userId=2", token: user, want: http.StatusOK},
+		{name: "foreign orders by query", method: http.MethodGet, path: "/orders?userId=1", token: user, want: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, w.Code)
+			}
+		})
+	}
+}
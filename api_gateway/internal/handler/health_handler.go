@@ -1,39 +1,78 @@
 package handler
 
 import (
+	"api_gateway/internal/upstream"
 	"net/http"
-
-	"github.com/sony/gobreaker"
 )
 
+// UpstreamInfo - пул сервиса и то, что о нём нужно знать /health.
+type UpstreamInfo struct {
+	Pool     *upstream.Pool
+	Balancer string // только для отчёта
+	Required bool   // без него gateway не готов принимать трафик
+}
+
 type HealthHandler struct {
-	usersCB  *gobreaker.CircuitBreaker
-	ordersCB *gobreaker.CircuitBreaker
+	upstreams map[string]UpstreamInfo // users, orders
 }
 
-func NewHealthHandler(
-	usersCB *gobreaker.CircuitBreaker,
-	ordersCB *gobreaker.CircuitBreaker,
-) *HealthHandler {
+func NewHealthHandler(upstreams map[string]UpstreamInfo) *HealthHandler {
 	return &HealthHandler{
-		usersCB:  usersCB,
-		ordersCB: ordersCB,
+		upstreams: upstreams,
 	}
 }
 
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	// circuits - сводка по пулу в прежнем формате, upstreams - по экземплярам
+	circuits := make(map[string]any, len(h.upstreams))
+	upstreams := make(map[string]any, len(h.upstreams))
+	for name, u := range h.upstreams {
+		state, counts := u.Pool.State()
+		circuits[name] = map[string]any{
+			"state": state,
+			"stats": counts,
+		}
+		upstreams[name] = map[string]any{
+			"balancer":  u.Balancer,
+			"required":  u.Required,
+			"ready":     u.Pool.Ready(),
+			"instances": u.Pool.Stats(),
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{
-		"status": "API Gateway is running",
-		"circuits": map[string]any{
-			"users": map[string]any{
-				"state": h.usersCB.State().String(),
-				"stats": h.usersCB.Counts(),
-			},
-			"orders": map[string]any{
-				"state": h.ordersCB.State().String(),
-				"stats": h.ordersCB.Counts(),
-			},
-		},
+		"status":    "API Gateway is running",
+		"circuits":  circuits,
+		"upstreams": upstreams,
+	})
+}
+
+// Live - процесс жив и отвечает; upstream'ы не проверяются.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "alive",
+	})
+}
+
+// Ready - 200, если у каждого обязательного upstream'а есть здоровый
+// экземпляр, иначе 503.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	status, code := "ready", http.StatusOK
+	upstreams := make(map[string]string, len(h.upstreams))
+	for name, u := range h.upstreams {
+		upstreams[name] = "ready"
+		if u.Pool.Ready() {
+			continue
+		}
+		upstreams[name] = "not ready"
+		if u.Required {
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+	}
+
+	writeJSON(w, code, map[string]any{
+		"status":    status,
+		"upstreams": upstreams,
 	})
 }
 
@@ -41,4 +80,4 @@ func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "API Gateway is running",
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"api_gateway/internal/upstream"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth_ReadyReflectsRequiredUpstreams(t *testing.T) {
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer users.Close()
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer orders.Close()
+
+	newCheckedPool := func(name, instanceURL string) *upstream.Pool {
+		pool, err := upstream.NewPool(name, upstream.Options{
+			Discovery:   upstream.Static{instanceURL},
+			HealthCheck: upstream.HealthCheck{Path: "/" + name + "/health", Interval: 10 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("failed to create pool: %v", err)
+		}
+		pool.Start(0)
+		t.Cleanup(pool.Close)
+		return pool
+	}
+	usersPool, ordersPool := newCheckedPool("users", users.URL), newCheckedPool("orders", orders.URL)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ordersPool.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected orders pool to become not ready")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ready := func(ordersRequired bool) (int, map[string]any) {
+		h := handler.NewHealthHandler(map[string]handler.UpstreamInfo{
+			"users":  {Pool: usersPool, Required: true},
+			"orders": {Pool: ordersPool, Required: ordersRequired},
+		})
+		w := httptest.NewRecorder()
+		h.Ready(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+		var body map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		return w.Code, body
+	}
+
+	if code, body := ready(true); code != http.StatusServiceUnavailable || body["status"] != "not ready" {
+		t.Fatalf("expected 503 not ready, got %d %v", code, body)
+	}
+	code, body := ready(false)
+	if code != http.StatusOK || body["upstreams"].(map[string]any)["orders"] != "not ready" {
+		t.Fatalf("expected 200 with orders reported as not ready, got %d %v", code, body)
+	}
+}
@@ -1,42 +1,49 @@
 package handler
 
 import (
+	"api_gateway/internal/upstream"
+	"common/deadline"
+	"common/problem"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 
 	"github.com/sony/gobreaker"
 )
 
+// Ошибки самого gateway; detail дополняется именем сервиса.
+var (
+	errUpstreamTimeout     = problem.New(http.StatusGatewayTimeout, "upstream_timeout", "service timed out")
+	errUpstreamUnavailable = problem.New(http.StatusServiceUnavailable, "upstream_unavailable", "service temporarily unavailable")
+	errBadGateway          = problem.New(http.StatusBadGateway, "bad_gateway", "service returned an invalid response")
+)
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func forwardResponse(w http.ResponseWriter, resp *http.Response) {
-	defer resp.Body.Close()
-
-	for k, vals := range resp.Header {
-		for _, v := range vals {
-			w.Header().Add(k, v)
+// общий helper для ошибок circuit breaker’а
+func handleCBError(w http.ResponseWriter, r *http.Request, err error, serviceName string) {
+	// клиент ушёл сам - это не сбой сервиса; 499 увидят только логи
+	if status, ok := deadline.Status(err); ok {
+		if status == http.StatusGatewayTimeout {
+			problem.Write(w, r, errUpstreamTimeout.WithDetail(fmt.Sprintf("%s service timed out", serviceName)))
+			return
 		}
+		problem.Write(w, r, problem.ClientClosed)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-
-	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
-}
 
-// общий helper для ошибок circuit breaker’а
-func handleCBError(w http.ResponseWriter, err error, serviceName string) {
-	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
-		msg := fmt.Sprintf(`{"error": "%s service temporarily unavailable"}`, serviceName)
-		http.Error(w, msg, http.StatusServiceUnavailable)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) ||
+		errors.Is(err, upstream.ErrNoAvailableInstance) {
+		problem.Write(w, r, errUpstreamUnavailable.WithDetail(fmt.Sprintf("%s service temporarily unavailable", serviceName)))
 		return
 	}
 
-	http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-}
\ No newline at end of file
+	slog.ErrorContext(r.Context(), "upstream request failed", "service", serviceName, "error", err)
+	problem.Write(w, r, problem.Internal)
+}
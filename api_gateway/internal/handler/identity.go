@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Через эти заголовки orders-service узнаёт, кто делает запрос. Доверять им можно
+// только потому, что их выставляет gateway, а клиентские копии вырезаются.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserRoles = "X-User-Roles"
+)
+
+// StripIdentityHeaders не даёт клиенту представиться кем-то другим.
+func StripIdentityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(HeaderUserID)
+		r.Header.Del(HeaderUserRoles)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setIdentityHeaders переносит личность из контекста (её положил JWTAuthMiddleware)
+// в запрос к downstream-сервису.
+func setIdentityHeaders(req *http.Request, r *http.Request) {
+	userID, ok := r.Context().Value(ContextKeyUserID).(int)
+	if !ok {
+		return
+	}
+	req.Header.Set(HeaderUserID, strconv.Itoa(userID))
+
+	if roles, ok := r.Context().Value(ContextKeyRoles).([]string); ok && len(roles) > 0 {
+		req.Header.Set(HeaderUserRoles, strings.Join(roles, ","))
+	}
+}
@@ -0,0 +1,49 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sony/gobreaker"
+)
+
+func TestOrdersProxy_ForwardsVerifiedIdentityOnly(t *testing.T) {
+	var gotUserID, gotRoles string
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserID = r.Header.Get(handler.HeaderUserID)
+		gotRoles = r.Header.Get(handler.HeaderUserRoles)
+		w.Write([]byte(`[]`))
+	}))
+	defer orders.Close()
+
+	key := newTestKey(t, "k1")
+	keys := []testKey{key}
+	fetches := 0
+	jwks := newJWKSServer(t, &keys, &fetches)
+	authn := handler.JWTAuthMiddleware(handler.NewJWKSCache(jwks.Client(), jwks.URL, time.Minute), nil)
+
+	h := handler.NewProxy(handler.Upstream{Service: "Orders", Transport: newPool(t, orders.URL, gobreaker.Settings{})}, "/orders", time.Second)
+	r := chi.NewRouter()
+	r.Use(handler.StripIdentityHeaders)
+	r.With(authn).Get("/orders", h.ServeHTTP)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+key.sign(t, 2, "user"))
+	// клиент пытается выдать себя за админа
+	req.Header.Set(handler.HeaderUserID, "3")
+	req.Header.Set(handler.HeaderUserRoles, "admin")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if gotUserID != "2" || gotRoles != "user" {
+		t.Fatalf("expected identity from token (2, user), got (%q, %q)", gotUserID, gotRoles)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+}
+
+// JWKSCache держит публичные ключи users-service. Ключи перечитываются раз в ttl,
+// а также сразу, если пришёл токен с незнакомым kid (ключ только что ротировали),
+// но не чаще minRefresh, чтобы мусорные kid не превращались в DoS на users-service.
+//
+// За ключами одновременно ходит один запрос (singleflight), остальные ждут его
+// результата, а запросы с известным kid его не ждут вовсе.
+type JWKSCache struct {
+	client       *http.Client
+	url          string
+	ttl          time.Duration
+	minRefresh   time.Duration
+	fetchTimeout time.Duration
+
+	group singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]ed25519.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func NewJWKSCache(client *http.Client, url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		client:       client,
+		url:          url,
+		ttl:          ttl,
+		minRefresh:   10 * time.Second,
+		fetchTimeout: 5 * time.Second,
+		keys:         make(map[string]ed25519.PublicKey),
+	}
+}
+
+func (c *JWKSCache) Key(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > c.ttl
+	c.mu.RUnlock()
+
+	if ok {
+		// устаревший, но известный ключ ещё годится: обновляем в фоне
+		if stale {
+			go c.group.Do("jwks", c.refresh)
+		}
+		return key, nil
+	}
+
+	// ждём общее обновление не дольше, чем готов ждать сам запрос
+	select {
+	case <-c.group.DoChan("jwks", c.refresh):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh перечитывает ключи; вызывается только через group.
+func (c *JWKSCache) refresh() (any, error) {
+	c.mu.Lock()
+	if time.Since(c.lastAttempt) < c.minRefresh {
+		c.mu.Unlock()
+		return nil, nil
+	}
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	// не ctx запроса: если клиент, из-за которого пошли за ключами, отключится,
+	// ключи всё равно нужны остальным
+	ctx, cancel := context.WithTimeout(context.Background(), c.fetchTimeout)
+	defer cancel()
+
+	keys, err := c.fetch(ctx)
+	if err != nil {
+		// остаёмся на старых ключах: лучше пускать по ним, чем ронять весь трафик
+		slog.Warn("failed to refresh JWKS", "url", c.url, "error", err)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil, nil
+}
+
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		// другие типы ключей мы не выпускаем, поэтому просто пропускаем
+		if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Kid == "" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
+	}
+
+	return keys, nil
+}
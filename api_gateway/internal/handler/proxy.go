@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"common/problem"
+	"context"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Upstream - сервис, в который проксируются маршруты из таблицы.
+type Upstream struct {
+	Service string // имя в ошибках: "Users service temporarily unavailable"
+	// Transport выбирает экземпляр сервиса и пропускает запрос через его
+	// breaker (upstream.Pool); запрос приходит с одним путём, без хоста.
+	Transport http.RoundTripper
+}
+
+// Proxy - reverse proxy для одного маршрута таблицы. Ответ отдаётся потоком.
+// Тело запроса держится в памяти, только если его нужно проверить
+// (ValidateBody, до validate.MaxBodyBytes) или повторить (retry.Transport,
+// до retry.MaxBufferedBody); остальное уходит потоком. Заголовки, статус и
+// ошибки такие же, как были у рукописных обработчиков: Content-Type ответа application/json (ошибки
+// сервисов остаются application/problem+json), сетевая ошибка - 500,
+// открытый breaker или нет живых экземпляров - 503.
+type Proxy struct {
+	upstream Upstream
+	rewrite  []string // путь в upstream, разбитый на сегменты; "{param}" подставляется
+	timeout  time.Duration
+	proxy    *httputil.ReverseProxy
+}
+
+type proxyTargetKey struct{}
+
+// NewProxy собирает прокси. rewrite - шаблон пути в upstream с параметрами chi,
+// например "/orders/{orderId}/pay". timeout <= 0 - без собственного таймаута.
+func NewProxy(u Upstream, rewrite string, timeout time.Duration) *Proxy {
+	p := &Proxy{
+		upstream: u,
+		rewrite:  strings.Split(rewrite, "/"),
+		timeout:  timeout,
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			target := *pr.In.Context().Value(proxyTargetKey{}).(*url.URL)
+			pr.Out.URL = &target
+			pr.Out.Host = ""
+
+			// downstream-сервисы принимают только JSON
+			pr.Out.Header.Set("Content-Type", "application/json")
+			setIdentityHeaders(pr.Out, pr.In)
+		},
+		Transport: u.Transport,
+		ModifyResponse: func(resp *http.Response) error {
+			if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != problem.ContentType {
+				resp.Header.Set("Content-Type", "application/json")
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handleCBError(w, r, err, u.Service)
+		},
+	}
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var target url.URL
+	target.Path, target.RawPath = escapedPath(p.expandPath(r), r.URL.RawPath != "")
+	target.RawQuery = r.URL.RawQuery
+
+	ctx := context.WithValue(r.Context(), proxyTargetKey{}, &target)
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// expandPath подставляет параметры маршрута в шаблон.
+func (p *Proxy) expandPath(r *http.Request) string {
+	segments := make([]string, len(p.rewrite))
+	for i, s := range p.rewrite {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			s = chi.URLParam(r, strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}"))
+		}
+		segments[i] = s
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapedPath возвращает пару Path/RawPath для url.URL. chi матчит по
+// RawPath, если он есть, и тогда параметры приходят в экранированном виде.
+func escapedPath(path string, escaped bool) (string, string) {
+	if !escaped {
+		return path, ""
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return path, ""
+	}
+	return unescaped, path
+}
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"api_gateway/internal/upstream"
+	"common/problem"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sony/gobreaker"
+)
+
+// newPool - пул из одного экземпляра; settings - для его breaker'а.
+func newPool(t *testing.T, instanceURL string, settings gobreaker.Settings) *upstream.Pool {
+	t.Helper()
+	pool, err := upstream.NewPool("orders", upstream.Options{
+		Discovery:  upstream.Static{instanceURL},
+		NewBreaker: func(string) *gobreaker.CircuitBreaker { return gobreaker.NewCircuitBreaker(settings) },
+	})
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	return pool
+}
+
+func newProxyRouter(t *testing.T, upstreamURL, pattern, rewrite string, settings gobreaker.Settings) *chi.Mux {
+	t.Helper()
+	u := handler.Upstream{Service: "Orders", Transport: newPool(t, upstreamURL, settings)}
+
+	r := chi.NewRouter()
+	r.Handle(pattern, handler.NewProxy(u, rewrite, time.Second))
+	return r
+}
+
+func TestProxy_ForwardsRequestAndResponse(t *testing.T) {
+	var gotPath, gotQuery, gotBody, gotContentType string
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotQuery = r.URL.RawQuery
+		gotContentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+
+		// сервисы иногда отвечают через http.Error с text/plain
+		w.Header().Set("X-Upstream", "orders")
+		http.Error(w, `{"error": "Invalid status transition"}`, http.StatusConflict)
+	}))
+	defer orders.Close()
+
+	r := newProxyRouter(t, orders.URL, "/api/orders/{orderId}/pay", "/orders/{orderId}/pay", gobreaker.Settings{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/a%2Fb/pay?force=1", strings.NewReader(`{"x":1}`))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if gotPath != "/orders/a%2Fb/pay" || gotQuery != "force=1" {
+		t.Fatalf("unexpected upstream url: %s?%s", gotPath, gotQuery)
+	}
+	if gotBody != `{"x":1}` || gotContentType != "application/json" {
+		t.Fatalf("unexpected upstream body/content type: %q, %q", gotBody, gotContentType)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+	if w.Header().Get("X-Upstream") != "orders" {
+		t.Fatalf("expected upstream headers to be copied")
+	}
+	if body := w.Body.String(); body != "{\"error\": \"Invalid status transition\"}\n" {
+		t.Fatalf("unexpected body: %q", body)
+	}
+}
+
+func TestProxy_UpstreamErrors(t *testing.T) {
+	orders := httptest.NewServer(http.NotFoundHandler())
+	orders.Close() // соединение будет отклонено
+
+	r := newProxyRouter(t, orders.URL, "/orders", "/orders", gobreaker.Settings{
+		ReadyToTrip: func(c gobreaker.Counts) bool { return c.ConsecutiveFailures >= 1 },
+		Timeout:     time.Minute,
+	})
+
+	tests := []struct {
+		code   int
+		detail string
+	}{
+		{code: http.StatusInternalServerError, detail: "internal server error"},
+		{code: http.StatusServiceUnavailable, detail: "Orders service temporarily unavailable"},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+		p := decodeProblem(t, w)
+		if w.Code != tc.code || p.Status != tc.code || p.Detail != tc.detail {
+			t.Fatalf("expected %d %q, got %d %+v", tc.code, tc.detail, w.Code, p)
+		}
+	}
+}
+
+func TestProxy_KeepsUpstreamProblemContentType(t *testing.T) {
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.Forbidden)
+	}))
+	defer orders.Close()
+
+	r := newProxyRouter(t, orders.URL, "/orders", "/orders", gobreaker.Settings{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Fatalf("expected %s, got %q", problem.ContentType, ct)
+	}
+	if p := decodeProblem(t, w); p.Code != "forbidden" {
+		t.Fatalf("expected upstream problem to pass through, got %+v", p)
+	}
+}
+
+// decodeProblem проверяет, что ответ - application/problem+json, и разбирает его.
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem.Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Fatalf("expected %s, got %q: %s", problem.ContentType, ct, w.Body.String())
+	}
+	var p problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("expected problem JSON, got: %v", err)
+	}
+	return p
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"api_gateway/internal/metrics"
+	"common/problem"
 	"net"
 	"net/http"
 	"strings"
@@ -16,17 +18,21 @@ type tokenBucket struct {
 type RateLimiter struct {
 	mu      sync.Mutex
 	clients map[string]*tokenBucket
+	class   string        // класс лимита - метка в метриках
 	rate    float64       // скорость пополнения токенов (tokens per second)
 	burst   float64       // максимальное количество токенов (ёмкость ведра)
 	ttl     time.Duration // сколько держать записи о клиенте без активности
+	swept   time.Time     // когда последний раз выбрасывали неактивных
 }
 
-func NewRateLimiter(rate float64, burst int, ttl time.Duration) *RateLimiter {
+func NewRateLimiter(class string, rate float64, burst int, ttl time.Duration) *RateLimiter {
 	return &RateLimiter{
 		clients: make(map[string]*tokenBucket),
+		class:   class,
 		rate:    rate,           // rate = сколько запросов в секунду,
 		burst:   float64(burst), // burst = "запас" для кратковременных всплесков.
 		ttl:     ttl,
+		swept:   time.Now(),
 	}
 }
 
@@ -35,7 +41,8 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 		key := rl.clientKey(r)
 
 		if !rl.allow(key) {
-			http.Error(w, `{"error": "rate limit exceeded"}`, http.StatusTooManyRequests)
+			metrics.RateLimitRejections.WithLabelValues(rl.class).Inc()
+			problem.Write(w, r, problem.RateLimited)
 			return
 		}
 
@@ -50,12 +57,15 @@ func (rl *RateLimiter) allow(key string) bool {
 	defer rl.mu.Unlock()
 
 	now := time.Now()
+	rl.sweep(now)
+
 	bucket, ok := rl.clients[key]
 	if !ok {
 		rl.clients[key] = &tokenBucket{
 			tokens:    rl.burst - 1,
 			lastCheck: now,
 		}
+		metrics.RateLimitClients.WithLabelValues(rl.class).Set(float64(len(rl.clients)))
 		return true
 	}
 
@@ -74,6 +84,22 @@ func (rl *RateLimiter) allow(key string) bool {
 	return true
 }
 
+// sweep раз в ttl выбрасывает клиентов, которые не приходили дольше ttl:
+// их ведёрки всё равно успели наполниться до краёв.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if rl.ttl <= 0 || now.Sub(rl.swept) < rl.ttl {
+		return
+	}
+	rl.swept = now
+
+	for key, bucket := range rl.clients {
+		if now.Sub(bucket.lastCheck) > rl.ttl {
+			delete(rl.clients, key)
+		}
+	}
+	metrics.RateLimitClients.WithLabelValues(rl.class).Set(float64(len(rl.clients)))
+}
+
 // clientKey пытается определить ключ клиента (по IP).
 // В проде можно смотреть X-Forwarded-For, X-Real-IP и т.п.
 // Для простоты берём RemoteAddr.
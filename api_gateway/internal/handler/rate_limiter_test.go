@@ -0,0 +1,36 @@
+package handler_test
+
+import (
+	"api_gateway/internal/handler"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_ForgetsIdleClients(t *testing.T) {
+	// ведёрко на один запрос почти не пополняется: 200 после паузы
+	// возможен, только если клиента забыли
+	rl := handler.NewRateLimiter("test", 0.001, 1, 20*time.Millisecond)
+	h := rl.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	do := func(ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got: %d", code)
+	}
+	if code := do("10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request to be limited, got: %d", code)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if code := do("10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("expected idle client to be forgotten after ttl, got: %d", code)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RevocationList - отозванные access-токены (jti) из users-service. Подпись
+// gateway проверяет сам, и без списка токен после logout проходил бы до конца
+// своего TTL. Список перечитывается в фоне раз в interval, поэтому после
+// logout токен может пройти ещё не дольше interval. Пока users-service
+// недоступен, действует последний полученный список.
+type RevocationList struct {
+	client   *http.Client
+	url      string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time // jti -> когда токен истекает сам
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewRevocationList(client *http.Client, url string, interval time.Duration) *RevocationList {
+	return &RevocationList{
+		client:   client,
+		url:      url,
+		interval: interval,
+		revoked:  make(map[string]time.Time),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start запускает опрос; первый запрос уходит сразу.
+func (l *RevocationList) Start() {
+	go func() {
+		t := time.NewTicker(l.interval)
+		defer t.Stop()
+		for {
+			if err := l.refresh(context.Background()); err != nil {
+				slog.Warn("failed to refresh revoked tokens", "error", err)
+			}
+			select {
+			case <-l.stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+func (l *RevocationList) Close() {
+	l.once.Do(func() { close(l.stop) })
+}
+
+// Revoked - отозван ли токен с этим jti.
+func (l *RevocationList) Revoked(jti string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	exp, ok := l.revoked[jti]
+	return ok && time.Now().Before(exp)
+}
+
+func (l *RevocationList) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Tokens []struct {
+			JTI       string    `json:"jti"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		} `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	revoked := make(map[string]time.Time, len(body.Tokens))
+	for _, t := range body.Tokens {
+		revoked[t.JTI] = t.ExpiresAt
+	}
+
+	l.mu.Lock()
+	l.revoked = revoked
+	l.mu.Unlock()
+	return nil
+}
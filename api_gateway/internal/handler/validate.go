@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"api_gateway/internal/model"
+	"bytes"
+	"common/problem"
+	"common/validate"
+	"io"
+	"net/http"
+)
+
+// RequestBodies - схемы тел запросов, на которые ссылается Route.Body.
+var RequestBodies = map[string]func() any{
+	"register":       func() any { return new(model.RegisterRequest) },
+	"login":          func() any { return new(model.LoginRequest) },
+	"refresh":        func() any { return new(model.RefreshRequest) },
+	"update_profile": func() any { return new(model.UpdateProfileRequest) },
+	"create_order":   func() any { return new(model.CreateOrderRequest) },
+	"update_order":   func() any { return new(model.UpdateOrderRequest) },
+}
+
+// ValidateBody проверяет JSON-тело по схеме до того, как запрос уйдёт в
+// сервис: заведомо неверный запрос не тратит попытки и бюджет breaker'а.
+// Тело (не больше validate.MaxBodyBytes) уходит дальше без изменений.
+func ValidateBody(newBody func() any) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body bytes.Buffer
+			limited := http.MaxBytesReader(w, r.Body, validate.MaxBodyBytes)
+			if err := validate.Decode(io.TeeReader(limited, &body), newBody()); err != nil {
+				problem.Mapper{}.Write(w, r, err)
+				return
+			}
+
+			r.Body = io.NopCloser(&body)
+			r.ContentLength = int64(body.Len())
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Package metrics - метрики gateway в формате Prometheus. HTTP-метрики
+// входящих запросов - в common/metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// UpstreamDuration - одна попытка запроса к экземпляру сервиса. outcome:
+	// класс статуса (2xx..5xx), error, canceled (клиент ушёл) или rejected
+	// (breaker открыт, живых экземпляров нет).
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Latency of requests to upstream services by upstream and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream", "outcome"})
+
+	// BreakerState: 0 - closed, 1 - half-open, 2 - open (как gobreaker.State).
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_circuit_breaker_state",
+		Help: "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, []string{"breaker"})
+
+	BreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_circuit_breaker_transitions_total",
+		Help: "Circuit breaker state transitions.",
+	}, []string{"breaker", "from", "to"})
+
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter by class.",
+	}, []string{"class"})
+
+	RateLimitClients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_rate_limit_clients",
+		Help: "Clients currently tracked by the rate limiter by class.",
+	}, []string{"class"})
+)
@@ -11,14 +11,40 @@ type User struct {
 }
 
 type Order struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	UserId      int       `json:"userId"`
-	Status      string    `json:"status"`
-	Price       int       `json:"price"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	UserId      int         `json:"userId"`
+	Status      string      `json:"status"`
+	Items       []OrderItem `json:"items"`
+	Subtotal    int         `json:"subtotal"`
+	Total       int         `json:"total"`
+	ItemCount   int         `json:"itemCount"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+
+	StatusHistory []StatusChange `json:"statusHistory"`
+}
+
+type OrderItem struct {
+	SKU       string `json:"sku" validate:"max=64"`
+	Title     string `json:"title" validate:"required,max=200"`
+	UnitPrice int    `json:"unitPrice" validate:"min=0"`
+	Quantity  int    `json:"quantity" validate:"min=1,max=10000"`
+	LineTotal int    `json:"lineTotal"`
+}
+
+type StatusChange struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// OrderPage - конверт, в котором orders-service отдаёт списки.
+type OrderPage struct {
+	Items      []Order `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+	Total      int     `json:"total"`
 }
 
 type UserDetailsResponse struct {
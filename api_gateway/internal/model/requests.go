@@ -0,0 +1,41 @@
+package model
+
+// Тела запросов, которые gateway проверяет до проксирования (Route.Body).
+// Поля и правила validate повторяют модели сервисов: gateway отсекает
+// заведомо неверные запросы, окончательно проверяет сервис. Расхождение с
+// сервисом ловит TestRequests_MatchServiceRules.
+
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type UpdateProfileRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CreateOrderRequest struct {
+	Name        string      `json:"name" validate:"required,max=200"`
+	Description string      `json:"description" validate:"max=2000"`
+	UserId      int         `json:"userId" validate:"min=0"`
+	Status      string      `json:"status" validate:"omitempty,oneof=created"`
+	Items       []OrderItem `json:"items" validate:"required,max=100"`
+}
+
+type UpdateOrderRequest struct {
+	ID          int         `json:"id" validate:"required,min=1"`
+	Name        string      `json:"name" validate:"max=200"`
+	Description string      `json:"description" validate:"max=2000"`
+	Status      string      `json:"status" validate:"omitempty,oneof=created paid shipped delivered canceled refunded"`
+	Items       []OrderItem `json:"items" validate:"max=100"`
+}
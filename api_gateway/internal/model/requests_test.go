@@ -0,0 +1,79 @@
+package model_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// structRules - "json validate" каждого поля структуры name из файла path,
+// у которого есть правило validate.
+func structRules(t *testing.T, path, name string) map[string]string {
+	t.Helper()
+
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+
+	var rules map[string]string
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != name {
+			return true
+		}
+		rules = make(map[string]string)
+		for _, field := range spec.Type.(*ast.StructType).Fields.List {
+			if field.Tag == nil || len(field.Names) == 0 {
+				continue
+			}
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag := reflect.StructTag(raw)
+			if rule, ok := tag.Lookup("validate"); ok {
+				json, _, _ := strings.Cut(tag.Get("json"), ",")
+				rules[field.Names[0].Name] = json + " " + rule
+			}
+		}
+		return false
+	})
+	if rules == nil {
+		t.Fatalf("no struct %s in %s", name, path)
+	}
+	return rules
+}
+
+// Gateway не может импортировать модели сервисов (internal, другой модуль),
+// поэтому правила сверяются по исходникам: поменяли правило в сервисе -
+// меняйте и здесь.
+func TestRequests_MatchServiceRules(t *testing.T) {
+	users := filepath.Join("..", "..", "..", "service_users", "internal", "model", "models.go")
+	orders := filepath.Join("..", "..", "..", "service_orders", "internal", "model", "models.go")
+
+	tests := []struct {
+		name    string
+		gateway string
+		service string
+	}{
+		{name: "RegisterRequest", gateway: "requests.go", service: users},
+		{name: "LoginRequest", gateway: "requests.go", service: users},
+		{name: "RefreshRequest", gateway: "requests.go", service: users},
+		{name: "UpdateProfileRequest", gateway: "requests.go", service: users},
+		{name: "CreateOrderRequest", gateway: "requests.go", service: orders},
+		{name: "UpdateOrderRequest", gateway: "requests.go", service: orders},
+		{name: "OrderItem", gateway: "models.go", service: orders},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := structRules(t, tc.gateway, tc.name)
+			want := structRules(t, tc.service, tc.name)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected service rules %v, got: %v", want, got)
+			}
+		})
+	}
+}
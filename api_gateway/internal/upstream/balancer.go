@@ -0,0 +1,93 @@
+package upstream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	BalancerRoundRobin    = "round-robin"
+	BalancerLeastInFlight = "least-in-flight"
+	BalancerUserHash      = "user-hash"
+)
+
+// Balancer выбирает экземпляр для запроса из доступных (их всегда хотя бы один).
+type Balancer interface {
+	Pick(req *http.Request, instances []*Instance) *Instance
+}
+
+func NewBalancer(name string) (Balancer, error) {
+	switch name {
+	case BalancerRoundRobin, "":
+		return NewRoundRobin(), nil
+	case BalancerLeastInFlight:
+		return &LeastInFlight{}, nil
+	case BalancerUserHash:
+		return &UserHash{fallback: NewRoundRobin()}, nil
+	}
+	return nil, fmt.Errorf("unknown balancer %q", name)
+}
+
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{}
+}
+
+func (b *RoundRobin) Pick(_ *http.Request, instances []*Instance) *Instance {
+	n := b.next.Add(1) - 1
+	return instances[n%uint64(len(instances))]
+}
+
+// LeastInFlight выбирает экземпляр с наименьшим числом незавершённых запросов;
+// при равенстве - по кругу, чтобы не грузить всегда первый.
+type LeastInFlight struct {
+	next atomic.Uint64
+}
+
+func (b *LeastInFlight) Pick(_ *http.Request, instances []*Instance) *Instance {
+	start := int(b.next.Add(1) % uint64(len(instances)))
+
+	best := instances[start]
+	for i := 1; i < len(instances); i++ {
+		inst := instances[(start+i)%len(instances)]
+		if inst.InFlight() < best.InFlight() {
+			best = inst
+		}
+	}
+	return best
+}
+
+// UserHash держит запросы одного пользователя на одном экземпляре
+// (rendezvous hashing по X-User-ID). Когда экземпляр выпадает, переезжают
+// только его пользователи. Анонимные запросы идут по кругу.
+type UserHash struct {
+	fallback *RoundRobin
+}
+
+// HeaderUserID - заголовок, который gateway выставляет по проверенному токену.
+const HeaderUserID = "X-User-ID"
+
+func (b *UserHash) Pick(req *http.Request, instances []*Instance) *Instance {
+	key := req.Header.Get(HeaderUserID)
+	if key == "" {
+		return b.fallback.Pick(req, instances)
+	}
+
+	var best *Instance
+	var bestScore uint64
+	for _, inst := range instances {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(inst.URL()))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = inst, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,42 @@
+package upstream
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Discovery - источник списка экземпляров (базовых URL) сервиса.
+type Discovery interface {
+	Instances() ([]string, error)
+}
+
+// Static - список из конфигурации.
+type Static []string
+
+func (s Static) Instances() ([]string, error) {
+	return s, nil
+}
+
+// File - локальный файл, по адресу на строку; пустые строки и строки с #
+// пропускаются. Файл перечитывается при каждом опросе пула.
+type File string
+
+func (f File) Instances() ([]string, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, sc.Err()
+}
@@ -0,0 +1,146 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck - активная проверка экземпляров: GET Path раз в Interval.
+// Экземпляр становится нездоровым после UnhealthyThreshold неудачных проверок
+// подряд и снова здоровым после HealthyThreshold удачных. Нездоровые
+// экземпляры не получают запросов.
+type HealthCheck struct {
+	Path               string // пусто - проверка выключена
+	Interval           time.Duration
+	Timeout            time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+func (hc HealthCheck) enabled() bool {
+	return hc.Path != "" && hc.Interval > 0
+}
+
+// ProbeResult - результат последней проверки экземпляра.
+type ProbeResult struct {
+	At      time.Time     `json:"at"`
+	OK      bool          `json:"ok"`
+	Status  int           `json:"status,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency"`
+}
+
+// health - состояние активной проверки экземпляра. До первой проверки
+// экземпляр считается здоровым, чтобы gateway сразу начал обслуживать запросы.
+type health struct {
+	mu             sync.Mutex
+	unhealthy      bool
+	successes      int // подряд, пока экземпляр нездоров
+	failures       int // подряд, пока экземпляр здоров
+	lastProbe      *ProbeResult
+	lastTransition *time.Time
+}
+
+func (i *Instance) Healthy() bool {
+	i.health.mu.Lock()
+	defer i.health.mu.Unlock()
+	return !i.health.unhealthy
+}
+
+// checkHealth проверяет все экземпляры пула параллельно.
+func (p *Pool) checkHealth() {
+	var wg sync.WaitGroup
+	for _, inst := range p.Instances() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.observe(inst, p.probe(inst))
+		}()
+	}
+	wg.Wait()
+}
+
+// probe идёт в экземпляр напрямую, мимо breaker'а: проверка не должна ни
+// открывать его, ни упираться в открытый.
+func (p *Pool) probe(inst *Instance) ProbeResult {
+	hc := p.opts.HealthCheck
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout)
+	defer cancel()
+
+	res := ProbeResult{At: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url.String()+hc.Path, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	resp, err := p.opts.Transport.RoundTrip(req)
+	res.Latency = time.Since(res.At)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	res.Status = resp.StatusCode
+	res.OK = resp.StatusCode < http.StatusMultipleChoices
+	if !res.OK {
+		res.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return res
+}
+
+// observe учитывает результат проверки и переключает состояние экземпляра,
+// когда набирается порог.
+func (p *Pool) observe(inst *Instance, res ProbeResult) {
+	hc := p.opts.HealthCheck
+	h := &inst.health
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastProbe = &res
+	switch {
+	case res.OK && h.unhealthy:
+		h.successes++
+		if h.successes < hc.HealthyThreshold {
+			return
+		}
+	case !res.OK && !h.unhealthy:
+		h.failures++
+		if h.failures < hc.UnhealthyThreshold {
+			return
+		}
+	default:
+		h.successes, h.failures = 0, 0
+		return
+	}
+
+	h.unhealthy = !h.unhealthy
+	h.successes, h.failures = 0, 0
+	at := res.At
+	h.lastTransition = &at
+
+	if h.unhealthy {
+		slog.Warn("upstream instance unhealthy", "upstream", p.name, "instance", inst.url.String(), "error", res.Error)
+	} else {
+		slog.Info("upstream instance healthy again", "upstream", p.name, "instance", inst.url.String())
+	}
+}
+
+// Ready - есть ли у пула хотя бы один экземпляр, готовый принимать запросы.
+func (p *Pool) Ready() bool {
+	now := time.Now()
+	for _, inst := range p.Instances() {
+		if inst.Healthy() && !inst.ejected(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package upstream_test
+
+import (
+	"api_gateway/internal/upstream"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	var down atomic.Bool
+	var probes atomic.Int64
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/health" {
+			probes.Add(1)
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("flaky " + r.URL.Path))
+	}))
+	defer flaky.Close()
+	stable := newInstance(t, "stable", nil)
+
+	pool, _ := upstream.NewPool("orders", upstream.Options{
+		Discovery: upstream.Static{flaky.URL, stable.URL},
+		HealthCheck: upstream.HealthCheck{
+			Path:               "/orders/health",
+			Interval:           10 * time.Millisecond,
+			Timeout:            time.Second,
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 3,
+		},
+	})
+	pool.Start(0)
+	defer pool.Close()
+
+	waitFor(t, "first probe", func() bool { return pool.Stats()[0].LastProbe != nil })
+	if s := pool.Stats()[0]; !s.Healthy || !s.LastProbe.OK || s.LastTransition != nil {
+		t.Fatalf("expected healthy instance without transitions, got %+v", s)
+	}
+
+	down.Store(true)
+	waitFor(t, "instance to become unhealthy", func() bool { return !pool.Stats()[0].Healthy })
+	if n := probes.Load(); n < 4 {
+		t.Fatalf("expected unhealthy threshold to be respected, got %d probes", n)
+	}
+	s := pool.Stats()[0]
+	if s.LastTransition == nil || s.LastProbe.Status != http.StatusServiceUnavailable {
+		t.Fatalf("expected probe result and transition time, got %+v", s)
+	}
+	for i := 0; i < 3; i++ {
+		if body, _ := call(t, pool, "/orders", ""); body != "stable /orders" {
+			t.Fatalf("expected unhealthy instance to be skipped, got %q", body)
+		}
+	}
+	if !pool.Ready() {
+		t.Fatalf("expected pool to be ready while one instance is healthy")
+	}
+
+	down.Store(false)
+	waitFor(t, "instance to recover", func() bool { return pool.Stats()[0].Healthy })
+}
+
+func TestPool_NotReadyWithoutHealthyInstances(t *testing.T) {
+	failing := http.StatusInternalServerError
+	srv := newInstance(t, "only", &failing)
+
+	pool, _ := upstream.NewPool("users", upstream.Options{
+		Discovery: upstream.Static{srv.URL},
+		HealthCheck: upstream.HealthCheck{
+			Path:     "/users/health",
+			Interval: 10 * time.Millisecond,
+		},
+	})
+	if !pool.Ready() {
+		t.Fatalf("expected pool to be ready before the first probe")
+	}
+	pool.Start(0)
+	defer pool.Close()
+
+	waitFor(t, "pool to become not ready", func() bool { return !pool.Ready() })
+	if state, _ := pool.State(); state != "open" {
+		t.Fatalf("expected pool state open, got %s", state)
+	}
+}
@@ -0,0 +1,402 @@
+// Package upstream - пулы экземпляров сервисов за gateway: балансировка,
+// circuit breaker на каждый экземпляр, выброс "выбросов" (outlier ejection),
+// активная проверка здоровья и обновление списка экземпляров из discovery.
+package upstream
+
+import (
+	"api_gateway/internal/metrics"
+	"common/logging"
+	"common/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNoAvailableInstance - все экземпляры нездоровы, выброшены или их
+// breaker'ы открыты.
+var ErrNoAvailableInstance = errors.New("no available upstream instance")
+
+// OutlierDetection - когда выводить экземпляр из балансировки. Ошибкой здесь
+// считается и сетевая ошибка, и ответ 5xx.
+type OutlierDetection struct {
+	ConsecutiveFailures int           // 0 - выключено
+	EjectionTime        time.Duration // на сколько выводить
+	MaxEjectedPercent   int           // не больше этой доли экземпляров одновременно
+}
+
+type Options struct {
+	Balancer    Balancer
+	Discovery   Discovery
+	Outlier     OutlierDetection
+	HealthCheck HealthCheck
+
+	// NewBreaker создаёт breaker экземпляра; name - "<pool>/<url>". Чтобы
+	// уход клиента не открывал breaker, в настройках нужен IsSuccessful.
+	NewBreaker func(name string) *gobreaker.CircuitBreaker
+	// Transport по умолчанию http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Pool - экземпляры одного сервиса. Сам Pool - http.RoundTripper: запрос с
+// путём (без хоста) уходит на выбранный экземпляр.
+type Pool struct {
+	name string
+	opts Options
+
+	mu        sync.RWMutex
+	instances []*Instance
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewPool(name string, opts Options) (*Pool, error) {
+	if opts.Balancer == nil {
+		opts.Balancer = NewRoundRobin()
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.HealthCheck.Timeout <= 0 {
+		opts.HealthCheck.Timeout = time.Second
+	}
+	opts.HealthCheck.HealthyThreshold = max(opts.HealthCheck.HealthyThreshold, 1)
+	opts.HealthCheck.UnhealthyThreshold = max(opts.HealthCheck.UnhealthyThreshold, 1)
+	if opts.NewBreaker == nil {
+		opts.NewBreaker = func(name string) *gobreaker.CircuitBreaker {
+			return gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: name, IsSuccessful: IsSuccessful})
+		}
+	}
+
+	p := &Pool{name: name, opts: opts, stop: make(chan struct{})}
+	if err := p.Refresh(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pool) Name() string { return p.name }
+
+// Refresh перечитывает список экземпляров из discovery. Экземпляры, которые
+// остались в списке, сохраняют breaker'ы и статистику.
+func (p *Pool) Refresh() error {
+	urls, err := p.opts.Discovery.Instances()
+	if err != nil {
+		return fmt.Errorf("%s discovery: %w", p.name, err)
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("%s discovery: no instances", p.name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*Instance, len(p.instances))
+	for _, inst := range p.instances {
+		existing[inst.url.String()] = inst
+	}
+
+	instances := make([]*Instance, 0, len(urls))
+	changed := len(urls) != len(p.instances)
+	for _, raw := range urls {
+		u, err := url.Parse(strings.TrimSuffix(raw, "/"))
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("%s discovery: invalid instance URL %q", p.name, raw)
+		}
+		inst, ok := existing[u.String()]
+		if !ok {
+			inst = &Instance{pool: p, url: u, breakers: make(map[string]*gobreaker.CircuitBreaker)}
+			changed = true
+		}
+		instances = append(instances, inst)
+	}
+
+	if changed && p.instances != nil {
+		slog.Info("upstream instances changed", "upstream", p.name, "instances", urls)
+	}
+	p.instances = instances
+	return nil
+}
+
+// Start опрашивает discovery раз в interval и проверяет здоровье экземпляров
+// (если проверка включена), пока пул не закрыт.
+func (p *Pool) Start(interval time.Duration) {
+	if hc := p.opts.HealthCheck; hc.enabled() {
+		go func() {
+			t := time.NewTicker(hc.Interval)
+			defer t.Stop()
+			for {
+				p.checkHealth()
+				select {
+				case <-p.stop:
+					return
+				case <-t.C:
+				}
+			}
+		}()
+	}
+
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-t.C:
+				// при ошибке работаем со старым списком
+				if err := p.Refresh(); err != nil {
+					slog.Warn("upstream discovery failed", "upstream", p.name, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (p *Pool) Close() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+func (p *Pool) Instances() []*Instance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.instances
+}
+
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	return p.roundTrip(req, "")
+}
+
+// Transport - RoundTripper со своей группой breaker'ов: маршруты с отдельным
+// breaker'ом не открывают "пробку" для остальных.
+func (p *Pool) Transport(group string) http.RoundTripper {
+	return groupTransport{pool: p, group: group}
+}
+
+type groupTransport struct {
+	pool  *Pool
+	group string
+}
+
+func (t groupTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.pool.roundTrip(req, t.group)
+}
+
+// roundTrip - одна попытка запроса. У каждой попытки свой клиентский спан:
+// какой экземпляр выбран и что решил его breaker.
+func (p *Pool) roundTrip(req *http.Request, group string) (*http.Response, error) {
+	start := time.Now()
+	ctx, span := tracing.StartClient(req.Context(), "upstream "+p.name,
+		attribute.String("upstream.name", p.name),
+		attribute.String("upstream.breaker_group", group),
+		semconv.HTTPRequestMethodKey.String(req.Method),
+	)
+
+	inst, err := p.pick(req, group)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		p.measure(ctx, start, nil, nil, err)
+		tracing.End(span, err)
+		return nil, err
+	}
+	cb := inst.breaker(group)
+	span.SetAttributes(
+		attribute.String("upstream.instance", inst.url.String()),
+		attribute.String("upstream.breaker", cb.Name()),
+		attribute.String("upstream.breaker_state", cb.State().String()),
+	)
+
+	out := req.Clone(ctx)
+	out.URL.Scheme = inst.url.Scheme
+	out.URL.Host = inst.url.Host
+	out.URL.Path = inst.url.Path + req.URL.Path
+	if req.URL.RawPath != "" {
+		out.URL.RawPath = inst.url.EscapedPath() + req.URL.RawPath
+	}
+	out.Host = ""
+
+	inst.inFlight.Add(1)
+	inst.requests.Add(1)
+	tracing.Inject(ctx, out.Header)
+	res, err := cb.Execute(func() (interface{}, error) {
+		return p.opts.Transport.RoundTrip(out)
+	})
+	resp, _ := res.(*http.Response)
+	p.measure(ctx, start, inst, resp, err)
+	if err != nil {
+		inst.inFlight.Add(-1)
+		// отказ самого breaker'а и уход клиента - не ошибка экземпляра
+		rejected := errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+		if rejected {
+			span.AddEvent("breaker rejected request", trace.WithAttributes(attribute.String("upstream.breaker_state", cb.State().String())))
+		}
+		if !rejected && !IsSuccessful(err) {
+			p.record(inst, false)
+		}
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	p.record(inst, resp.StatusCode < http.StatusInternalServerError)
+	tracing.EndResponse(span, resp.StatusCode)
+	// экземпляр занят, пока тело ответа не дочитано
+	resp.Body = &trackedBody{ReadCloser: resp.Body, inst: inst}
+	return resp, nil
+}
+
+// measure пишет длительность попытки в метрики gateway и в запись о запросе.
+func (p *Pool) measure(ctx context.Context, start time.Time, inst *Instance, resp *http.Response, err error) {
+	took := time.Since(start)
+	outcome := "error"
+	switch {
+	case err == nil:
+		outcome = fmt.Sprintf("%dxx", resp.StatusCode/100)
+	case errors.Is(err, ErrNoAvailableInstance), errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		outcome = "rejected"
+	case errors.Is(err, context.Canceled):
+		outcome = "canceled"
+	}
+	metrics.UpstreamDuration.WithLabelValues(p.name, outcome).Observe(took.Seconds())
+	logging.ObserveUpstream(ctx, took)
+
+	attrs := []slog.Attr{
+		slog.String("upstream", p.name),
+		slog.String("outcome", outcome),
+		slog.Duration("duration", took),
+	}
+	if inst != nil {
+		attrs = append(attrs, slog.String("instance", inst.url.String()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, slog.LevelDebug, "upstream attempt", attrs...)
+}
+
+func (p *Pool) pick(req *http.Request, group string) (*Instance, error) {
+	now := time.Now()
+
+	all := p.Instances()
+	available := make([]*Instance, 0, len(all))
+	for _, inst := range all {
+		if inst.Healthy() && !inst.ejected(now) && inst.breaker(group).State() != gobreaker.StateOpen {
+			available = append(available, inst)
+		}
+	}
+	if len(available) == 0 {
+		return nil, ErrNoAvailableInstance
+	}
+
+	return p.opts.Balancer.Pick(req, available), nil
+}
+
+// record учитывает исход запроса для outlier detection.
+func (p *Pool) record(inst *Instance, ok bool) {
+	if ok {
+		inst.consecutiveFailures.Store(0)
+		return
+	}
+	inst.failures.Add(1)
+
+	od := p.opts.Outlier
+	if od.ConsecutiveFailures <= 0 || inst.consecutiveFailures.Add(1) < int64(od.ConsecutiveFailures) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if inst.ejected(now) {
+		return
+	}
+	ejected := 0
+	for _, other := range p.instances {
+		if other.ejected(now) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(p.instances)*od.MaxEjectedPercent {
+		return
+	}
+
+	inst.ejectedUntil.Store(now.Add(od.EjectionTime).UnixNano())
+	inst.consecutiveFailures.Store(0)
+	slog.Warn("upstream instance ejected", "upstream", p.name, "instance", inst.url.String(), "for", od.EjectionTime)
+}
+
+// IsSuccessful - для gobreaker.Settings: клиент, закрывший соединение, не
+// говорит ничего плохого об экземпляре. Таймаут (DeadlineExceeded) - говорит.
+func IsSuccessful(err error) bool {
+	return err == nil || errors.Is(err, context.Canceled)
+}
+
+// Instance - один экземпляр сервиса.
+type Instance struct {
+	pool *Pool
+	url  *url.URL
+
+	mu       sync.Mutex
+	breakers map[string]*gobreaker.CircuitBreaker // по группам, "" - общая
+
+	inFlight            atomic.Int64
+	requests            atomic.Int64
+	failures            atomic.Int64
+	consecutiveFailures atomic.Int64
+	ejectedUntil        atomic.Int64 // unix nano
+
+	health health
+}
+
+func (i *Instance) URL() string { return i.url.String() }
+
+func (i *Instance) InFlight() int64 { return i.inFlight.Load() }
+
+func (i *Instance) breaker(group string) *gobreaker.CircuitBreaker {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	cb, ok := i.breakers[group]
+	if !ok {
+		name := i.pool.name + "/" + i.url.String()
+		if group != "" {
+			name = group + "/" + i.url.String()
+		}
+		cb = i.pool.opts.NewBreaker(name)
+		i.breakers[group] = cb
+	}
+	return cb
+}
+
+func (i *Instance) ejected(now time.Time) bool {
+	return now.UnixNano() < i.ejectedUntil.Load()
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	inst *Instance
+	once sync.Once
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(func() { b.inst.inFlight.Add(-1) })
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,267 @@
+package upstream_test
+
+import (
+	"api_gateway/internal/upstream"
+	"common/tracing"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newInstance - экземпляр, который отвечает своим именем или статусом status.
+func newInstance(t *testing.T, name string, status *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != nil && *status != 0 {
+			w.WriteHeader(*status)
+			return
+		}
+		w.Write([]byte(name + " " + r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func call(t *testing.T, rt http.RoundTripper, path, userID string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if userID != "" {
+		req.Header.Set(upstream.HeaderUserID, userID)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body), nil
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	a, b := newInstance(t, "a", nil), newInstance(t, "b", nil)
+	pool, err := upstream.NewPool("orders", upstream.Options{Discovery: upstream.Static{a.URL, b.URL}})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		body, err := call(t, pool, "/orders/1", "")
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		got = append(got, body)
+	}
+	if strings.Join(got, ",") != "a /orders/1,b /orders/1,a /orders/1,b /orders/1" {
+		t.Fatalf("unexpected distribution: %v", got)
+	}
+}
+
+func TestPool_UserHashIsSticky(t *testing.T) {
+	a, b, c := newInstance(t, "a", nil), newInstance(t, "b", nil), newInstance(t, "c", nil)
+	balancer, _ := upstream.NewBalancer(upstream.BalancerUserHash)
+	pool, _ := upstream.NewPool("orders", upstream.Options{
+		Discovery: upstream.Static{a.URL, b.URL, c.URL},
+		Balancer:  balancer,
+	})
+
+	seen := make(map[string]bool)
+	for user := 1; user <= 20; user++ {
+		id := string(rune('0'+user%10)) + string(rune('a'+user))
+		first, _ := call(t, pool, "/", id)
+		for i := 0; i < 3; i++ {
+			if again, _ := call(t, pool, "/", id); again != first {
+				t.Fatalf("user %s moved from %q to %q", id, first, again)
+			}
+		}
+		seen[first] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected users to be spread across instances, got %v", seen)
+	}
+}
+
+func TestPool_LeastInFlight(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := newInstance(t, "fast", nil)
+
+	balancer, _ := upstream.NewBalancer(upstream.BalancerLeastInFlight)
+	pool, _ := upstream.NewPool("orders", upstream.Options{
+		Discovery: upstream.Static{slow.URL, fast.URL},
+		Balancer:  balancer,
+	})
+
+	// первый запрос застревает на slow (или уходит на fast и сразу завершается)
+	go call(t, pool, "/", "")
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats()[0].InFlight == 0 && time.Now().Before(deadline) {
+		if body, _ := call(t, pool, "/", ""); body != "fast /" {
+			t.Fatalf("expected fast instance, got %q", body)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if body, _ := call(t, pool, "/", ""); body != "fast /" {
+			t.Fatalf("expected busy instance to be skipped, got %q", body)
+		}
+	}
+}
+
+func TestPool_OutlierEjection(t *testing.T) {
+	failing, healthy := http.StatusInternalServerError, 0
+	bad, good := newInstance(t, "bad", &failing), newInstance(t, "good", &healthy)
+
+	pool, _ := upstream.NewPool("orders", upstream.Options{
+		Discovery: upstream.Static{bad.URL, good.URL},
+		Outlier: upstream.OutlierDetection{
+			ConsecutiveFailures: 2,
+			EjectionTime:        time.Minute,
+			MaxEjectedPercent:   50,
+		},
+	})
+
+	for i := 0; i < 4; i++ {
+		call(t, pool, "/", "")
+	}
+
+	stats := pool.Stats()
+	if !stats[0].Ejected || stats[0].EjectedUntil == nil {
+		t.Fatalf("expected failing instance to be ejected, got %+v", stats[0])
+	}
+	for i := 0; i < 3; i++ {
+		if body, _ := call(t, pool, "/", ""); body != "good /" {
+			t.Fatalf("expected ejected instance to be skipped, got %q", body)
+		}
+	}
+
+	// второй экземпляр выбросить нельзя: это больше 50%
+	healthy = http.StatusBadGateway
+	for i := 0; i < 4; i++ {
+		call(t, pool, "/", "")
+	}
+	if pool.Stats()[1].Ejected {
+		t.Fatalf("expected max ejected percent to be respected")
+	}
+}
+
+func TestPool_SkipsOpenBreakers(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	up := newInstance(t, "up", nil)
+
+	newBreaker := func(name string) *gobreaker.CircuitBreaker {
+		return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:        name,
+			ReadyToTrip: func(c gobreaker.Counts) bool { return c.ConsecutiveFailures >= 1 },
+			Timeout:     time.Minute,
+		})
+	}
+	pool, _ := upstream.NewPool("orders", upstream.Options{
+		Discovery:  upstream.Static{down.URL, up.URL},
+		NewBreaker: newBreaker,
+	})
+
+	if _, err := call(t, pool, "/", ""); err == nil {
+		t.Fatalf("expected first request to hit the closed instance and fail")
+	}
+	for i := 0; i < 3; i++ {
+		if body, err := call(t, pool, "/", ""); err != nil || body != "up /" {
+			t.Fatalf("expected requests to go to the healthy instance, got %q, %v", body, err)
+		}
+	}
+	if pool.Stats()[0].State != "open" {
+		t.Fatalf("expected breaker of the failed instance to be open")
+	}
+
+	single, _ := upstream.NewPool("users", upstream.Options{
+		Discovery:  upstream.Static{down.URL},
+		NewBreaker: newBreaker,
+	})
+	call(t, single, "/", "")
+	if _, err := call(t, single, "/", ""); !errors.Is(err, upstream.ErrNoAvailableInstance) {
+		t.Fatalf("expected ErrNoAvailableInstance, got: %v", err)
+	}
+}
+
+func TestPool_FileDiscovery(t *testing.T) {
+	a, b := newInstance(t, "a", nil), newInstance(t, "b", nil)
+	path := filepath.Join(t.TempDir(), "orders.instances")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+
+	write("# orders\n" + a.URL + "\n")
+	pool, err := upstream.NewPool("orders", upstream.Options{Discovery: upstream.File(path)})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	call(t, pool, "/", "")
+
+	write(a.URL + "\n\n" + b.URL + "\n")
+	if err := pool.Refresh(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	stats := pool.Stats()
+	if len(stats) != 2 || stats[0].Requests != 1 {
+		t.Fatalf("expected existing instance to keep its stats, got %+v", stats)
+	}
+
+	write("")
+	if err := pool.Refresh(); err == nil || len(pool.Stats()) != 2 {
+		t.Fatalf("expected empty list to be rejected and old list kept")
+	}
+}
+
+func TestPool_TracesEachAttempt(t *testing.T) {
+	tracing.Install("test", nil, 0) // только W3C-пропагатор
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	pool, _ := upstream.NewPool("orders", upstream.Options{Discovery: upstream.Static{srv.URL}})
+	if _, err := call(t, pool, "/orders/1", ""); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 || spans[0].Name() != "upstream orders" {
+		t.Fatalf("expected one upstream span, got: %v", spans)
+	}
+	span := spans[0]
+	if !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Fatalf("expected instance to receive the attempt's span in traceparent, got %q", traceparent)
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["upstream.instance"] != srv.URL || attrs["upstream.breaker_state"] != "closed" {
+		t.Fatalf("expected instance and breaker state attributes, got: %v", attrs)
+	}
+}
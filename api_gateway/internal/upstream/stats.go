@@ -0,0 +1,83 @@
+package upstream
+
+import (
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+type InstanceStats struct {
+	URL          string           `json:"url"`
+	State        string           `json:"state"`
+	Stats        gobreaker.Counts `json:"stats"`
+	InFlight     int64            `json:"inFlight"`
+	Requests     int64            `json:"requests"`
+	Failures     int64            `json:"failures"`
+	Ejected      bool             `json:"ejected"`
+	EjectedUntil *time.Time       `json:"ejectedUntil,omitempty"`
+
+	Healthy        bool         `json:"healthy"`
+	LastProbe      *ProbeResult `json:"lastProbe,omitempty"`
+	LastTransition *time.Time   `json:"lastTransition,omitempty"`
+}
+
+func (p *Pool) Stats() []InstanceStats {
+	now := time.Now()
+
+	instances := p.Instances()
+	res := make([]InstanceStats, 0, len(instances))
+	for _, inst := range instances {
+		cb := inst.breaker("")
+		s := InstanceStats{
+			URL:      inst.URL(),
+			State:    cb.State().String(),
+			Stats:    cb.Counts(),
+			InFlight: inst.inFlight.Load(),
+			Requests: inst.requests.Load(),
+			Failures: inst.failures.Load(),
+			Ejected:  inst.ejected(now),
+		}
+		inst.health.mu.Lock()
+		s.Healthy = !inst.health.unhealthy
+		s.LastProbe = inst.health.lastProbe
+		s.LastTransition = inst.health.lastTransition
+		inst.health.mu.Unlock()
+
+		if s.Ejected {
+			until := time.Unix(0, inst.ejectedUntil.Load())
+			s.EjectedUntil = &until
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// State - состояние пула для старого поля "circuits" в /health: closed, если
+// хоть один экземпляр принимает запросы без ограничений, open - если ни один
+// (нездоровые и выброшенные экземпляры считаются открытыми).
+func (p *Pool) State() (string, gobreaker.Counts) {
+	var total gobreaker.Counts
+	closed, open := 0, 0
+	for _, s := range p.Stats() {
+		total.Requests += s.Stats.Requests
+		total.TotalSuccesses += s.Stats.TotalSuccesses
+		total.TotalFailures += s.Stats.TotalFailures
+		total.ConsecutiveSuccesses += s.Stats.ConsecutiveSuccesses
+		total.ConsecutiveFailures += s.Stats.ConsecutiveFailures
+
+		switch {
+		case !s.Healthy || s.Ejected || s.State == gobreaker.StateOpen.String():
+			open++
+		case s.State == gobreaker.StateClosed.String():
+			closed++
+		}
+	}
+
+	switch {
+	case closed > 0:
+		return gobreaker.StateClosed.String(), total
+	case open == len(p.Instances()):
+		return gobreaker.StateOpen.String(), total
+	}
+	return gobreaker.StateHalfOpen.String(), total
+}
@@ -0,0 +1,406 @@
+// Package config - загрузка конфигурации сервисов. Значения собираются слоями,
+// каждый следующий перекрывает предыдущий:
+//
+//	значения по умолчанию (профиль) -> YAML-файл -> переменные окружения -> флаги
+//
+// Ключи берутся из тегов yaml, вложенные структуры дают ключи через точку
+// (server.port). Имя переменной окружения - префикс + ключ в UPPER_SNAKE_CASE
+// (GATEWAY_SERVER_PORT) или явный тег env. Флаг называется так же, как ключ.
+// Списки структур (например, таблица маршрутов) задаются только в файле.
+// Поля с тегом secret:"true" в Describe не попадают в открытом виде.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProfileDevelopment = "development"
+	ProfileProduction  = "production"
+	ProfileTest        = "test"
+)
+
+// Profile определяет профиль запуска по APP_ENV, а если он не задан - по
+// NODE_ENV, который исторически выставляет docker-compose.
+func Profile() (string, error) {
+	profile := os.Getenv("APP_ENV")
+	if profile == "" {
+		profile = os.Getenv("NODE_ENV")
+	}
+	if profile == "" {
+		return ProfileDevelopment, nil
+	}
+
+	switch profile {
+	case ProfileDevelopment, ProfileProduction, ProfileTest:
+		return profile, nil
+	}
+	return "", fmt.Errorf("unknown profile %q (want %s, %s or %s)",
+		profile, ProfileDevelopment, ProfileProduction, ProfileTest)
+}
+
+// Validator реализует конфигурация сервиса; Load вызывает его последним.
+type Validator interface {
+	Validate() error
+}
+
+type Options struct {
+	Name      string   // имя программы в справке по флагам
+	EnvPrefix string   // префикс выведенных имён переменных, например "GATEWAY_"
+	Args      []string // аргументы без имени программы, обычно os.Args[1:]
+
+	// LookupEnv по умолчанию os.LookupEnv; подменяется в тестах.
+	LookupEnv func(key string) (string, bool)
+}
+
+// Load дополняет cfg - указатель на структуру, уже заполненную значениями по
+// умолчанию. Путь к файлу задаётся флагом -config или переменной
+// <prefix>CONFIG_FILE; без них файл не читается.
+func Load(cfg any, opts Options) error {
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	fields, err := collectFields(cfg)
+	if err != nil {
+		return err
+	}
+
+	// флаги разбираем первыми (нужен -config), а применяем последними
+	fs := flag.NewFlagSet(opts.Name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to YAML config file")
+	flagValues := make(map[string]string)
+	for _, f := range fields {
+		if f.fileOnly {
+			continue
+		}
+		isBool := f.value.Kind() == reflect.Bool
+		fs.Var(&recordedFlag{key: f.key, values: flagValues, isBool: isBool}, f.key, f.usage)
+	}
+	if err := fs.Parse(opts.Args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if *configFile == "" {
+		*configFile, _ = opts.LookupEnv(opts.EnvPrefix + "CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if f.fileOnly {
+			continue
+		}
+		name := f.envName(opts.EnvPrefix)
+		if raw, ok := opts.LookupEnv(name); ok {
+			if err := f.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", name, err))
+			}
+		}
+	}
+	for _, f := range fields {
+		if raw, ok := flagValues[f.key]; ok {
+			if err := f.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", f.key, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if v, ok := cfg.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return nil
+}
+
+// File - путь к файлу конфигурации, который прочитает Load с теми же опциями,
+// или "", если файл не задан. Нужен, чтобы следить за изменениями файла.
+func File(opts Options) string {
+	for i := 0; i < len(opts.Args); i++ {
+		arg := opts.Args[i]
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if !hasValue && i+1 < len(opts.Args) {
+			value = opts.Args[i+1]
+		}
+		return value
+	}
+
+	lookup := opts.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	path, _ := lookup(opts.EnvPrefix + "CONFIG_FILE")
+	return path
+}
+
+// Diff сравнивает две конфигурации построчно (как Describe) и возвращает
+// изменения вида "- key = old" / "+ key = new".
+func Diff(old, new any) []string {
+	before := strings.Split(strings.TrimSuffix(Describe(old), "\n"), "\n")
+	after := strings.Split(strings.TrimSuffix(Describe(new), "\n"), "\n")
+
+	inAfter := make(map[string]bool, len(after))
+	for _, line := range after {
+		inAfter[line] = true
+	}
+	inBefore := make(map[string]bool, len(before))
+	for _, line := range before {
+		inBefore[line] = true
+	}
+
+	var diff []string
+	for _, line := range before {
+		if !inAfter[line] {
+			diff = append(diff, "- "+line)
+		}
+	}
+	for _, line := range after {
+		if !inBefore[line] {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	return diff
+}
+
+// Describe - итоговая конфигурация построчно в виде "key = value",
+// отсортированная по ключу. Значения секретов заменены на "***".
+// Элементы списков структур выводятся по порядку: routes[0].path = ...
+func Describe(cfg any) string {
+	fields, err := collectFields(cfg)
+	if err != nil {
+		return err.Error()
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+
+	var b strings.Builder
+	describe(&b, fields)
+	return b.String()
+}
+
+func describe(b *strings.Builder, fields []field) {
+	for _, f := range fields {
+		if f.fileOnly {
+			for i := 0; i < f.value.Len(); i++ {
+				var items []field
+				walk(f.value.Index(i), fmt.Sprintf("%s[%d].", f.key, i), &items)
+				describe(b, items)
+			}
+			continue
+		}
+
+		value := f.String()
+		if f.secret && value != "" {
+			value = "***"
+		}
+		fmt.Fprintf(b, "%s = %s\n", f.key, value)
+	}
+}
+
+func loadFile(cfg any, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // опечатка в ключе - ошибка, а не молча проигнорированная настройка
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field - один лист структуры конфигурации.
+type field struct {
+	key      string
+	env      string // явное имя переменной из тега env
+	usage    string
+	secret   bool
+	fileOnly bool // список структур: ни переменной, ни флага
+	value    reflect.Value
+}
+
+func collectFields(cfg any) ([]field, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a pointer to struct, got %T", cfg)
+	}
+
+	var fields []field
+	walk(v.Elem(), "", &fields)
+	return fields, nil
+}
+
+func walk(v reflect.Value, prefix string, fields *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name) // так же ключ выводит yaml.v3
+		}
+		key := prefix + name
+
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			walk(fv, key+".", fields)
+			continue
+		}
+
+		*fields = append(*fields, field{
+			key:      key,
+			env:      sf.Tag.Get("env"),
+			usage:    sf.Tag.Get("usage"),
+			secret:   sf.Tag.Get("secret") == "true",
+			fileOnly: sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct,
+			value:    fv,
+		})
+	}
+}
+
+func (f field) envName(prefix string) string {
+	if f.env != "" {
+		return f.env
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	prevLower := false
+	for _, r := range f.key {
+		switch {
+		case r == '.' || r == '-':
+			b.WriteByte('_')
+			prevLower = false
+		case unicode.IsUpper(r):
+			if prevLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			prevLower = false
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+			prevLower = unicode.IsLower(r) || unicode.IsDigit(r)
+		}
+	}
+	return b.String()
+}
+
+func (f field) set(raw string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.CanUint():
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case v.CanFloat():
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (f field) String() string {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// recordedFlag запоминает значение флага, чтобы применить его после файла и окружения.
+type recordedFlag struct {
+	key    string
+	values map[string]string
+	isBool bool
+}
+
+func (f *recordedFlag) String() string   { return "" }
+func (f *recordedFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *recordedFlag) Set(raw string) error {
+	f.values[f.key] = raw
+	return nil
+}
@@ -0,0 +1,252 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"common/config"
+)
+
+type testConfig struct {
+	Server struct {
+		Port            int           `yaml:"port"`
+		ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	} `yaml:"server"`
+	Storage    string   `yaml:"storage" env:"LEGACY_STORAGE"`
+	Origins    []string `yaml:"origins"`
+	Debug      bool     `yaml:"debug"`
+	Rate       float64  `yaml:"rate"`
+	SigningKey string   `yaml:"signingKey" secret:"true"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Server.Port <= 0 {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func defaults() *testConfig {
+	cfg := &testConfig{Storage: "memory", Rate: 1}
+	cfg.Server.Port = 8000
+	cfg.Server.ShutdownTimeout = 5 * time.Second
+	return cfg
+}
+
+func envFrom(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad_LayersOverrideEachOther(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: 9000
+  shutdownTimeout: 10s
+storage: file
+rate: 2.5
+`)
+
+	cfg := defaults()
+	err := config.Load(cfg, config.Options{
+		EnvPrefix: "APP_",
+		Args:      []string{"-config", path, "-server.port=9100", "-debug"},
+		LookupEnv: envFrom(map[string]string{
+			"APP_SERVER_PORT":             "9050",
+			"APP_SERVER_SHUTDOWN_TIMEOUT": "15s",
+			"LEGACY_STORAGE":              "sqlite",
+			"APP_ORIGINS":                 "a.example, b.example",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if cfg.Server.Port != 9100 {
+		t.Errorf("expected flag to win, got port %d", cfg.Server.Port)
+	}
+	if cfg.Server.ShutdownTimeout != 15*time.Second {
+		t.Errorf("expected env to override file, got %v", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Storage != "sqlite" {
+		t.Errorf("expected explicit env name to be used, got %q", cfg.Storage)
+	}
+	if cfg.Rate != 2.5 {
+		t.Errorf("expected value from file, got %v", cfg.Rate)
+	}
+	if !cfg.Debug || len(cfg.Origins) != 2 || cfg.Origins[1] != "b.example" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoad_ConfigFileFromEnv(t *testing.T) {
+	path := writeFile(t, "storage: file\n")
+
+	cfg := defaults()
+	err := config.Load(cfg, config.Options{
+		EnvPrefix: "APP_",
+		LookupEnv: envFrom(map[string]string{"APP_CONFIG_FILE": path}),
+	})
+	if err != nil || cfg.Storage != "file" {
+		t.Fatalf("expected storage from file, got: %q, %v", cfg.Storage, err)
+	}
+}
+
+func TestLoad_FailsFast(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "bad env value", env: map[string]string{"APP_SERVER_PORT": "eighty"}, want: "APP_SERVER_PORT"},
+		{name: "bad duration", args: []string{"-server.shutdownTimeout=5"}, want: "server.shutdownTimeout"},
+		{name: "unknown key in file", file: "server:\n  prot: 80\n", want: "prot"},
+		{name: "validation", env: map[string]string{"APP_SERVER_PORT": "0"}, want: "invalid config"},
+		{name: "unknown flag", args: []string{"-nope=1"}, want: "nope"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if tc.file != "" {
+				args = append([]string{"-config", writeFile(t, tc.file)}, args...)
+			}
+
+			err := config.Load(defaults(), config.Options{
+				Name:      "test",
+				EnvPrefix: "APP_",
+				Args:      args,
+				LookupEnv: envFrom(tc.env),
+			})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error mentioning %q, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestDescribe_RedactsSecrets(t *testing.T) {
+	cfg := defaults()
+	cfg.SigningKey = "super-secret"
+
+	out := config.Describe(cfg)
+	if strings.Contains(out, "super-secret") {
+		t.Fatalf("expected secret to be redacted, got:\n%s", out)
+	}
+	for _, line := range []string{"signingKey = ***", "server.port = 8000", "server.shutdownTimeout = 5s", "storage = memory"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("expected %q in output, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	t.Setenv("NODE_ENV", "production")
+	if p, err := config.Profile(); err != nil || p != config.ProfileProduction {
+		t.Fatalf("expected NODE_ENV fallback, got: %q, %v", p, err)
+	}
+
+	t.Setenv("APP_ENV", "test")
+	if p, _ := config.Profile(); p != config.ProfileTest {
+		t.Fatalf("expected APP_ENV to win, got: %q", p)
+	}
+
+	t.Setenv("APP_ENV", "staging")
+	if _, err := config.Profile(); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+}
+
+type routesConfig struct {
+	Routes []struct {
+		Path    string        `yaml:"path"`
+		Timeout time.Duration `yaml:"timeout"`
+		Token   string        `yaml:"token" secret:"true"`
+	} `yaml:"routes"`
+}
+
+func TestLoad_ListOfStructsFromFileOnly(t *testing.T) {
+	path := writeFile(t, `
+routes:
+  - path: /a
+    timeout: 2s
+    token: hidden
+  - path: /b
+`)
+
+	cfg := &routesConfig{}
+	err := config.Load(cfg, config.Options{
+		EnvPrefix: "APP_",
+		Args:      []string{"-config", path},
+		LookupEnv: envFrom(map[string]string{"APP_ROUTES": "ignored"}),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(cfg.Routes) != 2 || cfg.Routes[0].Timeout != 2*time.Second || cfg.Routes[1].Path != "/b" {
+		t.Fatalf("unexpected routes: %+v", cfg.Routes)
+	}
+
+	want := "routes[0].path = /a\nroutes[0].timeout = 2s\nroutes[0].token = ***\n" +
+		"routes[1].path = /b\nroutes[1].timeout = 0s\nroutes[1].token = \n"
+	if got := config.Describe(cfg); got != want {
+		t.Fatalf("unexpected description:\n%s", got)
+	}
+
+	err = config.Load(&routesConfig{}, config.Options{Args: []string{"-routes=x"}})
+	if err == nil {
+		t.Fatalf("expected error for flag on a list of structs")
+	}
+}
+
+func TestFile(t *testing.T) {
+	env := envFrom(map[string]string{"APP_CONFIG_FILE": "/etc/env.yaml"})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-server.port=1", "-config", "/etc/a.yaml"}, want: "/etc/a.yaml"},
+		{args: []string{"--config=/etc/b.yaml"}, want: "/etc/b.yaml"},
+		{args: []string{"-debug"}, want: "/etc/env.yaml"},
+	}
+	for _, tc := range tests {
+		got := config.File(config.Options{EnvPrefix: "APP_", Args: tc.args, LookupEnv: env})
+		if got != tc.want {
+			t.Fatalf("args %v: expected %q, got %q", tc.args, tc.want, got)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := defaults()
+	after := defaults()
+	after.Server.Port = 9000
+	after.SigningKey = "changed"
+
+	diff := strings.Join(config.Diff(before, after), "\n")
+	want := "- server.port = 8000\n- signingKey = \n+ server.port = 9000\n+ signingKey = ***"
+	if diff != want {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if len(config.Diff(before, defaults())) != 0 {
+		t.Fatalf("expected no diff for equal configs")
+	}
+}
@@ -0,0 +1,103 @@
+// Package deadline передаёт оставшееся на запрос время между сервисами.
+// Исходящий запрос несёт в заголовке, сколько миллисекунд осталось у
+// вызывающего, а принимающий сервис ограничивает этим контекст обработки:
+// если клиент ушёл или время вышло, работа по цепочке прекращается.
+package deadline
+
+import (
+	"common/problem"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header - оставшееся время в миллисекундах.
+const Header = "X-Request-Timeout"
+
+// StatusClientClosedRequest - 499, см. problem.StatusClientClosedRequest.
+const StatusClientClosedRequest = problem.StatusClientClosedRequest
+
+// MaxTimeout - дольше сервис запрос не обрабатывает, сколько бы времени ни
+// оставалось у вызывающего.
+const MaxTimeout = time.Minute
+
+var invalidHeader = problem.Invalid(problem.FieldError{
+	Field:   Header,
+	Code:    "invalid",
+	Message: Header + " must be a positive number of milliseconds",
+})
+
+// Middleware ограничивает контекст запроса временем из заголовка Header (не
+// больше MaxTimeout). Без заголовка запрос не ограничивается; нечисловое или
+// неположительное значение - 400.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(Header)
+		if v == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms <= 0 {
+			problem.Write(w, r, invalidHeader)
+			return
+		}
+		// сравниваем в миллисекундах: огромное ms переполнило бы Duration
+		timeout := MaxTimeout
+		if ms < MaxTimeout.Milliseconds() {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Transport проставляет Header по дедлайну контекста запроса. Запрос, у
+// которого время уже вышло, не отправляется.
+type Transport struct {
+	Next http.RoundTripper
+}
+
+func NewTransport(next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{Next: next}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	at, ok := req.Context().Deadline()
+	if !ok {
+		return t.Next.RoundTrip(req)
+	}
+
+	// меньше миллисекунды в заголовке не передать, да и не успеть
+	left := time.Until(at)
+	if left < time.Millisecond {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, context.DeadlineExceeded
+	}
+
+	out := req.Clone(req.Context())
+	out.Header.Set(Header, strconv.FormatInt(left.Milliseconds(), 10))
+	return t.Next.RoundTrip(out)
+}
+
+// Status - код ответа для ошибки, вызванной контекстом запроса: 499, если
+// клиент ушёл, 504, если вышло время. ok == false - ошибка не из-за контекста.
+func Status(err error) (status int, ok bool) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, true
+	}
+	return 0, false
+}
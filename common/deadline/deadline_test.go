@@ -0,0 +1,115 @@
+package deadline_test
+
+import (
+	"common/deadline"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTransport_PropagatesRemainingTime(t *testing.T) {
+	var left time.Duration
+	downstream := httptest.NewServer(deadline.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		at, ok := r.Context().Deadline()
+		if !ok {
+			t.Errorf("expected downstream context to have a deadline")
+			return
+		}
+		left = time.Until(at)
+		ms, _ := strconv.Atoi(r.Header.Get(deadline.Header))
+		fmt.Fprint(w, ms)
+	})))
+	defer downstream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, downstream.URL, nil)
+
+	client := &http.Client{Transport: deadline.NewTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if left <= 0 || left > 500*time.Millisecond {
+		t.Fatalf("expected downstream deadline within the caller's, got %s", left)
+	}
+}
+
+func TestTransport_DoesNotSendExpiredRequests(t *testing.T) {
+	called := false
+	downstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer downstream.Close()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, downstream.URL, nil)
+
+	if _, err := deadline.NewTransport(nil).RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Fatalf("expected request not to be sent, got: %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+		ok   bool
+	}{
+		{err: fmt.Errorf("user check failed: %w", context.Canceled), want: deadline.StatusClientClosedRequest, ok: true},
+		{err: context.DeadlineExceeded, want: http.StatusGatewayTimeout, ok: true},
+		{err: errors.New("boom"), ok: false},
+	}
+	for _, tc := range tests {
+		if got, ok := deadline.Status(tc.err); got != tc.want || ok != tc.ok {
+			t.Fatalf("Status(%v) = %d, %v; want %d, %v", tc.err, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestMiddleware_ValidatesAndClampsHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		status int
+		left   time.Duration // 0 - без дедлайна
+	}{
+		{name: "no header", status: http.StatusOK},
+		{name: "valid", header: "500", status: http.StatusOK, left: 500 * time.Millisecond},
+		{name: "zero", header: "0", status: http.StatusBadRequest},
+		{name: "negative", header: "-5", status: http.StatusBadRequest},
+		{name: "not a number", header: "soon", status: http.StatusBadRequest},
+		// без ограничения time.Duration(ms)*time.Millisecond переполнился бы
+		{name: "huge", header: "9223372036854775807", status: http.StatusOK, left: deadline.MaxTimeout},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var left time.Duration
+			h := deadline.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if at, ok := r.Context().Deadline(); ok {
+					left = time.Until(at)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(deadline.Header, tc.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got: %d", tc.status, w.Code)
+			}
+			if left > tc.left || (tc.left > 0 && left < tc.left-time.Second) {
+				t.Fatalf("expected about %s left, got: %s", tc.left, left)
+			}
+		})
+	}
+}
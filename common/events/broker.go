@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Broker - куда relay отправляет события из outbox. Доставка at-least-once:
+// одно и то же событие (с тем же ID) может прийти повторно.
+type Broker interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+type Handler func(ctx context.Context, ev Event) error
+
+// LocalBroker доставляет события подписчикам внутри процесса синхронно.
+// Годится для тестов и для запуска без внешнего брокера. Истории событий не
+// хранит: в тестах опубликованное собирает подписчик.
+type LocalBroker struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler // тип события ("*" - все) -> подписчики
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe подписывает handler на тип события; "*" - на все события.
+func (b *LocalBroker) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+// Publish вызывает подписчиков по очереди и возвращает первую ошибку:
+// relay тогда оставит событие в outbox и повторит позже.
+func (b *LocalBroker) Publish(ctx context.Context, ev Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[ev.Type]...), b.handlers["*"]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogHandler пишет каждое событие в лог одной записью; request_id - запроса,
+// породившего событие (relay публикует вне запроса).
+func LogHandler(ctx context.Context, ev Event) error {
+	slog.InfoContext(ctx, "event published", "event_id", ev.ID, "type", ev.Type, "aggregate_id", ev.AggregateID, "request_id", ev.RequestID)
+	return nil
+}
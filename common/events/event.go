@@ -0,0 +1,83 @@
+// Package events - доменные события сервисов, outbox и доставка их в брокер.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Event - конверт, в котором событие лежит в outbox и уходит в брокер.
+// Формат Data определяется парой (Type, SchemaVersion), см. schemas/.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schemaVersion"`
+	Source        string          `json:"source"`
+	AggregateID   string          `json:"aggregateId"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	RequestID     string          `json:"requestId,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// New собирает событие. RequestID берётся из контекста (его кладёт chi
+// middleware.RequestID из заголовка X-Request-ID). Пустой aggregateID
+// репозиторий заменит на id созданной записи.
+func New(ctx context.Context, source, aggregateID string, payload Payload) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("marshal %s payload: %w", payload.EventType(), err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:            id,
+		Type:          payload.EventType(),
+		SchemaVersion: payload.SchemaVersion(),
+		Source:        source,
+		AggregateID:   aggregateID,
+		OccurredAt:    time.Now().UTC(),
+		RequestID:     middleware.GetReqID(ctx),
+		Data:          data,
+	}, nil
+}
+
+// Decode разбирает Data события в payload нужного типа.
+func (e Event) Decode(payload Payload) error {
+	if e.Type != payload.EventType() || e.SchemaVersion != payload.SchemaVersion() {
+		return fmt.Errorf("cannot decode %s v%d into %s v%d",
+			e.Type, e.SchemaVersion, payload.EventType(), payload.SchemaVersion())
+	}
+	return json.Unmarshal(e.Data, payload)
+}
+
+// FillAggregateID проставляет id созданной записи событиям, у которых его ещё нет.
+func FillAggregateID(evs []Event, id string) {
+	for i := range evs {
+		if evs[i].AggregateID == "" {
+			evs[i].AggregateID = id
+		}
+	}
+}
+
+// newID - случайный UUID v4.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
@@ -0,0 +1,160 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+
+	"common/events"
+)
+
+// Схема должна описывать ровно те поля, которые реально уходят в Data.
+func TestSchemas_MatchPayloads(t *testing.T) {
+	for _, p := range events.Known {
+		t.Run(p.EventType(), func(t *testing.T) {
+			raw, err := events.Schema(p.EventType(), p.SchemaVersion())
+			if err != nil {
+				t.Fatalf("expected schema for %s v%d, got: %v", p.EventType(), p.SchemaVersion(), err)
+			}
+
+			var schema struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			}
+			if err := json.Unmarshal(raw, &schema); err != nil {
+				t.Fatalf("expected valid json schema, got: %v", err)
+			}
+
+			data, err := json.Marshal(p)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			var fields map[string]json.RawMessage
+			_ = json.Unmarshal(data, &fields)
+
+			got := make([]string, 0, len(fields))
+			for f := range fields {
+				got = append(got, f)
+			}
+			sort.Strings(got)
+
+			want := append([]string(nil), schema.Required...)
+			sort.Strings(want)
+			if !slices.Equal(got, want) {
+				t.Fatalf("expected payload fields %v, got: %v", want, got)
+			}
+			for _, f := range got {
+				if _, ok := schema.Properties[f]; !ok {
+					t.Fatalf("expected property %q in schema", f)
+				}
+			}
+		})
+	}
+
+	if _, err := events.EnvelopeSchema(); err != nil {
+		t.Fatalf("expected envelope schema, got: %v", err)
+	}
+}
+
+func TestNew_DecodeRoundTrip(t *testing.T) {
+	ev, err := events.New(context.Background(), events.SourceOrders, "7",
+		events.OrderStatusChanged{UserID: 1, From: "created", To: "paid"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ev.ID == "" || ev.Type != events.TypeOrderStatusChanged || ev.SchemaVersion != 1 {
+		t.Fatalf("unexpected envelope: %+v", ev)
+	}
+
+	var p events.OrderStatusChanged
+	if err := ev.Decode(&p); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if p.To != "paid" || p.UserID != 1 {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+
+	if err := ev.Decode(&events.OrderDeleted{}); err == nil {
+		t.Fatalf("expected error decoding into another event type")
+	}
+}
+
+type failingBroker struct {
+	failOn    string
+	published []string
+}
+
+func (b *failingBroker) Publish(ctx context.Context, ev events.Event) error {
+	if ev.ID == b.failOn {
+		return errors.New("broker unavailable")
+	}
+	b.published = append(b.published, ev.ID)
+	return nil
+}
+
+func newEvents(t *testing.T, n int) []events.Event {
+	t.Helper()
+	evs := make([]events.Event, 0, n)
+	for i := 0; i < n; i++ {
+		ev, err := events.New(context.Background(), events.SourceUsers, "", events.UserUpdated{Name: "x"})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+func TestRelay_FlushStopsOnFailure(t *testing.T) {
+	evs := newEvents(t, 3)
+	outbox := &events.MemoryOutbox{}
+	outbox.Add(evs...)
+
+	broker := &failingBroker{failOn: evs[1].ID}
+	relay := events.NewRelay(outbox, broker, 0)
+
+	n, err := relay.Flush(context.Background())
+	if err == nil {
+		t.Fatalf("expected error from broker")
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 published event, got: %d", n)
+	}
+
+	pending, _ := outbox.PendingEvents(0)
+	if len(pending) != 2 || pending[0].ID != evs[1].ID {
+		t.Fatalf("expected failed event to stay first in outbox, got: %v", pending)
+	}
+
+	broker.failOn = ""
+	n, err = relay.Flush(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 published events, got: %d, %v", n, err)
+	}
+	if !slices.Equal(broker.published, []string{evs[0].ID, evs[1].ID, evs[2].ID}) {
+		t.Fatalf("expected events in outbox order, got: %v", broker.published)
+	}
+}
+
+func TestLocalBroker_Subscribe(t *testing.T) {
+	b := events.NewLocalBroker()
+
+	var typed, all int
+	b.Subscribe(events.TypeUserUpdated, func(ctx context.Context, ev events.Event) error { typed++; return nil })
+	b.Subscribe("*", func(ctx context.Context, ev events.Event) error { all++; return nil })
+
+	for _, ev := range newEvents(t, 2) {
+		if err := b.Publish(context.Background(), ev); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	other, _ := events.New(context.Background(), events.SourceUsers, "1", events.UserDeleted{Email: "a@b.c"})
+	_ = b.Publish(context.Background(), other)
+
+	if typed != 2 || all != 3 {
+		t.Fatalf("expected typed=2 all=3, got: %d %d", typed, all)
+	}
+}
@@ -0,0 +1,61 @@
+package events
+
+import "sync"
+
+// MemoryOutbox - outbox в памяти для репозиториев, которые сами живут в памяти.
+// Репозиторий вызывает Add под своей блокировкой, вместе с изменением данных.
+type MemoryOutbox struct {
+	mu      sync.Mutex
+	pending []Event
+	ids     map[string]bool
+}
+
+// Add пропускает события, которые уже лежат в outbox: при проигрывании
+// журнала поверх снапшота одно и то же событие может прийти дважды.
+func (o *MemoryOutbox) Add(evs ...Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.ids == nil {
+		o.ids = make(map[string]bool)
+	}
+	for _, ev := range evs {
+		if o.ids[ev.ID] {
+			continue
+		}
+		o.ids[ev.ID] = true
+		o.pending = append(o.pending, ev)
+	}
+}
+
+func (o *MemoryOutbox) PendingEvents(limit int) ([]Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(o.pending)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	return append([]Event(nil), o.pending[:n]...), nil
+}
+
+func (o *MemoryOutbox) MarkPublished(ids ...string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	done := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		done[id] = true
+	}
+
+	kept := o.pending[:0]
+	for _, ev := range o.pending {
+		if done[ev.ID] {
+			delete(o.ids, ev.ID)
+			continue
+		}
+		kept = append(kept, ev)
+	}
+	o.pending = kept
+	return nil
+}
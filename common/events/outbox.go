@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Outbox - хранилище ещё не опубликованных событий. Его реализуют репозитории:
+// события пишутся в той же транзакции, что и изменение состояния.
+type Outbox interface {
+	// PendingEvents отдаёт неопубликованные события в порядке записи.
+	PendingEvents(limit int) ([]Event, error)
+	MarkPublished(ids ...string) error
+}
+
+// Relay периодически вычитывает outbox и публикует события в брокер.
+type Relay struct {
+	outbox    Outbox
+	broker    Broker
+	interval  time.Duration
+	batchSize int
+}
+
+func NewRelay(outbox Outbox, broker Broker, interval time.Duration) *Relay {
+	return &Relay{outbox: outbox, broker: broker, interval: interval, batchSize: 100}
+}
+
+// Run крутится до отмены контекста. Перед выходом пытается дослать остаток.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if _, err := r.Flush(context.Background()); err != nil {
+				slog.ErrorContext(ctx, "outbox relay: final flush failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil {
+				slog.ErrorContext(ctx, "outbox relay: flush failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush публикует всё, что накопилось. Останавливается на первом событии,
+// которое не удалось опубликовать, чтобы не нарушать порядок.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		batch, err := r.outbox.PendingEvents(r.batchSize)
+		if err != nil {
+			return published, err
+		}
+		if len(batch) == 0 {
+			return published, nil
+		}
+
+		for _, ev := range batch {
+			if err := r.broker.Publish(ctx, ev); err != nil {
+				return published, err
+			}
+			if err := r.outbox.MarkPublished(ev.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+	}
+}
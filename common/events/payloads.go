@@ -0,0 +1,108 @@
+package events
+
+// Payload - данные конкретного события. Каждая пара (тип, версия) описана
+// JSON Schema в schemas/<type>.v<version>.json. Несовместимое изменение
+// полей - это новая версия, старую продолжаем публиковать, пока есть потребители.
+type Payload interface {
+	EventType() string
+	SchemaVersion() int
+}
+
+const (
+	SourceUsers  = "service_users"
+	SourceOrders = "service_orders"
+)
+
+const (
+	TypeUserRegistered     = "user.registered"
+	TypeUserCreated        = "user.created"
+	TypeUserUpdated        = "user.updated"
+	TypeUserDeleted        = "user.deleted"
+	TypeOrderCreated       = "order.created"
+	TypeOrderUpdated       = "order.updated"
+	TypeOrderStatusChanged = "order.status_changed"
+	TypeOrderDeleted       = "order.deleted"
+)
+
+// UserRegistered - пользователь зарегистрировался сам через /auth/register.
+type UserRegistered struct {
+	Email string   `json:"email"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func (UserRegistered) EventType() string  { return TypeUserRegistered }
+func (UserRegistered) SchemaVersion() int { return 1 }
+
+// UserCreated - пользователя завёл администратор через POST /users.
+type UserCreated struct {
+	Email string   `json:"email"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func (UserCreated) EventType() string  { return TypeUserCreated }
+func (UserCreated) SchemaVersion() int { return 1 }
+
+type UserUpdated struct {
+	Name string `json:"name"`
+}
+
+func (UserUpdated) EventType() string  { return TypeUserUpdated }
+func (UserUpdated) SchemaVersion() int { return 1 }
+
+type UserDeleted struct {
+	Email string `json:"email"`
+}
+
+func (UserDeleted) EventType() string  { return TypeUserDeleted }
+func (UserDeleted) SchemaVersion() int { return 1 }
+
+type OrderCreated struct {
+	UserID    int    `json:"userId"`
+	Status    string `json:"status"`
+	Total     int    `json:"total"`
+	ItemCount int    `json:"itemCount"`
+}
+
+func (OrderCreated) EventType() string  { return TypeOrderCreated }
+func (OrderCreated) SchemaVersion() int { return 1 }
+
+// OrderUpdated - поменялись название, описание или позиции заказа.
+type OrderUpdated struct {
+	UserID    int `json:"userId"`
+	Total     int `json:"total"`
+	ItemCount int `json:"itemCount"`
+}
+
+func (OrderUpdated) EventType() string  { return TypeOrderUpdated }
+func (OrderUpdated) SchemaVersion() int { return 1 }
+
+type OrderStatusChanged struct {
+	UserID int    `json:"userId"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+func (OrderStatusChanged) EventType() string  { return TypeOrderStatusChanged }
+func (OrderStatusChanged) SchemaVersion() int { return 1 }
+
+type OrderDeleted struct {
+	UserID int `json:"userId"`
+}
+
+func (OrderDeleted) EventType() string  { return TypeOrderDeleted }
+func (OrderDeleted) SchemaVersion() int { return 1 }
+
+// Known - все события, которые публикуют сервисы. По этому списку тест
+// проверяет, что у каждого есть схема.
+var Known = []Payload{
+	UserRegistered{},
+	UserCreated{},
+	UserUpdated{},
+	UserDeleted{},
+	OrderCreated{},
+	OrderUpdated{},
+	OrderStatusChanged{},
+	OrderDeleted{},
+}
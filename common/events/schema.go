@@ -0,0 +1,19 @@
+package events
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// Schema возвращает JSON Schema для данных события указанной версии.
+func Schema(eventType string, version int) ([]byte, error) {
+	return schemaFS.ReadFile(fmt.Sprintf("schemas/%s.v%d.json", eventType, version))
+}
+
+// EnvelopeSchema - схема самого конверта Event.
+func EnvelopeSchema() ([]byte, error) {
+	return schemaFS.ReadFile("schemas/envelope.v1.json")
+}
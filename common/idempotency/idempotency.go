@@ -0,0 +1,192 @@
+// Package idempotency - повтор POST с заголовком Idempotency-Key не выполняет
+// действие второй раз, а получает сохранённый ответ первого запроса. Ключи
+// живут в памяти процесса TTL и принадлежат пользователю: чужой ключ не
+// совпадёт с вашим.
+package idempotency
+
+import (
+	"bytes"
+	"common/problem"
+	"common/validate"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header - ключ, который клиент генерирует на одно действие и повторяет при
+// повторах запроса.
+const Header = "Idempotency-Key"
+
+// HeaderReplayed выставляется у ответа, взятого из хранилища.
+const HeaderReplayed = "Idempotent-Replayed"
+
+// MaxKeyLength - ключ длиннее считается ошибкой клиента (UUID - 36 символов).
+const MaxKeyLength = 255
+
+var (
+	invalidKey = problem.Invalid(problem.FieldError{
+		Field:   Header,
+		Code:    "invalid",
+		Message: Header + " must be 1-255 characters",
+	})
+	keyInUse = problem.New(http.StatusConflict, "idempotency_key_in_use",
+		"a request with this Idempotency-Key is still in progress")
+	keyReused = problem.New(http.StatusUnprocessableEntity, "idempotency_key_reused",
+		"Idempotency-Key was already used for a different request")
+)
+
+// Store - ключи и сохранённые ответы. Хранится в памяти: после рестарта и
+// между экземплярами сервиса ключи не видны.
+type Store struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+type entry struct {
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+
+	done   bool // false - первый запрос ещё выполняется
+	status int
+	header http.Header
+	body   []byte
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, entries: make(map[string]*entry)}
+}
+
+// begin резервирует ключ. Если ключ уже есть, возвращает его запись: с
+// ответом (done) или без - первый запрос ещё не закончился.
+func (s *Store) begin(key string, fp [sha256.Size]byte) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.entries {
+			if e.done && now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(s.ttl / 10)
+	}
+
+	if e, ok := s.entries[key]; ok && (!e.done || now.Before(e.expires)) {
+		copied := *e
+		return &copied, true
+	}
+	s.entries[key] = &entry{fingerprint: fp}
+	return nil, false
+}
+
+// finish сохраняет ответ. Ответы 5xx и 499 (клиент ушёл) не сохраняются:
+// ключ освобождается, и повтор выполнит действие заново.
+func (s *Store) finish(key string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status >= http.StatusInternalServerError || status == problem.StatusClientClosedRequest {
+		delete(s.entries, key)
+		return
+	}
+	e := s.entries[key]
+	e.done, e.status, e.header, e.body = true, status, header, body
+	e.expires = time.Now().Add(s.ttl)
+}
+
+// Middleware дедуплицирует запросы с Header. scope - владелец ключа
+// (обычно id пользователя, "" - анонимный запрос); ставится после
+// аутентификации. Запросы без ключа проходят как есть.
+func Middleware(store *Store, scope func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > MaxKeyLength {
+				problem.Write(w, r, invalidKey)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, validate.MaxBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					problem.Write(w, r, problem.BodyTooLarge)
+					return
+				}
+				problem.Write(w, r, problem.InvalidJSON)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// тот же ключ с другим запросом - ошибка клиента, а не повтор
+			fp := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+			storeKey := scope(r) + "\x00" + key
+
+			if e, ok := store.begin(storeKey, fp); ok {
+				switch {
+				case e.fingerprint != fp:
+					problem.Write(w, r, keyReused)
+				case !e.done:
+					problem.Write(w, r, keyInUse)
+				default:
+					for k, v := range e.header {
+						w.Header()[k] = v
+					}
+					w.Header().Set(HeaderReplayed, "true")
+					w.WriteHeader(e.status)
+					_, _ = w.Write(e.body)
+				}
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// паника - тоже 5xx: ключ освобождается
+				if p := recover(); p != nil {
+					store.finish(storeKey, http.StatusInternalServerError, nil, nil)
+					panic(p)
+				}
+				store.finish(storeKey, rec.status, rec.header, rec.body.Bytes())
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// recorder пишет ответ клиенту и запоминает его копию.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.header = r.ResponseWriter.Header().Clone()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,100 @@
+package idempotency_test
+
+import (
+	"common/idempotency"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// create отвечает 201 с номером вызова; X-User - владелец ключа.
+func newHandler(status int) (http.Handler, *atomic.Int32) {
+	var calls atomic.Int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"id":%d}`, n)
+	})
+	scope := func(r *http.Request) string { return r.Header.Get("X-User") }
+	return idempotency.Middleware(idempotency.NewStore(time.Hour), scope)(h), &calls
+}
+
+func post(h http.Handler, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_ReplaysResponse(t *testing.T) {
+	h, calls := newHandler(http.StatusCreated)
+
+	first := post(h, "1", "k1", `{"name":"a"}`)
+	again := post(h, "1", "k1", `{"name":"a"}`)
+	if calls.Load() != 1 {
+		t.Fatalf("expected handler to run once, got: %d", calls.Load())
+	}
+	if again.Code != http.StatusCreated || again.Body.String() != first.Body.String() ||
+		again.Header().Get("Content-Type") != "application/json" || again.Header().Get(idempotency.HeaderReplayed) != "true" {
+		t.Fatalf("expected replayed 201 %s, got: %d %s %v", first.Body, again.Code, again.Body, again.Header())
+	}
+
+	// ключ принадлежит пользователю, без ключа дедупликации нет
+	post(h, "2", "k1", `{"name":"a"}`)
+	post(h, "1", "", `{"name":"a"}`)
+	if calls.Load() != 3 {
+		t.Fatalf("expected other user and keyless request to run, got: %d calls", calls.Load())
+	}
+
+	if w := post(h, "1", "k1", `{"name":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a key reused with another body, got: %d", w.Code)
+	}
+	if w := post(h, "1", strings.Repeat("k", idempotency.MaxKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a too long key, got: %d", w.Code)
+	}
+}
+
+func TestMiddleware_ForgetsServerErrors(t *testing.T) {
+	h, calls := newHandler(http.StatusServiceUnavailable)
+
+	post(h, "1", "k1", `{}`)
+	post(h, "1", "k1", `{}`)
+	if calls.Load() != 2 {
+		t.Fatalf("expected 5xx not to be replayed, got: %d calls", calls.Load())
+	}
+}
+
+func TestMiddleware_KeyInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := idempotency.Middleware(idempotency.NewStore(time.Hour), func(*http.Request) string { return "" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		post(h, "", "k1", `{}`)
+		close(done)
+	}()
+	<-started
+	if w := post(h, "", "k1", `{}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while the first request runs, got: %d", w.Code)
+	}
+	close(release)
+	<-done
+
+	if w := post(h, "", "k1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected stored 201 after the first request, got: %d", w.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// requestState - то, что узнаётся по ходу обработки запроса и попадает в
+// его записи: пользователь после аутентификации, время ожидания сервисов.
+type requestState struct {
+	userID   atomic.Int64
+	upstream atomic.Int64 // наносекунды
+	attempts atomic.Int64
+}
+
+type stateKey struct{}
+
+func state(ctx context.Context) *requestState {
+	st, _ := ctx.Value(stateKey{}).(*requestState)
+	return st
+}
+
+// SetUser запоминает аутентифицированного пользователя запроса.
+func SetUser(ctx context.Context, userID int) {
+	if st := state(ctx); st != nil {
+		st.userID.Store(int64(userID))
+	}
+}
+
+// ObserveUpstream добавляет к запросу одну попытку вызова сервиса длительностью d.
+func ObserveUpstream(ctx context.Context, d time.Duration) {
+	if st := state(ctx); st != nil {
+		st.upstream.Add(int64(d))
+		st.attempts.Add(1)
+	}
+}
+
+// Middleware пишет запись о каждом запросе (вместо middleware.Logger).
+// Ставится после middleware.RequestID и tracing.Middleware, чтобы запись
+// получила их идентификаторы.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		st := &requestState{}
+		ctx := context.WithValue(r.Context(), stateKey{}, st)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("duration_ms", ms(time.Since(start))),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if n := st.attempts.Load(); n > 0 {
+			attrs = append(attrs,
+				slog.Float64("upstream_ms", ms(time.Duration(st.upstream.Load()))),
+				slog.Int64("upstream_attempts", n))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Default().LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
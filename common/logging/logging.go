@@ -0,0 +1,136 @@
+// Package logging - структурные логи на log/slog. Записи, сделанные с
+// контекстом запроса (slog.InfoContext и т.п.), сами получают request_id,
+// trace_id, user_id и шаблон маршрута. Пароли, токены и Authorization
+// вырезаются до вывода.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Форматы вывода.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config - секция log в конфигурации сервисов.
+type Config struct {
+	Level  string `yaml:"level" usage:"minimum log level: debug, info, warn or error"`
+	Format string `yaml:"format" usage:"log format: json or text"`
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Level))
+	}
+	if c.Format != FormatJSON && c.Format != FormatText {
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Format))
+	}
+	return errors.Join(errs...)
+}
+
+// Setup делает логгер сервиса логгером по умолчанию. Вывод пакета log
+// (log.Printf и т.п.) тоже идёт через него - уровнем info.
+func Setup(service string, cfg Config) *slog.Logger {
+	logger := New(os.Stderr, service, cfg)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal - замена log.Fatalf: пишет err уровнем error и завершает процесс.
+func Fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// New - логгер в w; некорректные уровень и формат заменяются на info и json.
+func New(w io.Writer, service string, cfg Config) *slog.Logger {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var h slog.Handler = slog.NewJSONHandler(w, opts)
+	if cfg.Format == FormatText {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{h}).With(slog.String("service", service))
+}
+
+// contextHandler дописывает к записи сведения о запросе из ctx.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if rid := middleware.GetReqID(ctx); rid != "" {
+		r.AddAttrs(slog.String("request_id", rid))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	if st := state(ctx); st != nil {
+		if uid := st.userID.Load(); uid != 0 {
+			r.AddAttrs(slog.Int64("user_id", uid))
+		}
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if route := rctx.RoutePattern(); route != "" {
+			r.AddAttrs(slog.String("route", route))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys - части имён полей и заголовков, значения которых не пишутся.
+var sensitiveKeys = []string{"authorization", "password", "token", "secret", "cookie"}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	if h, ok := a.Value.Any().(http.Header); ok {
+		clean := make(http.Header, len(h))
+		for k, v := range h {
+			if isSensitive(k) {
+				v = []string{redacted}
+			}
+			clean[k] = v
+		}
+		return slog.Any(a.Key, clean)
+	}
+	return a
+}
@@ -0,0 +1,97 @@
+package logging_test
+
+import (
+	"bytes"
+	"common/logging"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// capture подменяет логгер по умолчанию и возвращает записи в виде JSON.
+func capture(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, "test", logging.Config{Level: level, Format: logging.FormatJSON}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var res []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("expected JSON log line, got %q: %v", line, err)
+		}
+		res = append(res, rec)
+	}
+	return res
+}
+
+func TestMiddleware_LogsRequestWithCorrelation(t *testing.T) {
+	buf := capture(t, "info")
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(logging.Middleware)
+	r.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.SetUser(r.Context(), 42)
+		logging.ObserveUpstream(r.Context(), 3*time.Millisecond)
+		slog.InfoContext(r.Context(), "order loaded")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/7", nil))
+
+	recs := records(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("expected handler record and request record, got: %s", buf)
+	}
+	for _, rec := range recs {
+		if rec["request_id"] == "" || rec["route"] != "/orders/{id}" || rec["user_id"] != float64(42) || rec["service"] != "test" {
+			t.Fatalf("expected record to carry request id, route, user and service, got: %v", rec)
+		}
+	}
+	req := recs[1]
+	if req["msg"] != "request" || req["status"] != float64(404) || req["upstream_attempts"] != float64(1) || req["duration_ms"] == nil {
+		t.Fatalf("expected request record with status and upstream timings, got: %v", req)
+	}
+}
+
+func TestRedactsSecrets(t *testing.T) {
+	buf := capture(t, "debug")
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc.def.ghi")
+	h.Set("Accept", "application/json")
+	slog.Debug("login", "password", "hunter2", "refresh_token", "r-123", "headers", h, "email", "a@example.com")
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "r-123", "abc.def.ghi"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("expected %q to be redacted, got: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "application/json") || !strings.Contains(out, "a@example.com") {
+		t.Fatalf("expected other fields to be kept, got: %s", out)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (logging.Config{Level: "warn", Format: "text"}).Validate(); err != nil {
+		t.Fatalf("expected config to be valid, got: %v", err)
+	}
+	if err := (logging.Config{Level: "loud", Format: "xml"}).Validate(); err == nil {
+		t.Fatalf("expected error for unknown level and format")
+	}
+}
@@ -0,0 +1,72 @@
+// Package metrics - общие HTTP-метрики сервисов в формате Prometheus.
+// Маршрут в метках - шаблон chi (/orders/{id}), а не сырой путь: иначе
+// каждый id давал бы новый временной ряд.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute - метка для запросов, не попавших ни в один маршрут.
+const unmatchedRoute = "unmatched"
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Middleware считает запросы и их длительность. Ставится на корневой роутер
+// chi: шаблон маршрута известен только после того, как запрос обработан.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"method": r.Method,
+			"route":  Route(r),
+			"status": strconv.Itoa(status),
+		}
+		requests.With(labels).Inc()
+		duration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Route - шаблон маршрута chi, по которому обработан запрос.
+func Route(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return unmatchedRoute
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
+
+// Handler - GET /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"common/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Order not found"}`, http.StatusNotFound)
+	})
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/orders/{id}",status="404"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/orders/{id}",status="404"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), `route="/orders/1"`) {
+		t.Fatalf("expected raw paths not to be used as labels")
+	}
+}
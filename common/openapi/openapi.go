@@ -0,0 +1,294 @@
+// Package openapi - OpenAPI 3 спецификация сервиса, собранная из описаний
+// маршрутов. Схемы тел и ответов выводятся из типов model: имена полей - по
+// json-тегам, ограничения - по тегам validate, так что спецификация не
+// расходится с тем, что сервис на самом деле принимает.
+package openapi
+
+import (
+	"common/problem"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// Имена схем аутентификации в Components.SecuritySchemes.
+const (
+	BearerAuth      = "bearerAuth"      // JWT access-токен от users-service
+	GatewayIdentity = "gatewayIdentity" // X-User-ID, который выставляет api_gateway
+	ServiceAuth     = "serviceToken"    // общий токен для вызовов между сервисами
+)
+
+var (
+	BearerScheme   = SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	IdentityScheme = SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "X-User-ID",
+		Description: "id пользователя после проверки токена в api_gateway; роли - в X-User-Roles",
+	}
+	ServiceScheme = SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "X-Service-Token",
+		Description: "общий для сервисов секрет; маршрут вызывают только другие сервисы",
+	}
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	types map[string]reflect.Type // какой тип зарегистрирован под именем схемы
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem - операции одного пути; ключ - метод в нижнем регистре.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Roles       []string              `json:"x-roles,omitempty"` // нужна хотя бы одна из ролей
+	Owner       string                `json:"x-owner,omitempty"` // параметр с id пользователя: владелец проходит без ролей
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query или header
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Route - описание маршрута, из которого Add собирает операцию.
+type Route struct {
+	Method  string
+	Path    string // шаблон chi: /orders/{id}
+	ID      string // operationId
+	Summary string
+	Tag     string
+
+	Security string   // схема из Components.SecuritySchemes; пусто - маршрут публичный
+	Roles    []string // нужна хотя бы одна из ролей
+	Owner    string   // параметр с id пользователя: владелец проходит без ролей
+
+	// Params - параметры пути и query. Параметры пути, которых здесь нет,
+	// описываются строками.
+	Params []Parameter
+	Body   any // значение типа тела: model.LoginRequest{}; nil - без тела
+	// BodyOptional - тело можно не передавать.
+	BodyOptional bool
+
+	Status      int    // код успешного ответа; 0 - 200
+	Response    any    // значение типа ответа; nil - без схемы
+	ContentType string // тип успешного ответа; пусто - application/json
+	// Responses - другие ответы не в формате problem: 409 с записью аудита.
+	Responses map[int]any
+	// Errors - коды ответов application/problem+json. 400 для тела, 401/403
+	// для Security/Roles и 500 добавляются сами.
+	Errors []int
+}
+
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+		types: make(map[string]reflect.Type),
+	}
+}
+
+// Add описывает маршрут. Повторное описание того же метода и пути - ошибка
+// программиста, как и у chi.
+func (d *Document) Add(rt Route) {
+	method := strings.ToLower(rt.Method)
+	if _, ok := d.Paths[rt.Path][method]; ok {
+		panic(fmt.Sprintf("openapi: %s %s is described twice", rt.Method, rt.Path))
+	}
+
+	op := &Operation{
+		OperationID: rt.ID,
+		Summary:     rt.Summary,
+		Parameters:  pathParams(rt.Path, rt.Params),
+		Responses:   make(map[string]*Response),
+		Roles:       rt.Roles,
+		Owner:       rt.Owner,
+	}
+	if rt.Tag != "" {
+		op.Tags = []string{rt.Tag}
+	}
+
+	errs := append([]int(nil), rt.Errors...)
+	if rt.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: !rt.BodyOptional,
+			Content:  map[string]MediaType{"application/json": {Schema: d.SchemaOf(rt.Body)}},
+		}
+		errs = append(errs, http.StatusBadRequest, http.StatusRequestEntityTooLarge)
+	}
+	if rt.Security != "" {
+		op.Security = []map[string][]string{{rt.Security: {}}}
+		errs = append(errs, http.StatusUnauthorized)
+	}
+	if len(rt.Roles) > 0 {
+		errs = append(errs, http.StatusForbidden)
+	}
+	errs = append(errs, http.StatusInternalServerError)
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = d.response(status, rt.ContentType, rt.Response)
+	for code, v := range rt.Responses {
+		op.Responses[strconv.Itoa(code)] = d.response(code, "", v)
+	}
+	d.AddErrors(op, errs...)
+
+	if d.Paths[rt.Path] == nil {
+		d.Paths[rt.Path] = make(PathItem)
+	}
+	d.Paths[rt.Path][method] = op
+}
+
+// AddErrors добавляет к операции ответы application/problem+json. Уже
+// описанные коды не трогает.
+func (d *Document) AddErrors(op *Operation, codes ...int) {
+	ref := d.SchemaOf(problem.Problem{})
+	for _, code := range codes {
+		key := strconv.Itoa(code)
+		if _, ok := op.Responses[key]; ok {
+			continue
+		}
+		op.Responses[key] = &Response{
+			Description: statusText(code),
+			Content:     map[string]MediaType{problem.ContentType: {Schema: ref}},
+		}
+	}
+}
+
+func (d *Document) response(status int, contentType string, v any) *Response {
+	resp := &Response{Description: statusText(status)}
+	switch {
+	case v != nil:
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		resp.Content = map[string]MediaType{contentType: {Schema: d.SchemaOf(v)}}
+	case contentType != "":
+		resp.Content = map[string]MediaType{contentType: {Schema: &Schema{Type: "string"}}}
+	}
+	return resp
+}
+
+// pathParams - параметры пути в порядке шаблона, затем query.
+func pathParams(path string, params []Parameter) []Parameter {
+	var res []Parameter
+	for _, name := range PathParams(path) {
+		p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, given := range params {
+			if given.In == "path" && given.Name == name {
+				p = given
+				p.Required = true
+			}
+		}
+		res = append(res, p)
+	}
+	for _, p := range params {
+		if p.In != "path" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// PathParams - имена параметров шаблона chi: /orders/{id}/pay -> [id].
+func PathParams(path string) []string {
+	var params []string
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			params = append(params, s[1:len(s)-1])
+		}
+	}
+	return params
+}
+
+// PathInt - целочисленный параметр пути.
+func PathInt(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Description: description, Schema: &Schema{Type: "integer"}}
+}
+
+// IdempotencyKey - необязательный заголовок Idempotency-Key у create-запросов
+// (common/idempotency).
+var IdempotencyKey = Parameter{
+	Name: "Idempotency-Key", In: "header", Schema: &Schema{Type: "string"},
+	Description: "до 255 символов; повтор с тем же ключом вернёт ответ первого запроса, не создавая дубль",
+}
+
+// Query - необязательный параметр query-строки.
+func Query(name string, schema *Schema, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func statusText(code int) string {
+	if text := http.StatusText(code); text != "" {
+		return text
+	}
+	return strconv.Itoa(code)
+}
+
+// Handler отдаёт спецификацию как есть; документ после сборки не меняется.
+func (d *Document) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d)
+	}
+}
@@ -0,0 +1,162 @@
+package openapi_test
+
+import (
+	"common/openapi"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type totals struct {
+	Total int `json:"total"`
+}
+
+type item struct {
+	Title    string `json:"title" validate:"required,max=200"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+type order struct {
+	ID     int     `json:"id"`
+	Status string  `json:"status" validate:"omitempty,oneof=created paid"`
+	Email  string  `json:"email,omitempty" validate:"email"`
+	Items  []item  `json:"items" validate:"required,max=10"`
+	Cursor *string `json:"cursor"`
+	totals
+	CreatedAt time.Time `json:"createdAt"`
+	Secret    string    `json:"-"`
+}
+
+func TestSchemaOf_FollowsJSONAndValidateTags(t *testing.T) {
+	doc := openapi.New("Test", "1")
+	ref := doc.SchemaOf(order{})
+	if ref.Ref != "#/components/schemas/order" {
+		t.Fatalf("expected a reference to order, got: %+v", ref)
+	}
+
+	s := doc.Components.Schemas["order"]
+	if strings.Join(s.Required, ",") != "items" {
+		t.Fatalf("expected only items to be required, got: %v", s.Required)
+	}
+	if _, ok := s.Properties["Secret"]; ok {
+		t.Fatalf("expected json:\"-\" field to be skipped")
+	}
+	if s.Properties["total"] == nil || s.Properties["createdAt"].Format != "date-time" {
+		t.Fatalf("expected embedded total and date-time createdAt, got: %+v", s.Properties)
+	}
+	if got := s.Properties["status"].Enum; strings.Join(got, " ") != "created paid" {
+		t.Fatalf("expected status enum, got: %v", got)
+	}
+	if s.Properties["email"].Format != "email" || !s.Properties["cursor"].Nullable {
+		t.Fatalf("expected email format and nullable cursor, got: %+v %+v", s.Properties["email"], s.Properties["cursor"])
+	}
+	if items := s.Properties["items"]; *items.MaxItems != 10 || items.Items.Ref != "#/components/schemas/item" {
+		t.Fatalf("expected maxItems 10 of item, got: %+v", items)
+	}
+	it := doc.Components.Schemas["item"]
+	if *it.Properties["title"].MaxLength != 200 || *it.Properties["quantity"].Minimum != 1 {
+		t.Fatalf("expected title maxLength and quantity minimum, got: %+v", it.Properties)
+	}
+}
+
+func TestSchemaOf_DescribesMapsByValue(t *testing.T) {
+	doc := openapi.New("Test", "1")
+	s := doc.SchemaOf(map[string]any{"id": 0, "message": ""})
+	if s.Properties["id"].Type != "integer" || s.Properties["message"].Type != "string" {
+		t.Fatalf("expected id integer and message string, got: %+v", s.Properties)
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/orders/{id}", func(http.ResponseWriter, *http.Request) {})
+	r.Post("/orders", func(http.ResponseWriter, *http.Request) {})
+
+	doc := openapi.New("Test", "1")
+	doc.Add(openapi.Route{Method: http.MethodGet, Path: "/orders/{id}", Response: order{}})
+	doc.Add(openapi.Route{Method: http.MethodDelete, Path: "/orders/{id}"})
+
+	err := doc.CheckRoutes(r)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"POST /orders is not described", "describes DELETE /orders/{id}"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got: %v", want, err)
+		}
+	}
+
+	doc.Add(openapi.Route{Method: http.MethodPost, Path: "/orders", Body: order{}})
+	r.Delete("/orders/{id}", func(http.ResponseWriter, *http.Request) {})
+	if err := doc.CheckRoutes(r); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestAdd_DescribesAuthAndErrors(t *testing.T) {
+	doc := openapi.New("Test", "1")
+	doc.Add(openapi.Route{
+		Method:   http.MethodPost,
+		Path:     "/orders/{id}/pay",
+		Security: openapi.BearerAuth,
+		Roles:    []string{"admin"},
+		Params:   []openapi.Parameter{openapi.PathInt("id", "")},
+		Body:     item{},
+		Errors:   []int{http.StatusNotFound},
+	})
+
+	op := doc.Operation(http.MethodPost, "/orders/{orderId}/pay")
+	if op == nil {
+		t.Fatal("expected operation to be found regardless of parameter names")
+	}
+	for _, code := range []string{"200", "400", "401", "403", "404", "413", "500"} {
+		if op.Responses[code] == nil {
+			t.Fatalf("expected %s response, got: %v", code, op.Responses)
+		}
+	}
+	if op.Responses["404"].Content["application/problem+json"].Schema.Ref != "#/components/schemas/Problem" {
+		t.Fatalf("expected errors to be problems, got: %+v", op.Responses["404"])
+	}
+	if len(op.Security) != 1 || op.Parameters[0].Schema.Type != "integer" {
+		t.Fatalf("expected bearer security and integer id, got: %+v %+v", op.Security, op.Parameters)
+	}
+}
+
+type user struct {
+	ID    int      `json:"id"`
+	Roles []string `json:"roles"`
+}
+
+func TestImport_RenamesConflictingSchemas(t *testing.T) {
+	users := openapi.New("Users", "1")
+	users.Add(openapi.Route{Method: http.MethodGet, Path: "/users/{id}", Response: user{}})
+	users.Add(openapi.Route{Method: http.MethodGet, Path: "/internal", Response: order{}})
+
+	gw := openapi.New("Gateway", "1")
+	// у gateway своя, урезанная схема с тем же именем
+	gw.Components.Schemas["user"] = &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{"id": {Type: "integer"}}}
+
+	op, err := gw.Import(users, "Users", http.MethodGet, "/users/{id}", "/users/{userId}")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if op.Parameters[0].Name != "userId" {
+		t.Fatalf("expected path parameter to be renamed, got: %+v", op.Parameters)
+	}
+	if ref := op.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/Usersuser" {
+		t.Fatalf("expected reference to the renamed schema, got: %s", ref)
+	}
+	if gw.Components.Schemas["Usersuser"].Properties["roles"] == nil {
+		t.Fatalf("expected imported schema under the new name")
+	}
+	if _, ok := gw.Components.Schemas["order"]; ok {
+		t.Fatalf("expected schemas of other operations not to be imported")
+	}
+
+	if op, err := gw.Import(users, "Users", http.MethodPost, "/users", "/users"); op != nil || err != nil {
+		t.Fatalf("expected nothing for an undescribed operation, got: %v, %v", op, err)
+	}
+}
@@ -0,0 +1,180 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CheckRoutes сверяет спецификацию с маршрутами роутера: каждый маршрут
+// описан и каждое описание соответствует маршруту.
+func (d *Document) CheckRoutes(r chi.Routes) error {
+	registered := make(map[string]bool)
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	described := make(map[string]bool)
+	for path, item := range d.Paths {
+		for method := range item {
+			described[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(registered) {
+		if !described[key] {
+			errs = append(errs, fmt.Errorf("route %s is not described in the spec", key))
+		}
+	}
+	for _, key := range sortedKeys(described) {
+		if !registered[key] {
+			errs = append(errs, fmt.Errorf("spec describes %s, but there is no such route", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Operation ищет операцию по методу и шаблону пути. Имена параметров не
+// важны: /users/{userId} найдёт /users/{id}.
+func (d *Document) Operation(method, path string) *Operation {
+	want := pathShape(path)
+	for p, item := range d.Paths {
+		if pathShape(p) == want {
+			return item[strings.ToLower(method)]
+		}
+	}
+	return nil
+}
+
+// Import копирует в d операцию src, описанную для method и srcPath, под
+// путём path (параметры переименовываются по порядку) вместе со схемами, на
+// которые она ссылается. Схема, которая в d уже есть, но с другим
+// содержимым, копируется под именем prefix+имя. Возвращает скопированную
+// операцию или nil, если в src её нет.
+func (d *Document) Import(src *Document, prefix, method, srcPath, path string) (*Operation, error) {
+	op := src.Operation(method, srcPath)
+	if op == nil {
+		return nil, nil
+	}
+	if len(PathParams(srcPath)) != len(PathParams(path)) {
+		return nil, fmt.Errorf("%s and %s have different path parameters", srcPath, path)
+	}
+
+	raw, err := json.Marshal(op)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string][]byte)
+	pending := refs(raw)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := schemas[name]; ok {
+			continue
+		}
+		s, ok := src.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("%s refers to unknown schema %s", src.Info.Title, name)
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		schemas[name] = b
+		pending = append(pending, refs(b)...)
+	}
+
+	var renames []string
+	for name, s := range src.Components.Schemas {
+		if _, used := schemas[name]; !used {
+			continue
+		}
+		if known, ok := d.Components.Schemas[name]; ok && !sameSchema(known, s) {
+			renames = append(renames, `"`+refPrefix+name+`"`, `"`+refPrefix+prefix+name+`"`)
+		}
+	}
+	rename := strings.NewReplacer(renames...)
+
+	for name, b := range schemas {
+		var s Schema
+		if err := json.Unmarshal([]byte(rename.Replace(string(b))), &s); err != nil {
+			return nil, err
+		}
+		if known, ok := d.Components.Schemas[name]; ok && !sameSchema(known, &s) {
+			name = prefix + name
+			if known, ok := d.Components.Schemas[name]; ok && !sameSchema(known, &s) {
+				return nil, fmt.Errorf("schema %s from %s conflicts with an existing one", name, src.Info.Title)
+			}
+		}
+		d.Components.Schemas[name] = &s
+	}
+
+	var cp Operation
+	if err := json.Unmarshal([]byte(rename.Replace(string(raw))), &cp); err != nil {
+		return nil, err
+	}
+	names := PathParams(path)
+	i := 0
+	for j := range cp.Parameters {
+		if cp.Parameters[j].In == "path" && i < len(names) {
+			cp.Parameters[j].Name = names[i]
+			i++
+		}
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+	d.Paths[path][strings.ToLower(method)] = &cp
+	return &cp, nil
+}
+
+var refPattern = regexp.MustCompile(`"\$ref":"` + regexp.QuoteMeta(refPrefix) + `([^"]+)"`)
+
+// refs - имена схем, на которые ссылается JSON-фрагмент.
+func refs(b []byte) []string {
+	var names []string
+	for _, m := range refPattern.FindAllSubmatch(b, -1) {
+		names = append(names, string(m[1]))
+	}
+	return names
+}
+
+// sameSchema сравнивает схемы по JSON: схема, собранная по типу, и та же
+// схема, прочитанная из чужого документа, различаются пустыми map и срезами.
+func sameSchema(a, b *Schema) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// pathShape - шаблон пути без имён параметров: /users/{}.
+func pathShape(path string) string {
+	parts := strings.Split(path, "/")
+	for i, s := range parts {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			parts[i] = "{}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,202 @@
+package openapi
+
+import (
+	"common/validate"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema - подмножество JSON Schema из OpenAPI 3.0.
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+
+	Enum      []string `json:"enum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Minimum   *int     `json:"minimum,omitempty"`
+	Maximum   *int     `json:"maximum,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf - схема значения v. Именованные структуры попадают в
+// Components.Schemas и возвращаются ссылкой. Для полей-интерфейсов и
+// map с ключами схема строится по самому значению: так описываются ответы
+// вида map[string]any{"id": 0, "message": ""}. Указатель на структуру
+// описывается как сама структура: new(model.LoginRequest) - то же тело.
+func (d *Document) SchemaOf(v any) *Schema {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return d.schema(rv.Type(), rv)
+}
+
+func (d *Document) schema(t reflect.Type, v reflect.Value) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		var elem reflect.Value
+		if v.IsValid() && !v.IsNil() {
+			elem = v.Elem()
+		}
+		s := d.schema(t.Elem(), elem)
+		if s.Ref != "" {
+			// рядом с $ref остальные ключи игнорируются
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case t.Kind() == reflect.Interface:
+		if v.IsValid() && !v.IsNil() {
+			return d.schema(v.Elem().Type(), v.Elem())
+		}
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		var elem reflect.Value
+		if v.IsValid() && v.Len() > 0 {
+			elem = v.Index(0)
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem(), elem)}
+	case reflect.Map:
+		if v.IsValid() && v.Len() > 0 {
+			return d.mapSchema(v)
+		}
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem(), reflect.Value{})}
+	case reflect.Struct:
+		if t.Name() == "" || hasInterface(t) {
+			return d.structSchema(t, v)
+		}
+		return d.component(t)
+	}
+	panic(fmt.Sprintf("openapi: %s is not supported", t))
+}
+
+// component регистрирует именованную структуру и возвращает ссылку на неё.
+func (d *Document) component(t reflect.Type) *Schema {
+	name := t.Name()
+	ref := &Schema{Ref: refPrefix + name}
+	if known, ok := d.types[name]; ok {
+		if known != t {
+			panic(fmt.Sprintf("openapi: schema %s is both %s and %s", name, known, t))
+		}
+		return ref
+	}
+	d.types[name] = t
+	d.Components.Schemas[name] = d.structSchema(t, reflect.Value{})
+	return ref
+}
+
+func (d *Document) structSchema(t reflect.Type, v reflect.Value) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(s, t, v)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type, v reflect.Value) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+		// встроенная структура без json-имени раскрывается, как у encoding/json
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+			d.addFields(s, sf.Type, fv)
+			continue
+		}
+		name := validate.JSONName(sf)
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+
+		fs := d.schema(sf.Type, fv)
+		rules, _, err := validate.ParseTag(sf.Tag.Get("validate"))
+		if err != nil {
+			panic(fmt.Sprintf("openapi: %s.%s: %v", t.Name(), sf.Name, err))
+		}
+		for _, r := range rules {
+			if r.Name == "required" {
+				s.Required = append(s.Required, name)
+				continue
+			}
+			constrain(fs, r)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+func (d *Document) mapSchema(v reflect.Value) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, key := range v.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		s.Properties[name] = d.schema(v.Type().Elem(), v.MapIndex(key))
+		s.Required = append(s.Required, name)
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// constrain переносит правило validate в схему поля.
+func constrain(s *Schema, r validate.Rule) {
+	n := r.N
+	switch {
+	case r.Name == "email":
+		s.Format = "email"
+	case r.Name == "oneof":
+		s.Enum = strings.Fields(r.Arg)
+	case s.Type == "string" && r.Name == "min":
+		s.MinLength = &n
+	case s.Type == "string" && r.Name == "max":
+		s.MaxLength = &n
+	case s.Type == "array" && r.Name == "min":
+		s.MinItems = &n
+	case s.Type == "array" && r.Name == "max":
+		s.MaxItems = &n
+	case r.Name == "min":
+		s.Minimum = &n
+	case r.Name == "max":
+		s.Maximum = &n
+	}
+}
+
+// hasInterface - у структуры есть поле any: её схема зависит от значения,
+// и зарегистрировать её одной схемой нельзя.
+func hasInterface(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Interface {
+			return true
+		}
+	}
+	return false
+}
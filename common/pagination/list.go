@@ -0,0 +1,95 @@
+package pagination
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Item - элемент списка, который умеет отдавать ключи сортировки.
+type Item interface {
+	// SortKey - значение поля в виде строки, которая сравнивается так же, как
+	// само значение (для чисел и времени - IntKey и TimeKey).
+	SortKey(field string) string
+	SortID() int
+}
+
+// IntKey кодирует n так, что строки сравниваются так же, как числа, включая
+// отрицательные: знаковый бит инвертируется, и int64 превращается в uint64
+// того же порядка, дополненный нулями до 20 цифр.
+func IntKey(n int64) string {
+	return fmt.Sprintf("%020d", uint64(n)^(1<<63))
+}
+
+func TimeKey(t time.Time) string {
+	return IntKey(t.UnixNano())
+}
+
+// ParseIntKey - обратное к IntKey; ключ из курсора приходит от клиента.
+func ParseIntKey(key string) (int64, error) {
+	if len(key) != 20 {
+		return 0, ErrInvalidCursor
+	}
+	u, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return int64(u ^ (1 << 63)), nil
+}
+
+// Slice сортирует items по fields (id ASC - последним) и отдаёт не больше
+// limit элементов строго после after (limit 0 - все). items сортируется на месте.
+func Slice[T Item](items []T, fields []SortField, after *Cursor, limit int) []T {
+	sort.Slice(items, func(i, j int) bool {
+		return compare(items[i], sortKeys(items[j], fields), items[j].SortID(), fields) < 0
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return compare(items[i], after.Keys, after.ID, fields) > 0
+		})
+	}
+
+	end := len(items)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	return items[start:end]
+}
+
+// compare сравнивает элемент с позицией (keys, id) в порядке сортировки.
+func compare(item Item, keys []string, id int, fields []SortField) int {
+	for i, f := range fields {
+		k := item.SortKey(f.Field)
+		if k == keys[i] {
+			continue
+		}
+		less := k < keys[i]
+		if f.Desc {
+			less = !less
+		}
+		if less {
+			return -1
+		}
+		return 1
+	}
+
+	switch {
+	case item.SortID() < id:
+		return -1
+	case item.SortID() > id:
+		return 1
+	}
+	return 0
+}
+
+func sortKeys(item Item, fields []SortField) []string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = item.SortKey(f.Field)
+	}
+	return keys
+}
@@ -0,0 +1,145 @@
+// Package pagination - курсорная (keyset) пагинация списков: разбор limit,
+// sort и cursor из запроса, непрозрачный курсор и сортировка списков в памяти.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidLimit  = errors.New("invalid limit")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// SortField - одно поле сортировки; в query-строке "-name" означает DESC.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Cursor - ключи сортировки последнего отданного элемента и его id (tie-breaker).
+type Cursor struct {
+	Keys []string
+	ID   int
+}
+
+// Request - сырые параметры пагинации из запроса, разбирает их Parse.
+type Request struct {
+	Limit  int
+	Cursor string
+	Sort   string
+}
+
+// Params - разобранный Request.
+type Params struct {
+	Limit int
+	Sort  []SortField // id ASC всегда добавляется последним
+	After *Cursor     // nil - с начала
+	spec  string
+}
+
+// cursorPayload - то, что лежит внутри непрозрачного курсора. Сортировку кладём
+// туда же, чтобы курсор от одной сортировки нельзя было подсунуть к другой.
+type cursorPayload struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+	ID   int      `json:"id"`
+}
+
+// Parse проверяет limit, разрешённые поля сортировки и курсор. aliases -
+// старые имена полей (nil - их нет).
+func Parse(req Request, allowed []string, aliases map[string]string) (Params, error) {
+	var p Params
+
+	switch {
+	case req.Limit == 0:
+		p.Limit = DefaultLimit
+	case req.Limit < 0 || req.Limit > MaxLimit:
+		return p, ErrInvalidLimit
+	default:
+		p.Limit = req.Limit
+	}
+
+	sort, err := parseSort(req.Sort, allowed, aliases)
+	if err != nil {
+		return p, err
+	}
+	p.Sort = sort
+	p.spec = formatSort(sort)
+
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor, p.spec, len(sort))
+		if err != nil {
+			return p, err
+		}
+		p.After = after
+	}
+
+	return p, nil
+}
+
+// NextCursor - курсор на страницу после last.
+func (p Params) NextCursor(last Item) *string {
+	data, _ := json.Marshal(cursorPayload{Sort: p.spec, Keys: sortKeys(last, p.Sort), ID: last.SortID()})
+	s := base64.RawURLEncoding.EncodeToString(data)
+	return &s
+}
+
+// parseSort разбирает "createdAt,-name". Пустая строка - сортировка по id.
+func parseSort(raw string, allowed []string, aliases map[string]string) ([]SortField, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		f := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if alias, ok := aliases[f.Field]; ok {
+			f.Field = alias
+		}
+		if !slices.Contains(allowed, f.Field) {
+			return nil, ErrInvalidSort
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func formatSort(fields []SortField) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func decodeCursor(raw, spec string, fields int) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursorPayload
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != spec || len(c.Keys) != fields {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Keys: c.Keys, ID: c.ID}, nil
+}
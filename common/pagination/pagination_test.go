@@ -0,0 +1,111 @@
+package pagination_test
+
+import (
+	"common/pagination"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+)
+
+type item struct {
+	id    int
+	total int64
+}
+
+func (i item) SortKey(field string) string {
+	if field == "total" {
+		return pagination.IntKey(i.total)
+	}
+	return pagination.IntKey(int64(i.id))
+}
+
+func (i item) SortID() int { return i.id }
+
+func TestIntKey_KeepsOrder(t *testing.T) {
+	values := []int64{math.MinInt64, -1000, -10, -1, 0, 1, 9, 10, 1000, math.MaxInt64}
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = pagination.IntKey(v)
+
+		back, err := pagination.ParseIntKey(keys[i])
+		if err != nil || back != v {
+			t.Fatalf("expected %d back from %q, got: %d, %v", v, keys[i], back, err)
+		}
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("expected keys to sort like the numbers, got: %v", keys)
+	}
+
+	for _, bad := range []string{"", "-1", "123", "x0000000000000000000"} {
+		if _, err := pagination.ParseIntKey(bad); err != pagination.ErrInvalidCursor {
+			t.Fatalf("expected ErrInvalidCursor for %q, got: %v", bad, err)
+		}
+	}
+}
+
+// Постраничный обход по убыванию total, в том числе отрицательных.
+func TestSlice_PagesWithCursor(t *testing.T) {
+	totals := []int64{5, -3, 0, -20, 7, -3, 100}
+	var all []item
+	for i, v := range totals {
+		all = append(all, item{id: i + 1, total: v})
+	}
+
+	var got []int
+	cursor := ""
+	for range len(all) {
+		p, err := pagination.Parse(pagination.Request{Limit: 2, Sort: "-total", Cursor: cursor}, []string{"id", "total"}, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		page := pagination.Slice(slices.Clone(all), p.Sort, p.After, p.Limit+1)
+		if len(page) <= p.Limit {
+			for _, it := range page {
+				got = append(got, it.id)
+			}
+			break
+		}
+		for _, it := range page[:p.Limit] {
+			got = append(got, it.id)
+		}
+		cursor = *p.NextCursor(page[p.Limit-1])
+	}
+
+	// 100, 7, 5, 0, -3 (id 2), -3 (id 6), -20
+	if fmt.Sprint(got) != "[7 5 1 3 2 6 4]" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	first, err := pagination.Parse(pagination.Request{Sort: "total"}, []string{"total"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	cursor := *first.NextCursor(item{id: 1, total: 5})
+
+	tests := []struct {
+		name string
+		req  pagination.Request
+		want error
+	}{
+		{name: "limit too big", req: pagination.Request{Limit: pagination.MaxLimit + 1}, want: pagination.ErrInvalidLimit},
+		{name: "unknown sort field", req: pagination.Request{Sort: "password"}, want: pagination.ErrInvalidSort},
+		{name: "garbage cursor", req: pagination.Request{Cursor: "%%%"}, want: pagination.ErrInvalidCursor},
+		{name: "cursor from other sort", req: pagination.Request{Sort: "-total", Cursor: cursor}, want: pagination.ErrInvalidCursor},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := pagination.Parse(tc.req, []string{"total"}, map[string]string{"price": "total"}); err != tc.want {
+				t.Fatalf("expected %v, got: %v", tc.want, err)
+			}
+		})
+	}
+
+	p, err := pagination.Parse(pagination.Request{Sort: "-price"}, []string{"total"}, map[string]string{"price": "total"})
+	if err != nil || len(p.Sort) != 1 || p.Sort[0] != (pagination.SortField{Field: "total", Desc: true}) {
+		t.Fatalf("expected alias to resolve to -total, got: %+v, %v", p.Sort, err)
+	}
+}
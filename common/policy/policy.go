@@ -0,0 +1,125 @@
+// Package policy описывает, кому доступен маршрут, и регистрирует маршруты
+// так, что без политики маршрут не заведётся. Как проверять вызывающего,
+// решает сервис (см. Auth): gateway проверяет JWT сам, users-service - через
+// свой AuthMiddleware.
+package policy
+
+import (
+	"common/problem"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const RoleAdmin = "admin"
+
+// Policy описывает, кто может вызывать маршрут.
+type Policy struct {
+	Public     bool     // токен не нужен
+	Service    bool     // только другие сервисы: нужен общий токен (Auth.Service)
+	Roles      []string // нужна хотя бы одна из ролей; пусто - достаточно валидного токена
+	OwnerParam string   // параметр пути (или query) с id пользователя: владелец проходит без ролей
+}
+
+var (
+	PublicAccess  = Policy{Public: true}
+	Authenticated = Policy{}
+	AdminOnly     = Policy{Roles: []string{RoleAdmin}}
+	ServiceOnly   = Policy{Service: true}
+)
+
+// OwnerOrAdmin пускает пользователя к его собственным ресурсам, а админа - ко всем.
+func OwnerOrAdmin(param string) Policy {
+	return Policy{Roles: []string{RoleAdmin}, OwnerParam: param}
+}
+
+// Parse разбирает политику в текстовом виде (таблица маршрутов gateway):
+// public, authenticated, admin или owner:<param> (владелец или админ).
+func Parse(s string) (Policy, error) {
+	switch s {
+	case "public":
+		return PublicAccess, nil
+	case "authenticated":
+		return Authenticated, nil
+	case "admin":
+		return AdminOnly, nil
+	}
+	if param, ok := strings.CutPrefix(s, "owner:"); ok && param != "" {
+		return OwnerOrAdmin(param), nil
+	}
+	return Policy{}, fmt.Errorf("unknown auth policy %q", s)
+}
+
+// Auth - аутентификация конкретного сервиса.
+type Auth struct {
+	// Middleware отвечает 401 без валидных учётных данных, иначе кладёт
+	// личность вызывающего в контекст запроса.
+	Middleware func(http.Handler) http.Handler
+	// Identity достаёт из запроса то, что положил Middleware.
+	Identity func(r *http.Request) (userID int, roles []string, ok bool)
+	// Service отвечает 401 без токена сервиса (см. common/servicetoken);
+	// нужен, только если в таблице есть ServiceOnly.
+	Service func(http.Handler) http.Handler
+}
+
+// Middlewares собирает цепочку для маршрута: сначала аутентификация (401),
+// потом проверка ролей/владельца (403).
+func (p Policy) Middlewares(auth Auth) []func(http.Handler) http.Handler {
+	switch {
+	case p.Public:
+		return nil
+	case p.Service:
+		if auth.Service == nil {
+			panic("service-only route without service authentication")
+		}
+		return []func(http.Handler) http.Handler{auth.Service}
+	case p.OwnerParam != "" || len(p.Roles) > 0:
+		return []func(http.Handler) http.Handler{auth.Middleware, requireOwnerOrRoles(auth.Identity, p.OwnerParam, p.Roles)}
+	default:
+		return []func(http.Handler) http.Handler{auth.Middleware}
+	}
+}
+
+// requireOwnerOrRoles пропускает пользователя с одной из ролей, а при
+// непустом param - ещё и владельца: id из параметра совпадает с его id.
+func requireOwnerOrRoles(identity func(*http.Request) (int, []string, bool), param string, roles []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, userRoles, ok := identity(r)
+			if !ok {
+				problem.Write(w, r, problem.Unauthorized)
+				return
+			}
+
+			if hasAnyRole(userRoles, roles) || (param != "" && isOwner(r, param, userID)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			problem.Write(w, r, problem.Forbidden)
+		})
+	}
+}
+
+func isOwner(r *http.Request, param string, userID int) bool {
+	raw := chi.URLParam(r, param)
+	if raw == "" {
+		raw = r.URL.Query().Get(param)
+	}
+
+	id, err := strconv.Atoi(raw)
+	return err == nil && id == userID
+}
+
+func hasAnyRole(have, want []string) bool {
+	for _, role := range want {
+		if slices.Contains(have, role) {
+			return true
+		}
+	}
+	return false
+}
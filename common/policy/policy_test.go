@@ -0,0 +1,158 @@
+package policy_test
+
+import (
+	"common/policy"
+	"common/servicetoken"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParse(t *testing.T) {
+	p, err := policy.Parse("owner:userId")
+	if err != nil || p.OwnerParam != "userId" {
+		t.Fatalf("expected owner policy, got: %+v, %v", p, err)
+	}
+
+	if _, err := policy.Parse("owner:"); err == nil {
+		t.Fatalf("expected error for owner without param")
+	}
+	if _, err := policy.Parse("root"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
+
+// testAuth: "Authorization: <id> <role,role>", без заголовка - 401.
+func testAuth() policy.Auth {
+	type identity struct {
+		id    int
+		roles []string
+	}
+	type key struct{}
+	return policy.Auth{
+		Middleware: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, roles, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+				userID, err := strconv.Atoi(id)
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), key{}, identity{userID, strings.Split(roles, ",")}))
+				next.ServeHTTP(w, r)
+			})
+		},
+		Identity: func(r *http.Request) (int, []string, bool) {
+			who, ok := r.Context().Value(key{}).(identity)
+			return who.id, who.roles, ok
+		},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r := chi.NewRouter()
+	p := policy.NewRouter(r, map[string]policy.Policy{
+		"GET /users/{userId}": policy.OwnerOrAdmin("userId"),
+		"GET /health":         policy.PublicAccess,
+	}, testAuth())
+
+	var order []string
+	mark := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) { order = append(order, "handler") }
+	p.Handle(http.MethodGet, "/users/{userId}", ok, mark("limit"))
+	p.Handle(http.MethodGet, "/health", ok)
+	p.Verify()
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{name: "public", path: "/health", want: http.StatusOK},
+		{name: "anonymous", path: "/users/1", want: http.StatusUnauthorized},
+		{name: "owner", path: "/users/1", token: "1 user", want: http.StatusOK},
+		{name: "foreign", path: "/users/1", token: "2 user", want: http.StatusForbidden},
+		{name: "admin", path: "/users/1", token: "2 admin", want: http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, w.Code)
+			}
+		})
+	}
+
+	// pre стоят перед проверкой доступа: лимит считает и отказы
+	order = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if strings.Join(order, ",") != "limit" {
+		t.Fatalf("expected pre middleware before auth, got: %v", order)
+	}
+}
+
+func TestRouter_PanicsOnMismatch(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected panic for %s", name)
+			}
+		}()
+		f()
+	}
+
+	ok := func(http.ResponseWriter, *http.Request) {}
+	expectPanic("route without policy", func() {
+		policy.NewRouter(chi.NewRouter(), map[string]policy.Policy{}, testAuth()).Handle(http.MethodGet, "/x", ok)
+	})
+	expectPanic("policy without route", func() {
+		policy.NewRouter(chi.NewRouter(), map[string]policy.Policy{"GET /x": policy.PublicAccess}, testAuth()).Verify()
+	})
+	expectPanic("service route without service auth", func() {
+		policy.NewRouter(chi.NewRouter(), map[string]policy.Policy{"GET /x": policy.ServiceOnly}, testAuth()).Handle(http.MethodGet, "/x", ok)
+	})
+}
+
+func TestRouter_ServiceOnly(t *testing.T) {
+	auth := testAuth()
+	auth.Service = servicetoken.Middleware("secret")
+	r := chi.NewRouter()
+	p := policy.NewRouter(r, map[string]policy.Policy{"GET /internal/x": policy.ServiceOnly}, auth)
+	p.Handle(http.MethodGet, "/internal/x", func(http.ResponseWriter, *http.Request) {})
+
+	// токен пользователя, даже админа, сервисный маршрут не открывает
+	req := httptest.NewRequest(http.MethodGet, "/internal/x", nil)
+	req.Header.Set("Authorization", "1 admin")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for user token, got %d", w.Code)
+	}
+
+	req.Header.Set(servicetoken.Header, "secret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with service token, got %d", w.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Router регистрирует маршруты сервиса по его таблице политик: маршрут без
+// записи в таблице зарегистрировать нельзя, а Verify ловит записи без маршрута.
+type Router struct {
+	r        chi.Router
+	policies map[string]Policy // "METHOD /pattern" -> политика
+	auth     Auth
+	used     map[string]bool
+}
+
+func NewRouter(r chi.Router, policies map[string]Policy, auth Auth) *Router {
+	return &Router{r: r, policies: policies, auth: auth, used: make(map[string]bool)}
+}
+
+// Handle регистрирует маршрут с политикой из таблицы. pre ставятся перед
+// проверкой доступа (например, rate limit в gateway).
+func (p *Router) Handle(method, pattern string, h http.HandlerFunc, pre ...func(http.Handler) http.Handler) {
+	key := method + " " + pattern
+	policy, ok := p.policies[key]
+	if !ok {
+		panic(fmt.Sprintf("no access policy for route %s", key))
+	}
+	p.used[key] = true
+
+	p.Route(method, pattern, policy, h, pre...)
+}
+
+// Route регистрирует маршрут с явной политикой, в обход таблицы - так
+// приходят записи из таблицы маршрутов gateway.
+func (p *Router) Route(method, pattern string, policy Policy, h http.Handler, pre ...func(http.Handler) http.Handler) {
+	p.r.With(slices.Concat(pre, policy.Middlewares(p.auth))...).Method(method, pattern, h)
+}
+
+// Verify ловит записи в таблице, для которых маршрут так и не зарегистрировали
+// (например, маршрут переименовали, а политику забыли).
+func (p *Router) Verify() {
+	for key := range p.policies {
+		if !p.used[key] {
+			panic(fmt.Sprintf("access policy %s has no route", key))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package problem - ответы об ошибках в формате RFC 7807
+// (application/problem+json). У каждой ошибки стабильный машинный code;
+// detail - текст для человека, он может меняться.
+package problem
+
+import (
+	"common/validate"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// StatusClientClosedRequest - клиент закрыл соединение, не дождавшись ответа
+// (как 499 у nginx). Ответ уже никто не прочитает, код нужен логам и метрикам.
+const StatusClientClosedRequest = 499
+
+// typePrefix - type проблемы: относительная ссылка вида /problems/<code>.
+const typePrefix = "/problems/"
+
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError - ошибка в конкретном поле тела или параметре запроса.
+type FieldError = validate.FieldError
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// WithDetail - копия p с другим текстом для человека; code не меняется.
+func (p *Problem) WithDetail(detail string) *Problem {
+	out := *p
+	out.Detail = detail
+	return &out
+}
+
+// Общие проблемы; сервисы дополняют их своими через Mapper.
+var (
+	InvalidJSON      = New(http.StatusBadRequest, "invalid_json", "invalid JSON body")
+	Unauthorized     = New(http.StatusUnauthorized, "unauthorized", "authentication required")
+	Forbidden        = New(http.StatusForbidden, "forbidden", "forbidden")
+	Internal         = New(http.StatusInternalServerError, "internal_error", "internal server error")
+	ClientClosed     = New(StatusClientClosedRequest, "client_closed_request", "client closed request")
+	DeadlineExceeded = New(http.StatusGatewayTimeout, "deadline_exceeded", "request deadline exceeded")
+	RateLimited      = New(http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+	ValidationFailed = New(http.StatusBadRequest, "validation_failed", "request is not valid")
+	BodyTooLarge     = New(http.StatusRequestEntityTooLarge, "body_too_large", "request body is too large")
+)
+
+// NotFound и MethodNotAllowed - обработчики chi для неизвестных маршрутов
+// (r.NotFound, r.MethodNotAllowed).
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, routeNotFound)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, methodNotAllowed)
+}
+
+var (
+	routeNotFound    = New(http.StatusNotFound, "route_not_found", "no such route")
+	methodNotAllowed = New(http.StatusMethodNotAllowed, "method_not_allowed", "method is not allowed for this route")
+)
+
+// Invalid - 400 со списком ошибок по полям.
+func Invalid(fields ...FieldError) *Problem {
+	p := *ValidationFailed
+	p.Errors = fields
+	return &p
+}
+
+// Write отвечает проблемой p, дописывая путь и X-Request-ID запроса.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	out := *p
+	out.Instance = r.URL.Path
+	out.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(out.Status)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// Mapper сопоставляет доменные ошибки сервиса проблемам.
+type Mapper map[error]*Problem
+
+// With - копия m, в которой extra дополняет и переопределяет сопоставления:
+// так отдельный обработчик уточняет detail для своих ошибок.
+func (m Mapper) With(extra Mapper) Mapper {
+	out := make(Mapper, len(m)+len(extra))
+	for k, v := range m {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+// Problem - проблема для err: сама err, если это *Problem, затем ошибки
+// разбора и проверки тела (validate), ошибки из m (через errors.Is), обрыв
+// по контексту запроса (499/504); всё остальное - 500.
+func (m Mapper) Problem(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var fields validate.Errors
+	if errors.As(err, &fields) {
+		return Invalid(fields...)
+	}
+	switch {
+	case errors.Is(err, validate.ErrBodyTooLarge):
+		return BodyTooLarge
+	case errors.Is(err, validate.ErrInvalidJSON):
+		return InvalidJSON.WithDetail(err.Error())
+	}
+	for target, p := range m {
+		if errors.Is(err, target) {
+			return p
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClientClosed
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	}
+	return Internal
+}
+
+// Write отвечает проблемой для err. Неожиданные ошибки (500) пишутся в лог:
+// в ответ их текст не попадает.
+func (m Mapper) Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := m.Problem(err)
+	if p.Status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "error", err)
+	}
+	Write(w, r, p)
+}
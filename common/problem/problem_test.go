@@ -0,0 +1,68 @@
+package problem_test
+
+import (
+	"common/problem"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var errNotFound = errors.New("order not found")
+
+var problems = problem.Mapper{
+	errNotFound: problem.New(http.StatusNotFound, "order_not_found", "Order not found"),
+}
+
+func write(t *testing.T, err error) (*httptest.ResponseRecorder, problem.Problem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "rid-1"))
+	w := httptest.NewRecorder()
+	problems.Write(w, req, err)
+
+	var p problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("expected problem JSON, got: %v", err)
+	}
+	return w, p
+}
+
+func TestMapper_Write(t *testing.T) {
+	w, p := write(t, fmt.Errorf("load: %w", errNotFound))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("expected 404 problem+json, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := problem.Problem{
+		Type: "/problems/order_not_found", Title: "Not Found", Status: 404, Detail: "Order not found",
+		Instance: "/orders/1", Code: "order_not_found", RequestID: "rid-1",
+	}
+	if fmt.Sprint(p) != fmt.Sprint(want) {
+		t.Fatalf("expected %+v, got %+v", want, p)
+	}
+}
+
+func TestMapper_Fallbacks(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{err: problem.Invalid(problem.FieldError{Field: "limit", Code: "invalid_integer", Message: "limit must be an integer"}), code: "validation_failed"},
+		{err: context.DeadlineExceeded, code: "deadline_exceeded"},
+		{err: errors.New("disk is on fire"), code: "internal_error"},
+	}
+	for _, tc := range tests {
+		_, p := write(t, tc.err)
+		if p.Code != tc.code {
+			t.Fatalf("expected %s for %v, got %+v", tc.code, tc.err, p)
+		}
+		if p.Code == "internal_error" && p.Detail != "internal server error" {
+			t.Fatalf("expected internal error text not to leak, got %q", p.Detail)
+		}
+	}
+}
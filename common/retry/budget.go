@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget ограничивает долю повторов к одному сервису: за окно повторов может
+// быть не больше MinRetries + Ratio * запросов. Когда сервис лежит, повторы
+// быстро упираются в бюджет и не умножают нагрузку на него.
+type Budget struct {
+	ratio      float64
+	minRetries int
+	window     time.Duration
+
+	mu       sync.Mutex
+	start    time.Time
+	requests int
+	retries  int
+}
+
+func NewBudget(ratio float64, minRetries int, window time.Duration) *Budget {
+	return &Budget{ratio: ratio, minRetries: minRetries, window: window, start: time.Now()}
+}
+
+func (b *Budget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rotate()
+	b.requests++
+}
+
+// withdraw забирает один повтор из бюджета; false - бюджет исчерпан.
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rotate()
+	if float64(b.retries) >= float64(b.minRetries)+b.ratio*float64(b.requests) {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+func (b *Budget) rotate() {
+	if now := time.Now(); now.Sub(b.start) >= b.window {
+		b.start, b.requests, b.retries = now, 0, 0
+	}
+}
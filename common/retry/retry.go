@@ -0,0 +1,194 @@
+// Package retry - повтор идемпотентных HTTP-запросов к соседним сервисам:
+// экспоненциальная задержка с jitter, бюджет повторов и Retry-After.
+package retry
+
+import (
+	"bytes"
+	"common/idempotency"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxBufferedBody - самое большое тело, которое Transport держит в памяти
+// ради повторов (столько же принимает validate.DecodeJSON). Запрос с телом
+// больше или неизвестной длины уходит один раз, потоком и без повторов.
+const MaxBufferedBody = 1 << 20
+
+// Policy - сколько раз и как часто повторять запрос.
+type Policy struct {
+	MaxAttempts int           // вместе с первой попыткой; 1 - без повторов
+	BaseDelay   time.Duration // задержка перед вторым запросом, дальше удваивается
+	MaxDelay    time.Duration // потолок задержки и Retry-After
+
+	// Retryable решает, стоит ли повторять попытку с таким исходом.
+	// nil - DefaultRetryable.
+	Retryable func(resp *http.Response, err error) bool
+}
+
+// DefaultRetryable повторяет сетевые ошибки и ответы 502, 503, 504 и 429.
+// Отмену и истечение контекста запроса повторять бессмысленно.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// Idempotent - можно ли повторить запрос, не рискуя выполнить действие дважды.
+// POST повторяется только с Idempotency-Key: дубль сервис отбросит и вернёт
+// ответ первого запроса (common/idempotency). PATCH не повторяется.
+func Idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return req.Header.Get(idempotency.Header) != ""
+	}
+	return false
+}
+
+// Transport повторяет идемпотентные запросы через Next. Каждая попытка - это
+// отдельный вызов Next, поэтому circuit breaker за ним (upstream.Pool)
+// видит и считает каждую попытку.
+type Transport struct {
+	Next   http.RoundTripper
+	Policy Policy
+	Budget *Budget // nil - без ограничения
+}
+
+func NewTransport(next http.RoundTripper, policy Policy, budget *Budget) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = DefaultRetryable
+	}
+	return &Transport{Next: next, Policy: policy, Budget: budget}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Budget != nil {
+		t.Budget.request()
+	}
+	if t.Policy.MaxAttempts <= 1 || !Idempotent(req) {
+		return t.Next.RoundTrip(req)
+	}
+
+	// тело нужно для каждой попытки; у проксируемых запросов GetBody нет
+	getBody := req.GetBody
+	if getBody == nil && req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength < 0 || req.ContentLength > MaxBufferedBody {
+			return t.Next.RoundTrip(req)
+		}
+		body, err := readBody(req)
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	for attempt := 1; ; attempt++ {
+		out := req
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			out = req.Clone(req.Context())
+			out.Body = body
+		}
+
+		resp, err := t.Next.RoundTrip(out)
+		if attempt >= t.Policy.MaxAttempts || !t.Policy.Retryable(resp, err) {
+			return resp, err
+		}
+
+		delay, ok := t.delay(attempt, resp)
+		if !ok || !t.fitsDeadline(req.Context(), delay) || (t.Budget != nil && !t.Budget.withdraw()) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readBody читает тело длиной ровно ContentLength.
+func readBody(req *http.Request) ([]byte, error) {
+	defer req.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, req.ContentLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != req.ContentLength {
+		return nil, fmt.Errorf("retry: body length %d does not match ContentLength %d", len(body), req.ContentLength)
+	}
+	return body, nil
+}
+
+// delay - пауза перед попыткой attempt+1. Retry-After сервиса важнее своей
+// задержки; если он больше MaxDelay, повторять не стоит (ok == false).
+func (t *Transport) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= t.Policy.MaxDelay
+		}
+	}
+
+	d := t.Policy.BaseDelay << (attempt - 1)
+	if d <= 0 || d > t.Policy.MaxDelay {
+		d = t.Policy.MaxDelay
+	}
+	// "equal jitter": половина задержки фиксирована, половина случайна
+	half := d / 2
+	if half > 0 {
+		d = half + rand.N(half)
+	}
+	return d, true
+}
+
+// fitsDeadline - успеет ли запрос хотя бы начаться после паузы.
+func (t *Transport) fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package retry_test
+
+import (
+	"common/idempotency"
+	"common/retry"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flaky отвечает статусами из codes по очереди, дальше - 200.
+func flaky(t *testing.T, codes []int, headers map[string]string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		body, _ := io.ReadAll(r.Body)
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		if n < len(codes) {
+			w.WriteHeader(codes[n])
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+var fastPolicy = retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestTransport_RetriesIdempotentRequests(t *testing.T) {
+	srv, calls := flaky(t, []int{http.StatusServiceUnavailable, http.StatusBadGateway}, nil)
+	client := &http.Client{Transport: retry.NewTransport(nil, fastPolicy, nil)}
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"x":1}`))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != `{"x":1}` || calls.Load() != 3 {
+		t.Fatalf("expected body to be replayed on the 3rd attempt, got %d %q after %d calls", resp.StatusCode, body, calls.Load())
+	}
+}
+
+func TestTransport_PostNeedsIdempotencyKey(t *testing.T) {
+	rt := retry.NewTransport(nil, fastPolicy, nil)
+
+	srv, calls := flaky(t, []int{http.StatusServiceUnavailable}, nil)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	resp, err := rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected POST without key not to be retried, got %v after %d calls", err, calls.Load())
+	}
+	resp.Body.Close()
+
+	srv, calls = flaky(t, []int{http.StatusServiceUnavailable}, nil)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	req.Header.Set(idempotency.Header, "k1")
+	resp, err = rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected POST with key to be retried, got %v after %d calls", err, calls.Load())
+	}
+	resp.Body.Close()
+
+	// PATCH не повторяется и с ключом
+	srv, calls = flaky(t, []int{http.StatusServiceUnavailable}, nil)
+	req, _ = http.NewRequest(http.MethodPatch, srv.URL, strings.NewReader(`{}`))
+	req.Header.Set(idempotency.Header, "k2")
+	resp, err = rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected PATCH not to be retried, got %v after %d calls", err, calls.Load())
+	}
+	resp.Body.Close()
+}
+
+// Как у запроса, пришедшего в gateway: GetBody нет, длина может быть неизвестна.
+func TestTransport_BuffersOnlySmallBodiesOfKnownLength(t *testing.T) {
+	rt := retry.NewTransport(nil, fastPolicy, nil)
+	send := func(body io.Reader, length int64) (int, int64) {
+		t.Helper()
+		srv, calls := flaky(t, []int{http.StatusServiceUnavailable}, nil)
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, body)
+		req.GetBody = nil
+		req.ContentLength = length
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, calls.Load()
+	}
+
+	if code, calls := send(io.NopCloser(strings.NewReader(`{"x":1}`)), 7); code != http.StatusOK || calls != 2 {
+		t.Fatalf("expected small body to be replayed, got %d after %d calls", code, calls)
+	}
+	if code, calls := send(io.NopCloser(strings.NewReader(`{"x":1}`)), -1); code != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("expected body of unknown length to be sent once, got %d after %d calls", code, calls)
+	}
+	big := strings.Repeat("a", retry.MaxBufferedBody+1)
+	if code, calls := send(io.NopCloser(strings.NewReader(big)), int64(len(big))); code != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("expected large body to be sent once, got %d after %d calls", code, calls)
+	}
+}
+
+func TestTransport_RetryAfter(t *testing.T) {
+	srv, calls := flaky(t, []int{http.StatusServiceUnavailable}, map[string]string{"Retry-After": "1"})
+
+	// Retry-After больше MaxDelay - ответ отдаётся как есть
+	resp, err := retry.NewTransport(nil, fastPolicy, nil).RoundTrip(mustGet(t, srv.URL))
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected no retry beyond MaxDelay, got %v after %d calls", err, calls.Load())
+	}
+	resp.Body.Close()
+
+	srv, calls = flaky(t, []int{http.StatusTooManyRequests}, map[string]string{"Retry-After": "0"})
+	resp, err = retry.NewTransport(nil, fastPolicy, nil).RoundTrip(mustGet(t, srv.URL))
+	if err != nil || resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected retry after Retry-After, got %v after %d calls", err, calls.Load())
+	}
+	resp.Body.Close()
+}
+
+func TestTransport_Budget(t *testing.T) {
+	srv, calls := flaky(t, []int{503, 503, 503, 503, 503, 503, 503, 503, 503, 503}, nil)
+	// без доли от запросов: на всё окно один повтор
+	rt := retry.NewTransport(nil, fastPolicy, retry.NewBudget(0, 1, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		resp, err := rt.RoundTrip(mustGet(t, srv.URL))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("expected 3 requests and 1 retry, got %d calls", calls.Load())
+	}
+}
+
+func TestTransport_DoesNotRetryCustomPermanentErrors(t *testing.T) {
+	permanent := errors.New("permanent")
+	var calls int
+	next := roundTripFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		return nil, permanent
+	})
+	policy := fastPolicy
+	policy.Retryable = func(resp *http.Response, err error) bool {
+		return !errors.Is(err, permanent) && retry.DefaultRetryable(resp, err)
+	}
+
+	if _, err := retry.NewTransport(next, policy, nil).RoundTrip(mustGet(t, "http://example")); !errors.Is(err, permanent) || calls != 1 {
+		t.Fatalf("expected single attempt, got %v after %d calls", err, calls)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func mustGet(t *testing.T, url string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	return req
+}
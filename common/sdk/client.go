@@ -0,0 +1,220 @@
+// Package sdk - типизированный Go-клиент API. Пути у gateway и сервисов
+// общие, поэтому один Client работает и с gateway, и с сервисом напрямую
+// (тогда личность вызывающего передаётся заголовками из Config.Header).
+//
+// Access-токен подставляется в каждый запрос. На 401 клиент один раз
+// обновляет пару по refresh-токену и повторяет запрос. Ошибки API - *Error
+// с тем же code, что в problem+json; проверять их удобно через errors.Is:
+//
+//	if errors.Is(err, sdk.ErrUserNotFound) { ... }
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type Config struct {
+	BaseURL   string            // http://localhost:8000
+	Timeout   time.Duration     // на запрос вместе с повторами транспорта; 0 - без таймаута
+	Transport http.RoundTripper // nil - http.DefaultTransport
+	// Header добавляется к каждому запросу, например X-User-ID при вызове
+	// orders-service в обход gateway.
+	Header http.Header
+
+	// Tokens - пара токенов, с которой клиент начинает; Auth.Login заменяет её.
+	Tokens *TokenPair
+	// OnTokens вызывается при каждой смене пары: логин, обновление, выход (nil).
+	// Вызов идёт под блокировкой сессии, методы клиента из него не вызывать.
+	OnTokens func(*TokenPair)
+}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	header  http.Header
+	session session
+
+	Users       *UsersService
+	Auth        *AuthService
+	Orders      *OrdersService
+	Aggregation *AggregationService
+}
+
+func New(cfg Config) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		http:    &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport},
+		header:  cfg.Header,
+		session: session{tokens: cfg.Tokens, onChange: cfg.OnTokens},
+	}
+	c.Users = &UsersService{c: c}
+	c.Auth = &AuthService{c: c}
+	c.Orders = &OrdersService{c: c}
+	c.Aggregation = &AggregationService{c: c}
+	return c
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	public bool // без токена и без обновления по 401: логин, регистрация, refresh
+}
+
+// do выполняет запрос и раскладывает успешный ответ в out (nil - тело не нужно).
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+
+	var access string
+	if !req.public {
+		access = c.session.accessToken()
+	}
+	resp, err := c.send(ctx, req, payload, access)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && access != "" && c.session.refreshable() {
+		resp.Body.Close()
+		if access, err = c.session.refresh(ctx, access, c.Auth.refresh); err != nil {
+			return err
+		}
+		if resp, err = c.send(ctx, req, payload, access); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, req request, payload []byte, access string) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Accept", "application/json")
+	if payload != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if access != "" {
+		r.Header.Set("Authorization", "Bearer "+access)
+	}
+	if rid := middleware.GetReqID(ctx); rid != "" {
+		r.Header.Set(middleware.RequestIDHeader, rid)
+	}
+	return c.http.Do(r)
+}
+
+// session - текущая пара токенов клиента. Обновление идёт под мьютексом:
+// refresh-токен одноразовый, и второй параллельный refresh тем же токеном
+// users-service сочтёт кражей и отзовёт всю сессию.
+type session struct {
+	mu       sync.Mutex
+	tokens   *TokenPair
+	onChange func(*TokenPair)
+}
+
+func (s *session) accessToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		return ""
+	}
+	return s.tokens.AccessToken
+}
+
+func (s *session) refreshable() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens != nil && s.tokens.RefreshToken != ""
+}
+
+func (s *session) get() *TokenPair {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		return nil
+	}
+	pair := *s.tokens
+	return &pair
+}
+
+func (s *session) set(pair *TokenPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(pair)
+}
+
+func (s *session) store(pair *TokenPair) {
+	s.tokens = pair
+	if s.onChange != nil {
+		s.onChange(pair)
+	}
+}
+
+// refresh обновляет пару, с которой запрос получил 401, и возвращает новый
+// access-токен. Если пару уже обновил другой запрос, новая берётся как есть.
+func (s *session) refresh(ctx context.Context, stale string, refresh func(context.Context, string) (*TokenPair, error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.tokens == nil || s.tokens.RefreshToken == "":
+		// пока ждали мьютекс, сессию закрыли
+		return "", ErrUnauthorized
+	case s.tokens.AccessToken != stale:
+		return s.tokens.AccessToken, nil
+	}
+
+	pair, err := refresh(ctx, s.tokens.RefreshToken)
+	if err != nil {
+		// с отозванным refresh-токеном сессию уже не спасти
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			s.store(nil)
+		}
+		return "", err
+	}
+	s.store(pair)
+	return pair.AccessToken, nil
+}
@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"common/problem"
+	"common/validate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// FieldError - ошибка в поле тела или параметре запроса.
+type FieldError = validate.FieldError
+
+// Error - ошибка API (application/problem+json). Code стабилен, Detail -
+// текст для человека и может меняться.
+type Error struct {
+	Status    int
+	Code      string
+	Title     string
+	Detail    string
+	Instance  string
+	RequestID string
+	Fields    []FieldError // для validation_failed
+}
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("api error %d: %s", e.Status, msg)
+	}
+	return fmt.Sprintf("api error %d %s: %s", e.Status, e.Code, msg)
+}
+
+// Is сравнивает ошибки по code: errors.Is(err, sdk.ErrOrderNotFound).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+func codeError(code string) *Error {
+	return &Error{Code: code}
+}
+
+// Коды ошибок сервисов и gateway; с ними сравниваются ошибки через errors.Is.
+var (
+	// общие для всех
+	ErrInvalidJSON      = codeError("invalid_json")
+	ErrValidationFailed = codeError("validation_failed")
+	ErrBodyTooLarge     = codeError("body_too_large")
+	ErrUnauthorized     = codeError("unauthorized")
+	ErrForbidden        = codeError("forbidden")
+	ErrRouteNotFound    = codeError("route_not_found")
+	ErrMethodNotAllowed = codeError("method_not_allowed")
+	ErrRateLimited      = codeError("rate_limited")
+	ErrDeadlineExceeded = codeError("deadline_exceeded")
+	ErrInternal         = codeError("internal_error")
+
+	// gateway
+	ErrUpstreamTimeout     = codeError("upstream_timeout")
+	ErrUpstreamUnavailable = codeError("upstream_unavailable")
+	ErrBadGateway          = codeError("bad_gateway")
+
+	// users-service
+	ErrUserNotFound          = codeError("user_not_found")
+	ErrMissingRequiredFields = codeError("missing_required_fields")
+	ErrInvalidEmail          = codeError("invalid_email")
+	ErrEmailTaken            = codeError("email_taken")
+	ErrInvalidCredentials    = codeError("invalid_credentials")
+	ErrInvalidPassword       = codeError("invalid_password")
+	ErrInvalidRefreshToken   = codeError("invalid_refresh_token")
+	ErrRefreshTokenReused    = codeError("refresh_token_reused")
+	ErrUserHasActiveOrders   = codeError("user_has_active_orders")
+
+	// orders-service
+	ErrOrderNotFound     = codeError("order_not_found")
+	ErrInvalidPrice      = codeError("invalid_price")
+	ErrInvalidQuantity   = codeError("invalid_quantity")
+	ErrNoItems           = codeError("no_items")
+	ErrInvalidStatus     = codeError("invalid_status")
+	ErrInvalidTransition = codeError("invalid_transition")
+
+	// пагинация
+	ErrInvalidLimit  = codeError("invalid_limit")
+	ErrInvalidSort   = codeError("invalid_sort")
+	ErrInvalidCursor = codeError("invalid_cursor")
+)
+
+// decodeError читает ответ с ошибкой. Ответ не в формате problem (например,
+// от балансировщика перед gateway) становится *Error без code.
+func decodeError(resp *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("api error %d: %w", resp.StatusCode, err)
+	}
+
+	var p problem.Problem
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mt != problem.ContentType || json.Unmarshal(body, &p) != nil {
+		return &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode), Detail: string(body)}
+	}
+	return &Error{
+		Status:    resp.StatusCode,
+		Code:      p.Code,
+		Title:     p.Title,
+		Detail:    p.Detail,
+		Instance:  p.Instance,
+		RequestID: p.RequestID,
+		Fields:    p.Errors,
+	}
+}
@@ -0,0 +1,116 @@
+package sdk
+
+import "time"
+
+type User struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type CreateUserRequest struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type UpdateUserRequest struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"` // время жизни access-токена в секундах
+}
+
+type Order struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	UserID      int         `json:"userId"`
+	Status      string      `json:"status"`
+	Items       []OrderItem `json:"items"`
+	Subtotal    int         `json:"subtotal"`
+	Total       int         `json:"total"`
+	ItemCount   int         `json:"itemCount"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+
+	StatusHistory []StatusChange `json:"statusHistory"`
+}
+
+type OrderItem struct {
+	SKU       string `json:"sku,omitempty"`
+	Title     string `json:"title"`
+	UnitPrice int    `json:"unitPrice"`
+	Quantity  int    `json:"quantity"`
+	LineTotal int    `json:"lineTotal,omitempty"` // считает сервис
+}
+
+type StatusChange struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// Статусы заказа.
+const (
+	StatusCreated   = "created"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCanceled  = "canceled"
+	StatusRefunded  = "refunded"
+)
+
+type CreateOrderRequest struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	UserID      int         `json:"userId,omitempty"` // 0 - заказ на себя
+	Items       []OrderItem `json:"items"`
+}
+
+type UpdateOrderRequest struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Status      string      `json:"status,omitempty"`
+	Items       []OrderItem `json:"items,omitempty"` // nil - позиции не меняются
+}
+
+// UserDeletion - запись аудита о заказах удалённого пользователя.
+type UserDeletion struct {
+	ID                 int       `json:"id"`
+	UserID             int       `json:"userId"`
+	Policy             string    `json:"policy"`
+	Decision           string    `json:"decision"`
+	CanceledOrderIDs   []int     `json:"canceledOrderIds"`
+	AnonymizedOrderIDs []int     `json:"anonymizedOrderIds"`
+	BlockingOrderIDs   []int     `json:"blockingOrderIds"`
+	RequestID          string    `json:"requestId,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// UserDetails - ответ агрегации gateway: пользователь и его заказы.
+type UserDetails struct {
+	User   User    `json:"user"`
+	Orders []Order `json:"orders"`
+}
+
+// Page - страница списка; NextCursor == nil - страница последняя.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+	Total      int     `json:"total"`
+}
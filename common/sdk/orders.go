@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// OrdersService - /orders. Не админ видит и меняет только свои заказы.
+type OrdersService struct {
+	c *Client
+}
+
+// OrderQuery - фильтры и пагинация GET /orders.
+type OrderQuery struct {
+	Limit  int    // 0 - по умолчанию сервиса
+	Cursor string // NextCursor предыдущей страницы
+	Sort   string // "-total,createdAt"
+
+	UserID      *int
+	Status      string
+	MinTotal    *int
+	MaxTotal    *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+func (q OrderQuery) values() url.Values {
+	v := pageQuery(q.Limit, q.Cursor, q.Sort)
+	setInt(v, "userId", q.UserID)
+	setString(v, "status", q.Status)
+	// в API границы total до сих пор называются minPrice/maxPrice
+	setInt(v, "minPrice", q.MinTotal)
+	setInt(v, "maxPrice", q.MaxTotal)
+	if q.CreatedFrom != nil {
+		v.Set("createdFrom", q.CreatedFrom.Format(time.RFC3339))
+	}
+	if q.CreatedTo != nil {
+		v.Set("createdTo", q.CreatedTo.Format(time.RFC3339))
+	}
+	return v
+}
+
+func (s *OrdersService) List(ctx context.Context, q OrderQuery) (*Page[Order], error) {
+	return s.list(ctx, q.values())
+}
+
+// All - все заказы по q, страница за страницей.
+func (s *OrdersService) All(ctx context.Context, q OrderQuery) iter.Seq2[Order, error] {
+	return pages(ctx, q.values(), s.list)
+}
+
+func (s *OrdersService) list(ctx context.Context, q url.Values) (*Page[Order], error) {
+	var page Page[Order]
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: "/orders", query: q}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *OrdersService) Get(ctx context.Context, id int) (*Order, error) {
+	var order Order
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/orders/%d", id)}, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Create создаёт заказ и возвращает его id.
+func (s *OrdersService) Create(ctx context.Context, req CreateOrderRequest) (int, error) {
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/orders", body: req}, &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+func (s *OrdersService) Update(ctx context.Context, req UpdateOrderRequest) error {
+	return s.c.do(ctx, request{method: http.MethodPut, path: "/orders", body: req}, nil)
+}
+
+func (s *OrdersService) Delete(ctx context.Context, id int) error {
+	return s.c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/orders/%d", id)}, nil)
+}
+
+// Переходы по жизненному циклу заказа. Недопустимый переход -
+// ErrInvalidTransition.
+
+func (s *OrdersService) Pay(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, "pay")
+}
+
+func (s *OrdersService) Ship(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, "ship")
+}
+
+func (s *OrdersService) Deliver(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, "deliver")
+}
+
+func (s *OrdersService) Cancel(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, "cancel")
+}
+
+func (s *OrdersService) Refund(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, "refund")
+}
+
+func (s *OrdersService) transition(ctx context.Context, id int, action string) (*Order, error) {
+	var order Order
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/orders/%d/%s", id, action)}, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UserDeletions - аудит удалений пользователей (только админ); userID 0 - все.
+func (s *OrdersService) UserDeletions(ctx context.Context, userID int) ([]UserDeletion, error) {
+	q := url.Values{}
+	if userID != 0 {
+		q.Set("userId", strconv.Itoa(userID))
+	}
+	var deletions []UserDeletion
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: "/orders/audit/user-deletions", query: q}, &deletions); err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// AggregationService - маршруты, которые gateway собирает из нескольких
+// сервисов. У сервисов напрямую их нет.
+type AggregationService struct {
+	c *Client
+}
+
+// UserDetails - пользователь вместе с его заказами (сам пользователь или админ).
+func (s *AggregationService) UserDetails(ctx context.Context, userID int) (*UserDetails, error) {
+	var details UserDetails
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/users/%d/details", userID)}, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
@@ -0,0 +1,59 @@
+package sdk
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// pages обходит список страница за страницей, начиная с курсора из query.
+// Ошибка отдаётся последним элементом, после неё обход заканчивается.
+func pages[T any](ctx context.Context, query url.Values, fetch func(context.Context, url.Values) (*Page[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		for {
+			page, err := fetch(ctx, q)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.NextCursor == nil {
+				return
+			}
+			q.Set("cursor", *page.NextCursor)
+		}
+	}
+}
+
+// pageQuery - общие параметры пагинации.
+func pageQuery(limit int, cursor, sort string) url.Values {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	setString(q, "cursor", cursor)
+	setString(q, "sort", sort)
+	return q
+}
+
+func setString(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+func setInt(q url.Values, key string, value *int) {
+	if value != nil {
+		q.Set(key, strconv.Itoa(*value))
+	}
+}
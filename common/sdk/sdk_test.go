@@ -0,0 +1,226 @@
+package sdk_test
+
+import (
+	"common/problem"
+	"common/sdk"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func newClient(t *testing.T, h http.HandlerFunc, cfg sdk.Config) *sdk.Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	cfg.BaseURL = srv.URL + "/"
+	return sdk.New(cfg)
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/7":
+			problem.Write(w, r, problem.New(http.StatusNotFound, "user_not_found", "User not found"))
+		case "/orders":
+			problem.Write(w, r, problem.Invalid(problem.FieldError{Field: "items[0].quantity", Code: "min", Message: "must be at least 1"}))
+		default:
+			http.Error(w, "upstream is down", http.StatusBadGateway)
+		}
+	}, sdk.Config{})
+	ctx := context.Background()
+
+	_, err := c.Users.Get(ctx, 7)
+	if !errors.Is(err, sdk.ErrUserNotFound) || errors.Is(err, sdk.ErrOrderNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got: %v", err)
+	}
+	var apiErr *sdk.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Detail != "User not found" {
+		t.Fatalf("expected 404 with detail, got: %+v", apiErr)
+	}
+
+	_, err = c.Orders.Create(ctx, sdk.CreateOrderRequest{Name: "book"})
+	if !errors.Is(err, sdk.ErrValidationFailed) || !errors.As(err, &apiErr) {
+		t.Fatalf("expected ErrValidationFailed, got: %v", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "items[0].quantity" {
+		t.Fatalf("expected field error for items[0].quantity, got: %+v", apiErr.Fields)
+	}
+
+	// не problem+json: ошибка без code, тело - в Detail
+	_, err = c.Orders.Get(ctx, 1)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway || apiErr.Code != "" || apiErr.Detail != "upstream is down\n" {
+		t.Fatalf("expected plain 502 error, got: %+v", err)
+	}
+	if errors.Is(err, sdk.ErrBadGateway) {
+		t.Fatalf("expected error without code not to match ErrBadGateway")
+	}
+}
+
+func TestClient_RefreshesOnceOn401(t *testing.T) {
+	var refreshes atomic.Int32
+	var mu sync.Mutex
+	var changes []string
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/refresh":
+			var body struct {
+				RefreshToken string `json:"refreshToken"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.RefreshToken != "refresh-1" || r.Header.Get("Authorization") != "" {
+				problem.Write(w, r, problem.New(http.StatusUnauthorized, "invalid_refresh_token", "bad refresh"))
+				return
+			}
+			refreshes.Add(1)
+			writeJSON(w, map[string]any{"success": true, "data": sdk.TokenPair{AccessToken: "access-2", RefreshToken: "refresh-2"}})
+		case "/users/me":
+			if r.Header.Get("Authorization") != "Bearer access-2" {
+				problem.Write(w, r, problem.New(http.StatusUnauthorized, "unauthorized", "token expired"))
+				return
+			}
+			writeJSON(w, sdk.User{ID: 1, Name: "Ann"})
+		}
+	}, sdk.Config{
+		Tokens: &sdk.TokenPair{AccessToken: "access-1", RefreshToken: "refresh-1"},
+		OnTokens: func(p *sdk.TokenPair) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, p.AccessToken)
+		},
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := c.Users.Me(context.Background())
+			if err == nil && u.Name != "Ann" {
+				err = fmt.Errorf("unexpected user %+v", u)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected request to succeed after refresh, got: %v", err)
+		}
+	}
+
+	if n := refreshes.Load(); n != 1 {
+		t.Fatalf("expected exactly one refresh, got: %d", n)
+	}
+	if len(changes) != 1 || changes[0] != "access-2" {
+		t.Fatalf("expected OnTokens with access-2 once, got: %v", changes)
+	}
+	if p := c.Auth.Tokens(); p == nil || p.RefreshToken != "refresh-2" {
+		t.Fatalf("expected rotated pair, got: %+v", p)
+	}
+}
+
+func TestClient_FailedRefreshClearsSession(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth/refresh" {
+			problem.Write(w, r, problem.New(http.StatusUnauthorized, "refresh_token_reused", "refresh token reused"))
+			return
+		}
+		problem.Write(w, r, problem.New(http.StatusUnauthorized, "unauthorized", "token expired"))
+	}, sdk.Config{Tokens: &sdk.TokenPair{AccessToken: "access-1", RefreshToken: "refresh-1"}})
+
+	_, err := c.Orders.Get(context.Background(), 1)
+	if !errors.Is(err, sdk.ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got: %v", err)
+	}
+	if p := c.Auth.Tokens(); p != nil {
+		t.Fatalf("expected session to be cleared, got: %+v", p)
+	}
+
+	// без пары токенов 401 отдаётся как есть
+	_, err = c.Orders.Get(context.Background(), 1)
+	if !errors.Is(err, sdk.ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got: %v", err)
+	}
+}
+
+func TestClient_AllFollowsCursor(t *testing.T) {
+	var calls []string
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.RawQuery)
+		q := r.URL.Query()
+		start := 0
+		if cur := q.Get("cursor"); cur != "" {
+			start, _ = strconv.Atoi(cur)
+		}
+		page := sdk.Page[sdk.Order]{Total: 5}
+		for id := start + 1; id <= min(start+2, 5); id++ {
+			page.Items = append(page.Items, sdk.Order{ID: id, Status: q.Get("status")})
+		}
+		if start+2 < 5 {
+			next := strconv.Itoa(start + 2)
+			page.NextCursor = &next
+		}
+		writeJSON(w, page)
+	}, sdk.Config{})
+
+	var ids []int
+	for o, err := range c.Orders.All(context.Background(), sdk.OrderQuery{Limit: 2, Status: sdk.StatusPaid}) {
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if o.Status != sdk.StatusPaid {
+			t.Fatalf("expected status filter to be kept on every page, got: %q", o.Status)
+		}
+		ids = append(ids, o.ID)
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5]" || len(calls) != 3 {
+		t.Fatalf("expected 5 orders from 3 pages, got: %v from %v", ids, calls)
+	}
+	if calls[2] != "cursor=4&limit=2&status=paid" {
+		t.Fatalf("unexpected last page query: %q", calls[2])
+	}
+
+	// прерывание обхода не запрашивает следующие страницы
+	calls = nil
+	for range c.Orders.All(context.Background(), sdk.OrderQuery{Limit: 2}) {
+		break
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected a single page request, got: %v", calls)
+	}
+}
+
+func TestClient_Headers(t *testing.T) {
+	var got http.Header
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		writeJSON(w, sdk.Order{ID: 1})
+	}, sdk.Config{
+		Header: http.Header{"X-User-Id": {"42"}},
+		Tokens: &sdk.TokenPair{AccessToken: "access-1"},
+	})
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "rid-1")
+	if _, err := c.Orders.Get(ctx, 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got.Get("X-User-ID") != "42" || got.Get("X-Request-ID") != "rid-1" || got.Get("Authorization") != "Bearer access-1" {
+		t.Fatalf("expected identity, request id and token headers, got: %v", got)
+	}
+}
@@ -0,0 +1,185 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+)
+
+// UsersService - /users.
+type UsersService struct {
+	c *Client
+}
+
+// UserQuery - фильтры и пагинация GET /users.
+type UserQuery struct {
+	Limit  int    // 0 - по умолчанию сервиса
+	Cursor string // NextCursor предыдущей страницы
+	Sort   string // "name,-createdAt"
+
+	Role        string
+	EmailPrefix string
+	Name        string // подстрока имени
+}
+
+func (q UserQuery) values() url.Values {
+	v := pageQuery(q.Limit, q.Cursor, q.Sort)
+	setString(v, "role", q.Role)
+	setString(v, "emailPrefix", q.EmailPrefix)
+	setString(v, "name", q.Name)
+	return v
+}
+
+func (s *UsersService) List(ctx context.Context, q UserQuery) (*Page[User], error) {
+	return s.list(ctx, q.values())
+}
+
+// All - все пользователи по q, страница за страницей.
+func (s *UsersService) All(ctx context.Context, q UserQuery) iter.Seq2[User, error] {
+	return pages(ctx, q.values(), s.list)
+}
+
+func (s *UsersService) list(ctx context.Context, q url.Values) (*Page[User], error) {
+	var page Page[User]
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: "/users", query: q}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *UsersService) Get(ctx context.Context, id int) (*User, error) {
+	var user User
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/users/%d", id)}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create создаёт пользователя (только админ) и возвращает его id.
+func (s *UsersService) Create(ctx context.Context, req CreateUserRequest) (int, error) {
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/users", body: req}, &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+func (s *UsersService) Update(ctx context.Context, req UpdateUserRequest) error {
+	return s.c.do(ctx, request{method: http.MethodPut, path: "/users", body: req}, nil)
+}
+
+// Delete удаляет пользователя. ErrUserHasActiveOrders - заказы пользователя
+// не дают его удалить.
+func (s *UsersService) Delete(ctx context.Context, id int) error {
+	return s.c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/users/%d", id)}, nil)
+}
+
+// Me - пользователь, которому выдан текущий токен.
+func (s *UsersService) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := s.c.do(ctx, request{method: http.MethodGet, path: "/users/me"}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *UsersService) UpdateMe(ctx context.Context, name string) (*User, error) {
+	var user User
+	body := map[string]string{"name": name}
+	if err := s.c.do(ctx, request{method: http.MethodPut, path: "/users/me", body: body}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AuthService - /auth. Login и Refresh запоминают пару токенов в клиенте,
+// Logout и LogoutAll её забывают.
+type AuthService struct {
+	c *Client
+}
+
+// envelope - конверт ответов /auth: {"success": true, "data": ...}.
+type envelope[T any] struct {
+	Success bool `json:"success"`
+	Data    T    `json:"data"`
+}
+
+// Register регистрирует пользователя и возвращает его id; токены не выдаются.
+func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (int, error) {
+	var resp envelope[struct {
+		ID int `json:"id"`
+	}]
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/auth/register", body: req, public: true}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.ID, nil
+}
+
+func (s *AuthService) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	body := map[string]string{"email": email, "password": password}
+	var resp envelope[TokenPair]
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/auth/login", body: body, public: true}, &resp); err != nil {
+		return nil, err
+	}
+	pair := resp.Data
+	s.c.session.set(&pair)
+	return &pair, nil
+}
+
+// Refresh обновляет пару заранее, не дожидаясь 401.
+func (s *AuthService) Refresh(ctx context.Context) (*TokenPair, error) {
+	stale := s.c.session.accessToken()
+	if _, err := s.c.session.refresh(ctx, stale, s.refresh); err != nil {
+		return nil, err
+	}
+	return s.c.session.get(), nil
+}
+
+func (s *AuthService) refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	body := map[string]string{"refreshToken": refreshToken}
+	var resp envelope[TokenPair]
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/auth/refresh", body: body, public: true}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// Logout отзывает текущую пару.
+func (s *AuthService) Logout(ctx context.Context) error {
+	var body any // без тела отзывается только access-токен
+	if pair := s.c.session.get(); pair != nil && pair.RefreshToken != "" {
+		body = map[string]string{"refreshToken": pair.RefreshToken}
+	}
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/auth/logout", body: body}, nil); err != nil {
+		return err
+	}
+	s.c.session.set(nil)
+	return nil
+}
+
+// LogoutAll отзывает все сессии пользователя на всех устройствах.
+func (s *AuthService) LogoutAll(ctx context.Context) error {
+	if err := s.c.do(ctx, request{method: http.MethodPost, path: "/auth/logout-all"}, nil); err != nil {
+		return err
+	}
+	s.c.session.set(nil)
+	return nil
+}
+
+// Tokens - текущая пара токенов клиента; nil - клиент не вошёл.
+func (s *AuthService) Tokens() *TokenPair {
+	return s.c.session.get()
+}
+
+// SetTokens подставляет сохранённую ранее пару, например после рестарта.
+func (s *AuthService) SetTokens(pair *TokenPair) {
+	if pair != nil {
+		cp := *pair
+		pair = &cp
+	}
+	s.c.session.set(pair)
+}
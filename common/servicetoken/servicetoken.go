@@ -0,0 +1,51 @@
+// Package servicetoken - общий секрет, которым сервисы подтверждают вызовы
+// друг к другу: маршруты /internal/... и те, что наружу gateway не отдаёт.
+// Токен один на все сервисы и задаётся конфигурацией каждого из них.
+package servicetoken
+
+import (
+	"common/problem"
+	"crypto/subtle"
+	"net/http"
+)
+
+// Header - заголовок с токеном.
+const Header = "X-Service-Token"
+
+// DevToken - токен по умолчанию в профилях development и test, чтобы
+// сервисы на одной машине договорились без настройки. В production его нет.
+const DevToken = "dev-service-token"
+
+// Middleware отвечает 401, если токен запроса не совпал с token. Пустой
+// token не пропускает никого.
+func Middleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(Header)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				problem.Write(w, r, problem.Unauthorized.WithDetail("missing or invalid "+Header))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Transport добавляет токен к каждому запросу.
+type Transport struct {
+	Next  http.RoundTripper
+	token string
+}
+
+func NewTransport(next http.RoundTripper, token string) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{Next: next, token: token}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Header.Set(Header, t.token)
+	return t.Next.RoundTrip(out)
+}
@@ -0,0 +1,53 @@
+package servicetoken_test
+
+import (
+	"common/servicetoken"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := servicetoken.Middleware("secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		name, token string
+		want        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "guess", http.StatusUnauthorized},
+		{"valid token", "secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/internal/users/1/deletion", nil)
+		if tc.token != "" {
+			req.Header.Set(servicetoken.Header, tc.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("%s: expected %d, got: %d", tc.name, tc.want, w.Code)
+		}
+	}
+
+	// не настроенный токен не открывает маршрут запросу без заголовка
+	w := httptest.NewRecorder()
+	servicetoken.Middleware("")(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with empty token, got: %d", w.Code)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(servicetoken.Middleware("secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: servicetoken.NewTransport(nil, "secret")}).Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || req.Header.Get(servicetoken.Header) != "" {
+		t.Fatalf("expected 200 without touching the caller's request, got: %d, %q", resp.StatusCode, req.Header.Get(servicetoken.Header))
+	}
+}
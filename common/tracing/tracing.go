@@ -0,0 +1,149 @@
+// Package tracing - распределённая трассировка на OpenTelemetry. Контекст
+// трассы передаётся между сервисами в заголовке traceparent (W3C Trace
+// Context): Middleware продолжает трассу входящего запроса, Transport и
+// Inject передают её дальше.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортёры спанов.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Config - секция tracing в конфигурации сервисов.
+type Config struct {
+	Exporter string `yaml:"exporter" usage:"span exporter: none, stdout, file or otlp"`
+	// для file - JSON по спану на строку
+	File string `yaml:"file" usage:"span output file for the file exporter"`
+	// OTLP/HTTP, например http://otel-collector:4318
+	Endpoint    string  `yaml:"endpoint" usage:"OTLP/HTTP collector URL for the otlp exporter"`
+	SampleRatio float64 `yaml:"sampleRatio" usage:"share of new traces to record, 0..1; continued traces follow the caller"`
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterFile:
+		if c.File == "" {
+			errs = append(errs, errors.New("tracing.file is required for the file exporter"))
+		}
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout, file or otlp, got %q", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sampleRatio must be in 0..1, got %v", c.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
+
+// NewExporter создаёт экспортёр по конфигурации; для none - nil.
+func NewExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exp, file: f}, nil
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	return nil, fmt.Errorf("unknown span exporter %q", cfg.Exporter)
+}
+
+// Setup включает трассировку сервиса с экспортёром из cfg. Возвращённая
+// функция досылает накопленные спаны; её нужно вызвать при остановке.
+func Setup(ctx context.Context, service string, cfg Config) (func(context.Context) error, error) {
+	exp, err := NewExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	return Install(service, exp, cfg.SampleRatio), nil
+}
+
+// Install делает провайдер с экспортёром exp глобальным. Без экспортёра
+// спаны не записываются, но traceparent всё равно передаётся дальше.
+func Install(service string, exp sdktrace.SpanExporter, ratio float64) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if exp == nil {
+		return func(context.Context) error { return nil }
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer("common/tracing")
+}
+
+// Start начинает внутренний спан - операцию в пределах сервиса.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartClient начинает спан исходящего вызова.
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// End завершает спан, отмечая его ошибкой, если err != nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// fileExporter закрывает файл вместе с экспортёром.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file io.Closer
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
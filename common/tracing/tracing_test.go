@@ -0,0 +1,86 @@
+package tracing_test
+
+import (
+	"common/tracing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPropagatesTraceBetweenServices(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install("test", keepSpans{exp}, 1)
+
+	downstream := chi.NewRouter()
+	downstream.Use(tracing.Middleware)
+	downstream.Get("/items/{id}", func(http.ResponseWriter, *http.Request) {})
+	down := httptest.NewServer(downstream)
+	defer down.Close()
+
+	client := &http.Client{Transport: tracing.NewTransport(nil)}
+	upstream := chi.NewRouter()
+	upstream.Use(tracing.Middleware)
+	upstream.Get("/details", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, down.URL+"/items/1", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got: %v", err)
+			return
+		}
+		resp.Body.Close()
+	})
+	up := httptest.NewServer(upstream)
+	defer up.Close()
+
+	resp, err := http.Get(up.URL + "/details")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exp.GetSpans() {
+		spans[s.Name] = s
+	}
+	server, call, item := spans["GET /details"], spans["HTTP GET"], spans["GET /items/{id}"]
+	if len(spans) != 3 || !item.SpanContext.IsValid() {
+		t.Fatalf("expected spans named by route template, got: %v", spans)
+	}
+	if call.Parent.SpanID() != server.SpanContext.SpanID() || item.Parent.SpanID() != call.SpanContext.SpanID() {
+		t.Fatalf("expected server -> client -> downstream server chain")
+	}
+	if item.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Fatalf("expected one trace across services, got %s and %s", server.SpanContext.TraceID(), item.SpanContext.TraceID())
+	}
+}
+
+// keepSpans не даёт InMemoryExporter стереть спаны при остановке провайдера.
+type keepSpans struct{ *tracetest.InMemoryExporter }
+
+func (keepSpans) Shutdown(context.Context) error { return nil }
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		cfg tracing.Config
+		ok  bool
+	}{
+		{cfg: tracing.Config{Exporter: tracing.ExporterNone}, ok: true},
+		{cfg: tracing.Config{Exporter: tracing.ExporterFile, SampleRatio: 1}, ok: false},
+		{cfg: tracing.Config{Exporter: tracing.ExporterOTLP, Endpoint: "http://collector:4318", SampleRatio: 0.1}, ok: true},
+		{cfg: tracing.Config{Exporter: "jaeger"}, ok: false},
+		{cfg: tracing.Config{Exporter: tracing.ExporterStdout, SampleRatio: 2}, ok: false},
+	}
+	for _, tc := range tests {
+		if err := tc.cfg.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("Validate(%+v) = %v, want ok=%v", tc.cfg, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MaxBodyBytes - предел размера JSON-тела запроса.
+const MaxBodyBytes = 1 << 20
+
+var (
+	ErrInvalidJSON  = errors.New("invalid JSON body")
+	ErrBodyTooLarge = errors.New("request body is too large")
+)
+
+// DecodeJSON читает тело r в v и проверяет результат по тегам (Struct).
+// Тело длиннее MaxBodyBytes, неизвестные поля и данные после объекта -
+// ошибка.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return Decode(http.MaxBytesReader(w, r.Body, MaxBodyBytes), v)
+}
+
+// Decode - DecodeJSON для уже ограниченного по размеру потока.
+func Decode(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return ErrBodyTooLarge
+		}
+		return fmt.Errorf("%w: unexpected data after JSON object", ErrInvalidJSON)
+	}
+	return Struct(v)
+}
+
+func decodeError(err error) error {
+	var (
+		tooLarge  *http.MaxBytesError
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return ErrBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("%w: body is empty", ErrInvalidJSON)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		field := fieldPath(typeErr.Field)
+		return Errors{{
+			Field:   field,
+			Code:    CodeInvalidType,
+			Message: fmt.Sprintf("%s must be %s", field, jsonType(typeErr.Type.Kind())),
+		}}
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%w: %v at offset %d", ErrInvalidJSON, syntaxErr, syntaxErr.Offset)
+	}
+	// у encoding/json нет типа для неизвестного поля, только текст
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name = strings.Trim(name, `"`)
+		return Errors{{Field: name, Code: CodeUnknownField, Message: name + " is not a known field"}}
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+}
+
+// fieldPath приводит путь encoding/json (items.0.quantity) к виду, в котором
+// поля называет Struct: items[0].quantity.
+func fieldPath(path string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// jsonType - тип Go в терминах JSON для сообщения об ошибке.
+func jsonType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "of a different type"
+}
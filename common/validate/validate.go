@@ -0,0 +1,246 @@
+// Package validate - декларативная проверка запросов по тегам `validate` и
+// строгий разбор JSON-тела. Ошибки собираются сразу по всем полям.
+//
+// Правила в теге перечисляются через запятую:
+//
+//	required     - значение не пустое (строка - не из одних пробелов)
+//	omitempty    - остальные правила только для непустого значения
+//	min=N, max=N - длина строки в символах, число элементов среза, значение числа
+//	oneof=a b c  - одно из перечисленных значений
+//	email        - адрес электронной почты
+//
+// Вложенные структуры и срезы структур проверяются всегда; поле в ошибке
+// называется по json-тегу: items[0].quantity.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Коды ошибок полей; вместе с кодами ответа они - часть API.
+const (
+	CodeRequired      = "required"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeTooSmall      = "too_small"
+	CodeTooLarge      = "too_large"
+	CodeNotAllowed    = "not_allowed"
+	CodeInvalidFormat = "invalid_format"
+	CodeInvalidType   = "invalid_type"
+	CodeUnknownField  = "unknown_field"
+)
+
+// FieldError - ошибка в конкретном поле тела или параметре запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors - все ошибки запроса; пустой Errors ошибкой не считается.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct проверяет v (структуру или указатель на неё) по тегам.
+// Возвращает Errors или nil.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: expected struct, got %T", v))
+	}
+
+	var errs Errors
+	checkStruct(rv, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Rule - одно правило из тега validate: "max=100" - {Name: "max", Arg: "100", N: 100}.
+type Rule struct {
+	Name string
+	Arg  string
+	N    int // для min/max
+}
+
+// ParseTag разбирает тег validate. По тем же правилам ограничения полей
+// попадают в OpenAPI-спецификацию.
+func ParseTag(tag string) (rules []Rule, omitempty bool, err error) {
+	for _, part := range strings.Split(tag, ",") {
+		r := Rule{}
+		r.Name, r.Arg, _ = strings.Cut(strings.TrimSpace(part), "=")
+		switch r.Name {
+		case "":
+			continue
+		case "omitempty":
+			omitempty = true
+			continue
+		case "min", "max":
+			n, err := strconv.Atoi(r.Arg)
+			if err != nil {
+				return nil, false, fmt.Errorf("bad %s value %q", r.Name, r.Arg)
+			}
+			r.N = n
+		case "required", "oneof", "email":
+		default:
+			return nil, false, fmt.Errorf("unknown rule %q", r.Name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, omitempty, nil
+}
+
+type field struct {
+	index     int
+	name      string // имя в JSON
+	omitempty bool
+	rules     []Rule
+}
+
+var cache sync.Map // reflect.Type -> []field
+
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := cache.Load(t); ok {
+		return fs.([]field)
+	}
+
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := JSONName(sf)
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		rules, omitempty, err := ParseTag(sf.Tag.Get("validate"))
+		if err != nil {
+			panic(fmt.Sprintf("validate: %s.%s: %v", t.Name(), sf.Name, err))
+		}
+		fs = append(fs, field{index: i, name: name, omitempty: omitempty, rules: rules})
+	}
+
+	cache.Store(t, fs)
+	return fs
+}
+
+// JSONName - имя поля в JSON; "-", если поле не кодируется.
+func JSONName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func checkStruct(v reflect.Value, prefix string, errs *Errors) {
+	for _, f := range fieldsOf(v.Type()) {
+		fv := v.Field(f.index)
+		path := prefix + f.name
+
+		if !(f.omitempty && isEmpty(fv)) {
+			for _, r := range f.rules {
+				if fe, ok := check(r, fv, path); !ok {
+					*errs = append(*errs, fe)
+					break // одной ошибки на поле достаточно
+				}
+			}
+		}
+
+		switch elem := reflect.Indirect(fv); elem.Kind() {
+		case reflect.Struct:
+			checkStruct(elem, path+".", errs)
+		case reflect.Slice:
+			for i := 0; i < elem.Len(); i++ {
+				if item := reflect.Indirect(elem.Index(i)); item.Kind() == reflect.Struct {
+					checkStruct(item, fmt.Sprintf("%s[%d].", path, i), errs)
+				}
+			}
+		}
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func check(r Rule, v reflect.Value, path string) (FieldError, bool) {
+	fail := func(code, format string, args ...any) (FieldError, bool) {
+		return FieldError{Field: path, Code: code, Message: path + " " + fmt.Sprintf(format, args...)}, false
+	}
+
+	switch r.Name {
+	case "required":
+		if isEmpty(v) {
+			return fail(CodeRequired, "is required")
+		}
+	case "min", "max":
+		n, unit, ok := measure(v)
+		if !ok {
+			panic(fmt.Sprintf("validate: %s: %s is not supported for %s", path, r.Name, v.Kind()))
+		}
+		if r.Name == "min" && n < r.N {
+			if unit == "" {
+				return fail(CodeTooSmall, "must be at least %d", r.N)
+			}
+			return fail(CodeTooShort, "must contain at least %d %s", r.N, unit)
+		}
+		if r.Name == "max" && n > r.N {
+			if unit == "" {
+				return fail(CodeTooLarge, "must be at most %d", r.N)
+			}
+			return fail(CodeTooLong, "must contain at most %d %s", r.N, unit)
+		}
+	case "oneof":
+		allowed := strings.Fields(r.Arg)
+		if !slices.Contains(allowed, fmt.Sprint(v.Interface())) {
+			return fail(CodeNotAllowed, "must be one of: %s", strings.Join(allowed, ", "))
+		}
+	case "email":
+		if !IsEmail(v.String()) {
+			return fail(CodeInvalidFormat, "must be a valid email address")
+		}
+	}
+	return FieldError{}, true
+}
+
+// measure - то, с чем сравниваются min и max, и в чём оно измеряется
+// (пустая единица - значение числа).
+func measure(v reflect.Value) (int, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), "characters", true
+	case reflect.Slice, reflect.Map:
+		return v.Len(), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), "", true
+	}
+	return 0, "", false
+}
+
+// IsEmail - адрес без отображаемого имени: user@example.com.
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
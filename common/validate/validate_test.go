@@ -0,0 +1,95 @@
+package validate_test
+
+import (
+	"common/validate"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Title    string `json:"title" validate:"required,max=5"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+type order struct {
+	Name   string `json:"name" validate:"required"`
+	Email  string `json:"email" validate:"omitempty,email"`
+	Status string `json:"status" validate:"omitempty,oneof=created paid"`
+	Items  []item `json:"items" validate:"required,max=2"`
+}
+
+func codes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validate.Errors, got: %v", err)
+	}
+	res := make(map[string]string)
+	for _, fe := range errs {
+		res[fe.Field] = fe.Code
+	}
+	return res
+}
+
+func TestStruct_CollectsAllFieldErrors(t *testing.T) {
+	err := validate.Struct(order{
+		Name:   "  ",
+		Email:  "not-an-email",
+		Status: "lost",
+		Items:  []item{{Title: "Tea", Quantity: 1}, {Title: "Coffee"}},
+	})
+
+	want := map[string]string{
+		"name":              validate.CodeRequired,
+		"email":             validate.CodeInvalidFormat,
+		"status":            validate.CodeNotAllowed,
+		"items[1].title":    validate.CodeTooLong,
+		"items[1].quantity": validate.CodeTooSmall,
+	}
+	if got := codes(t, err); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got: %v", want, got)
+	}
+}
+
+func TestStruct_Valid(t *testing.T) {
+	if err := validate.Struct(&order{Name: "A", Items: []item{{Title: "Tea", Quantity: 2}}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		is   error
+		code map[string]string
+	}{
+		{name: "syntax", body: `{"name":`, is: validate.ErrInvalidJSON},
+		{name: "empty", body: ``, is: validate.ErrInvalidJSON},
+		{name: "trailing data", body: `{"name":"A","items":[{"title":"T","quantity":1}]} {}`, is: validate.ErrInvalidJSON},
+		{name: "too large", body: `{"name":"` + strings.Repeat("a", validate.MaxBodyBytes) + `"}`, is: validate.ErrBodyTooLarge},
+		{name: "unknown field", body: `{"name":"A","discount":5}`, code: map[string]string{"discount": validate.CodeUnknownField}},
+		{name: "wrong type", body: `{"name":5}`, code: map[string]string{"name": validate.CodeInvalidType}},
+		{name: "rules", body: `{"items":[]}`, code: map[string]string{"name": validate.CodeRequired, "items": validate.CodeRequired}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tc.body))
+			var o order
+			err := validate.DecodeJSON(httptest.NewRecorder(), r, &o)
+			if tc.is != nil {
+				if !errors.Is(err, tc.is) {
+					t.Fatalf("expected %v, got: %v", tc.is, err)
+				}
+				return
+			}
+			if got := codes(t, err); !reflect.DeepEqual(got, tc.code) {
+				t.Fatalf("expected %v, got: %v", tc.code, got)
+			}
+		})
+	}
+}
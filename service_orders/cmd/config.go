@@ -0,0 +1,179 @@
+package main
+
+import (
+	"common/config"
+	"common/logging"
+	"common/servicetoken"
+	"common/tracing"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"service_orders/internal/model"
+	"time"
+)
+
+// Config - настройки orders-service. Старые имена переменных (ORDERS_STORAGE и т.п.)
+// сохранены явными тегами env, остальные выводятся из префикса ORDERS_.
+type Config struct {
+	Server struct {
+		Port            int           `yaml:"port" usage:"HTTP port"`
+		ShutdownTimeout time.Duration `yaml:"shutdownTimeout" usage:"graceful shutdown timeout"`
+	} `yaml:"server"`
+
+	Users struct {
+		URL     string        `yaml:"url" usage:"users-service base URL"`
+		Timeout time.Duration `yaml:"timeout" usage:"users-service request timeout, retries included"`
+
+		// повтор проверки пользователя при сбоях users-service
+		Retry struct {
+			MaxAttempts      int           `yaml:"maxAttempts" usage:"attempts per request including the first, 1 - no retries"`
+			BaseDelay        time.Duration `yaml:"baseDelay" usage:"backoff before the first retry, doubled after each"`
+			MaxDelay         time.Duration `yaml:"maxDelay" usage:"backoff cap; longer Retry-After is not waited for"`
+			BudgetRatio      float64       `yaml:"budgetRatio" usage:"retries allowed per request"`
+			BudgetMinRetries int           `yaml:"budgetMinRetries" usage:"retries always allowed per budget window"`
+		} `yaml:"retry"`
+	} `yaml:"users"`
+
+	Storage struct {
+		// memory или sqlite
+		Kind   string `yaml:"kind" env:"ORDERS_STORAGE" usage:"storage backend: memory or sqlite"`
+		DBPath string `yaml:"dbPath" env:"ORDERS_DB_PATH" usage:"path to sqlite database"`
+	} `yaml:"storage"`
+
+	// cancel, anonymize или block
+	UserDeletionPolicy string `yaml:"userDeletionPolicy" env:"ORDERS_USER_DELETION_POLICY" usage:"what to do with orders of a deleted user: cancel, anonymize or block"`
+
+	Outbox struct {
+		Interval time.Duration `yaml:"interval" env:"ORDERS_OUTBOX_INTERVAL" usage:"outbox relay poll interval"`
+	} `yaml:"outbox"`
+
+	Idempotency struct {
+		TTL time.Duration `yaml:"ttl" usage:"how long POST /orders responses are kept for repeats with the same Idempotency-Key"`
+	} `yaml:"idempotency"`
+
+	// один на все сервисы: им подписаны вызовы /internal/... и к users-service
+	ServiceToken string `yaml:"serviceToken" env:"SERVICE_TOKEN" secret:"true" usage:"shared token for service-to-service calls"`
+
+	Log     logging.Config `yaml:"log"`
+	Tracing tracing.Config `yaml:"tracing"`
+}
+
+// defaultConfig - значения по умолчанию для профиля. В development сервисы
+// запускаются на одной машине, поэтому порты разные и адреса - localhost.
+func defaultConfig(profile string) *Config {
+	cfg := &Config{}
+	cfg.Server.Port = 8002
+	cfg.Server.ShutdownTimeout = 5 * time.Second
+	cfg.Users.URL = "http://localhost:8001"
+	cfg.Users.Timeout = 2 * time.Second
+	cfg.Users.Retry.MaxAttempts = 3
+	cfg.Users.Retry.BaseDelay = 50 * time.Millisecond
+	cfg.Users.Retry.MaxDelay = 500 * time.Millisecond
+	cfg.Users.Retry.BudgetRatio = 0.2
+	cfg.Users.Retry.BudgetMinRetries = 10
+	cfg.Storage.Kind = "memory"
+	cfg.Storage.DBPath = "./data/orders.db"
+	cfg.UserDeletionPolicy = model.DeletionPolicyCancel
+	cfg.Outbox.Interval = time.Second
+	cfg.Idempotency.TTL = 24 * time.Hour
+	cfg.Log.Level = "info"
+	cfg.Log.Format = logging.FormatJSON
+	cfg.Tracing.Exporter = tracing.ExporterNone
+	cfg.Tracing.SampleRatio = 1
+
+	// локально текст читать удобнее, чем JSON
+	if profile == config.ProfileDevelopment {
+		cfg.Log.Format = logging.FormatText
+	}
+
+	// в production токен задаётся только окружением
+	if profile != config.ProfileProduction {
+		cfg.ServiceToken = servicetoken.DevToken
+	}
+
+	if profile == config.ProfileProduction {
+		cfg.Server.Port = 8000
+		cfg.Users.URL = "http://service_users:8000"
+		cfg.Storage.Kind = "sqlite"
+		cfg.Storage.DBPath = "/app/data/orders.db"
+	}
+
+	return cfg
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be in 1..65535, got %d", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	if err := validateURL(c.Users.URL); err != nil {
+		errs = append(errs, fmt.Errorf("users.url: %w", err))
+	}
+	if c.Users.Timeout <= 0 {
+		errs = append(errs, errors.New("users.timeout must be positive"))
+	}
+	if r := c.Users.Retry; r.MaxAttempts < 1 || r.BaseDelay < 0 || r.MaxDelay < r.BaseDelay || r.BudgetRatio < 0 || r.BudgetMinRetries < 0 {
+		errs = append(errs, errors.New("users.retry: maxAttempts must be positive, delays and budget non-negative, maxDelay >= baseDelay"))
+	}
+	switch c.Storage.Kind {
+	case "memory":
+	case "sqlite":
+		if c.Storage.DBPath == "" {
+			errs = append(errs, errors.New("storage.dbPath is required for sqlite storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage.kind %q", c.Storage.Kind))
+	}
+	if !model.IsValidDeletionPolicy(c.UserDeletionPolicy) {
+		errs = append(errs, fmt.Errorf("unknown userDeletionPolicy %q", c.UserDeletionPolicy))
+	}
+	if c.Outbox.Interval <= 0 {
+		errs = append(errs, errors.New("outbox.interval must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+	if c.ServiceToken == "" {
+		errs = append(errs, errors.New("serviceToken is required"))
+	}
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func loadConfig() (*Config, string, error) {
+	profile, err := config.Profile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := defaultConfig(profile)
+	err = config.Load(cfg, config.Options{
+		Name:      "orders-service",
+		EnvPrefix: "ORDERS_",
+		Args:      os.Args[1:],
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, profile, nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want absolute http(s) URL, got %q", raw)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"common/idempotency"
+	"common/retry"
+	"common/servicetoken"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"service_orders/internal/handler"
+	"service_orders/internal/repository"
+	"service_orders/internal/service"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type existingUsers struct{}
+
+func (existingUsers) UserExists(context.Context, int) (bool, error) { return true, nil }
+
+// lostResponse доводит первый запрос до сервиса, но вместо его ответа отдаёт
+// 502 - как прокси, у которого оборвалось соединение с сервисом.
+type lostResponse struct {
+	calls atomic.Int32
+}
+
+func (t *lostResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || t.calls.Add(1) > 1 {
+		return resp, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody, Header: http.Header{}, Request: req}, nil
+}
+
+func TestCreateOrder_RetriedPostCreatesOneOrder(t *testing.T) {
+	const userID = 5
+	repo := repository.NewInMemoryOrderRepository()
+	svc := service.NewOrderService(repo, existingUsers{}, "")
+	srv := httptest.NewServer(initRouter(handler.NewOrderController(*svc), idempotency.NewStore(time.Hour), servicetoken.DevToken))
+	defer srv.Close()
+
+	lost := &lostResponse{}
+	client := &http.Client{Transport: retry.NewTransport(lost,
+		retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}, nil)}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/orders",
+		strings.NewReader(`{"name":"Soup","items":[{"title":"Soup","unitPrice":300,"quantity":1}]}`))
+	req.Header.Set(handler.HeaderUserID, "5")
+	req.Header.Set(idempotency.Header, "3f0c6a5e-create-soup")
+	req.GetBody = nil // как у запроса, проксируемого gateway
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get(idempotency.HeaderReplayed) != "true" || lost.calls.Load() != 2 {
+		t.Fatalf("expected replayed 201 on the second attempt, got: %d, %q after %d calls",
+			resp.StatusCode, resp.Header.Get(idempotency.HeaderReplayed), lost.calls.Load())
+	}
+	if orders, _ := repo.GetByUserID(context.Background(), userID); len(orders) != 1 {
+		t.Fatalf("expected exactly one order, got: %d", len(orders))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"common/servicetoken"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInternalRoutes_RequireServiceToken(t *testing.T) {
+	r := newTestRouter()
+
+	for _, path := range []string{"/internal/users/1/deletion/check", "/internal/users/1/deletion"} {
+		// X-User-ID от gateway внутренние маршруты не открывает
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-User-ID", "1")
+		req.Header.Set("X-User-Roles", "admin")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %s without service token, got: %d", path, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/internal/users/1/deletion/check", nil)
+	req.Header.Set(servicetoken.Header, servicetoken.DevToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with service token, got: %d %s", w.Code, w.Body)
+	}
+}
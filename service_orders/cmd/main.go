@@ -1,9 +1,19 @@
 package main
 
 import (
+	"common/config"
+	"common/deadline"
+	"common/logging"
+	"common/events"
+	"common/idempotency"
+	"common/metrics"
+	"common/problem"
+	"common/retry"
+	"common/servicetoken"
+	"common/tracing"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os/signal"
 	"service_orders/internal/handler"
@@ -17,23 +27,36 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-const (
-	port            = "8000"
-	shutdownTimeout = 5 * time.Second
-	usersServiceUrl = "http://service_users:8000"
-)
-
 func main() {
+	cfg, profile, err := loadConfig()
+	if err != nil {
+		logging.Fatal("failed to load config", err)
+	}
+	logging.Setup("service_orders", cfg.Log)
+	slog.Info("effective config", "profile", profile, "config", config.Describe(cfg))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "service_orders", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("failed to init tracing", err)
+	}
+
 	// DI
-	usersClient := client.NewUsersClient(usersServiceUrl)
-	orderRepo := repository.NewInMemoryOrderRepository()
-	orderService := service.NewOrderService(orderRepo, usersClient)
+	usersClient := newUsersClient(cfg)
+	orderRepo, closeRepo, err := newOrderRepository(cfg)
+	if err != nil {
+		logging.Fatal("failed to init order repository", err)
+	}
+	defer closeRepo()
+
+	relay := newRelay(orderRepo, cfg.Outbox.Interval)
+
+	orderService := service.NewOrderService(orderRepo, usersClient, cfg.UserDeletionPolicy)
 	orderController := handler.NewOrderController(*orderService)
 
-	r := initRouter(orderController)
+	r := initRouter(orderController, idempotency.NewStore(cfg.Idempotency.TTL), cfg.ServiceToken)
 
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%v", port),
+		Addr:    fmt.Sprintf(":%v", cfg.Server.Port),
 		Handler: r,
 	}
 
@@ -41,40 +64,119 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	relayDone := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(relayDone)
+	}()
+
 	go func() {
-		log.Println("starting orders-service on port", port)
+		slog.InfoContext(ctx, "starting orders-service", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server starting failed: %v", err)
+			logging.Fatal("server starting failed", err)
 		}
 	}()
 
 	<-ctx.Done()
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	log.Println("shutting down server gracefully")
+	slog.InfoContext(shutdownCtx, "shutting down server gracefully")
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Println("error when shutting down:", err)
+		slog.ErrorContext(shutdownCtx, "error when shutting down", "error", err)
 	} else {
-		log.Println("server stopped")
+		slog.InfoContext(shutdownCtx, "server stopped")
+	}
+
+	// relay досылает остаток до закрытия репозитория
+	<-relayDone
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.ErrorContext(shutdownCtx, "error when flushing traces", "error", err)
+	}
+}
+
+func newOrderRepository(cfg *Config) (service.OrderRepository, func(), error) {
+	if cfg.Storage.Kind != "sqlite" {
+		return repository.NewInMemoryOrderRepository(), func() {}, nil
+	}
+
+	path := cfg.Storage.DBPath
+	repo, err := repository.NewSQLiteOrderRepository(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	slog.Info("using sqlite storage", "path", path)
+
+	return repo, func() {
+		if err := repo.Close(); err != nil {
+			slog.Error("error when closing order repository", "error", err)
+		}
+	}, nil
+}
+
+// newRelay публикует события из outbox. Внешнего брокера пока нет:
+// события доставляются внутри процесса и пишутся в лог.
+func newRelay(outbox events.Outbox, interval time.Duration) *events.Relay {
+	broker := events.NewLocalBroker()
+	broker.Subscribe("*", events.LogHandler)
+
+	return events.NewRelay(outbox, broker, interval)
 }
 
-func initRouter(order *handler.OrderController) *chi.Mux {
+func initRouter(order *handler.OrderController, keys *idempotency.Store, serviceToken string) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(metrics.Middleware)
 	r.Use(middleware.Recoverer)
+	r.Use(deadline.Middleware)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
 
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+	r.Get("/openapi.json", apiSpec().Handler())
 	r.Get("/orders/status", order.Status)
 	r.Get("/orders/health", order.Health)
-	r.Get("/orders/{id}", order.GetOrder)
-	r.Get("/orders", order.ListOrders)
-	r.Post("/orders", order.CreateOrder)
-	r.Put("/orders", order.UpdateOrder)
-	r.Delete("/orders/{id}", order.DeleteOrder)
+
+	// Внутренние маршруты: вызываются другими сервисами напрямую, gateway их не проксирует.
+	r.Group(func(r chi.Router) {
+		r.Use(servicetoken.Middleware(serviceToken))
+
+		r.Post("/internal/users/{userId}/deletion/check", order.CheckUserDeletion)
+		r.Post("/internal/users/{userId}/deletion", order.HandleUserDeletion)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(handler.IdentityMiddleware)
+
+		r.Get("/orders/{id}", order.GetOrder)
+		r.Get("/orders", order.ListOrders)
+		r.With(idempotency.Middleware(keys, handler.IdempotencyScope)).Post("/orders", order.CreateOrder)
+		r.Put("/orders", order.UpdateOrder)
+		r.Delete("/orders/{id}", order.DeleteOrder)
+		r.Get("/orders/audit/user-deletions", order.ListUserDeletions)
+
+		r.Post("/orders/{id}/pay", order.PayOrder)
+		r.Post("/orders/{id}/ship", order.ShipOrder)
+		r.Post("/orders/{id}/deliver", order.DeliverOrder)
+		r.Post("/orders/{id}/cancel", order.CancelOrder)
+		r.Post("/orders/{id}/refund", order.RefundOrder)
+	})
 
 	return r
 }
+
+// newUsersClient - клиент users-service с повтором запросов при сбоях. Каждая
+// попытка - отдельный спан; users-service получает traceparent, оставшееся
+// у запроса время и токен сервиса.
+func newUsersClient(cfg *Config) *client.UsersClient {
+	r := cfg.Users.Retry
+	transport := retry.NewTransport(tracing.NewTransport(servicetoken.NewTransport(deadline.NewTransport(nil), cfg.ServiceToken)),
+		retry.Policy{MaxAttempts: r.MaxAttempts, BaseDelay: r.BaseDelay, MaxDelay: r.MaxDelay},
+		retry.NewBudget(r.BudgetRatio, r.BudgetMinRetries, 10*time.Second))
+	return client.NewUsersClient(cfg.Users.URL, cfg.Users.Timeout, transport)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"common/openapi"
+	"net/http"
+	"service_orders/internal/model"
+)
+
+var (
+	stringParam = &openapi.Schema{Type: "string"}
+	intParam    = &openapi.Schema{Type: "integer"}
+	timeParam   = &openapi.Schema{Type: "string", Format: "date-time"}
+	idParam     = openapi.PathInt("id", "id заказа")
+)
+
+// orderRoutes описывает маршруты initRouter для /openapi.json. Расхождение с
+// роутером ловит TestOpenAPI_MatchesRouter.
+var orderRoutes = []openapi.Route{
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "getOpenAPI", Summary: "Эта спецификация", Tag: "meta", Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/metrics", ID: "getMetrics", Summary: "Метрики Prometheus", Tag: "meta", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/orders/status", ID: "getOrdersStatus", Tag: "meta",
+		Response: map[string]any{"status": ""}},
+	{Method: http.MethodGet, Path: "/orders/health", ID: "getOrdersHealth", Tag: "meta",
+		Response: map[string]any{"status": "", "service": "", "timestamp": ""}},
+
+	{Method: http.MethodPost, Path: "/internal/users/{userId}/deletion/check", ID: "checkUserDeletion",
+		Summary: "Вызывается users-service перед удалением пользователя, заказы не меняет; 409 - удаление заблокировано политикой", Tag: "internal",
+		Security: openapi.ServiceAuth,
+		Params:   []openapi.Parameter{openapi.PathInt("userId", "id удаляемого пользователя")},
+		Response: model.UserDeletion{}, Responses: map[int]any{http.StatusConflict: model.UserDeletion{}},
+		Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPost, Path: "/internal/users/{userId}/deletion", ID: "applyUserDeletion",
+		Summary: "Вызывается users-service по событию user.deleted: отменяет и обезличивает заказы; повтор отдаёт то же решение", Tag: "internal",
+		Security: openapi.ServiceAuth,
+		Params:   []openapi.Parameter{openapi.PathInt("userId", "id удалённого пользователя")},
+		Response: model.UserDeletion{},
+		Errors:   []int{http.StatusBadRequest}},
+
+	{Method: http.MethodGet, Path: "/orders", ID: "listOrders", Summary: "Заказы с курсорной пагинацией; не админ видит только свои", Tag: "orders",
+		Security: openapi.GatewayIdentity,
+		Params: []openapi.Parameter{
+			openapi.Query("limit", intParam, "размер страницы, по умолчанию 20, не больше 100"),
+			openapi.Query("cursor", stringParam, "nextCursor предыдущей страницы"),
+			openapi.Query("sort", stringParam, "поля через запятую, -total - по убыванию: id, name, status, total, createdAt, updatedAt"),
+			openapi.Query("userId", intParam, "заказы пользователя"),
+			openapi.Query("status", stringParam, "статус заказа"),
+			openapi.Query("minPrice", intParam, "нижняя граница total"),
+			openapi.Query("maxPrice", intParam, "верхняя граница total"),
+			openapi.Query("createdFrom", timeParam, "создан не раньше (RFC 3339)"),
+			openapi.Query("createdTo", timeParam, "создан не позже (RFC 3339)"),
+		},
+		Response: model.OrderPage{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/orders/{id}", ID: "getOrder", Tag: "orders", Security: openapi.GatewayIdentity,
+		Params: []openapi.Parameter{idParam}, Response: model.Order{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/orders", ID: "createOrder", Tag: "orders", Security: openapi.GatewayIdentity,
+		Params: []openapi.Parameter{openapi.IdempotencyKey},
+		Body:   model.CreateOrderRequest{}, Status: http.StatusCreated, Response: map[string]any{"id": 0, "message": ""},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity}},
+	{Method: http.MethodPut, Path: "/orders", ID: "updateOrder", Tag: "orders", Security: openapi.GatewayIdentity,
+		Body: model.UpdateOrderRequest{}, Response: map[string]any{"message": ""},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+	{Method: http.MethodDelete, Path: "/orders/{id}", ID: "deleteOrder", Tag: "orders", Security: openapi.GatewayIdentity,
+		Params: []openapi.Parameter{idParam}, Response: map[string]any{"message": ""},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/orders/audit/user-deletions", ID: "listUserDeletions", Summary: "Аудит решений по удалённым пользователям", Tag: "orders",
+		Security: openapi.GatewayIdentity,
+		Params:   []openapi.Parameter{openapi.Query("userId", intParam, "записи по одному пользователю")},
+		Response: []model.UserDeletion{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+
+	statusRoute("/orders/{id}/pay", "payOrder"),
+	statusRoute("/orders/{id}/ship", "shipOrder"),
+	statusRoute("/orders/{id}/deliver", "deliverOrder"),
+	statusRoute("/orders/{id}/cancel", "cancelOrder"),
+	statusRoute("/orders/{id}/refund", "refundOrder"),
+}
+
+// statusRoute - переход заказа по жизненному циклу (changeStatus).
+func statusRoute(path, id string) openapi.Route {
+	return openapi.Route{
+		Method: http.MethodPost, Path: path, ID: id, Tag: "orders", Security: openapi.GatewayIdentity,
+		Params: []openapi.Parameter{idParam}, Response: model.Order{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	}
+}
+
+func apiSpec() *openapi.Document {
+	doc := openapi.New("Orders Service", "1.0.0")
+	doc.Components.SecuritySchemes[openapi.GatewayIdentity] = openapi.IdentityScheme
+	doc.Components.SecuritySchemes[openapi.ServiceAuth] = openapi.ServiceScheme
+	for _, rt := range orderRoutes {
+		doc.Add(rt)
+	}
+	return doc
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"common/idempotency"
+	"common/openapi"
+	"common/servicetoken"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"service_orders/internal/handler"
+	"service_orders/internal/repository"
+	"service_orders/internal/service"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestRouter() *chi.Mux {
+	svc := service.NewOrderService(repository.NewInMemoryOrderRepository(), nil, "")
+	return initRouter(handler.NewOrderController(*svc), idempotency.NewStore(time.Hour), servicetoken.DevToken)
+}
+
+func TestOpenAPI_MatchesRouter(t *testing.T) {
+	if err := apiSpec().CheckRoutes(newTestRouter()); err != nil {
+		t.Fatalf("spec and router disagree:\n%v", err)
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc openapi.Document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected spec JSON, got %d: %v", w.Code, err)
+	}
+	create := doc.Paths["/orders"]["post"]
+	if create == nil || len(create.Security) == 0 || create.Responses["201"] == nil {
+		t.Fatalf("expected createOrder with identity and 201, got: %+v", create)
+	}
+	status := doc.Components.Schemas["UpdateOrderRequest"].Properties["status"]
+	if len(status.Enum) != 6 {
+		t.Fatalf("expected status enum from validate tag, got: %+v", status)
+	}
+	if order := doc.Components.Schemas["Order"]; order.Properties["total"] == nil {
+		t.Fatalf("expected embedded totals in Order, got: %+v", order.Properties)
+	}
+}
@@ -1,52 +1,35 @@
 package client
 
 import (
+	"common/sdk"
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"time"
 )
 
 type UsersClient struct {
-	baseURL string
-	client  *http.Client
+	api *sdk.Client
 }
 
-func NewUsersClient(baseURL string) *UsersClient {
+// NewUsersClient - transport отвечает за повторы (retry.Transport); nil -
+// без них. timeout ограничивает запрос вместе со всеми повторами.
+func NewUsersClient(baseURL string, timeout time.Duration, transport http.RoundTripper) *UsersClient {
 	return &UsersClient{
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 2 * time.Second,
-		},
+		api: sdk.New(sdk.Config{
+			BaseURL:   baseURL,
+			Timeout:   timeout,
+			Transport: transport,
+		}),
 	}
 }
 
 func (c *UsersClient) UserExists(ctx context.Context, userID int) (bool, error) {
-	url := fmt.Sprintf("%s/users/%d", c.baseURL, userID)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return false, err
-	}
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return false, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return false, nil
-	}
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("unexpected status from users service: %d", resp.StatusCode)
-	}
-
-	var body any
-	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+	if _, err := c.api.Users.Get(ctx, userID); err != nil {
+		if errors.Is(err, sdk.ErrUserNotFound) {
+			return false, nil
+		}
 		return false, err
 	}
-
 	return true, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"common/problem"
+	"net/http"
+	"service_orders/internal/model"
+)
+
+var errInvalidID = problem.Invalid(problem.FieldError{Field: "id", Code: "invalid", Message: "id must be an integer"})
+
+// Коды ошибок - часть API: клиенты ветвятся по ним, detail может меняться.
+var problems = problem.Mapper{
+	model.ErrOrderNotFound:         problem.New(http.StatusNotFound, "order_not_found", "order not found"),
+	model.ErrMissingRequiredFields: problem.New(http.StatusBadRequest, "missing_required_fields", "missing required fields"),
+	model.ErrInvalidPrice:          problem.New(http.StatusBadRequest, "invalid_price", "price must not be negative"),
+	model.ErrInvalidQuantity:       problem.New(http.StatusBadRequest, "invalid_quantity", "quantity must be positive"),
+	model.ErrNoItems:               problem.New(http.StatusBadRequest, "no_items", "order must contain at least one item"),
+	model.ErrUserNotFound:          problem.New(http.StatusNotFound, "user_not_found", "user not found"),
+	model.ErrInvalidStatus:         problem.New(http.StatusBadRequest, "invalid_status", "invalid status"),
+	model.ErrInvalidTransition:     problem.New(http.StatusConflict, "invalid_transition", "status transition is not allowed"),
+	model.ErrForbidden:             problem.Forbidden,
+	model.ErrItemsLocked:           problem.New(http.StatusConflict, "items_locked", "items can be changed only while the order is created"),
+	model.ErrInvalidLimit:          problem.New(http.StatusBadRequest, "invalid_limit", "invalid limit"),
+	model.ErrInvalidSort:           problem.New(http.StatusBadRequest, "invalid_sort", "invalid sort"),
+	model.ErrInvalidCursor:         problem.New(http.StatusBadRequest, "invalid_cursor", "invalid cursor"),
+	model.ErrUserHasActiveOrders:   problem.New(http.StatusConflict, "user_has_active_orders", "user has orders in progress"),
+}
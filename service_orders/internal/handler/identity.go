@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"common/logging"
+	"common/problem"
+	"context"
+	"net/http"
+	"service_orders/internal/model"
+	"strconv"
+	"strings"
+)
+
+// Заголовки с личностью пользователя выставляет api_gateway после проверки JWT.
+// Клиентские копии gateway вырезает, а напрямую сервис снаружи не доступен.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserRoles = "X-User-Roles"
+)
+
+type contextKey string
+
+const callerContextKey contextKey = "caller"
+
+func IdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.Header.Get(HeaderUserID))
+		if err != nil || userID <= 0 {
+			problem.Write(w, r, problem.Unauthorized.WithDetail("missing caller identity"))
+			return
+		}
+
+		caller := model.Caller{UserID: userID}
+		for _, role := range strings.Split(r.Header.Get(HeaderUserRoles), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				caller.Roles = append(caller.Roles, role)
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), callerContextKey, caller)
+		logging.SetUser(ctx, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// IdempotencyScope - владелец Idempotency-Key: пользователь из X-User-ID.
+func IdempotencyScope(r *http.Request) string {
+	return strconv.Itoa(callerFromContext(r.Context()).UserID)
+}
+
+func callerFromContext(ctx context.Context) model.Caller {
+	caller, _ := ctx.Value(callerContextKey).(model.Caller)
+	return caller
+}
@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Бизнес-метрики orders-service (переходы статусов - в service); HTTP-метрики -
+// в common/metrics.
+var ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "orders_created_total",
+	Help: "Created orders by initial status.",
+}, []string{"status"})
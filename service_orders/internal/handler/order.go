@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"common/pagination"
+	"common/problem"
+	"common/validate"
 	"encoding/json"
 	"net/http"
 	"service_orders/internal/model"
@@ -37,40 +40,56 @@ func (c *OrderController) GetOrder(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid id"}`, http.StatusBadRequest)
+		problem.Write(w, r, errInvalidID)
 		return
 	}
 
-	order, err := c.service.GetOrder(id)
+	order, err := c.service.GetOrder(r.Context(), callerFromContext(r.Context()), id)
 	if err != nil {
-		switch err {
-		case model.ErrOrderNotFound:
-			http.Error(w, `{"error": "Order not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "Server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, order)
 }
 
+// ListOrders: ?limit=&cursor=&sort=createdAt,-price&userId=&status=
+// &minPrice=&maxPrice=&createdFrom=&createdTo= (даты в RFC3339)
 func (c *OrderController) ListOrders(w http.ResponseWriter, r *http.Request) {
-	userIdParam := r.URL.Query().Get("userId")
+	q := r.URL.Query()
 
-	var userID *int
-	if userIdParam != "" {
-		parsed, err := strconv.Atoi(userIdParam)
-		if err != nil {
-			http.Error(w, `{"error": "invalid userId"}`, http.StatusBadRequest)
-			return
-		}
-		userID = &parsed
+	var filter model.OrderFilter
+	filter.Status = q.Get("status")
+
+	var ok bool
+	if filter.UserID, ok = queryInt(w, r, q.Get("userId"), "userId"); !ok {
+		return
+	}
+	if filter.MinTotal, ok = queryInt(w, r, q.Get("minPrice"), "minPrice"); !ok {
+		return
+	}
+	if filter.MaxTotal, ok = queryInt(w, r, q.Get("maxPrice"), "maxPrice"); !ok {
+		return
+	}
+	if filter.CreatedFrom, ok = queryTime(w, r, q.Get("createdFrom"), "createdFrom"); !ok {
+		return
+	}
+	if filter.CreatedTo, ok = queryTime(w, r, q.Get("createdTo"), "createdTo"); !ok {
+		return
+	}
+
+	page := pagination.Request{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+	limit, ok := queryInt(w, r, q.Get("limit"), "limit")
+	if !ok {
+		return
+	}
+	if limit != nil {
+		page.Limit = *limit
 	}
 
-	orders, err := c.service.ListOrders(userID)
+	orders, err := c.service.ListOrders(r.Context(), callerFromContext(r.Context()), filter, page)
 	if err != nil {
-		http.Error(w, `{"error": "Server error"}`, http.StatusInternalServerError)
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -79,25 +98,21 @@ func (c *OrderController) ListOrders(w http.ResponseWriter, r *http.Request) {
 
 func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	id, err := c.service.CreateOrder(r.Context(), req)
+	id, err := c.service.CreateOrder(r.Context(), callerFromContext(r.Context()), req)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "Missing required fields"}`, http.StatusBadRequest)
-		case model.ErrInvalidPrice:
-			http.Error(w, `{"error": "Invalid price"}`, http.StatusBadRequest)
-		case model.ErrUserNotFound:
-			http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "Server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
+	status := req.Status
+	if status == "" {
+		status = model.StatusCreated
+	}
+	ordersCreated.WithLabelValues(status).Inc()
 
 	response := map[string]interface{}{
 		"id": id,
@@ -109,27 +124,14 @@ func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 func (c *OrderController) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	var req model.UpdateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
-		return
-	}
-	if req.ID == 0 {
-		http.Error(w, `{"error": "invalid ID"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	err := c.service.UpdateOrder(req)
+	err := c.service.UpdateOrder(r.Context(), callerFromContext(r.Context()), req)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "Missing required fields"}`, http.StatusBadRequest)
-		case model.ErrInvalidPrice:
-			http.Error(w, `{"error": "Invalid price"}`, http.StatusBadRequest)
-		case model.ErrOrderNotFound:
-			http.Error(w, `{"error": "Order not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "Server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -144,18 +146,13 @@ func (c *OrderController) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		problem.Write(w, r, errInvalidID)
 		return
 	}
 
-	err = c.service.DeleteOrder(id)
+	err = c.service.DeleteOrder(r.Context(), callerFromContext(r.Context()), id)
 	if err != nil {
-		switch err {
-		case model.ErrOrderNotFound:
-			http.Error(w, `{"error": "Order not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "Server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -166,8 +163,70 @@ func (c *OrderController) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, response)
 }
 
+func (c *OrderController) PayOrder(w http.ResponseWriter, r *http.Request) {
+	c.changeStatus(w, r, model.StatusPaid)
+}
+
+func (c *OrderController) ShipOrder(w http.ResponseWriter, r *http.Request) {
+	c.changeStatus(w, r, model.StatusShipped)
+}
+
+func (c *OrderController) DeliverOrder(w http.ResponseWriter, r *http.Request) {
+	c.changeStatus(w, r, model.StatusDelivered)
+}
+
+func (c *OrderController) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	c.changeStatus(w, r, model.StatusCanceled)
+}
+
+func (c *OrderController) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	c.changeStatus(w, r, model.StatusRefunded)
+}
+
+func (c *OrderController) changeStatus(w http.ResponseWriter, r *http.Request, status string) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		problem.Write(w, r, errInvalidID)
+		return
+	}
+
+	order, err := c.service.ChangeStatus(r.Context(), callerFromContext(r.Context()), id, status)
+	if err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
-}
\ No newline at end of file
+}
+
+// queryInt разбирает необязательный числовой параметр; при ошибке сам отвечает 400.
+func queryInt(w http.ResponseWriter, r *http.Request, raw, name string) (*int, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		problem.Write(w, r, problem.Invalid(problem.FieldError{Field: name, Code: "invalid", Message: name + " must be an integer"}))
+		return nil, false
+	}
+	return &n, true
+}
+
+func queryTime(w http.ResponseWriter, r *http.Request, raw, name string) (*time.Time, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		problem.Write(w, r, problem.Invalid(problem.FieldError{Field: name, Code: "invalid", Message: name + " must be an RFC 3339 timestamp"}))
+		return nil, false
+	}
+	return &t, true
+}
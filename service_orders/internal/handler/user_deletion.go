@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"common/problem"
+	"errors"
+	"net/http"
+	"service_orders/internal/model"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CheckUserDeletion - внутренний вызов от service_users перед удалением
+// пользователя; заказы не меняются. 200 - можно удалять, 409 - удаление
+// заблокировано политикой. В обоих случаях в ответе решение.
+func (c *OrderController) CheckUserDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := deletedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deletion, err := c.service.CheckUserDeletion(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, model.ErrUserHasActiveOrders) {
+			writeJSON(w, http.StatusConflict, deletion)
+			return
+		}
+		problems.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deletion)
+}
+
+// HandleUserDeletion - внутренний вызов от service_users, когда пользователь
+// уже удалён: заказы отменяются и обезличиваются по политике. Повторный вызов
+// отдаёт ту же запись аудита.
+func (c *OrderController) HandleUserDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := deletedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deletion, err := c.service.HandleUserDeletion(r.Context(), userID)
+	if err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deletion)
+}
+
+func deletedUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil || userID <= 0 {
+		problem.Write(w, r, problem.Invalid(problem.FieldError{Field: "userId", Code: "invalid", Message: "userId must be a positive integer"}))
+		return 0, false
+	}
+	return userID, true
+}
+
+// ListUserDeletions: ?userId= - аудит решений по удалённым пользователям.
+func (c *OrderController) ListUserDeletions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := queryInt(w, r, r.URL.Query().Get("userId"), "userId")
+	if !ok {
+		return
+	}
+
+	deletions, err := c.service.ListUserDeletions(r.Context(), callerFromContext(r.Context()), userID)
+	if err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deletions)
+}
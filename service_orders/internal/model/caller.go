@@ -0,0 +1,21 @@
+package model
+
+import "slices"
+
+const RoleAdmin = "admin"
+
+// Caller - пользователь, от имени которого пришёл запрос. Заполняется из
+// заголовков, которые выставляет gateway после проверки токена.
+type Caller struct {
+	UserID int
+	Roles  []string
+}
+
+func (c Caller) IsAdmin() bool {
+	return slices.Contains(c.Roles, RoleAdmin)
+}
+
+// CanAccess - админ видит все заказы, остальные только свои.
+func (c Caller) CanAccess(ownerID int) bool {
+	return c.IsAdmin() || c.UserID == ownerID
+}
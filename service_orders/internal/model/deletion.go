@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Политики обработки заказов удаляемого пользователя. Завершённые заказы
+// (и всё, что осталось после применения политики) обезличиваются: у них
+// больше нет владельца, но история продаж сохраняется.
+//
+//	cancel    - открытые заказы (created, paid) отменяются; если есть
+//	            отправленный заказ, удаление блокируется
+//	anonymize - статусы не трогаем, обезличиваем все заказы
+//	block     - пока есть незавершённые заказы, удалять пользователя нельзя
+const (
+	DeletionPolicyCancel    = "cancel"
+	DeletionPolicyAnonymize = "anonymize"
+	DeletionPolicyBlock     = "block"
+)
+
+// AnonymousUserID - владелец обезличенного заказа. Такого пользователя нет,
+// поэтому доступ к заказу остаётся только у админа.
+const AnonymousUserID = 0
+
+const (
+	DeletionAllowed = "allowed"
+	DeletionBlocked = "blocked"
+)
+
+// UserDeletion - запись аудита: что решили с заказами удаляемого пользователя.
+type UserDeletion struct {
+	ID                 int       `json:"id"`
+	UserID             int       `json:"userId"`
+	Policy             string    `json:"policy"`
+	Decision           string    `json:"decision"`
+	CanceledOrderIDs   []int     `json:"canceledOrderIds"`   // отменены и обезличены
+	AnonymizedOrderIDs []int     `json:"anonymizedOrderIds"` // только обезличены, статус прежний
+	BlockingOrderIDs   []int     `json:"blockingOrderIds"`
+	RequestID          string    `json:"requestId,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+func IsValidDeletionPolicy(policy string) bool {
+	switch policy {
+	case DeletionPolicyCancel, DeletionPolicyAnonymize, DeletionPolicyBlock:
+		return true
+	}
+	return false
+}
+
+// IsCompletedStatus - заказ дошёл до конца жизненного цикла (возврат
+// после доставки тоже считаем завершением).
+func IsCompletedStatus(status string) bool {
+	switch status {
+	case StatusDelivered, StatusCanceled, StatusRefunded:
+		return true
+	}
+	return false
+}
@@ -1,10 +1,23 @@
 package model
 
-import "errors"
+import (
+	"common/pagination"
+	"errors"
+)
 
 var (
 	ErrOrderNotFound         = errors.New("order not found")
 	ErrMissingRequiredFields = errors.New("missing required fields")
 	ErrInvalidPrice          = errors.New("invalid price")
+	ErrInvalidQuantity       = errors.New("invalid quantity")
+	ErrNoItems               = errors.New("order must contain at least one item")
 	ErrUserNotFound          = errors.New("user not found")
+	ErrInvalidStatus         = errors.New("invalid status")
+	ErrInvalidTransition     = errors.New("status transition is not allowed")
+	ErrForbidden             = errors.New("access to the order is forbidden")
+	ErrItemsLocked           = errors.New("items can be changed only while the order is created")
+	ErrInvalidLimit          = pagination.ErrInvalidLimit
+	ErrInvalidSort           = pagination.ErrInvalidSort
+	ErrInvalidCursor         = pagination.ErrInvalidCursor
+	ErrUserHasActiveOrders   = errors.New("user has orders in progress")
 )
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"common/pagination"
+	"time"
+)
+
+type OrderFilter struct {
+	UserID      *int
+	Status      string
+	MinTotal    *int
+	MaxTotal    *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+type OrderListQuery struct {
+	Filter OrderFilter
+	Sort   []pagination.SortField // id ASC всегда добавляется последним
+	Limit  int
+	After  *pagination.Cursor
+}
+
+type OrderPage struct {
+	Items      []Order `json:"items"`
+	NextCursor *string `json:"nextCursor"` // null - это последняя страница
+	Total      int     `json:"total"`
+}
+
+// OrderSortFields - поля, по которым разрешена сортировка.
+var OrderSortFields = []string{"id", "name", "status", "total", "createdAt", "updatedAt"}
+
+// OrderSortAliases - старые имена полей. Цены у заказа больше нет, есть total.
+var OrderSortAliases = map[string]string{"price": "total"}
+
+// SortKey - ключ сортировки заказа для pagination.Item.
+func (o Order) SortKey(field string) string {
+	switch field {
+	case "name":
+		return o.Name
+	case "status":
+		return o.Status
+	case "total":
+		return pagination.IntKey(int64(o.Total))
+	case "createdAt":
+		return pagination.TimeKey(o.CreatedAt)
+	case "updatedAt":
+		return pagination.TimeKey(o.UpdatedAt)
+	default:
+		return pagination.IntKey(int64(o.ID))
+	}
+}
+
+func (o Order) SortID() int { return o.ID }
+
+func (f OrderFilter) Match(o Order) bool {
+	switch {
+	case f.UserID != nil && o.UserId != *f.UserID:
+		return false
+	case f.Status != "" && o.Status != f.Status:
+		return false
+	case f.MinTotal != nil && o.Total < *f.MinTotal:
+		return false
+	case f.MaxTotal != nil && o.Total > *f.MaxTotal:
+		return false
+	case f.CreatedFrom != nil && o.CreatedAt.Before(*f.CreatedFrom):
+		return false
+	case f.CreatedTo != nil && o.CreatedAt.After(*f.CreatedTo):
+		return false
+	}
+	return true
+}
@@ -3,28 +3,55 @@ package model
 import "time"
 
 type Order struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	UserId      int       `json:"userId"`
-	Status      string    `json:"status"`
-	Price       int       `json:"price"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	UserId      int         `json:"userId"`
+	Status      string      `json:"status"`
+	Items       []OrderItem `json:"items"`
+	OrderTotals
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	StatusHistory []StatusChange `json:"statusHistory"`
+}
+
+type OrderItem struct {
+	SKU       string `json:"sku" validate:"max=64"`
+	Title     string `json:"title" validate:"required,max=200"`
+	UnitPrice int    `json:"unitPrice" validate:"min=0"`
+	Quantity  int    `json:"quantity" validate:"min=1,max=10000"`
+	LineTotal int    `json:"lineTotal"` // считается сервисом, от клиента игнорируется
+}
+
+// OrderTotals считает OrderService по позициям заказа, клиент их не передаёт.
+type OrderTotals struct {
+	Subtotal  int `json:"subtotal"`
+	Total     int `json:"total"` // пока скидок и доставки нет, совпадает с subtotal
+	ItemCount int `json:"itemCount"`
 }
 
+// Правила validate проверяются при разборе тела запроса (validate.DecodeJSON).
 type CreateOrderRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       int    `json:"price"`
-	UserId      int    `json:"userId"`
-	Status      string `json:"status"`
+	Name        string      `json:"name" validate:"required,max=200"`
+	Description string      `json:"description" validate:"max=2000"`
+	UserId      int         `json:"userId" validate:"min=0"` // 0 - заказ на себя
+	Status      string      `json:"status" validate:"omitempty,oneof=created"`
+	Items       []OrderItem `json:"items" validate:"required,max=100"`
+
+	Totals OrderTotals `json:"-"`
 }
 
 type UpdateOrderRequest struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       int    `json:"price"`
-	Status      string `json:"status"`
-}
\ No newline at end of file
+	ID          int         `json:"id" validate:"required,min=1"`
+	Name        string      `json:"name" validate:"max=200"`
+	Description string      `json:"description" validate:"max=2000"`
+	Status      string      `json:"status" validate:"omitempty,oneof=created paid shipped delivered canceled refunded"`
+	Items       []OrderItem `json:"items" validate:"max=100"` // nil - позиции не меняются
+
+	Totals OrderTotals `json:"-"`
+	// ExpectedStatus - статус, от которого сервис проверял переход. Репозиторий
+	// меняет заказ, только если статус с тех пор не изменился, иначе -
+	// ErrInvalidTransition. Пустой - без проверки.
+	ExpectedStatus string `json:"-"`
+}
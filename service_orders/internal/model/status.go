@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// Жизненный цикл заказа:
+//
+//	created -> paid -> shipped -> delivered
+//	created, paid -> canceled
+//	paid, delivered -> refunded
+const (
+	StatusCreated   = "created"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCanceled  = "canceled"
+	StatusRefunded  = "refunded"
+)
+
+var statusTransitions = map[string][]string{
+	StatusCreated:   {StatusPaid, StatusCanceled},
+	StatusPaid:      {StatusShipped, StatusCanceled, StatusRefunded},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {StatusRefunded},
+	StatusCanceled:  {},
+	StatusRefunded:  {},
+}
+
+// StatusChange - запись о переходе заказа в новый статус.
+type StatusChange struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// adminStatuses - переводы, которые делает только администратор: отгрузка,
+// доставка и возврат денег. Владельцу заказа остаются оплата и отмена.
+var adminStatuses = map[string]bool{
+	StatusShipped:   true,
+	StatusDelivered: true,
+	StatusRefunded:  true,
+}
+
+// RequiresAdmin - перевести заказ в status может только администратор.
+func RequiresAdmin(status string) bool {
+	return adminStatuses[status]
+}
+
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+func CanTransition(from, to string) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"common/events"
+	"context"
 	"service_orders/internal/model"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -10,6 +14,9 @@ type InMemoryOrderRepository struct {
 	mu      sync.RWMutex
 	storage map[int]model.Order
 	nextID  int
+	outbox  events.MemoryOutbox
+
+	deletions []model.UserDeletion
 }
 
 func NewInMemoryOrderRepository() *InMemoryOrderRepository {
@@ -18,43 +25,64 @@ func NewInMemoryOrderRepository() *InMemoryOrderRepository {
 		nextID:  4,
 	}
 
+	now := time.Now()
+
 	r.storage[1] = model.Order{
 		ID:          1,
 		Name:        "Pizza Margherita",
 		Description: "Classic pizza with tomatoes and cheese",
-		Price:       1200,
-		Status:      "canceled",
+		Items: []model.OrderItem{
+			{SKU: "PIZZA-MARGHERITA", Title: "Pizza Margherita", UnitPrice: 1200, Quantity: 1, LineTotal: 1200},
+		},
+		OrderTotals: model.OrderTotals{Subtotal: 1200, Total: 1200, ItemCount: 1},
+		Status:      model.StatusCanceled,
 		UserId:      1,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		StatusHistory: []model.StatusChange{
+			{To: model.StatusCanceled, At: now},
+		},
 	}
 
 	r.storage[2] = model.Order{
 		ID:          2,
 		Name:        "Burger XXL",
 		Description: "Double beef burger with fries",
-		Price:       1500,
-		Status:      "delivered",
+		Items: []model.OrderItem{
+			{SKU: "BURGER-XXL", Title: "Burger XXL", UnitPrice: 1200, Quantity: 1, LineTotal: 1200},
+			{SKU: "FRIES", Title: "Fries", UnitPrice: 300, Quantity: 1, LineTotal: 300},
+		},
+		OrderTotals: model.OrderTotals{Subtotal: 1500, Total: 1500, ItemCount: 2},
+		Status:      model.StatusDelivered,
 		UserId:      1,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		StatusHistory: []model.StatusChange{
+			{To: model.StatusDelivered, At: now},
+		},
 	}
 
 	r.storage[3] = model.Order{
 		ID:          3,
 		Name:        "Latte",
 		Description: "Coffee latte 400ml",
-		Price:       450,
-		Status:      "delivered",
+		Items: []model.OrderItem{
+			{SKU: "LATTE-400", Title: "Latte 400ml", UnitPrice: 450, Quantity: 1, LineTotal: 450},
+		},
+		OrderTotals: model.OrderTotals{Subtotal: 450, Total: 450, ItemCount: 1},
+		Status:      model.StatusDelivered,
 		UserId:      2,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		StatusHistory: []model.StatusChange{
+			{To: model.StatusDelivered, At: now},
+		},
 	}
 
 	return r
 }
 
-func (r *InMemoryOrderRepository) GetByID(id int) (*model.Order, error) {
+func (r *InMemoryOrderRepository) GetByID(_ context.Context, id int) (*model.Order, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -67,7 +95,7 @@ func (r *InMemoryOrderRepository) GetByID(id int) (*model.Order, error) {
 	return &o, nil
 }
 
-func (r *InMemoryOrderRepository) GetAll() ([]model.Order, error) {
+func (r *InMemoryOrderRepository) GetAll(_ context.Context) ([]model.Order, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -79,31 +107,70 @@ func (r *InMemoryOrderRepository) GetAll() ([]model.Order, error) {
 	return orders, nil
 }
 
-func (r *InMemoryOrderRepository) Create(req *model.CreateOrderRequest) (int, error) {
+func (r *InMemoryOrderRepository) List(_ context.Context, q model.OrderListQuery) ([]model.Order, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]model.Order, 0, len(r.storage))
+	for _, o := range r.storage {
+		all = append(all, o)
+	}
+
+	items, total := listOrders(all, q)
+	return items, total, nil
+}
+
+func (r *InMemoryOrderRepository) GetByUserID(_ context.Context, userID int) ([]model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := make([]model.Order, 0)
+	for _, o := range r.storage {
+		if o.UserId == userID {
+			orders = append(orders, o)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+
+	return orders, nil
+}
+
+func (r *InMemoryOrderRepository) Create(_ context.Context, req *model.CreateOrderRequest, evs ...events.Event) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	id := r.nextID
 	r.nextID++
 
+	now := time.Now()
 	order := model.Order{
 		ID:          id,
 		Name:        req.Name,
 		Description: req.Description,
 		UserId:      req.UserId,
 		Status:      req.Status,
-		Price:       req.Price,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Items:       copyItems(req.Items),
+		OrderTotals: req.Totals,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		StatusHistory: []model.StatusChange{
+			{To: req.Status, At: now},
+		},
 	}
 
 	r.storage[id] = order
 
+	events.FillAggregateID(evs, strconv.Itoa(id))
+	r.outbox.Add(evs...)
+
 	o := order
 	return o.ID, nil
 }
 
-func (r *InMemoryOrderRepository) Update(req *model.UpdateOrderRequest) error {
+func (r *InMemoryOrderRepository) Update(_ context.Context, req *model.UpdateOrderRequest, evs ...events.Event) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -111,19 +178,32 @@ func (r *InMemoryOrderRepository) Update(req *model.UpdateOrderRequest) error {
 	if !ok {
 		return model.ErrOrderNotFound
 	}
+	// заказ успели перевести в другой статус после того, как сервис его прочитал
+	if req.ExpectedStatus != "" && order.Status != req.ExpectedStatus {
+		return model.ErrInvalidTransition
+	}
+
+	now := time.Now()
+	if req.Status != order.Status {
+		history := make([]model.StatusChange, len(order.StatusHistory), len(order.StatusHistory)+1)
+		copy(history, order.StatusHistory)
+		order.StatusHistory = append(history, model.StatusChange{From: order.Status, To: req.Status, At: now})
+	}
 
 	order.Name = req.Name
 	order.Description = req.Description
-	order.Price = req.Price
+	order.Items = copyItems(req.Items)
+	order.OrderTotals = req.Totals
 	order.Status = req.Status
-	order.UpdatedAt = time.Now()
+	order.UpdatedAt = now
 
 	r.storage[req.ID] = order
+	r.outbox.Add(evs...)
 
 	return nil
 }
 
-func (r *InMemoryOrderRepository) Delete(id int) error {
+func (r *InMemoryOrderRepository) Delete(_ context.Context, id int, evs ...events.Event) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -133,6 +213,72 @@ func (r *InMemoryOrderRepository) Delete(id int) error {
 	}
 
 	delete(r.storage, id)
+	r.outbox.Add(evs...)
+
+	return nil
+}
+
+func (r *InMemoryOrderRepository) ApplyUserDeletion(_ context.Context, d *model.UserDeletion, evs ...events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := append(append([]int(nil), d.CanceledOrderIDs...), d.AnonymizedOrderIDs...)
+	for _, id := range ids {
+		if _, ok := r.storage[id]; !ok {
+			return model.ErrOrderNotFound
+		}
+	}
+
+	now := time.Now()
+	for _, id := range d.CanceledOrderIDs {
+		order := r.storage[id]
+		history := make([]model.StatusChange, len(order.StatusHistory), len(order.StatusHistory)+1)
+		copy(history, order.StatusHistory)
+		order.StatusHistory = append(history, model.StatusChange{From: order.Status, To: model.StatusCanceled, At: now})
+		order.Status = model.StatusCanceled
+		order.UserId = model.AnonymousUserID
+		order.UpdatedAt = now
+		r.storage[id] = order
+	}
+	for _, id := range d.AnonymizedOrderIDs {
+		order := r.storage[id]
+		order.UserId = model.AnonymousUserID
+		order.UpdatedAt = now
+		r.storage[id] = order
+	}
+
+	d.ID = len(r.deletions) + 1
+	d.CreatedAt = now
+	r.deletions = append(r.deletions, *d)
+	r.outbox.Add(evs...)
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (r *InMemoryOrderRepository) ListUserDeletions(_ context.Context, userID *int) ([]model.UserDeletion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]model.UserDeletion, 0)
+	for i := len(r.deletions) - 1; i >= 0; i-- {
+		if userID == nil || r.deletions[i].UserID == *userID {
+			res = append(res, r.deletions[i])
+		}
+	}
+
+	return res, nil
+}
+
+func (r *InMemoryOrderRepository) PendingEvents(limit int) ([]events.Event, error) {
+	return r.outbox.PendingEvents(limit)
+}
+
+func (r *InMemoryOrderRepository) MarkPublished(ids ...string) error {
+	return r.outbox.MarkPublished(ids...)
+}
+
+func copyItems(items []model.OrderItem) []model.OrderItem {
+	res := make([]model.OrderItem, len(items))
+	copy(res, items)
+	return res
+}
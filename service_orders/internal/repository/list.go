@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"common/pagination"
+	"service_orders/internal/model"
+)
+
+// listOrders - фильтр, сортировка и keyset-пагинация для in-memory репозитория.
+func listOrders(all []model.Order, q model.OrderListQuery) ([]model.Order, int) {
+	filtered := make([]model.Order, 0, len(all))
+	for _, o := range all {
+		if q.Filter.Match(o) {
+			filtered = append(filtered, o)
+		}
+	}
+
+	return pagination.Slice(filtered, q.Sort, q.After, q.Limit), len(filtered)
+}
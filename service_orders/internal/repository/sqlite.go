@@ -0,0 +1,772 @@
+package repository
+
+import (
+	"common/events"
+	"common/pagination"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"service_orders/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteOrderRepository хранит заказы во встроенной SQLite (чистый Go, без cgo
+// и без отдельного сервера БД).
+type SQLiteOrderRepository struct {
+	db *sql.DB
+}
+
+// migrations применяются по порядку, номер версии = индекс + 1.
+// Уже выпущенные миграции не редактируем, только дописываем новые.
+var migrations = []string{
+	// 1: схема заказов
+	`CREATE TABLE orders (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT    NOT NULL,
+		description TEXT    NOT NULL DEFAULT '',
+		user_id     INTEGER NOT NULL,
+		status      TEXT    NOT NULL,
+		price       INTEGER NOT NULL,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL
+	);
+	CREATE INDEX idx_orders_user_id ON orders(user_id);
+	CREATE INDEX idx_orders_status ON orders(status);
+	CREATE INDEX idx_orders_created_at ON orders(created_at);`,
+
+	// 2: те же стартовые заказы, что и в in-memory репозитории
+	`INSERT INTO orders (id, name, description, user_id, status, price, created_at, updated_at) VALUES
+		(1, 'Pizza Margherita', 'Classic pizza with tomatoes and cheese', 1, 'canceled', 1200, CAST(unixepoch('subsec') * 1e9 AS INTEGER), CAST(unixepoch('subsec') * 1e9 AS INTEGER)),
+		(2, 'Burger XXL', 'Double beef burger with fries', 1, 'delivered', 1500, CAST(unixepoch('subsec') * 1e9 AS INTEGER), CAST(unixepoch('subsec') * 1e9 AS INTEGER)),
+		(3, 'Latte', 'Coffee latte 400ml', 2, 'delivered', 450, CAST(unixepoch('subsec') * 1e9 AS INTEGER), CAST(unixepoch('subsec') * 1e9 AS INTEGER));`,
+
+	// 3: история переходов статусов; для существующих заказов - одна запись с текущим статусом
+	`CREATE TABLE order_status_history (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id    INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		from_status TEXT    NOT NULL DEFAULT '',
+		to_status   TEXT    NOT NULL,
+		changed_at  INTEGER NOT NULL
+	);
+	CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id);
+	INSERT INTO order_status_history (order_id, to_status, changed_at)
+		SELECT id, status, updated_at FROM orders;`,
+
+	// 4: позиции заказа и посчитанные итоги; старая цена превращается в одну позицию
+	`CREATE TABLE order_items (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id   INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		sku        TEXT    NOT NULL DEFAULT '',
+		title      TEXT    NOT NULL,
+		unit_price INTEGER NOT NULL,
+		quantity   INTEGER NOT NULL
+	);
+	CREATE INDEX idx_order_items_order_id ON order_items(order_id);
+	ALTER TABLE orders ADD COLUMN subtotal INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN total INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN item_count INTEGER NOT NULL DEFAULT 0;
+	INSERT INTO order_items (order_id, position, title, unit_price, quantity)
+		SELECT id, 0, name, price, 1 FROM orders;
+	UPDATE orders SET subtotal = price, total = price, item_count = 1;
+	ALTER TABLE orders DROP COLUMN price;`,
+
+	// 5: outbox доменных событий; event хранит конверт целиком
+	`CREATE TABLE outbox (
+		seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id     TEXT    NOT NULL UNIQUE,
+		event        TEXT    NOT NULL,
+		created_at   INTEGER NOT NULL,
+		published_at INTEGER
+	);
+	CREATE INDEX idx_outbox_pending ON outbox(seq) WHERE published_at IS NULL;`,
+
+	// 6: аудит обработки заказов удалённых пользователей; списки id - JSON-массивы
+	`CREATE TABLE user_deletions (
+		id                   INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id              INTEGER NOT NULL,
+		policy               TEXT    NOT NULL,
+		decision             TEXT    NOT NULL,
+		canceled_order_ids   TEXT    NOT NULL DEFAULT '[]',
+		anonymized_order_ids TEXT    NOT NULL DEFAULT '[]',
+		blocking_order_ids   TEXT    NOT NULL DEFAULT '[]',
+		request_id           TEXT    NOT NULL DEFAULT '',
+		created_at           INTEGER NOT NULL
+	);
+	CREATE INDEX idx_user_deletions_user_id ON user_deletions(user_id);`,
+}
+
+const orderColumns = `id, name, description, user_id, status, subtotal, total, item_count, created_at, updated_at`
+
+func NewSQLiteOrderRepository(path string) (*SQLiteOrderRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite всё равно сериализует запись, одно соединение избавляет от SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	r := &SQLiteOrderRepository{db: db}
+	if err := r.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	return r, nil
+}
+
+func (r *SQLiteOrderRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteOrderRepository) migrate() error {
+	ctx := context.Background()
+	if _, err := r.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		err := r.withTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().UnixNano())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *SQLiteOrderRepository) GetByID(ctx context.Context, id int) (*model.Order, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id)
+
+	order, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []model.Order{*order}
+	if err := r.loadRelations(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return &orders[0], nil
+}
+
+func (r *SQLiteOrderRepository) GetAll(ctx context.Context) ([]model.Order, error) {
+	return r.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders ORDER BY id`)
+}
+
+func (r *SQLiteOrderRepository) GetByUserID(ctx context.Context, userID int) ([]model.Order, error) {
+	return r.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY id`, userID)
+}
+
+// sortColumns - поле сортировки -> колонка и признак числового значения
+// (ключ курсора для таких полей нужно превратить обратно в число).
+var sortColumns = map[string]struct {
+	column  string
+	numeric bool
+}{
+	"id":        {"id", true},
+	"name":      {"name", false},
+	"status":    {"status", false},
+	"total":     {"total", true},
+	"createdAt": {"created_at", true},
+	"updatedAt": {"updated_at", true},
+}
+
+func (r *SQLiteOrderRepository) List(ctx context.Context, q model.OrderListQuery) ([]model.Order, int, error) {
+	var where []string
+	var args []any
+
+	f := q.Filter
+	if f.UserID != nil {
+		where, args = append(where, "user_id = ?"), append(args, *f.UserID)
+	}
+	if f.Status != "" {
+		where, args = append(where, "status = ?"), append(args, f.Status)
+	}
+	if f.MinTotal != nil {
+		where, args = append(where, "total >= ?"), append(args, *f.MinTotal)
+	}
+	if f.MaxTotal != nil {
+		where, args = append(where, "total <= ?"), append(args, *f.MaxTotal)
+	}
+	if f.CreatedFrom != nil {
+		where, args = append(where, "created_at >= ?"), append(args, f.CreatedFrom.UnixNano())
+	}
+	if f.CreatedTo != nil {
+		where, args = append(where, "created_at <= ?"), append(args, f.CreatedTo.UnixNano())
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := make([]string, 0, len(q.Sort)+1)
+	for _, s := range q.Sort {
+		col := sortColumns[s.Field].column
+		if s.Desc {
+			col += " DESC"
+		}
+		order = append(order, col)
+	}
+	order = append(order, "id")
+
+	if q.After != nil {
+		cond, condArgs, err := keysetCondition(q.Sort, q.After)
+		if err != nil {
+			return nil, 0, err
+		}
+		where, args = append(where, cond), append(args, condArgs...)
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	orders, err := r.queryOrders(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// keysetCondition строит "строго после курсора" для сортировки со смешанными
+// направлениями: (a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?).
+func keysetCondition(fields []pagination.SortField, after *pagination.Cursor) (string, []any, error) {
+	values := make([]any, len(fields))
+	for i, f := range fields {
+		if !sortColumns[f.Field].numeric {
+			values[i] = after.Keys[i]
+			continue
+		}
+		n, err := pagination.ParseIntKey(after.Keys[i])
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = n
+	}
+
+	var ors []string
+	var args []any
+	for i := 0; i <= len(fields); i++ {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, sortColumns[fields[j].Field].column+" = ?")
+			args = append(args, values[j])
+		}
+		if i < len(fields) {
+			op := " > ?"
+			if fields[i].Desc {
+				op = " < ?"
+			}
+			ands = append(ands, sortColumns[fields[i].Field].column+op)
+			args = append(args, values[i])
+		} else {
+			ands = append(ands, "id > ?")
+			args = append(args, after.ID)
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+func (r *SQLiteOrderRepository) Create(ctx context.Context, req *model.CreateOrderRequest, evs ...events.Event) (int, error) {
+	var id int64
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UnixNano()
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO orders (name, description, user_id, status, subtotal, total, item_count, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			req.Name, req.Description, req.UserId, req.Status,
+			req.Totals.Subtotal, req.Totals.Total, req.Totals.ItemCount, now, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err := replaceItems(ctx, tx, id, req.Items); err != nil {
+			return err
+		}
+		if err := insertStatusChange(ctx, tx, id, "", req.Status, now); err != nil {
+			return err
+		}
+
+		events.FillAggregateID(evs, strconv.FormatInt(id, 10))
+		return insertEvents(ctx, tx, evs)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (r *SQLiteOrderRepository) Update(ctx context.Context, req *model.UpdateOrderRequest, evs ...events.Event) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		var current string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = ?`, req.ID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if req.ExpectedStatus != "" {
+			current = req.ExpectedStatus
+		}
+
+		// compare-and-set по статусу: заказ могли перевести в другой статус
+		// после того, как сервис его прочитал
+		now := time.Now().UnixNano()
+		res, err := tx.ExecContext(ctx,
+			`UPDATE orders SET name = ?, description = ?, status = ?, subtotal = ?, total = ?, item_count = ?, updated_at = ?
+			 WHERE id = ? AND status = ?`,
+			req.Name, req.Description, req.Status,
+			req.Totals.Subtotal, req.Totals.Total, req.Totals.ItemCount, now, req.ID, current,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return model.ErrInvalidTransition
+		}
+
+		if err := replaceItems(ctx, tx, int64(req.ID), req.Items); err != nil {
+			return err
+		}
+
+		if current != req.Status {
+			if err := insertStatusChange(ctx, tx, int64(req.ID), current, req.Status, now); err != nil {
+				return err
+			}
+		}
+		return insertEvents(ctx, tx, evs)
+	})
+}
+
+func (r *SQLiteOrderRepository) Delete(ctx context.Context, id int, evs ...events.Event) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		if err := requireAffected(res); err != nil {
+			return err
+		}
+		return insertEvents(ctx, tx, evs)
+	})
+}
+
+func (r *SQLiteOrderRepository) ApplyUserDeletion(ctx context.Context, d *model.UserDeletion, evs ...events.Event) error {
+	canceled, err := json.Marshal(d.CanceledOrderIDs)
+	if err != nil {
+		return err
+	}
+	anonymized, err := json.Marshal(d.AnonymizedOrderIDs)
+	if err != nil {
+		return err
+	}
+	blocking, err := json.Marshal(d.BlockingOrderIDs)
+	if err != nil {
+		return err
+	}
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UnixNano()
+
+		for _, id := range d.CanceledOrderIDs {
+			var current string
+			err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = ?`, id).Scan(&current)
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrOrderNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE orders SET status = ?, user_id = ?, updated_at = ? WHERE id = ?`, model.StatusCanceled, model.AnonymousUserID, now, id)
+			if err != nil {
+				return err
+			}
+			if err := insertStatusChange(ctx, tx, int64(id), current, model.StatusCanceled, now); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range d.AnonymizedOrderIDs {
+			res, err := tx.ExecContext(ctx, `UPDATE orders SET user_id = ?, updated_at = ? WHERE id = ?`, model.AnonymousUserID, now, id)
+			if err != nil {
+				return err
+			}
+			if err := requireAffected(res); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO user_deletions (user_id, policy, decision, canceled_order_ids, anonymized_order_ids, blocking_order_ids, request_id, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.UserID, d.Policy, d.Decision, string(canceled), string(anonymized), string(blocking), d.RequestID, now,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		d.ID = int(id)
+		d.CreatedAt = time.Unix(0, now)
+
+		return insertEvents(ctx, tx, evs)
+	})
+}
+
+func (r *SQLiteOrderRepository) ListUserDeletions(ctx context.Context, userID *int) ([]model.UserDeletion, error) {
+	query := `SELECT id, user_id, policy, decision, canceled_order_ids, anonymized_order_ids, blocking_order_ids, request_id, created_at
+		FROM user_deletions`
+	var args []any
+	if userID != nil {
+		query += ` WHERE user_id = ?`
+		args = append(args, *userID)
+	}
+	query += ` ORDER BY id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.UserDeletion, 0)
+	for rows.Next() {
+		var (
+			d                              model.UserDeletion
+			canceled, anonymized, blocking string
+			createdAt                      int64
+		)
+		err := rows.Scan(&d.ID, &d.UserID, &d.Policy, &d.Decision, &canceled, &anonymized, &blocking, &d.RequestID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range []struct {
+			raw string
+			dst *[]int
+		}{{canceled, &d.CanceledOrderIDs}, {anonymized, &d.AnonymizedOrderIDs}, {blocking, &d.BlockingOrderIDs}} {
+			if err := json.Unmarshal([]byte(col.raw), col.dst); err != nil {
+				return nil, fmt.Errorf("decode user deletion %d: %w", d.ID, err)
+			}
+		}
+		d.CreatedAt = time.Unix(0, createdAt)
+		res = append(res, d)
+	}
+
+	return res, rows.Err()
+}
+
+func (r *SQLiteOrderRepository) PendingEvents(limit int) ([]events.Event, error) {
+	query := `SELECT event FROM outbox WHERE published_at IS NULL ORDER BY seq`
+	args := []any{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]events.Event, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var ev events.Event
+		if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+			return nil, fmt.Errorf("decode outbox event: %w", err)
+		}
+		res = append(res, ev)
+	}
+
+	return res, rows.Err()
+}
+
+// MarkPublished не удаляет события, а помечает: таблица заодно служит журналом.
+func (r *SQLiteOrderRepository) MarkPublished(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := []any{time.Now().UnixNano()}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	_, err := r.db.Exec(
+		`UPDATE outbox SET published_at = ? WHERE event_id IN (`+strings.Join(placeholders, ",")+`)`,
+		args...,
+	)
+	return err
+}
+
+// withTx выполняет fn в транзакции: commit при успехе, rollback при ошибке.
+func (r *SQLiteOrderRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLiteOrderRepository) queryOrders(ctx context.Context, query string, args ...any) ([]model.Order, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]model.Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRelations(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// loadRelations подтягивает позиции и историю статусов для всех заказов
+// двумя запросами, без N+1.
+func (r *SQLiteOrderRepository) loadRelations(ctx context.Context, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*model.Order, len(orders))
+	placeholders := make([]string, 0, len(orders))
+	args := make([]any, 0, len(orders))
+	for i := range orders {
+		orders[i].Items = make([]model.OrderItem, 0)
+		orders[i].StatusHistory = make([]model.StatusChange, 0)
+		byID[orders[i].ID] = &orders[i]
+		placeholders = append(placeholders, "?")
+		args = append(args, orders[i].ID)
+	}
+	in := strings.Join(placeholders, ",")
+
+	if err := r.loadItems(ctx, byID, in, args); err != nil {
+		return err
+	}
+	return r.loadHistory(ctx, byID, in, args)
+}
+
+func (r *SQLiteOrderRepository) loadItems(ctx context.Context, byID map[int]*model.Order, in string, args []any) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, sku, title, unit_price, quantity FROM order_items
+		 WHERE order_id IN (`+in+`) ORDER BY order_id, position`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID int
+			item    model.OrderItem
+		)
+		if err := rows.Scan(&orderID, &item.SKU, &item.Title, &item.UnitPrice, &item.Quantity); err != nil {
+			return err
+		}
+		item.LineTotal = item.UnitPrice * item.Quantity
+
+		if o, ok := byID[orderID]; ok {
+			o.Items = append(o.Items, item)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *SQLiteOrderRepository) loadHistory(ctx context.Context, byID map[int]*model.Order, in string, args []any) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_id, from_status, to_status, changed_at FROM order_status_history
+		 WHERE order_id IN (`+in+`) ORDER BY id`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID   int
+			change    model.StatusChange
+			changedAt int64
+		)
+		if err := rows.Scan(&orderID, &change.From, &change.To, &changedAt); err != nil {
+			return err
+		}
+		change.At = time.Unix(0, changedAt)
+
+		if o, ok := byID[orderID]; ok {
+			o.StatusHistory = append(o.StatusHistory, change)
+		}
+	}
+
+	return rows.Err()
+}
+
+func replaceItems(ctx context.Context, tx *sql.Tx, orderID int64, items []model.OrderItem) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = ?`, orderID); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO order_items (order_id, position, sku, title, unit_price, quantity) VALUES (?, ?, ?, ?, ?, ?)`,
+			orderID, i, item.SKU, item.Title, item.UnitPrice, item.Quantity,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertEvents(ctx context.Context, tx *sql.Tx, evs []events.Event) error {
+	for _, ev := range evs {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO outbox (event_id, event, created_at) VALUES (?, ?, ?)`,
+			ev.ID, string(data), ev.OccurredAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertStatusChange(ctx context.Context, tx *sql.Tx, orderID int64, from, to string, at int64) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, changed_at) VALUES (?, ?, ?, ?)`,
+		orderID, from, to, at,
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (*model.Order, error) {
+	var (
+		o                    model.Order
+		createdAt, updatedAt int64
+	)
+
+	err := row.Scan(
+		&o.ID, &o.Name, &o.Description, &o.UserId, &o.Status,
+		&o.Subtotal, &o.Total, &o.ItemCount, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.CreatedAt = time.Unix(0, createdAt)
+	o.UpdatedAt = time.Unix(0, updatedAt)
+	return &o, nil
+}
+
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrOrderNotFound
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package repository_test
+
+import (
+	"common/events"
+	"context"
+	"fmt"
+	"path/filepath"
+	"service_orders/internal/model"
+	"service_orders/internal/repository"
+	"strconv"
+	"testing"
+)
+
+func newTestSQLiteRepo(t *testing.T) (*repository.SQLiteOrderRepository, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "orders.db")
+	repo, err := repository.NewSQLiteOrderRepository(path)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo, path
+}
+
+func TestSQLiteOrderRepository_SeedsOnFirstStart(t *testing.T) {
+	repo, _ := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	orders, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("expected 3 seeded orders, got %d", len(orders))
+	}
+	if orders[0].Name != "Pizza Margherita" || orders[0].Status != "canceled" {
+		t.Errorf("unexpected first order: %+v", orders[0])
+	}
+	// старая цена после миграции превращается в одну позицию
+	if len(orders[0].Items) != 1 || orders[0].Items[0].UnitPrice != 1200 || orders[0].Total != 1200 {
+		t.Errorf("unexpected migrated items: %+v", orders[0])
+	}
+}
+
+func TestSQLiteOrderRepository_CRUD(t *testing.T) {
+	repo, _ := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &model.CreateOrderRequest{
+		Name:   "Soup",
+		UserId: 2,
+		Status: "created",
+		Items: []model.OrderItem{
+			{SKU: "SOUP", Title: "Soup", UnitPrice: 300, Quantity: 1},
+		},
+		Totals: model.OrderTotals{Subtotal: 300, Total: 300, ItemCount: 1},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if id != 4 {
+		t.Errorf("expected id 4 after seeds, got %d", id)
+	}
+
+	err = repo.Update(ctx, &model.UpdateOrderRequest{
+		ID:     id,
+		Name:   "Big soup",
+		Status: "paid",
+		Items: []model.OrderItem{
+			{SKU: "SOUP", Title: "Soup", UnitPrice: 300, Quantity: 1},
+			{SKU: "BREAD", Title: "Bread", UnitPrice: 100, Quantity: 2},
+		},
+		Totals: model.OrderTotals{Subtotal: 500, Total: 500, ItemCount: 3},
+	})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	o, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if o.Name != "Big soup" || o.Total != 500 || o.ItemCount != 3 || o.Status != "paid" || o.UserId != 2 {
+		t.Errorf("unexpected order after update: %+v", o)
+	}
+	if len(o.Items) != 2 || o.Items[1].Title != "Bread" || o.Items[1].LineTotal != 200 {
+		t.Errorf("unexpected items after update: %+v", o.Items)
+	}
+	if len(o.StatusHistory) != 2 || o.StatusHistory[1].From != "created" || o.StatusHistory[1].To != "paid" {
+		t.Errorf("unexpected status history: %+v", o.StatusHistory)
+	}
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, id); err != model.ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound, got: %v", err)
+	}
+	if err := repo.Delete(ctx, id); err != model.ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound on second delete, got: %v", err)
+	}
+	if err := repo.Update(ctx, &model.UpdateOrderRequest{ID: id, Name: "x"}); err != model.ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound on update, got: %v", err)
+	}
+}
+
+func TestSQLiteOrderRepository_GetByUserID(t *testing.T) {
+	repo, _ := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	orders, err := repo.GetByUserID(ctx, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected 2 orders of user 1, got %d", len(orders))
+	}
+	for _, o := range orders {
+		if o.UserId != 1 {
+			t.Errorf("unexpected order of another user: %+v", o)
+		}
+	}
+}
+
+func TestSQLiteOrderRepository_SurvivesReopen(t *testing.T) {
+	repo, path := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &model.CreateOrderRequest{
+		Name:   "Tea",
+		UserId: 3,
+		Status: "created",
+		Items:  []model.OrderItem{{Title: "Tea", UnitPrice: 100, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	repo.Close()
+
+	reopened, err := repository.NewSQLiteOrderRepository(path)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, id); err != nil {
+		t.Fatalf("expected order after reopen, got: %v", err)
+	}
+
+	// миграции не должны применяться повторно (сиды не дублируются)
+	orders, _ := reopened.GetAll(ctx)
+	if len(orders) != 4 {
+		t.Fatalf("expected 4 orders, got %d", len(orders))
+	}
+}
+
+func TestSQLiteOrderRepository_Outbox(t *testing.T) {
+	repo, _ := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	created, _ := events.New(ctx, events.SourceOrders, "", events.OrderCreated{UserID: 1, Status: "created", Total: 100, ItemCount: 1})
+	id, err := repo.Create(ctx, &model.CreateOrderRequest{
+		Name:   "Tea",
+		UserId: 1,
+		Status: "created",
+		Items:  []model.OrderItem{{Title: "Tea", UnitPrice: 100, Quantity: 1}},
+	}, created)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	// событие неудачной операции в outbox не попадает
+	orphan, _ := events.New(ctx, events.SourceOrders, "999", events.OrderDeleted{UserID: 1})
+	if err := repo.Delete(ctx, 999, orphan); err != model.ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound, got: %v", err)
+	}
+
+	deleted, _ := events.New(ctx, events.SourceOrders, strconv.Itoa(id), events.OrderDeleted{UserID: 1})
+	if err := repo.Delete(ctx, id, deleted); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	pending, err := repo.PendingEvents(10)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != created.ID || pending[1].ID != deleted.ID {
+		t.Fatalf("expected created and deleted events in order, got: %+v", pending)
+	}
+	if pending[0].AggregateID != strconv.Itoa(id) {
+		t.Fatalf("expected aggregate id %d, got: %s", id, pending[0].AggregateID)
+	}
+
+	if err := repo.MarkPublished(created.ID); err != nil {
+		t.Fatalf("mark published failed: %v", err)
+	}
+	pending, _ = repo.PendingEvents(10)
+	if len(pending) != 1 || pending[0].ID != deleted.ID {
+		t.Fatalf("expected only deleted event pending, got: %+v", pending)
+	}
+}
+
+func TestSQLiteOrderRepository_ApplyUserDeletion(t *testing.T) {
+	repo, path := newTestSQLiteRepo(t)
+	ctx := context.Background()
+
+	// в сиде у пользователя 1 два завершённых заказа, у пользователя 2 - один
+	blocked := &model.UserDeletion{Policy: "block", Decision: "blocked", UserID: 2, BlockingOrderIDs: []int{3}}
+	if err := repo.ApplyUserDeletion(ctx, blocked); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	id, err := repo.Create(ctx, &model.CreateOrderRequest{
+		Name:   "Tea",
+		UserId: 1,
+		Status: "created",
+		Items:  []model.OrderItem{{Title: "Tea", UnitPrice: 100, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	d := &model.UserDeletion{
+		UserID:             1,
+		Policy:             "cancel",
+		Decision:           "allowed",
+		CanceledOrderIDs:   []int{id},
+		AnonymizedOrderIDs: []int{1, 2, id},
+		BlockingOrderIDs:   []int{},
+	}
+	if err := repo.ApplyUserDeletion(ctx, d); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if d.ID == 0 || d.CreatedAt.IsZero() {
+		t.Fatalf("expected id and createdAt to be set, got: %+v", d)
+	}
+
+	order, _ := repo.GetByID(ctx, id)
+	if order.Status != "canceled" || order.UserId != model.AnonymousUserID {
+		t.Fatalf("expected canceled anonymous order, got: %+v", order)
+	}
+	if last := order.StatusHistory[len(order.StatusHistory)-1]; last.From != "created" || last.To != "canceled" {
+		t.Fatalf("expected cancel in history, got: %+v", order.StatusHistory)
+	}
+	if left, _ := repo.GetByUserID(ctx, 1); len(left) != 0 {
+		t.Fatalf("expected no orders left for user 1, got: %+v", left)
+	}
+
+	// несуществующий заказ откатывает всю транзакцию
+	bad := &model.UserDeletion{UserID: 2, Policy: "anonymize", Decision: "allowed", AnonymizedOrderIDs: []int{3, 999}}
+	if err := repo.ApplyUserDeletion(ctx, bad); err != model.ErrOrderNotFound {
+		t.Fatalf("expected ErrOrderNotFound, got: %v", err)
+	}
+	if o, _ := repo.GetByID(ctx, 3); o.UserId != 2 {
+		t.Fatalf("expected order 3 to keep its owner after rollback, got: %+v", o)
+	}
+
+	repo.Close()
+	reopened, err := repository.NewSQLiteOrderRepository(path)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	all, err := reopened.ListUserDeletions(ctx, nil)
+	if err != nil || len(all) != 2 || all[0].ID != d.ID {
+		t.Fatalf("expected 2 audit records newest first, got: %+v, %v", all, err)
+	}
+	user := 2
+	byUser, _ := reopened.ListUserDeletions(ctx, &user)
+	if len(byUser) != 1 || byUser[0].Decision != "blocked" || fmt.Sprint(byUser[0].BlockingOrderIDs) != "[3]" {
+		t.Fatalf("unexpected audit for user 2: %+v", byUser)
+	}
+}
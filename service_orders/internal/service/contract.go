@@ -1,16 +1,32 @@
 package service
 
 import (
+	"common/events"
 	"context"
 	"service_orders/internal/model"
 )
 
 type OrderRepository interface {
-	GetByID(id int) (*model.Order, error)
-	GetAll() ([]model.Order, error)
-	Create(req *model.CreateOrderRequest) (int, error)
-	Update(req *model.UpdateOrderRequest) error
-	Delete(id int) error
+	GetByID(ctx context.Context, id int) (*model.Order, error)
+	GetAll(ctx context.Context) ([]model.Order, error)
+	GetByUserID(ctx context.Context, userID int) ([]model.Order, error)
+	// List возвращает не больше q.Limit заказов после q.After и общее число
+	// заказов, подходящих под фильтр (без учёта курсора).
+	List(ctx context.Context, q model.OrderListQuery) ([]model.Order, int, error)
+	// События пишутся атомарно с изменением. У событий Create без AggregateID
+	// репозиторий проставляет id созданного заказа.
+	Create(ctx context.Context, req *model.CreateOrderRequest, evs ...events.Event) (int, error)
+	Update(ctx context.Context, req *model.UpdateOrderRequest, evs ...events.Event) error
+	Delete(ctx context.Context, id int, evs ...events.Event) error
+
+	// ApplyUserDeletion одной транзакцией отменяет и обезличивает заказы из
+	// d.CanceledOrderIDs, обезличивает d.AnonymizedOrderIDs и сохраняет d в
+	// аудит, проставляя ID и CreatedAt.
+	ApplyUserDeletion(ctx context.Context, d *model.UserDeletion, evs ...events.Event) error
+	// ListUserDeletions - записи аудита от новых к старым; nil - по всем пользователям.
+	ListUserDeletions(ctx context.Context, userID *int) ([]model.UserDeletion, error)
+
+	events.Outbox
 }
 
 type UserChecker interface {
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// statusChanges считается там, где переход записан: в заказ статус попадает
+// и через ChangeStatus, и через PUT /orders, и при удалении пользователя.
+var statusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "orders_status_changes_total",
+	Help: "Order status transitions by target status.",
+}, []string{"status"})
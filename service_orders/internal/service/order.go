@@ -1,50 +1,91 @@
 package service
 
 import (
+	"common/events"
+	"common/pagination"
 	"context"
 	"fmt"
+	"log/slog"
 	"service_orders/internal/model"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type OrderService struct {
 	repo OrderRepository
 	userChecker UserChecker
+	deletionPolicy string
 }
 
-func NewOrderService(r OrderRepository, uc UserChecker) *OrderService {
-	return &OrderService{repo: r, userChecker: uc}
+func NewOrderService(r OrderRepository, uc UserChecker, deletionPolicy string) *OrderService {
+	return &OrderService{repo: tracedRepository{r}, userChecker: uc, deletionPolicy: deletionPolicy}
 }
 
-func (s *OrderService) GetOrder(id int) (*model.Order, error) {
-	return s.repo.GetByID(id)
+func (s *OrderService) GetOrder(ctx context.Context, caller model.Caller, id int) (*model.Order, error) {
+	return s.getOwnedOrder(ctx, caller, id)
 }
 
-func (s *OrderService) ListOrders(userID *int) ([]model.Order, error) {
-	orders, err := s.repo.GetAll()
+// ListOrders отдаёт страницу заказов. Без фильтра по пользователю админ видит
+// все заказы, а обычный пользователь - только свои.
+func (s *OrderService) ListOrders(ctx context.Context, caller model.Caller, filter model.OrderFilter, page pagination.Request) (*model.OrderPage, error) {
+	if filter.UserID == nil && !caller.IsAdmin() {
+		filter.UserID = &caller.UserID
+	}
+	if filter.UserID != nil && !caller.CanAccess(*filter.UserID) {
+		return nil, model.ErrForbidden
+	}
+
+	p, err := pagination.Parse(page, model.OrderSortFields, model.OrderSortAliases)
 	if err != nil {
 		return nil, err
 	}
 
-	if userID == nil {
-		return orders, nil
+	// просим на один заказ больше, чтобы понять, есть ли следующая страница
+	orders, total, err := s.repo.List(ctx, model.OrderListQuery{
+		Filter: filter,
+		Sort:   p.Sort,
+		Limit:  p.Limit + 1,
+		After:  p.After,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	filtered := make([]model.Order, 0)
-	for _, o := range orders {
-		if o.UserId == *userID {
-			filtered = append(filtered, o)
-		}
+	res := &model.OrderPage{Items: orders, Total: total}
+	if len(orders) > p.Limit {
+		res.Items = orders[:p.Limit]
+		res.NextCursor = p.NextCursor(res.Items[p.Limit-1])
 	}
 
-	return filtered, nil
+	return res, nil
 }
 
-func (s *OrderService) CreateOrder(ctx context.Context, req model.CreateOrderRequest) (int, error) {
-	if req.Name == "" || req.Status == "" || req.UserId == 0 {
+func (s *OrderService) CreateOrder(ctx context.Context, caller model.Caller, req model.CreateOrderRequest) (int, error) {
+	// по умолчанию заказ оформляется на того, кто его создаёт
+	if req.UserId == 0 {
+		req.UserId = caller.UserID
+	}
+	if req.Name == "" || req.UserId == 0 {
 		return 0, model.ErrMissingRequiredFields
 	}
-	if req.Price < 0 {
-		return 0, model.ErrInvalidPrice
+	if !caller.CanAccess(req.UserId) {
+		return 0, model.ErrForbidden
+	}
+
+	items, totals, err := calculateTotals(req.Items)
+	if err != nil {
+		return 0, err
+	}
+	req.Items = items
+	req.Totals = totals
+
+	// новый заказ всегда начинает жизненный цикл с created
+	if req.Status == "" {
+		req.Status = model.StatusCreated
+	}
+	if req.Status != model.StatusCreated {
+		return 0, model.ErrInvalidStatus
 	}
 
 	exists, err := s.userChecker.UserExists(ctx, req.UserId)
@@ -55,29 +96,317 @@ func (s *OrderService) CreateOrder(ctx context.Context, req model.CreateOrderReq
 		return 0, model.ErrUserNotFound
 	}
 
-	return s.repo.Create(&req)
+	ev, err := orderEvent(ctx, 0, events.OrderCreated{
+		UserID:    req.UserId,
+		Status:    req.Status,
+		Total:     req.Totals.Total,
+		ItemCount: req.Totals.ItemCount,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.repo.Create(ctx, &req, ev)
 }
 
-func (s *OrderService) UpdateOrder(req model.UpdateOrderRequest) error {
-	if req.Name == "" && req.Status == "" && req.Description == "" {
+func (s *OrderService) UpdateOrder(ctx context.Context, caller model.Caller, req model.UpdateOrderRequest) error {
+	if req.Name == "" && req.Status == "" && req.Description == "" && req.Items == nil {
 		return model.ErrMissingRequiredFields
 	}
-	if req.Price < 0 {
-		return model.ErrInvalidPrice
-	}
+	statusOnly := req.Name == "" && req.Description == "" && req.Items == nil
 
-	existingOrder, err := s.repo.GetByID(req.ID)
+	existingOrder, err := s.getOwnedOrder(ctx, caller, req.ID)
 	if err != nil {
 		return err
 	}
 
+	// после оплаты состав и суммы заказа не меняются
+	if req.Items != nil && existingOrder.Status != model.StatusCreated {
+		return model.ErrItemsLocked
+	}
+
+	if req.Items == nil {
+		req.Items = existingOrder.Items
+		req.Totals = existingOrder.OrderTotals
+	} else {
+		items, totals, err := calculateTotals(req.Items)
+		if err != nil {
+			return err
+		}
+		req.Items = items
+		req.Totals = totals
+	}
+
 	if req.Name == "" { req.Name = existingOrder.Name }
 	if req.Status == "" { req.Status = existingOrder.Status }
 	if req.Description == "" { req.Description = existingOrder.Description }
+	req.ExpectedStatus = existingOrder.Status
+
+	var evs []events.Event
+	if !statusOnly {
+		ev, err := orderEvent(ctx, req.ID, events.OrderUpdated{
+			UserID:    existingOrder.UserId,
+			Total:     req.Totals.Total,
+			ItemCount: req.Totals.ItemCount,
+		})
+		if err != nil {
+			return err
+		}
+		evs = append(evs, ev)
+	}
+
+	if req.Status != existingOrder.Status {
+		if err := checkTransition(caller, existingOrder.Status, req.Status); err != nil {
+			return err
+		}
 
-	return s.repo.Update(&req)
+		ev, err := orderEvent(ctx, req.ID, events.OrderStatusChanged{
+			UserID: existingOrder.UserId,
+			From:   existingOrder.Status,
+			To:     req.Status,
+		})
+		if err != nil {
+			return err
+		}
+		evs = append(evs, ev)
+	}
+
+	if err := s.repo.Update(ctx, &req, evs...); err != nil {
+		return err
+	}
+	if req.Status != existingOrder.Status {
+		statusChanges.WithLabelValues(req.Status).Inc()
+	}
+	return nil
+}
+
+// ChangeStatus переводит заказ в новый статус, если это разрешено жизненным циклом.
+func (s *OrderService) ChangeStatus(ctx context.Context, caller model.Caller, id int, status string) (*model.Order, error) {
+	order, err := s.getOwnedOrder(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTransition(caller, order.Status, status); err != nil {
+		return nil, err
+	}
+
+	req := model.UpdateOrderRequest{
+		ID:          order.ID,
+		Name:        order.Name,
+		Description: order.Description,
+		Status:      status,
+		Items:       order.Items,
+		Totals:      order.OrderTotals,
+
+		ExpectedStatus: order.Status,
+	}
+	ev, err := orderEvent(ctx, id, events.OrderStatusChanged{
+		UserID: order.UserId,
+		From:   order.Status,
+		To:     status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, &req, ev); err != nil {
+		return nil, err
+	}
+	statusChanges.WithLabelValues(status).Inc()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *OrderService) DeleteOrder(ctx context.Context, caller model.Caller, id int) error {
+	order, err := s.getOwnedOrder(ctx, caller, id)
+	if err != nil {
+		return err
+	}
+
+	ev, err := orderEvent(ctx, id, events.OrderDeleted{UserID: order.UserId})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Delete(ctx, id, ev)
 }
 
-func (s *OrderService) DeleteOrder(id int) error {
-	return s.repo.Delete(id)
+// CheckUserDeletion решает по политике, можно ли удалить пользователя, и
+// ничего не меняет: users-service спрашивает до удаления, а сами заказы
+// меняет HandleUserDeletion, когда пользователь уже удалён. Блокировка
+// попадает в аудит и возвращается вместе с ErrUserHasActiveOrders.
+func (s *OrderService) CheckUserDeletion(ctx context.Context, userID int) (*model.UserDeletion, error) {
+	orders, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, _, err := s.decideUserDeletion(ctx, userID, orders)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.BlockingOrderIDs) == 0 {
+		return d, nil
+	}
+
+	d.Decision = model.DeletionBlocked
+	d.CanceledOrderIDs = []int{}
+	d.AnonymizedOrderIDs = []int{}
+	if err := s.repo.ApplyUserDeletion(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, model.ErrUserHasActiveOrders
+}
+
+// HandleUserDeletion применяет политику к заказам уже удалённого
+// пользователя (по событию user.deleted) и пишет решение в аудит. Блокировать
+// поздно: заказы, появившиеся после проверки и мешающие удалению, только
+// обезличиваются. Событие может прийти повторно - тогда возвращается уже
+// записанное решение.
+func (s *OrderService) HandleUserDeletion(ctx context.Context, userID int) (*model.UserDeletion, error) {
+	done, err := s.repo.ListUserDeletions(ctx, &userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range done {
+		if d.Decision == model.DeletionAllowed {
+			return &d, nil
+		}
+	}
+
+	orders, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, evs, err := s.decideUserDeletion(ctx, userID, orders)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.BlockingOrderIDs) > 0 {
+		slog.WarnContext(ctx, "orders of deleted user block deletion, anonymizing them as is",
+			"user_id", userID, "order_ids", d.BlockingOrderIDs)
+		d.AnonymizedOrderIDs = append(d.AnonymizedOrderIDs, d.BlockingOrderIDs...)
+		d.BlockingOrderIDs = []int{}
+	}
+
+	if err := s.repo.ApplyUserDeletion(ctx, d, evs...); err != nil {
+		return nil, err
+	}
+	statusChanges.WithLabelValues(model.StatusCanceled).Add(float64(len(d.CanceledOrderIDs)))
+	return d, nil
+}
+
+// decideUserDeletion раскладывает заказы пользователя по политике: отменить
+// (и обезличить), только обезличить или считать блокирующими. evs - события
+// об отмене.
+func (s *OrderService) decideUserDeletion(ctx context.Context, userID int, orders []model.Order) (*model.UserDeletion, []events.Event, error) {
+	d := &model.UserDeletion{
+		UserID:             userID,
+		Policy:             s.deletionPolicy,
+		Decision:           model.DeletionAllowed,
+		CanceledOrderIDs:   []int{},
+		AnonymizedOrderIDs: []int{},
+		BlockingOrderIDs:   []int{},
+		RequestID:          middleware.GetReqID(ctx),
+	}
+
+	var evs []events.Event
+	for _, o := range orders {
+		switch {
+		case model.IsCompletedStatus(o.Status), s.deletionPolicy == model.DeletionPolicyAnonymize:
+			d.AnonymizedOrderIDs = append(d.AnonymizedOrderIDs, o.ID)
+		case s.deletionPolicy == model.DeletionPolicyCancel && model.CanTransition(o.Status, model.StatusCanceled):
+			ev, err := orderEvent(ctx, o.ID, events.OrderStatusChanged{
+				UserID: userID,
+				From:   o.Status,
+				To:     model.StatusCanceled,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			evs = append(evs, ev)
+			d.CanceledOrderIDs = append(d.CanceledOrderIDs, o.ID)
+		default:
+			d.BlockingOrderIDs = append(d.BlockingOrderIDs, o.ID)
+		}
+	}
+	return d, evs, nil
+}
+
+// ListUserDeletions - аудит удалений пользователей, только для админа.
+func (s *OrderService) ListUserDeletions(ctx context.Context, caller model.Caller, userID *int) ([]model.UserDeletion, error) {
+	if !caller.IsAdmin() {
+		return nil, model.ErrForbidden
+	}
+
+	return s.repo.ListUserDeletions(ctx, userID)
+}
+
+// orderEvent собирает событие заказа; для нового заказа (id = 0) id
+// проставит репозиторий.
+func orderEvent(ctx context.Context, id int, payload events.Payload) (events.Event, error) {
+	aggregateID := ""
+	if id != 0 {
+		aggregateID = strconv.Itoa(id)
+	}
+	return events.New(ctx, events.SourceOrders, aggregateID, payload)
+}
+
+func (s *OrderService) getOwnedOrder(ctx context.Context, caller model.Caller, id int) (*model.Order, error) {
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.CanAccess(order.UserId) {
+		return nil, model.ErrForbidden
+	}
+
+	return order, nil
+}
+
+// calculateTotals проверяет позиции и считает суммы. Всё, что прислал клиент
+// в lineTotal, перезаписывается.
+func calculateTotals(items []model.OrderItem) ([]model.OrderItem, model.OrderTotals, error) {
+	var totals model.OrderTotals
+
+	if len(items) == 0 {
+		return nil, totals, model.ErrNoItems
+	}
+
+	res := make([]model.OrderItem, 0, len(items))
+	for _, item := range items {
+		if item.Title == "" {
+			return nil, totals, model.ErrMissingRequiredFields
+		}
+		if item.UnitPrice < 0 {
+			return nil, totals, model.ErrInvalidPrice
+		}
+		if item.Quantity <= 0 {
+			return nil, totals, model.ErrInvalidQuantity
+		}
+
+		item.LineTotal = item.UnitPrice * item.Quantity
+		totals.Subtotal += item.LineTotal
+		totals.ItemCount += item.Quantity
+		res = append(res, item)
+	}
+	totals.Total = totals.Subtotal
+
+	return res, totals, nil
+}
+
+// checkTransition проверяет переход и право caller его сделать. Проверка здесь,
+// а не только в политиках маршрутов: статус меняют и через PUT /orders.
+func checkTransition(caller model.Caller, from, to string) error {
+	if !model.IsValidStatus(to) {
+		return model.ErrInvalidStatus
+	}
+	if model.RequiresAdmin(to) && !caller.IsAdmin() {
+		return model.ErrForbidden
+	}
+	if !model.CanTransition(from, to) {
+		return model.ErrInvalidTransition
+	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,758 @@
+package service_test
+
+import (
+	"common/events"
+	"common/pagination"
+	"context"
+	"fmt"
+	"path/filepath"
+	"service_orders/internal/model"
+	"service_orders/internal/repository"
+	"service_orders/internal/service"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type stubUserChecker struct {
+	exists bool
+}
+
+func (s stubUserChecker) UserExists(ctx context.Context, userID int) (bool, error) {
+	return s.exists, nil
+}
+
+var testAdmin = model.Caller{UserID: 3, Roles: []string{model.RoleAdmin}}
+
+func newTestService() *service.OrderService {
+	return service.NewOrderService(repository.NewInMemoryOrderRepository(), stubUserChecker{exists: true}, model.DeletionPolicyCancel)
+}
+
+func TestOrderService_CreateOrder_DefaultsToCreated(t *testing.T) {
+	svc := newTestService()
+
+	id, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+		Name:   "Soup",
+		UserId: 1,
+		Items:  []model.OrderItem{{Title: "Soup", UnitPrice: 300, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	order, err := svc.GetOrder(context.Background(), testAdmin, id)
+	if err != nil {
+		t.Fatalf("GetOrder error: %v", err)
+	}
+	if order.Status != model.StatusCreated {
+		t.Errorf("expected status created, got %s", order.Status)
+	}
+	if len(order.StatusHistory) != 1 || order.StatusHistory[0].To != model.StatusCreated {
+		t.Errorf("expected initial history entry, got %+v", order.StatusHistory)
+	}
+}
+
+func TestOrderService_CreateOrder_RejectsOtherStatus(t *testing.T) {
+	svc := newTestService()
+
+	_, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+		Name:   "Soup",
+		UserId: 1,
+		Status: model.StatusDelivered,
+		Items:  []model.OrderItem{{Title: "Soup", UnitPrice: 300, Quantity: 1}},
+	})
+	if err != model.ErrInvalidStatus {
+		t.Fatalf("expected ErrInvalidStatus, got: %v", err)
+	}
+}
+
+func TestOrderService_ChangeStatus_HappyPath(t *testing.T) {
+	svc := newTestService()
+
+	id, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+		Name:   "Soup",
+		UserId: 1,
+		Items:  []model.OrderItem{{Title: "Soup", UnitPrice: 300, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	for _, status := range []string{model.StatusPaid, model.StatusShipped, model.StatusDelivered, model.StatusRefunded} {
+		if _, err := svc.ChangeStatus(context.Background(), testAdmin, id, status); err != nil {
+			t.Fatalf("transition to %s failed: %v", status, err)
+		}
+	}
+
+	order, _ := svc.GetOrder(context.Background(), testAdmin, id)
+	if len(order.StatusHistory) != 5 {
+		t.Fatalf("expected 5 history entries, got %+v", order.StatusHistory)
+	}
+	last := order.StatusHistory[4]
+	if last.From != model.StatusDelivered || last.To != model.StatusRefunded || last.At.IsZero() {
+		t.Errorf("unexpected last history entry: %+v", last)
+	}
+}
+
+func TestOrderService_ChangeStatus_IllegalTransitions(t *testing.T) {
+	svc := newTestService()
+
+	tests := []struct {
+		name    string
+		orderID int
+		status  string
+	}{
+		{name: "delivered back to created", orderID: 2, status: model.StatusCreated},
+		{name: "canceled to paid", orderID: 1, status: model.StatusPaid},
+		{name: "delivered to canceled", orderID: 3, status: model.StatusCanceled},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := svc.ChangeStatus(context.Background(), testAdmin, tc.orderID, tc.status); err != model.ErrInvalidTransition {
+				t.Fatalf("expected ErrInvalidTransition, got: %v", err)
+			}
+		})
+	}
+}
+
+// readBarrier задерживает следующие n чтений заказа, пока их не наберётся
+// n: все запросы видят один и тот же снимок и только потом пишут.
+type readBarrier struct {
+	service.OrderRepository
+	pending atomic.Int32
+	reads   sync.WaitGroup
+}
+
+func (r *readBarrier) arm(n int) {
+	r.reads.Add(n)
+	r.pending.Store(int32(n))
+}
+
+func (r *readBarrier) GetByID(ctx context.Context, id int) (*model.Order, error) {
+	order, err := r.OrderRepository.GetByID(ctx, id)
+	if r.pending.Add(-1) >= 0 {
+		r.reads.Done()
+		r.reads.Wait()
+	}
+	return order, err
+}
+
+func TestOrderService_ChangeStatus_ConcurrentTransitions(t *testing.T) {
+	sqliteRepo, err := repository.NewSQLiteOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite repository: %v", err)
+	}
+	defer sqliteRepo.Close()
+
+	repos := map[string]service.OrderRepository{
+		"memory": repository.NewInMemoryOrderRepository(),
+		"sqlite": sqliteRepo,
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			barrier := &readBarrier{OrderRepository: repo}
+			svc := service.NewOrderService(barrier, stubUserChecker{exists: true}, model.DeletionPolicyCancel)
+			id := newOrderInStatus(t, svc, 1, model.StatusPaid)
+
+			// paid -> shipped и paid -> refunded по отдельности допустимы,
+			// но пройти должен только один из них
+			barrier.arm(2)
+			targets := []string{model.StatusShipped, model.StatusRefunded}
+			errs := make([]error, len(targets))
+			var wg sync.WaitGroup
+			for i, status := range targets {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = svc.ChangeStatus(context.Background(), testAdmin, id, status)
+				}()
+			}
+			wg.Wait()
+
+			var won string
+			for i, err := range errs {
+				switch err {
+				case nil:
+					if won != "" {
+						t.Fatalf("expected a single transition to win, got %s and %s", won, targets[i])
+					}
+					won = targets[i]
+				case model.ErrInvalidTransition:
+				default:
+					t.Fatalf("expected ErrInvalidTransition for the loser, got: %v", err)
+				}
+			}
+			if won == "" {
+				t.Fatalf("expected one transition to succeed, got: %v", errs)
+			}
+
+			order, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if order.Status != won || len(order.StatusHistory) != 3 {
+				t.Fatalf("expected status %s after created, paid, got %s with history %+v", won, order.Status, order.StatusHistory)
+			}
+
+			pending, _ := repo.PendingEvents(0)
+			changes := 0
+			for _, ev := range pending {
+				if ev.Type == events.TypeOrderStatusChanged && ev.AggregateID == fmt.Sprint(id) {
+					changes++
+				}
+			}
+			if changes != 2 {
+				t.Fatalf("expected status_changed events for paid and %s only, got %d", won, changes)
+			}
+		})
+	}
+}
+
+func TestOrderService_UpdateOrder_ValidatesStatus(t *testing.T) {
+	svc := newTestService()
+
+	err := svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{ID: 2, Status: "banana"})
+	if err != model.ErrInvalidStatus {
+		t.Fatalf("expected ErrInvalidStatus, got: %v", err)
+	}
+
+	err = svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{ID: 2, Status: "created"})
+	if err != model.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition, got: %v", err)
+	}
+
+	// смена только названия статус не трогает
+	if err := svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{ID: 2, Name: "Burger XXL with fries"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestOrderService_CreateOrder_ComputesTotals(t *testing.T) {
+	svc := newTestService()
+
+	id, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+		Name:   "Dinner",
+		UserId: 1,
+		Items: []model.OrderItem{
+			{SKU: "BURGER-XXL", Title: "Burger XXL", UnitPrice: 1200, Quantity: 2, LineTotal: 1},
+			{SKU: "FRIES", Title: "Fries", UnitPrice: 300, Quantity: 3},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	order, _ := svc.GetOrder(context.Background(), testAdmin, id)
+	if order.Subtotal != 3300 || order.Total != 3300 || order.ItemCount != 5 {
+		t.Errorf("unexpected totals: %+v", order.OrderTotals)
+	}
+	if order.Items[0].LineTotal != 2400 {
+		t.Errorf("expected client lineTotal to be recalculated, got %d", order.Items[0].LineTotal)
+	}
+}
+
+func TestOrderService_CreateOrder_InvalidItems(t *testing.T) {
+	svc := newTestService()
+
+	tests := []struct {
+		name  string
+		items []model.OrderItem
+		want  error
+	}{
+		{name: "no items", items: nil, want: model.ErrNoItems},
+		{name: "zero quantity", items: []model.OrderItem{{Title: "Tea", UnitPrice: 100}}, want: model.ErrInvalidQuantity},
+		{name: "negative price", items: []model.OrderItem{{Title: "Tea", UnitPrice: -1, Quantity: 1}}, want: model.ErrInvalidPrice},
+		{name: "missing title", items: []model.OrderItem{{UnitPrice: 100, Quantity: 1}}, want: model.ErrMissingRequiredFields},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+				Name:   "Order",
+				UserId: 1,
+				Items:  tc.items,
+			})
+			if err != tc.want {
+				t.Fatalf("expected %v, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestOrderService_UpdateOrder_ReplacesItems(t *testing.T) {
+	svc := newTestService()
+	id := newOrderInStatus(t, svc, 1)
+
+	err := svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{
+		ID:    id,
+		Items: []model.OrderItem{{SKU: "LATTE-400", Title: "Latte 400ml", UnitPrice: 450, Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	order, _ := svc.GetOrder(context.Background(), testAdmin, id)
+	if order.Total != 900 || order.ItemCount != 2 || order.Name != "Order" {
+		t.Errorf("unexpected order after update: %+v", order)
+	}
+
+	// оплаченный заказ не переписать даже админу
+	if _, err := svc.ChangeStatus(context.Background(), testAdmin, id, model.StatusPaid); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	err = svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{
+		ID:    id,
+		Items: []model.OrderItem{{Title: "Latte 400ml", UnitPrice: 1, Quantity: 1}},
+	})
+	if err != model.ErrItemsLocked {
+		t.Fatalf("expected ErrItemsLocked, got: %v", err)
+	}
+}
+
+// Владелец не обходит админские /ship, /deliver и /refund через PUT /orders.
+func TestOrderService_UpdateOrder_AdminOnlyStatuses(t *testing.T) {
+	svc := newTestService()
+	owner := model.Caller{UserID: 1, Roles: []string{"user"}}
+
+	paid := newOrderInStatus(t, svc, owner.UserID, model.StatusPaid)
+	for _, status := range []string{model.StatusShipped, model.StatusRefunded} {
+		err := svc.UpdateOrder(context.Background(), owner, model.UpdateOrderRequest{ID: paid, Status: status})
+		if err != model.ErrForbidden {
+			t.Fatalf("expected ErrForbidden for %s, got: %v", status, err)
+		}
+		if _, err := svc.ChangeStatus(context.Background(), owner, paid, status); err != model.ErrForbidden {
+			t.Fatalf("expected ErrForbidden for %s via ChangeStatus, got: %v", status, err)
+		}
+	}
+	if order, _ := svc.GetOrder(context.Background(), owner, paid); order.Status != model.StatusPaid {
+		t.Fatalf("expected order to stay paid, got: %s", order.Status)
+	}
+
+	// оплатить и отменить свой заказ владелец может
+	created := newOrderInStatus(t, svc, owner.UserID)
+	if err := svc.UpdateOrder(context.Background(), owner, model.UpdateOrderRequest{ID: created, Status: model.StatusPaid}); err != nil {
+		t.Fatalf("expected owner to pay, got: %v", err)
+	}
+	if _, err := svc.ChangeStatus(context.Background(), owner, created, model.StatusCanceled); err != nil {
+		t.Fatalf("expected owner to cancel, got: %v", err)
+	}
+}
+
+func TestOrderService_Ownership(t *testing.T) {
+	svc := newTestService()
+	owner := model.Caller{UserID: 1, Roles: []string{"user"}}
+	stranger := model.Caller{UserID: 2, Roles: []string{"user"}}
+
+	// заказ 2 принадлежит пользователю 1
+	if _, err := svc.GetOrder(context.Background(), owner, 2); err != nil {
+		t.Fatalf("expected owner to read the order, got: %v", err)
+	}
+	if _, err := svc.GetOrder(context.Background(), stranger, 2); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden on read, got: %v", err)
+	}
+	if err := svc.UpdateOrder(context.Background(), stranger, model.UpdateOrderRequest{ID: 2, Name: "Mine now"}); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden on update, got: %v", err)
+	}
+	if _, err := svc.ChangeStatus(context.Background(), stranger, 2, model.StatusRefunded); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden on status change, got: %v", err)
+	}
+	if err := svc.DeleteOrder(context.Background(), stranger, 2); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden on delete, got: %v", err)
+	}
+	if _, err := svc.GetOrder(context.Background(), testAdmin, 2); err != nil {
+		t.Fatalf("expected admin to read any order, got: %v", err)
+	}
+}
+
+func TestOrderService_ListOrders_ScopedToCaller(t *testing.T) {
+	svc := newTestService()
+	user := model.Caller{UserID: 1, Roles: []string{"user"}}
+
+	page, err := svc.ListOrders(context.Background(), user, model.OrderFilter{}, pagination.Request{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(page.Items) != 2 || page.Total != 2 {
+		t.Fatalf("expected only 2 own orders, got %d", len(page.Items))
+	}
+	for _, o := range page.Items {
+		if o.UserId != user.UserID {
+			t.Errorf("unexpected order of another user: %+v", o)
+		}
+	}
+
+	other := 2
+	if _, err := svc.ListOrders(context.Background(), user, model.OrderFilter{UserID: &other}, pagination.Request{}); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden for foreign filter, got: %v", err)
+	}
+
+	all, err := svc.ListOrders(context.Background(), testAdmin, model.OrderFilter{}, pagination.Request{})
+	if err != nil || all.Total != 3 {
+		t.Fatalf("expected admin to see all 3 orders, got %+v (%v)", all, err)
+	}
+}
+
+func TestOrderService_CreateOrder_DefaultsToCaller(t *testing.T) {
+	svc := newTestService()
+	user := model.Caller{UserID: 2, Roles: []string{"user"}}
+	items := []model.OrderItem{{Title: "Tea", UnitPrice: 100, Quantity: 1}}
+
+	id, err := svc.CreateOrder(context.Background(), user, model.CreateOrderRequest{Name: "Tea", Items: items})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	order, _ := svc.GetOrder(context.Background(), user, id)
+	if order.UserId != 2 {
+		t.Errorf("expected order to belong to caller, got user %d", order.UserId)
+	}
+
+	_, err = svc.CreateOrder(context.Background(), user, model.CreateOrderRequest{Name: "Tea", UserId: 1, Items: items})
+	if err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden when ordering for someone else, got: %v", err)
+	}
+}
+
+func TestOrderService_ListOrders_PaginationMatchesAcrossRepositories(t *testing.T) {
+	sqliteRepo, err := repository.NewSQLiteOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite repository: %v", err)
+	}
+	defer sqliteRepo.Close()
+
+	repos := map[string]service.OrderRepository{
+		"memory": repository.NewInMemoryOrderRepository(),
+		"sqlite": sqliteRepo,
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			svc := service.NewOrderService(repo, stubUserChecker{exists: true}, model.DeletionPolicyCancel)
+
+			// одинаковые суммы, чтобы проверить tie-breaker по id
+			for _, price := range []int{500, 100, 500, 900, 100, 500} {
+				_, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+					Name:   "Order",
+					UserId: 5,
+					Items:  []model.OrderItem{{Title: "Item", UnitPrice: price, Quantity: 1}},
+				})
+				if err != nil {
+					t.Fatalf("create failed: %v", err)
+				}
+			}
+
+			user := 5
+			minPrice := 200
+			filter := model.OrderFilter{UserID: &user, MinTotal: &minPrice}
+
+			var got []int
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatalf("pagination does not terminate")
+				}
+				page, err := svc.ListOrders(context.Background(), testAdmin, filter, pagination.Request{Limit: 2, Sort: "-price,createdAt", Cursor: cursor})
+				if err != nil {
+					t.Fatalf("list failed: %v", err)
+				}
+				if page.Total != 4 {
+					t.Fatalf("expected total 4, got %d", page.Total)
+				}
+				for _, o := range page.Items {
+					got = append(got, o.ID)
+				}
+				if page.NextCursor == nil {
+					break
+				}
+				cursor = *page.NextCursor
+			}
+
+			// 900 (id 7), затем три по 500 в порядке создания (4, 6, 9)
+			want := []int{7, 4, 6, 9}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestOrderService_ListOrders_InvalidParams(t *testing.T) {
+	svc := newTestService()
+
+	tests := []struct {
+		name string
+		req  pagination.Request
+		want error
+	}{
+		{name: "negative limit", req: pagination.Request{Limit: -1}, want: model.ErrInvalidLimit},
+		{name: "unknown sort field", req: pagination.Request{Sort: "userId"}, want: model.ErrInvalidSort},
+		{name: "garbage cursor", req: pagination.Request{Cursor: "not-a-cursor"}, want: model.ErrInvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := svc.ListOrders(context.Background(), testAdmin, model.OrderFilter{}, tc.req); err != tc.want {
+				t.Fatalf("expected %v, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestOrderService_WritesEventsToOutbox(t *testing.T) {
+	repo := repository.NewInMemoryOrderRepository()
+	svc := service.NewOrderService(repo, stubUserChecker{exists: true}, model.DeletionPolicyCancel)
+	ctx := context.Background()
+
+	id, err := svc.CreateOrder(ctx, testAdmin, model.CreateOrderRequest{
+		Name:   "Soup",
+		UserId: 1,
+		Items:  []model.OrderItem{{Title: "Soup", UnitPrice: 300, Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := svc.ChangeStatus(ctx, testAdmin, id, model.StatusPaid); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// недопустимый переход событий не оставляет
+	if _, err := svc.ChangeStatus(ctx, testAdmin, id, model.StatusCreated); err != model.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition, got: %v", err)
+	}
+	if err := svc.DeleteOrder(ctx, testAdmin, id); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	pending, _ := repo.PendingEvents(0)
+	types := make([]string, 0, len(pending))
+	for _, ev := range pending {
+		types = append(types, ev.Type)
+		if ev.AggregateID != fmt.Sprint(id) || ev.Source != events.SourceOrders {
+			t.Fatalf("unexpected envelope: %+v", ev)
+		}
+	}
+	want := []string{events.TypeOrderCreated, events.TypeOrderStatusChanged, events.TypeOrderDeleted}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("expected events %v, got: %v", want, types)
+	}
+
+	var created events.OrderCreated
+	if err := pending[0].Decode(&created); err != nil || created.Total != 600 || created.ItemCount != 2 {
+		t.Fatalf("unexpected order.created payload: %+v, %v", created, err)
+	}
+	var changed events.OrderStatusChanged
+	if err := pending[1].Decode(&changed); err != nil || changed.From != model.StatusCreated || changed.To != model.StatusPaid {
+		t.Fatalf("unexpected order.status_changed payload: %+v, %v", changed, err)
+	}
+}
+
+// newOrderInStatus создаёт заказ и проводит его по цепочке статусов.
+func newOrderInStatus(t *testing.T, svc *service.OrderService, userID int, path ...string) int {
+	t.Helper()
+
+	id, err := svc.CreateOrder(context.Background(), testAdmin, model.CreateOrderRequest{
+		Name:   "Order",
+		UserId: userID,
+		Items:  []model.OrderItem{{Title: "Item", UnitPrice: 100, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	for _, status := range path {
+		if _, err := svc.ChangeStatus(context.Background(), testAdmin, id, status); err != nil {
+			t.Fatalf("change status to %s failed: %v", status, err)
+		}
+	}
+	return id
+}
+
+func TestOrderService_UserDeletion_Policies(t *testing.T) {
+	const userID = 7
+
+	tests := []struct {
+		policy         string
+		withShipped    bool
+		wantErr        error    // от CheckUserDeletion; без ошибки следом идёт HandleUserDeletion
+		wantCanceled   []string // created, paid, shipped, delivered
+		wantAnonymized []string // только обезличены
+		wantBlocking   []string
+	}{
+		{model.DeletionPolicyCancel, false, nil, []string{"created", "paid"}, []string{"delivered"}, nil},
+		{model.DeletionPolicyCancel, true, model.ErrUserHasActiveOrders, nil, nil, []string{"shipped"}},
+		{model.DeletionPolicyAnonymize, true, nil, nil, []string{"created", "paid", "shipped", "delivered"}, nil},
+		{model.DeletionPolicyBlock, false, model.ErrUserHasActiveOrders, nil, nil, []string{"created", "paid"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s/shipped=%v", tc.policy, tc.withShipped), func(t *testing.T) {
+			repo := repository.NewInMemoryOrderRepository()
+			svc := service.NewOrderService(repo, stubUserChecker{exists: true}, tc.policy)
+
+			ids := map[string]int{
+				"created":   newOrderInStatus(t, svc, userID),
+				"paid":      newOrderInStatus(t, svc, userID, model.StatusPaid),
+				"delivered": newOrderInStatus(t, svc, userID, model.StatusPaid, model.StatusShipped, model.StatusDelivered),
+			}
+			if tc.withShipped {
+				ids["shipped"] = newOrderInStatus(t, svc, userID, model.StatusPaid, model.StatusShipped)
+			}
+			otherUsersOrder := newOrderInStatus(t, svc, 8)
+
+			toIDs := func(names []string) string {
+				res := make([]int, 0, len(names))
+				for _, n := range names {
+					res = append(res, ids[n])
+				}
+				sort.Ints(res)
+				return fmt.Sprint(res)
+			}
+			sorted := func(ids []int) string {
+				res := append([]int(nil), ids...)
+				sort.Ints(res)
+				return fmt.Sprint(res)
+			}
+			expectOrders := func(canceled, anonymized []string) {
+				t.Helper()
+				for name, id := range ids {
+					order, err := repo.GetByID(context.Background(), id)
+					if err != nil {
+						t.Fatalf("expected order %d to exist, got: %v", id, err)
+					}
+
+					wantOwner := userID
+					if slices.Contains(canceled, name) || slices.Contains(anonymized, name) {
+						wantOwner = model.AnonymousUserID
+					}
+					wantStatus := name
+					if slices.Contains(canceled, name) {
+						wantStatus = model.StatusCanceled
+					}
+					if order.UserId != wantOwner || order.Status != wantStatus {
+						t.Fatalf("order %s: expected owner %d and status %s, got: %d, %s", name, wantOwner, wantStatus, order.UserId, order.Status)
+					}
+				}
+			}
+
+			d, err := svc.CheckUserDeletion(context.Background(), userID)
+			if err != tc.wantErr {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+			// проверка заказы не трогает: пользователь ещё может остаться
+			expectOrders(nil, nil)
+
+			if tc.wantErr == nil {
+				if d, err = svc.HandleUserDeletion(context.Background(), userID); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+			if sorted(d.CanceledOrderIDs) != toIDs(tc.wantCanceled) ||
+				sorted(d.AnonymizedOrderIDs) != toIDs(tc.wantAnonymized) ||
+				sorted(d.BlockingOrderIDs) != toIDs(tc.wantBlocking) {
+				t.Fatalf("unexpected decision: %+v", d)
+			}
+			expectOrders(tc.wantCanceled, tc.wantAnonymized)
+
+			if order, _ := repo.GetByID(context.Background(), otherUsersOrder); order.UserId != 8 {
+				t.Fatalf("expected other user's order to stay untouched, got: %+v", order)
+			}
+
+			audit, err := svc.ListUserDeletions(context.Background(), testAdmin, nil)
+			if err != nil || len(audit) != 1 || audit[0].ID != d.ID || audit[0].Policy != tc.policy {
+				t.Fatalf("expected decision in audit trail, got: %+v, %v", audit, err)
+			}
+		})
+	}
+}
+
+// user.deleted доставляется at-least-once, а блокировать удаление уже поздно.
+func TestOrderService_HandleUserDeletion_AfterUserIsGone(t *testing.T) {
+	const userID = 7
+	repo := repository.NewInMemoryOrderRepository()
+	svc := service.NewOrderService(repo, stubUserChecker{exists: true}, model.DeletionPolicyCancel)
+
+	created := newOrderInStatus(t, svc, userID)
+	// отправлен уже после проверки
+	shipped := newOrderInStatus(t, svc, userID, model.StatusPaid, model.StatusShipped)
+
+	d, err := svc.HandleUserDeletion(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if fmt.Sprint(d.CanceledOrderIDs) != fmt.Sprint([]int{created}) || fmt.Sprint(d.AnonymizedOrderIDs) != fmt.Sprint([]int{shipped}) || len(d.BlockingOrderIDs) != 0 {
+		t.Fatalf("expected shipped order to be anonymized instead of blocking, got: %+v", d)
+	}
+	if order, _ := repo.GetByID(context.Background(), shipped); order.UserId != model.AnonymousUserID || order.Status != model.StatusShipped {
+		t.Fatalf("expected shipped order to keep its status without owner, got: %+v", order)
+	}
+
+	again, err := svc.HandleUserDeletion(context.Background(), userID)
+	if err != nil || again.ID != d.ID {
+		t.Fatalf("expected repeated delivery to return decision %d, got: %+v, %v", d.ID, again, err)
+	}
+	if audit, _ := svc.ListUserDeletions(context.Background(), testAdmin, nil); len(audit) != 1 {
+		t.Fatalf("expected a single audit record, got: %+v", audit)
+	}
+}
+
+func TestOrderService_ListUserDeletions_AdminOnly(t *testing.T) {
+	svc := newTestService()
+
+	if _, err := svc.ListUserDeletions(context.Background(), model.Caller{UserID: 1}, nil); err != model.ErrForbidden {
+		t.Fatalf("expected ErrForbidden, got: %v", err)
+	}
+}
+
+// statusChangesTotal - текущее значение orders_status_changes_total{status}.
+func statusChangesTotal(t *testing.T, status string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "orders_status_changes_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "status" && l.GetValue() == status {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// Переход считается, каким бы путём он ни случился.
+func TestOrderService_CountsStatusChanges(t *testing.T) {
+	const userID = 7
+	svc := newTestService()
+	paid, canceled := statusChangesTotal(t, model.StatusPaid), statusChangesTotal(t, model.StatusCanceled)
+
+	newOrderInStatus(t, svc, userID, model.StatusPaid)
+	id := newOrderInStatus(t, svc, userID)
+	if err := svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{ID: id, Status: model.StatusPaid}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// только имя - не переход
+	if err := svc.UpdateOrder(context.Background(), testAdmin, model.UpdateOrderRequest{ID: id, Name: "Renamed"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// политика cancel отменяет и оплаченные заказы
+	if _, err := svc.HandleUserDeletion(context.Background(), userID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got := statusChangesTotal(t, model.StatusPaid) - paid; got != 2 {
+		t.Fatalf("expected 2 transitions to paid, got: %v", got)
+	}
+	if got := statusChangesTotal(t, model.StatusCanceled) - canceled; got != 2 {
+		t.Fatalf("expected 2 transitions to canceled, got: %v", got)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"common/events"
+	"common/tracing"
+	"context"
+	"service_orders/internal/model"
+)
+
+// tracedRepository - репозиторий со спаном на каждую операцию сервиса.
+// Спаны - дочерние к ctx запроса, и этот же ctx уходит в запросы к БД;
+// outbox relay ходит в репозиторий напрямую.
+type tracedRepository struct {
+	OrderRepository
+}
+
+func span(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, span := tracing.Start(ctx, "orders.repository."+op)
+	return ctx, func(err error) { tracing.End(span, err) }
+}
+
+func (r tracedRepository) GetByID(ctx context.Context, id int) (*model.Order, error) {
+	ctx, end := span(ctx, "GetByID")
+	order, err := r.OrderRepository.GetByID(ctx, id)
+	end(err)
+	return order, err
+}
+
+func (r tracedRepository) GetByUserID(ctx context.Context, userID int) ([]model.Order, error) {
+	ctx, end := span(ctx, "GetByUserID")
+	orders, err := r.OrderRepository.GetByUserID(ctx, userID)
+	end(err)
+	return orders, err
+}
+
+func (r tracedRepository) List(ctx context.Context, q model.OrderListQuery) ([]model.Order, int, error) {
+	ctx, end := span(ctx, "List")
+	orders, total, err := r.OrderRepository.List(ctx, q)
+	end(err)
+	return orders, total, err
+}
+
+func (r tracedRepository) Create(ctx context.Context, req *model.CreateOrderRequest, evs ...events.Event) (int, error) {
+	ctx, end := span(ctx, "Create")
+	id, err := r.OrderRepository.Create(ctx, req, evs...)
+	end(err)
+	return id, err
+}
+
+func (r tracedRepository) Update(ctx context.Context, req *model.UpdateOrderRequest, evs ...events.Event) error {
+	ctx, end := span(ctx, "Update")
+	err := r.OrderRepository.Update(ctx, req, evs...)
+	end(err)
+	return err
+}
+
+func (r tracedRepository) Delete(ctx context.Context, id int, evs ...events.Event) error {
+	ctx, end := span(ctx, "Delete")
+	err := r.OrderRepository.Delete(ctx, id, evs...)
+	end(err)
+	return err
+}
+
+func (r tracedRepository) ApplyUserDeletion(ctx context.Context, d *model.UserDeletion, evs ...events.Event) error {
+	ctx, end := span(ctx, "ApplyUserDeletion")
+	err := r.OrderRepository.ApplyUserDeletion(ctx, d, evs...)
+	end(err)
+	return err
+}
+
+func (r tracedRepository) ListUserDeletions(ctx context.Context, userID *int) ([]model.UserDeletion, error) {
+	ctx, end := span(ctx, "ListUserDeletions")
+	deletions, err := r.OrderRepository.ListUserDeletions(ctx, userID)
+	end(err)
+	return deletions, err
+}
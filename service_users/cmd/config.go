@@ -0,0 +1,177 @@
+package main
+
+import (
+	"common/config"
+	"common/logging"
+	"common/servicetoken"
+	"common/tracing"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Config - настройки user-service. Старые имена переменных (USERS_STORAGE и т.п.)
+// сохранены явными тегами env, остальные выводятся из префикса USERS_.
+type Config struct {
+	Server struct {
+		Port            int           `yaml:"port" usage:"HTTP port"`
+		ShutdownTimeout time.Duration `yaml:"shutdownTimeout" usage:"graceful shutdown timeout"`
+	} `yaml:"server"`
+
+	Orders struct {
+		URL     string        `yaml:"url" usage:"orders-service base URL"`
+		Timeout time.Duration `yaml:"timeout" usage:"orders-service request timeout"`
+	} `yaml:"orders"`
+
+	Storage struct {
+		// memory или file
+		Kind          string `yaml:"kind" env:"USERS_STORAGE" usage:"storage backend: memory or file"`
+		DataDir       string `yaml:"dataDir" env:"USERS_DATA_DIR" usage:"directory for file storage and signing keys"`
+		SnapshotEvery int    `yaml:"snapshotEvery" env:"USERS_SNAPSHOT_EVERY" usage:"WAL records between snapshots, 0 - default"`
+	} `yaml:"storage"`
+
+	// Overlap должен перекрывать TTL access-токена (15 минут) плюс время,
+	// на которое gateway кэширует JWKS. Publish - не меньше этого времени
+	// кэширования, иначе gateway увидит токен с ещё незнакомым ключом.
+	Keys struct {
+		Rotation time.Duration `yaml:"rotation" env:"USERS_KEY_ROTATION" usage:"signing key rotation interval"`
+		Overlap  time.Duration `yaml:"overlap" env:"USERS_KEY_OVERLAP" usage:"how long retired keys stay in JWKS"`
+		Publish  time.Duration `yaml:"publish" env:"USERS_KEY_PUBLISH" usage:"how long a new key is in JWKS before it signs tokens"`
+	} `yaml:"keys"`
+
+	Outbox struct {
+		Interval time.Duration `yaml:"interval" env:"USERS_OUTBOX_INTERVAL" usage:"outbox relay poll interval"`
+	} `yaml:"outbox"`
+
+	Idempotency struct {
+		TTL time.Duration `yaml:"ttl" usage:"how long POST /users and /auth/register responses are kept for repeats with the same Idempotency-Key"`
+	} `yaml:"idempotency"`
+
+	// один на все сервисы: им подписаны вызовы к orders-service и маршруты
+	// users-service, которые вызывают только сервисы
+	ServiceToken string `yaml:"serviceToken" env:"SERVICE_TOKEN" secret:"true" usage:"shared token for service-to-service calls"`
+
+	Log     logging.Config `yaml:"log"`
+	Tracing tracing.Config `yaml:"tracing"`
+}
+
+// defaultConfig - значения по умолчанию для профиля. В development сервисы
+// запускаются на одной машине, поэтому порты разные и адреса - localhost.
+func defaultConfig(profile string) *Config {
+	cfg := &Config{}
+	cfg.Server.Port = 8001
+	cfg.Server.ShutdownTimeout = 5 * time.Second
+	cfg.Orders.URL = "http://localhost:8002"
+	cfg.Orders.Timeout = 5 * time.Second
+	cfg.Storage.Kind = "memory"
+	cfg.Storage.DataDir = "./data"
+	cfg.Keys.Rotation = 24 * time.Hour
+	cfg.Keys.Overlap = time.Hour
+	cfg.Keys.Publish = 10 * time.Minute
+	cfg.Outbox.Interval = time.Second
+	cfg.Idempotency.TTL = 24 * time.Hour
+	cfg.Log.Level = "info"
+	cfg.Log.Format = logging.FormatJSON
+	cfg.Tracing.Exporter = tracing.ExporterNone
+	cfg.Tracing.SampleRatio = 1
+
+	// локально текст читать удобнее, чем JSON
+	if profile == config.ProfileDevelopment {
+		cfg.Log.Format = logging.FormatText
+	}
+
+	// в production токен задаётся только окружением
+	if profile != config.ProfileProduction {
+		cfg.ServiceToken = servicetoken.DevToken
+	}
+
+	if profile == config.ProfileProduction {
+		cfg.Server.Port = 8000
+		cfg.Orders.URL = "http://service_orders:8000"
+		cfg.Storage.Kind = "file"
+		cfg.Storage.DataDir = "/app/data"
+	}
+
+	return cfg
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be in 1..65535, got %d", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	if err := validateURL(c.Orders.URL); err != nil {
+		errs = append(errs, fmt.Errorf("orders.url: %w", err))
+	}
+	if c.Orders.Timeout <= 0 {
+		errs = append(errs, errors.New("orders.timeout must be positive"))
+	}
+	switch c.Storage.Kind {
+	case "memory":
+	case "file":
+		if c.Storage.DataDir == "" {
+			errs = append(errs, errors.New("storage.dataDir is required for file storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage.kind %q", c.Storage.Kind))
+	}
+	if c.Storage.SnapshotEvery < 0 {
+		errs = append(errs, errors.New("storage.snapshotEvery must be non-negative"))
+	}
+	if c.Keys.Rotation <= 0 || c.Keys.Overlap < 0 {
+		errs = append(errs, errors.New("keys.rotation must be positive and keys.overlap non-negative"))
+	}
+	if c.Keys.Publish < 0 || c.Keys.Publish >= c.Keys.Rotation {
+		errs = append(errs, errors.New("keys.publish must be non-negative and shorter than keys.rotation"))
+	}
+	if c.Outbox.Interval <= 0 {
+		errs = append(errs, errors.New("outbox.interval must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+	if c.ServiceToken == "" {
+		errs = append(errs, errors.New("serviceToken is required"))
+	}
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func loadConfig() (*Config, string, error) {
+	profile, err := config.Profile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := defaultConfig(profile)
+	err = config.Load(cfg, config.Options{
+		Name:      "users-service",
+		EnvPrefix: "USERS_",
+		Args:      os.Args[1:],
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, profile, nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want absolute http(s) URL, got %q", raw)
+	}
+	return nil
+}
@@ -1,81 +1,187 @@
 package main
 
 import (
+	"common/config"
+	"common/deadline"
+	"common/logging"
+	"common/events"
+	"common/idempotency"
+	"common/metrics"
+	"common/policy"
+	"common/problem"
+	"common/servicetoken"
+	"common/tracing"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os/signal"
+	"path/filepath"
+	"service_users/internal/client"
 	"service_users/internal/handler"
 	"service_users/internal/repository"
 	"service_users/internal/service"
 	"syscall"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-const (
-	port     = "8000"
-	shutdownTimeout = 5 * time.Second
-)
-
 func main() {
+	cfg, profile, err := loadConfig()
+	if err != nil {
+		logging.Fatal("failed to load config", err)
+	}
+	logging.Setup("service_users", cfg.Log)
+	slog.Info("effective config", "profile", profile, "config", config.Describe(cfg))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "service_users", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("failed to init tracing", err)
+	}
+
 	// Dependency injection
-	userRepository := repository.NewUserRepository()
-	userService := service.NewUserService(userRepository)
+	userRepository, closeRepository, err := newUserRepository(cfg)
+	if err != nil {
+		logging.Fatal("failed to init user repository", err)
+	}
+	defer closeRepository()
+
+	keys, err := newKeyManager(cfg)
+	if err != nil {
+		logging.Fatal("failed to init signing keys", err)
+	}
+
+	tokenStore := repository.NewTokenStore()
+	ordersClient := client.NewOrdersClient(cfg.Orders.URL, cfg.Orders.Timeout, cfg.ServiceToken)
+	userService := service.NewUserService(userRepository, ordersClient, tokenStore, keys)
+
+	// Внешнего брокера пока нет: события публикуются внутри процесса и пишутся в лог.
+	// Заказы удалённого пользователя service_orders меняет по user.deleted.
+	broker := events.NewLocalBroker()
+	broker.Subscribe("*", events.LogHandler)
+	broker.Subscribe(events.TypeUserDeleted, userService.HandleUserDeleted)
+	relay := events.NewRelay(userRepository, broker, cfg.Outbox.Interval)
 	user := handler.NewUserController(*userService)
 
 	srv := &http.Server{
-		Addr: fmt.Sprintf(":%v", port),
-		Handler: initRouter(user),
+		Addr: fmt.Sprintf(":%v", cfg.Server.Port),
+		Handler: initRouter(user, idempotency.NewStore(cfg.Idempotency.TTL), cfg.ServiceToken),
 	}
 
 	// Graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+
+	go keys.RotateEvery(ctx, cfg.Keys.Rotation)
+
+	relayDone := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(relayDone)
+	}()
 	
 	go func() {
-		log.Println("starting user-service on port", port)
+		slog.InfoContext(ctx, "starting user-service", "port", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server starting failed: %v", err)
+			logging.Fatal("server starting failed", err)
 		}
 	}() 
 	
 	<-ctx.Done()
 
-	shutDownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	shutDownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	
-	log.Printf("shutting down server gracefully")
+	slog.InfoContext(shutDownCtx, "shutting down server gracefully")
 	if err := srv.Shutdown(shutDownCtx); err != nil {
-		log.Println("error when shutting down:", err)
+		slog.ErrorContext(shutDownCtx, "error when shutting down", "error", err)
 	} else {
-		log.Println("server stopped")
+		slog.InfoContext(shutDownCtx, "server stopped")
+	}
+
+	// relay досылает остаток до закрытия репозитория
+	<-relayDone
+
+	if err := shutdownTracing(shutDownCtx); err != nil {
+		slog.ErrorContext(shutDownCtx, "error when flushing traces", "error", err)
 	}
 }
 
-func initRouter(user *handler.UserController) *chi.Mux {
+func newUserRepository(cfg *Config) (service.UserRepository, func(), error) {
+	if cfg.Storage.Kind != "file" {
+		return repository.NewUserRepository(), func() {}, nil
+	}
+
+	dir := cfg.Storage.DataDir
+	repo, err := repository.NewFileUserRepository(dir, cfg.Storage.SnapshotEvery)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("using file storage", "dir", dir)
+
+	return repo, func() {
+		if err := repo.Close(); err != nil {
+			slog.Error("error when closing user repository", "error", err)
+		}
+	}, nil
+}
+
+// newKeyManager хранит ключи рядом с данными, если включено файловое хранилище,
+// иначе ключи живут в памяти и после рестарта все access-токены становятся невалидными.
+func newKeyManager(cfg *Config) (*service.KeyManager, error) {
+	path := ""
+	if cfg.Storage.Kind == "file" {
+		path = filepath.Join(cfg.Storage.DataDir, "signing_keys.json")
+	}
+
+	return service.NewKeyManager(path, cfg.Keys.Overlap, cfg.Keys.Publish)
+}
+
+func initRouter(user *handler.UserController, keys *idempotency.Store, serviceToken string) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(metrics.Middleware)
 	r.Use(middleware.Recoverer)
+	r.Use(deadline.Middleware)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+
+	auth := user.Auth()
+	auth.Service = servicetoken.Middleware(serviceToken)
+	p := policy.NewRouter(r, routePolicies, auth)
+	// ключ привязан к пользователю, поэтому дедупликация - после аутентификации
+	once := func(h http.HandlerFunc) http.HandlerFunc {
+		return idempotency.Middleware(keys, handler.IdempotencyScope)(h).ServeHTTP
+	}
 
-	r.Get("/users", user.GetMany)
-	r.Get("/users/{id}", user.GetUser)
-	r.Post("/users", user.CreateUser)
-	r.Put("/users", user.UpdateUser)
-	r.Delete("/users/{id}",user.DeleteUser)
-	r.Get("/users/health", user.Health)
-	r.Get("/users/status", user.Status)	
+	p.Handle(http.MethodGet, "/metrics", metrics.Handler().ServeHTTP)
+	p.Handle(http.MethodGet, "/openapi.json", apiSpec().Handler())
 
-	r.Post("/auth/register", user.Register)
-	r.Post("/auth/login", user.Login)
+	p.Handle(http.MethodGet, "/users", user.GetMany)
+	p.Handle(http.MethodGet, "/users/{id}", user.GetUser)
+	p.Handle(http.MethodPost, "/users", once(user.CreateUser))
+	p.Handle(http.MethodPut, "/users", user.UpdateUser)
+	p.Handle(http.MethodDelete, "/users/{id}", user.DeleteUser)
+	p.Handle(http.MethodGet, "/users/health", user.Health)
+	p.Handle(http.MethodGet, "/users/status", user.Status)
+
+	p.Handle(http.MethodGet, "/.well-known/jwks.json", user.JWKS)
+
+	p.Handle(http.MethodPost, "/auth/register", once(user.Register))
+	p.Handle(http.MethodPost, "/auth/login", user.Login)
+	p.Handle(http.MethodPost, "/auth/refresh", user.Refresh)
+	p.Handle(http.MethodPost, "/auth/logout", user.Logout)
+	p.Handle(http.MethodPost, "/auth/logout-all", user.LogoutAll)
+	p.Handle(http.MethodGet, "/auth/revoked", user.RevokedTokens)
+
+	p.Handle(http.MethodGet, "/users/me", user.GetMe)
+	p.Handle(http.MethodPut, "/users/me", user.UpdateMe)
+
+	p.Verify()
 
-	r.With(user.AuthMiddleware).Get("/users/me", user.GetMe)
-	r.With(user.AuthMiddleware).Put("/users/me", user.UpdateMe)
-	
 	return r
 }
\ No newline at end of file
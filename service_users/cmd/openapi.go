@@ -0,0 +1,98 @@
+package main
+
+import (
+	"common/openapi"
+	"net/http"
+	"service_users/internal/model"
+)
+
+var (
+	stringParam = &openapi.Schema{Type: "string"}
+	intParam    = &openapi.Schema{Type: "integer"}
+	idParam     = openapi.PathInt("id", "id пользователя")
+)
+
+// userRoutes описывает маршруты initRouter для /openapi.json. Аутентификация
+// и роли берутся из routePolicies. Расхождение с роутером ловит
+// TestOpenAPI_MatchesRouter.
+var userRoutes = []openapi.Route{
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "getOpenAPI", Summary: "Эта спецификация", Tag: "meta", Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/metrics", ID: "getMetrics", Summary: "Метрики Prometheus", Tag: "meta", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/users/health", ID: "getUsersHealth", Tag: "meta",
+		Response: map[string]any{"status": "", "service": "", "timestamp": ""}},
+	{Method: http.MethodGet, Path: "/users/status", ID: "getUsersStatus", Tag: "meta",
+		Response: map[string]any{"status": ""}},
+
+	{Method: http.MethodGet, Path: "/users", ID: "listUsers", Summary: "Список пользователей с курсорной пагинацией", Tag: "users",
+		Params: []openapi.Parameter{
+			openapi.Query("limit", intParam, "размер страницы, по умолчанию 20, не больше 100"),
+			openapi.Query("cursor", stringParam, "nextCursor предыдущей страницы"),
+			openapi.Query("sort", stringParam, "поля через запятую, -name - по убыванию: id, name, email, createdAt"),
+			openapi.Query("role", stringParam, "только пользователи с ролью"),
+			openapi.Query("emailPrefix", stringParam, "начало email без учёта регистра"),
+			openapi.Query("name", stringParam, "подстрока имени без учёта регистра"),
+		},
+		Response: model.UserPage{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/users/{id}", ID: "getUser", Tag: "users",
+		Params: []openapi.Parameter{idParam}, Response: model.User{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/users", ID: "createUser", Tag: "users",
+		Params: []openapi.Parameter{openapi.IdempotencyKey},
+		Body:   model.CreateUserRequest{}, Status: http.StatusCreated, Response: map[string]any{"id": 0, "message": ""},
+		Errors: []int{http.StatusConflict, http.StatusUnprocessableEntity}},
+	{Method: http.MethodPut, Path: "/users", ID: "updateUser", Tag: "users",
+		Body: model.UpdateUserRequest{}, Response: map[string]any{"message": ""},
+		Errors: []int{http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/users/{id}", ID: "deleteUser", Summary: "Удаление; заказы обрабатывает orders-service по своей политике", Tag: "users",
+		Params: []openapi.Parameter{idParam}, Response: map[string]any{"message": ""},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusGatewayTimeout}},
+	{Method: http.MethodGet, Path: "/users/me", ID: "getMe", Tag: "users", Response: model.User{},
+		Errors: []int{http.StatusNotFound}},
+	{Method: http.MethodPut, Path: "/users/me", ID: "updateMe", Tag: "users",
+		Body: model.UpdateProfileRequest{}, Response: model.User{},
+		Errors: []int{http.StatusNotFound}},
+
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", ID: "getJWKS", Summary: "Публичные ключи подписи access-токенов", Tag: "auth",
+		Response: model.JWKS{}},
+	{Method: http.MethodPost, Path: "/auth/register", ID: "register", Tag: "auth",
+		Params: []openapi.Parameter{openapi.IdempotencyKey},
+		Body:   model.RegisterRequest{}, Status: http.StatusCreated,
+		Response: model.APIResponse{Success: true, Data: map[string]any{"id": 0}},
+		Errors:   []int{http.StatusConflict, http.StatusUnprocessableEntity}},
+	{Method: http.MethodPost, Path: "/auth/login", ID: "login", Tag: "auth",
+		Body: model.LoginRequest{}, Response: tokensResponse, Errors: []int{http.StatusUnauthorized}},
+	{Method: http.MethodPost, Path: "/auth/refresh", ID: "refresh", Summary: "Ротация refresh-токена", Tag: "auth",
+		Body: model.RefreshRequest{}, Response: tokensResponse, Errors: []int{http.StatusUnauthorized}},
+	{Method: http.MethodPost, Path: "/auth/logout", ID: "logout", Summary: "Тело необязательное: без refresh-токена отзывается только access-токен", Tag: "auth",
+		Body: model.LogoutRequest{}, BodyOptional: true, Response: model.APIResponse{Success: true}},
+	{Method: http.MethodPost, Path: "/auth/logout-all", ID: "logoutAll", Tag: "auth",
+		Response: model.APIResponse{Success: true}},
+	{Method: http.MethodGet, Path: "/auth/revoked", ID: "listRevokedTokens", Summary: "Отозванные, но ещё не истёкшие access-токены (для gateway)", Tag: "auth",
+		Response: model.RevokedTokens{}},
+}
+
+// tokensResponse - тело ответа writeTokens.
+var tokensResponse = model.APIResponse{Success: true, Data: map[string]any{
+	"token":        "",
+	"accessToken":  "",
+	"refreshToken": "",
+	"tokenType":    "",
+	"expiresIn":    0,
+}}
+
+func apiSpec() *openapi.Document {
+	doc := openapi.New("Users Service", "1.0.0")
+	doc.Components.SecuritySchemes[openapi.BearerAuth] = openapi.BearerScheme
+	doc.Components.SecuritySchemes[openapi.ServiceAuth] = openapi.ServiceScheme
+	for _, rt := range userRoutes {
+		switch policy := routePolicies[rt.Method+" "+rt.Path]; {
+		case policy.Service:
+			rt.Security = openapi.ServiceAuth
+		case !policy.Public:
+			rt.Security = openapi.BearerAuth
+			rt.Roles = policy.Roles
+		}
+		doc.Add(rt)
+	}
+	return doc
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"common/openapi"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPI_MatchesRouter(t *testing.T) {
+	if err := apiSpec().CheckRoutes(newTestRouter(t)); err != nil {
+		t.Fatalf("spec and router disagree:\n%v", err)
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc openapi.Document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected spec JSON, got %d: %v", w.Code, err)
+	}
+	register := doc.Paths["/auth/register"]["post"]
+	if register == nil || register.Security != nil {
+		t.Fatalf("expected public register operation, got: %+v", register)
+	}
+	if del := doc.Paths["/users/{id}"]["delete"]; len(del.Security) == 0 || del.Roles[0] != "admin" {
+		t.Fatalf("expected admin-only delete, got: %+v", del)
+	}
+	req := doc.Components.Schemas["RegisterRequest"]
+	if req == nil || len(req.Required) != 3 || *req.Properties["password"].MinLength != 6 {
+		t.Fatalf("expected RegisterRequest schema from validate tags, got: %+v", req)
+	}
+}
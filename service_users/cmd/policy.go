@@ -0,0 +1,31 @@
+package main
+
+import "common/policy"
+
+// routePolicies - единственное место, где описано, кому доступен какой маршрут.
+// Маршрут без записи здесь зарегистрировать нельзя (см. policy.Router.Handle).
+var routePolicies = map[string]policy.Policy{
+	"GET /users":         policy.AdminOnly,
+	"POST /users":        policy.AdminOnly,
+	"PUT /users":         policy.AdminOnly,
+	"DELETE /users/{id}": policy.AdminOnly,
+	// service_orders проверяет здесь существование пользователя
+	"GET /users/{id}":   policy.ServiceOnly,
+	"GET /users/health": policy.PublicAccess,
+	"GET /users/status": policy.PublicAccess,
+	"GET /users/me":     policy.Authenticated,
+	"PUT /users/me":     policy.Authenticated,
+
+	"GET /.well-known/jwks.json": policy.PublicAccess,
+	// Prometheus ходит без токена; наружу gateway этот маршрут не проксирует
+	"GET /metrics":      policy.PublicAccess,
+	"GET /openapi.json": policy.PublicAccess,
+
+	"POST /auth/register":   policy.PublicAccess,
+	"POST /auth/login":      policy.PublicAccess,
+	"POST /auth/refresh":    policy.PublicAccess,
+	"POST /auth/logout":     policy.Authenticated,
+	"POST /auth/logout-all": policy.Authenticated,
+	// gateway опрашивает список отозванных; в его таблицу маршрутов этот путь не входит
+	"GET /auth/revoked": policy.ServiceOnly,
+}
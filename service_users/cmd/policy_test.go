@@ -0,0 +1,57 @@
+package main
+
+import (
+	"common/idempotency"
+	"common/servicetoken"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"service_users/internal/handler"
+	"service_users/internal/repository"
+	"service_users/internal/service"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type stubOrdersClient struct{}
+
+func (stubOrdersClient) CheckUserDeletion(ctx context.Context, userID int) error { return nil }
+func (stubOrdersClient) ApplyUserDeletion(ctx context.Context, userID int) error { return nil }
+
+func newTestRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	keys, err := service.NewKeyManager("", time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	svc := service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), keys)
+	return initRouter(handler.NewUserController(*svc), idempotency.NewStore(time.Hour), servicetoken.DevToken)
+}
+
+// initRouter паникует, если маршрут и таблица политик разошлись.
+func TestInitRouter_EveryRouteHasPolicy(t *testing.T) {
+	newTestRouter(t)
+}
+
+// маршруты, которые вызывают только сервисы, без токена сервиса закрыты
+func TestServiceRoutes_RequireServiceToken(t *testing.T) {
+	r := newTestRouter(t)
+
+	for _, path := range []string{"/users/1", "/auth/revoked"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %s without service token, got: %d", path, w.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(servicetoken.Header, servicetoken.DevToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusUnauthorized {
+			t.Fatalf("expected %s to accept the service token, got: %d", path, w.Code)
+		}
+	}
+}
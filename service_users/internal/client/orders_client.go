@@ -0,0 +1,71 @@
+package client
+
+import (
+	"common/deadline"
+	"common/servicetoken"
+	"common/tracing"
+	"context"
+	"fmt"
+	"net/http"
+	"service_users/internal/model"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type OrdersClient struct {
+	baseURL string
+	token   string // токен сервиса для /internal/... (см. common/servicetoken)
+	client  *http.Client
+}
+
+func NewOrdersClient(baseURL string, timeout time.Duration, token string) *OrdersClient {
+	return &OrdersClient{
+		baseURL: baseURL,
+		token:   token,
+		client: &http.Client{
+			Timeout: timeout,
+			// service_orders получает traceparent и узнаёт, сколько времени
+			// осталось у запроса
+			Transport: tracing.NewTransport(deadline.NewTransport(nil)),
+		},
+	}
+}
+
+// CheckUserDeletion спрашивает service_orders, можно ли удалить пользователя.
+// model.ErrUserHasActiveOrders - удалять пока нельзя.
+func (c *OrdersClient) CheckUserDeletion(ctx context.Context, userID int) error {
+	return c.post(ctx, fmt.Sprintf("%s/internal/users/%d/deletion/check", c.baseURL, userID))
+}
+
+// ApplyUserDeletion просит service_orders разобраться с заказами удалённого
+// пользователя по своей политике.
+func (c *OrdersClient) ApplyUserDeletion(ctx context.Context, userID int) error {
+	return c.post(ctx, fmt.Sprintf("%s/internal/users/%d/deletion", c.baseURL, userID))
+}
+
+func (c *OrdersClient) post(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(servicetoken.Header, c.token)
+	if rid := middleware.GetReqID(ctx); rid != "" {
+		req.Header.Set(middleware.RequestIDHeader, rid)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("orders service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return model.ErrUserHasActiveOrders
+	default:
+		return fmt.Errorf("unexpected status from orders service: %d", resp.StatusCode)
+	}
+}
@@ -1,26 +1,33 @@
 package handler
 
 import (
+	"common/logging"
+	"common/problem"
 	"context"
 	"net/http"
+	"service_users/internal/service"
+	"strconv"
 	"strings"
 )
 
 type contextKey string
 
-const userIDContextKey contextKey = "userID"
+const (
+	userIDContextKey   contextKey = "userID"
+	authInfoContextKey contextKey = "authInfo"
+)
 
 func (c *UserController) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, `{"error": "missing Authorization header"}`, http.StatusUnauthorized)
+			problem.Write(w, r, problem.Unauthorized.WithDetail("missing Authorization header"))
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			http.Error(w, `{"error": "invalid Authorization header format"}`, http.StatusUnauthorized)
+			problem.Write(w, r, problem.Unauthorized.WithDetail("invalid Authorization header format"))
 			return
 		}
 
@@ -28,17 +35,28 @@ func (c *UserController) AuthMiddleware(next http.Handler) http.Handler {
 
 		authInfo, err := c.service.ParseToken(tokenStr)
 		if err != nil {
-			http.Error(w, `{"error": "invalid or expired token"}`, http.StatusUnauthorized)
+			problem.Write(w, r, problem.Unauthorized.WithDetail("invalid or expired token"))
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), userIDContextKey, authInfo.UserID)
+		ctx = context.WithValue(ctx, authInfoContextKey, authInfo)
 		r = r.WithContext(ctx)
+		logging.SetUser(ctx, authInfo.UserID)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// IdempotencyScope - владелец Idempotency-Key: пользователь из токена,
+// для анонимных запросов (регистрация) - общий пустой scope.
+func IdempotencyScope(r *http.Request) string {
+	if id, ok := getUserIDFromContext(r.Context()); ok {
+		return strconv.Itoa(id)
+	}
+	return ""
+}
+
 // helpers
 func getUserIDFromContext(ctx context.Context) (int, bool) {
 	val := ctx.Value(userIDContextKey)
@@ -47,4 +65,9 @@ func getUserIDFromContext(ctx context.Context) (int, bool) {
 	}
 	id, ok := val.(int)
 	return id, ok
-}
\ No newline at end of file
+}
+
+func getAuthInfoFromContext(ctx context.Context) (*service.AuthInfo, bool) {
+	info, ok := ctx.Value(authInfoContextKey).(*service.AuthInfo)
+	return info, ok && info != nil
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"common/policy"
+	"net/http"
+)
+
+// Auth - аутентификация users-service для policy.Router.
+func (c *UserController) Auth() policy.Auth {
+	return policy.Auth{Middleware: c.AuthMiddleware, Identity: identity}
+}
+
+// identity - личность, которую положил AuthMiddleware.
+func identity(r *http.Request) (int, []string, bool) {
+	info, ok := getAuthInfoFromContext(r.Context())
+	if !ok {
+		return 0, nil, false
+	}
+	return info.UserID, info.Roles, true
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"common/problem"
+	"net/http"
+	"service_users/internal/model"
+)
+
+var errInvalidID = problem.Invalid(problem.FieldError{Field: "id", Code: "invalid", Message: "id must be an integer"})
+
+// Коды ошибок - часть API: клиенты ветвятся по ним, detail может меняться.
+var problems = problem.Mapper{
+	model.ErrUserNotFound:          problem.New(http.StatusNotFound, "user_not_found", "user not found"),
+	model.ErrMissingRequiredFields: problem.New(http.StatusBadRequest, "missing_required_fields", "missing required fields"),
+	model.ErrInvalidEmail:          problem.New(http.StatusBadRequest, "invalid_email", "email is not valid"),
+	model.ErrUniqueEmailConflict:   problem.New(http.StatusConflict, "email_taken", "user with this email already exists"),
+	model.ErrInvalidCredentials:    problem.New(http.StatusUnauthorized, "invalid_credentials", "invalid email or password"),
+	model.ErrInvalidPassword:       problem.New(http.StatusBadRequest, "invalid_password", "password is too short"),
+	model.ErrInvalidRefreshToken:   problem.New(http.StatusUnauthorized, "invalid_refresh_token", "invalid or expired refresh token"),
+	model.ErrRefreshTokenReused:    problem.New(http.StatusUnauthorized, "refresh_token_reused", "refresh token reuse detected, all sessions of this login were revoked"),
+	model.ErrInvalidLimit:          problem.New(http.StatusBadRequest, "invalid_limit", "invalid limit"),
+	model.ErrInvalidSort:           problem.New(http.StatusBadRequest, "invalid_sort", "invalid sort"),
+	model.ErrInvalidCursor:         problem.New(http.StatusBadRequest, "invalid_cursor", "invalid cursor"),
+	model.ErrUserHasActiveOrders:   problem.New(http.StatusConflict, "user_has_active_orders", "user has orders in progress"),
+}
+
+// При выходе неверный refresh-токен - ошибка в теле запроса, а не в аутентификации.
+var logoutProblems = problems.With(problem.Mapper{
+	model.ErrInvalidRefreshToken: problem.Invalid(problem.FieldError{Field: "refreshToken", Code: "invalid", Message: "invalid refresh token"}),
+})
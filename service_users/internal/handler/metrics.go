@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Бизнес-метрики users-service; HTTP-метрики - в common/metrics.
+var (
+	registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "users_registrations_total",
+		Help: "Successfully registered users.",
+	})
+
+	// failure - неверные email или пароль; ошибки валидации и сервера не считаются
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_logins_total",
+		Help: "Login attempts by result: success or failure.",
+	}, []string{"result"})
+)
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"common/deadline"
+	"common/pagination"
+	"common/problem"
+	"common/validate"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"service_users/internal/model"
 	"service_users/internal/service"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -24,30 +28,38 @@ func (c *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid id"}`, http.StatusBadRequest)
+		problem.Write(w, r, errInvalidID)
 		return
 	}
 	
 	var user *model.User
-	user, err = c.service.GetUser(id)
+	user, err = c.service.GetUser(r.Context(), id)
 
 	if err != nil {
-		switch err {
-		case model.ErrUserNotFound:
-			http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 	
 	c.writeJSON(w, http.StatusOK, user)
 }
 
+// GetMany: ?limit=&cursor=&sort=name,-createdAt&role=&emailPrefix=&name=
 func (c *UserController) GetMany(w http.ResponseWriter, r *http.Request) {
-	users, err := c.service.GetAllUsers()
+	q := r.URL.Query()
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	filter := model.UserFilter{
+		Role:         q.Get("role"),
+		EmailPrefix:  q.Get("emailPrefix"),
+		NameContains: q.Get("name"),
+	}
+
+	users, err := c.service.ListUsers(r.Context(), filter, page)
 	if err != nil {
-		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -56,23 +68,14 @@ func (c *UserController) GetMany(w http.ResponseWriter, r *http.Request) {
 
 func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var reqUser model.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqUser); err != nil {
-		http.Error(w, `{"error": "Invalid JSON}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &reqUser); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	id, err := c.service.CreateUser(reqUser)
+	id, err := c.service.CreateUser(r.Context(), reqUser)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "Missing required fields"}`, http.StatusBadRequest)
-		case model.ErrInvalidEmail:
-			http.Error(w, `{"error": "Invalid email"}`, http.StatusBadRequest)
-		case model.ErrUniqueEmailConflict:
-			http.Error(w, `{"error": "User with this email is already exists"}`, http.StatusConflict)
-		default:
-			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -86,30 +89,15 @@ func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	var reqUser model.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqUser); err != nil {
-		http.Error(w, `{"error": "Invalid JSON}`, http.StatusBadRequest)
-		return
-	}
-	if reqUser.ID == 0 {
-		http.Error(w, `{"error": "Id is required"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &reqUser); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	err := c.service.UpdateUser(reqUser)
+	err := c.service.UpdateUser(r.Context(), reqUser)
 
 	if err != nil {
-		switch err {
-		case model.ErrUserNotFound:
-			http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "Missing required fields"}`, http.StatusBadRequest)
-		case model.ErrInvalidEmail:
-			http.Error(w, `{"error": "Invalid email"}`, http.StatusBadRequest)
-		case model.ErrUniqueEmailConflict:
-			http.Error(w, `{"error": "User with this email is already exists"}`, http.StatusConflict)
-		default:
-			http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -124,17 +112,18 @@ func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid id"}`, http.StatusBadRequest)
+		problem.Write(w, r, errInvalidID)
 		return
 	}
 
-	err = c.service.DeleteUser(id)
+	err = c.service.DeleteUser(r.Context(), id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		// запрос к service_orders оборвался: клиент ушёл или вышло время
+		if _, ok := deadline.Status(err); ok {
+			problem.Write(w, r, problems.Problem(err).WithDetail("orders service did not respond in time"))
 			return
 		}
-		http.Error(w, `{"error": "Database error"}`, http.StatusInternalServerError)
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -165,27 +154,17 @@ func (c *UserController) Status(w http.ResponseWriter, r *http.Request) {
 
 func (c *UserController) Register(w http.ResponseWriter, r *http.Request) {
 	var req model.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	id, err := c.service.Register(req)
+	id, err := c.service.Register(r.Context(), req)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "email, name and password are required"}`, http.StatusBadRequest)
-		case model.ErrInvalidEmail:
-			http.Error(w, `{"error": "email is not valid"}`, http.StatusBadRequest)
-		case model.ErrInvalidPassword:
-			http.Error(w, `{"error": "password is too short"}`, http.StatusBadRequest)
-		case model.ErrUniqueEmailConflict:
-			http.Error(w, `{"error": "user with this email already exists"}`, http.StatusConflict)
-		default:
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
+	registrations.Inc()
 
 	c.writeJSON(w, http.StatusCreated, model.APIResponse{
 		Success: true,
@@ -198,50 +177,90 @@ func (c *UserController) Register(w http.ResponseWriter, r *http.Request) {
 
 func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
 	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	token, err := c.service.Login(req)
+	tokens, err := c.service.Login(r.Context(), req)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "email and password are required"}`, http.StatusBadRequest)
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			logins.WithLabelValues("failure").Inc()
+		}
+		problems.Write(w, r, err)
+		return
+	}
+	logins.WithLabelValues("success").Inc()
 
-		case model.ErrInvalidCredentials:
-			http.Error(w, `{"error": "invalid email or password"}`, http.StatusUnauthorized)
+	c.writeTokens(w, tokens)
+}
 
-		default:
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
+func (c *UserController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req model.RefreshRequest
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	tokens, err := c.service.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	c.writeTokens(w, tokens)
+}
+
+func (c *UserController) Logout(w http.ResponseWriter, r *http.Request) {
+	info, ok := getAuthInfoFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.Unauthorized)
+		return
+	}
+
+	// тело необязательное: без refresh-токена отзываем только текущий access-токен
+	var req model.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := validate.DecodeJSON(w, r, &req); err != nil {
+			problems.Write(w, r, err)
+			return
 		}
+	}
+
+	if err := c.service.Logout(info, req.RefreshToken); err != nil {
+		logoutProblems.Write(w, r, err)
 		return
 	}
 
-	c.writeJSON(w, http.StatusOK, model.APIResponse{
-		Success: true,
-		Data: map[string]interface{}{
-			"token": token,
-		},
-	})
+	c.writeJSON(w, http.StatusOK, model.APIResponse{Success: true})
+}
+
+func (c *UserController) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	info, ok := getAuthInfoFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, problem.Unauthorized)
+		return
+	}
+
+	if err := c.service.LogoutAll(info); err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, model.APIResponse{Success: true})
 }
 
 
 func (c *UserController) GetMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := getUserIDFromContext(r.Context())
 	if !ok || userID == 0 {
-		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		problem.Write(w, r, problem.Unauthorized)
 		return
 	}
 
-	user, err := c.service.GetCurrentUser(userID)
+	user, err := c.service.GetCurrentUser(r.Context(), userID)
 	if err != nil {
-		switch err {
-		case model.ErrUserNotFound:
-			http.Error(w, `{"error": "user not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
@@ -251,37 +270,77 @@ func (c *UserController) GetMe(w http.ResponseWriter, r *http.Request) {
 func (c *UserController) UpdateMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := getUserIDFromContext(r.Context())
 	if !ok || userID == 0 {
-		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		problem.Write(w, r, problem.Unauthorized)
 		return
 	}
 
 	var req model.UpdateProfileRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+	if err := validate.DecodeJSON(w, r, &req); err != nil {
+		problems.Write(w, r, err)
 		return
 	}
 
-	user, err := c.service.UpdateProfile(userID, req)
+	user, err := c.service.UpdateProfile(r.Context(), userID, req)
 	if err != nil {
-		switch err {
-		case model.ErrMissingRequiredFields:
-			http.Error(w, `{"error": "name is required"}`, http.StatusBadRequest)
-		case model.ErrUserNotFound:
-			http.Error(w, `{"error": "user not found"}`, http.StatusNotFound)
-		default:
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		}
+		problems.Write(w, r, err)
 		return
 	}
 
 	c.writeJSON(w, http.StatusOK, user)
 }
 
+// JWKS публикует публичные ключи подписи access-токенов (RFC 7517).
+func (c *UserController) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	c.writeJSON(w, http.StatusOK, c.service.JWKS())
+}
+
+// RevokedTokens отдаёт отозванные access-токены; gateway опрашивает список
+// и не пускает их дальше.
+func (c *UserController) RevokedTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := c.service.RevokedTokens()
+	if err != nil {
+		problems.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	c.writeJSON(w, http.StatusOK, tokens)
+}
+
 // Helpers
+func parsePageRequest(w http.ResponseWriter, r *http.Request) (pagination.Request, bool) {
+	q := r.URL.Query()
+	page := pagination.Request{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Write(w, r, problem.Invalid(problem.FieldError{Field: "limit", Code: "invalid", Message: "limit must be an integer"}))
+			return page, false
+		}
+		page.Limit = limit
+	}
+
+	return page, true
+}
+
+func (c *UserController) writeTokens(w http.ResponseWriter, tokens *model.TokenPair) {
+	c.writeJSON(w, http.StatusOK, model.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"token":        tokens.AccessToken, // для старых клиентов
+			"accessToken":  tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"tokenType":    "Bearer",
+			"expiresIn":    tokens.ExpiresIn,
+		},
+	})
+}
+
 func (c *UserController) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, `{"error": "Failed to encode response"}`, http.StatusInternalServerError)
-	}
+	// заголовок уже отправлен: при ошибке кодирования ответ исправить нельзя
+	_ = json.NewEncoder(w).Encode(data)
 }
\ No newline at end of file
@@ -2,23 +2,38 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/crypto/bcrypt"
 
+	"common/policy"
+	"common/problem"
 	"service_users/internal/handler"
 	"service_users/internal/model"
 	"service_users/internal/repository"
 	"service_users/internal/service"
 )
 
+type stubOrdersClient struct{}
+
+func (stubOrdersClient) CheckUserDeletion(ctx context.Context, userID int) error { return nil }
+func (stubOrdersClient) ApplyUserDeletion(ctx context.Context, userID int) error { return nil }
+
 func newTestController() (*handler.UserController, *service.UserService, *repository.UserRepository) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	keys, err := service.NewKeyManager("", time.Hour, 0)
+	if err != nil {
+		panic(err)
+	}
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), keys)
 	ctrl := handler.NewUserController(*svc)
 	return ctrl, svc, repo
 }
@@ -80,6 +95,58 @@ func TestRegisterHandler_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestCreateUserHandler_InvalidJSONIsProblem(t *testing.T) {
+	ctrl, _, _ := newTestController()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Post("/users", ctrl.CreateUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{invalid json`))
+	req.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Fatalf("expected %s, got: %s", problem.ContentType, ct)
+	}
+	var p problem.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatalf("expected valid problem JSON, got: %v", err)
+	}
+	if p.Status != http.StatusBadRequest || p.Code != "invalid_json" || p.RequestID != "req-42" || p.Instance != "/users" {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+}
+
+func TestGetUserHandler_NotFoundAndInvalidID(t *testing.T) {
+	ctrl, _, _ := newTestController()
+
+	r := chi.NewRouter()
+	r.Get("/users/{id}", ctrl.GetUser)
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{path: "/users/999", status: http.StatusNotFound, code: "user_not_found"},
+		{path: "/users/abc", status: http.StatusBadRequest, code: "validation_failed"},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		var p problem.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("expected valid problem JSON, got: %v", err)
+		}
+		if rr.Code != tc.status || p.Code != tc.code {
+			t.Fatalf("%s: expected %d %s, got %d %+v", tc.path, tc.status, tc.code, rr.Code, p)
+		}
+	}
+}
+
 func TestRegisterHandler_MissingFields(t *testing.T) {
 	ctrl, _, _ := newTestController()
 
@@ -96,8 +163,29 @@ func TestRegisterHandler_MissingFields(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
-	if !contains(rr.Body.String(), "email, name and password are required") {
-		t.Fatalf("unexpected body: %s", rr.Body.String())
+	for _, msg := range []string{"email is required", "name is required", "password is required"} {
+		if !contains(rr.Body.String(), msg) {
+			t.Fatalf("expected field error %q, got: %s", msg, rr.Body.String())
+		}
+	}
+}
+
+func TestRegisterHandler_RejectsUnknownFields(t *testing.T) {
+	ctrl, _, _ := newTestController()
+
+	r := chi.NewRouter()
+	r.Post("/auth/register", ctrl.Register)
+
+	body := `{"email":"test@example.com","name":"Test User","password":"secret123","roles":["admin"]}`
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body)))
+
+	var p problem.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatalf("expected valid problem JSON, got: %v", err)
+	}
+	if rr.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "roles" || p.Errors[0].Code != "unknown_field" {
+		t.Fatalf("expected unknown_field error for roles, got %d %+v", rr.Code, p)
 	}
 }
 
@@ -107,7 +195,7 @@ func TestRegisterHandler_EmailConflict(t *testing.T) {
 	r := chi.NewRouter()
 	r.Post("/auth/register", ctrl.Register)
 
-	_, err := svc.Register(model.RegisterRequest{
+	_, err := svc.Register(context.Background(), model.RegisterRequest{
 		Email:    "same@example.com",
 		Name:     "User1",
 		Password: "secret123",
@@ -138,7 +226,7 @@ func TestLoginHandler_Success(t *testing.T) {
 	r.Post("/auth/login", ctrl.Login)
 	r.Post("/auth/register", ctrl.Register)
 
-	_, err := svc.Register(model.RegisterRequest{
+	_, err := svc.Register(context.Background(), model.RegisterRequest{
 		Email:    "login@example.com",
 		Name:     "Login User",
 		Password: "secret123",
@@ -181,7 +269,7 @@ func TestLoginHandler_InvalidCredentials(t *testing.T) {
 	r := chi.NewRouter()
 	r.Post("/auth/login", ctrl.Login)
 
-	_, err := svc.Register(model.RegisterRequest{
+	_, err := svc.Register(context.Background(), model.RegisterRequest{
 		Email:    "login2@example.com",
 		Name:     "User",
 		Password: "secret123",
@@ -221,8 +309,10 @@ func TestLoginHandler_MissingFields(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
-	if !contains(rr.Body.String(), "email and password are required") {
-		t.Fatalf("unexpected body: %s", rr.Body.String())
+	for _, msg := range []string{"email is required", "password is required"} {
+		if !contains(rr.Body.String(), msg) {
+			t.Fatalf("expected field error %q, got: %s", msg, rr.Body.String())
+		}
 	}
 }
 
@@ -233,7 +323,7 @@ func TestGetMeHandler_Success(t *testing.T) {
 
 	r.With(ctrl.AuthMiddleware).Get("/users/me", ctrl.GetMe)
 
-	id, err := svc.Register(model.RegisterRequest{
+	id, err := svc.Register(context.Background(), model.RegisterRequest{
 		Email:    "me@example.com",
 		Name:     "Me User",
 		Password: "secret123",
@@ -245,7 +335,7 @@ func TestGetMeHandler_Success(t *testing.T) {
 		t.Fatalf("expected non-zero id")
 	}
 
-	token, err := svc.Login(model.LoginRequest{
+	token, err := svc.Login(context.Background(), model.LoginRequest{
 		Email:    "me@example.com",
 		Password: "secret123",
 	})
@@ -254,7 +344,7 @@ func TestGetMeHandler_Success(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
-	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 
 	rr := httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
@@ -318,4 +408,113 @@ func TestGetMeHandler_InvalidToken(t *testing.T) {
 
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
-}
\ No newline at end of file
+}
+func TestJWKSHandler_PublishesSigningKey(t *testing.T) {
+	ctrl, svc, _ := newTestController()
+
+	r := chi.NewRouter()
+	r.Get("/.well-known/jwks.json", ctrl.JWKS)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var jwks model.JWKS
+	if err := json.NewDecoder(w.Body).Decode(&jwks); err != nil {
+		t.Fatalf("failed to decode JWKS: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != svc.JWKS().Keys[0].Kid {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+}
+
+func TestAdminOnlyPolicy(t *testing.T) {
+	ctrl, svc, repo := newTestController()
+
+	r := chi.NewRouter()
+	r.With(policy.AdminOnly.Middlewares(ctrl.Auth())...).Delete("/users/{id}", ctrl.DeleteUser)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if _, err := repo.Create(&model.CreateUserRequest{
+		Email:        "root@example.com",
+		Name:         "Root",
+		PasswordHash: string(hash),
+		Roles:        []string{policy.RoleAdmin},
+	}); err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	if _, err := svc.Register(context.Background(), model.RegisterRequest{Email: "plain@example.com", Name: "Plain", Password: "secret123"}); err != nil {
+		t.Fatalf("unexpected error on register: %v", err)
+	}
+
+	login := func(email string) string {
+		tokens, err := svc.Login(context.Background(), model.LoginRequest{Email: email, Password: "secret123"})
+		if err != nil {
+			t.Fatalf("unexpected error on login: %v", err)
+		}
+		return tokens.AccessToken
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		userID string
+		want   int
+	}{
+		{name: "anonymous", token: "", userID: "1", want: http.StatusUnauthorized},
+		{name: "regular user", token: login("plain@example.com"), userID: "1", want: http.StatusForbidden},
+		{name: "admin", token: login("root@example.com"), userID: "1", want: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/users/"+tc.userID, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("expected status %d, got %d, body: %s", tc.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetManyHandler_ReturnsPageEnvelope(t *testing.T) {
+	ctrl, _, _ := newTestController()
+
+	r := chi.NewRouter()
+	r.Get("/users", ctrl.GetMany)
+
+	req := httptest.NewRequest(http.MethodGet, "/users?limit=2&sort=-createdAt", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	var page model.UserPage
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode page: %v", err)
+	}
+	if len(page.Items) != 2 || page.Total != 3 || page.NextCursor == nil {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users?limit=abc", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for bad limit, got %d", rr.Code)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
-import "errors"
+import (
+	"common/pagination"
+	"errors"
+)
 
 var (
 	ErrUserNotFound          = errors.New("user not found")
@@ -9,15 +12,19 @@ var (
 	ErrUniqueEmailConflict   = errors.New("user with this email is already exists")
 	ErrInvalidCredentials    = errors.New("invalid email or password")
 	ErrInvalidPassword       = errors.New("invalid password")
+	ErrInvalidRefreshToken   = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused    = errors.New("refresh token reuse detected")
+	ErrTokenRevoked          = errors.New("token has been revoked")
+	ErrUnknownSigningKey     = errors.New("unknown or expired signing key")
+	ErrInvalidLimit          = pagination.ErrInvalidLimit
+	ErrInvalidSort           = pagination.ErrInvalidSort
+	ErrInvalidCursor         = pagination.ErrInvalidCursor
+	ErrUserHasActiveOrders   = errors.New("user has orders in progress")
 )
 
-type APIError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-}
-
+// APIResponse - конверт успешных ответов auth-эндпоинтов; ошибки уходят
+// как application/problem+json.
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   *APIError   `json:"error,omitempty"`
 }
\ No newline at end of file
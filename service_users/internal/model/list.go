@@ -0,0 +1,58 @@
+package model
+
+import (
+	"common/pagination"
+	"slices"
+	"strings"
+)
+
+type UserFilter struct {
+	Role         string
+	EmailPrefix  string
+	NameContains string // без учёта регистра
+}
+
+type UserListQuery struct {
+	Filter UserFilter
+	Sort   []pagination.SortField // id ASC всегда добавляется последним
+	Limit  int
+	After  *pagination.Cursor
+}
+
+type UserPage struct {
+	Items      []User  `json:"items"`
+	NextCursor *string `json:"nextCursor"` // null - это последняя страница
+	Total      int     `json:"total"`
+}
+
+// UserSortFields - поля, по которым разрешена сортировка.
+var UserSortFields = []string{"id", "name", "email", "createdAt"}
+
+// SortKey - ключ сортировки пользователя для pagination.Item.
+func (u User) SortKey(field string) string {
+	switch field {
+	case "name":
+		return u.Name
+	case "email":
+		return u.Email
+	case "createdAt":
+		return pagination.TimeKey(u.CreatedAt)
+	default:
+		return pagination.IntKey(int64(u.ID))
+	}
+}
+
+func (u User) SortID() int { return u.ID }
+
+func (f UserFilter) Match(u User) bool {
+	if f.Role != "" && !slices.Contains(u.Roles, f.Role) {
+		return false
+	}
+	if f.EmailPrefix != "" && !strings.HasPrefix(strings.ToLower(u.Email), strings.ToLower(f.EmailPrefix)) {
+		return false
+	}
+	if f.NameContains != "" && !strings.Contains(strings.ToLower(u.Name), strings.ToLower(f.NameContains)) {
+		return false
+	}
+	return true
+}
@@ -12,29 +12,84 @@ type User struct {
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
+// Правила validate проверяются при разборе тела запроса (validate.DecodeJSON).
 type CreateUserRequest struct {
-	Email        string `json:"email,omitempty"`
-	Name         string `json:"name,omitempty"`
+	Email        string `json:"email,omitempty" validate:"required,email,max=254"`
+	Name         string `json:"name,omitempty" validate:"required,max=100"`
 	PasswordHash string
 	Roles        []string
 }
 
 type UpdateUserRequest struct {
-	ID   int    `json:"id"`
-	Name string `json:"name,omitempty"`
+	ID   int    `json:"id" validate:"required,min=1"`
+	Name string `json:"name,omitempty" validate:"required,max=100"`
 }
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,min=6,max=72"` // bcrypt учитывает только 72 байта
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UpdateProfileRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // время жизни access-токена в секундах
+}
+
+// RefreshToken - запись о выданном refresh-токене. Сам токен не храним, только его хеш.
+// Все токены, полученные ротацией от одного логина, образуют семейство (FamilyID).
+type RefreshToken struct {
+	Hash            string
+	UserID          int
+	FamilyID        string
+	AccessJTI       string    // jti access-токена, выданного в паре с этим refresh-токеном
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	Used            bool
+	Revoked         bool
+	CreatedAt       time.Time
+}
+
+// JWK - публичный ключ подписи в формате RFC 7517 (у нас только Ed25519 / OKP).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// RevokedToken - отозванный access-токен, который ещё не истёк сам.
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RevokedTokens - список для gateway: он проверяет токены сам и без списка
+// пускал бы отозванные токены до конца их TTL.
+type RevokedTokens struct {
+	Tokens []RevokedToken `json:"tokens"`
 }
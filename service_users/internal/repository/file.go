@@ -0,0 +1,536 @@
+package repository
+
+import (
+	"bufio"
+	"common/events"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"service_users/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	walFileName      = "users.wal"
+	snapshotFileName = "users.snapshot.json"
+
+	walOpPut       = "put"
+	walOpDelete    = "delete"
+	walOpPublished = "published"
+
+	defaultSnapshotEvery = 1000
+)
+
+// FileUserRepository держит пользователей в памяти, но каждое изменение
+// сначала дописывается в журнал (WAL) на диске. Журнал периодически
+// сворачивается в снапшот, а при старте снапшот + журнал проигрываются заново.
+type FileUserRepository struct {
+	mu      sync.RWMutex
+	storage map[int]model.User
+	nextID  int
+	outbox  events.MemoryOutbox
+
+	dir           string
+	wal           *os.File
+	walRecords    int
+	snapshotEvery int
+}
+
+// storedUser - представление пользователя на диске. model.User не годится,
+// потому что PasswordHash у него не сериализуется.
+type storedUser struct {
+	ID           int       `json:"id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"passwordHash"`
+	Roles        []string  `json:"roles"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Записи журнала идемпотентны: put кладёт пользователя целиком, delete удаляет по ID.
+// Поэтому повторное проигрывание уже попавших в снапшот записей безопасно.
+// События outbox едут в той же записи, что и изменение, а published убирает
+// их из outbox после публикации.
+type walRecord struct {
+	Op       string         `json:"op"`
+	ID       int            `json:"id"`
+	User     *storedUser    `json:"user,omitempty"`
+	Events   []events.Event `json:"events,omitempty"`
+	EventIDs []string       `json:"eventIds,omitempty"`
+}
+
+type snapshot struct {
+	NextID int            `json:"nextId"`
+	Users  []storedUser   `json:"users"`
+	Outbox []events.Event `json:"outbox,omitempty"`
+}
+
+func NewFileUserRepository(dir string, snapshotEvery int) (*FileUserRepository, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	r := &FileUserRepository{
+		storage:       make(map[int]model.User),
+		nextID:        1,
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+
+	hasSnapshot, err := r.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+	r.wal = wal
+
+	if err := r.replayWAL(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	// пустое хранилище заполняем теми же пользователями, что и in-memory вариант
+	if !hasSnapshot && r.walRecords == 0 {
+		for _, u := range seedUsers(time.Now()) {
+			r.storage[u.ID] = u
+			if u.ID >= r.nextID {
+				r.nextID = u.ID + 1
+			}
+		}
+		if err := r.writeSnapshot(); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *FileUserRepository) GetByID(id int) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.storage[id]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (r *FileUserRepository) GetAll() ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]model.User, 0, len(r.storage))
+	for _, u := range r.storage {
+		res = append(res, u)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r *FileUserRepository) List(q model.UserListQuery) ([]model.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]model.User, 0, len(r.storage))
+	for _, u := range r.storage {
+		all = append(all, u)
+	}
+
+	items, total := listUsers(all, q)
+	return items, total, nil
+}
+
+func (r *FileUserRepository) Create(req *model.CreateUserRequest, evs ...events.Event) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.storage {
+		if req.Email == u.Email {
+			return 0, model.ErrUniqueEmailConflict
+		}
+	}
+
+	now := time.Now()
+	newUser := model.User{
+		ID:           r.nextID,
+		Email:        req.Email,
+		Name:         req.Name,
+		PasswordHash: req.PasswordHash,
+		Roles:        req.Roles,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	events.FillAggregateID(evs, strconv.Itoa(newUser.ID))
+	if err := r.commit(walRecord{Op: walOpPut, ID: newUser.ID, User: toStored(newUser), Events: evs}); err != nil {
+		return 0, err
+	}
+
+	return newUser.ID, nil
+}
+
+func (r *FileUserRepository) Update(user *model.UpdateUserRequest, evs ...events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userDB, ok := r.storage[user.ID]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+
+	for _, u := range r.storage {
+		if userDB.Email == u.Email && userDB.ID != u.ID {
+			return model.ErrUniqueEmailConflict
+		}
+	}
+
+	userDB.Name = user.Name
+	userDB.UpdatedAt = time.Now()
+
+	return r.commit(walRecord{Op: walOpPut, ID: userDB.ID, User: toStored(userDB), Events: evs})
+}
+
+func (r *FileUserRepository) Delete(id int, evs ...events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.storage[id]; !ok {
+		return model.ErrUserNotFound
+	}
+
+	return r.commit(walRecord{Op: walOpDelete, ID: id, Events: evs})
+}
+
+func (r *FileUserRepository) GetByEmail(email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.storage {
+		if u.Email == email {
+			userCopy := u
+			return &userCopy, nil
+		}
+	}
+
+	return nil, model.ErrUserNotFound
+}
+
+func (r *FileUserRepository) PendingEvents(limit int) ([]events.Event, error) {
+	return r.outbox.PendingEvents(limit)
+}
+
+// MarkPublished тоже идёт через журнал, иначе после рестарта
+// уже опубликованные события ушли бы повторно.
+func (r *FileUserRepository) MarkPublished(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.commit(walRecord{Op: walOpPublished, EventIDs: ids})
+}
+
+// Snapshot принудительно сворачивает журнал в снапшот.
+func (r *FileUserRepository) Snapshot() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.writeSnapshot()
+}
+
+func (r *FileUserRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.wal == nil {
+		return nil
+	}
+	err := r.wal.Close()
+	r.wal = nil
+	return err
+}
+
+// commit пишет запись в журнал, дожидается fsync и только потом применяет
+// её к состоянию в памяти. Вызывать под r.mu.
+func (r *FileUserRepository) commit(rec walRecord) error {
+	if r.wal == nil {
+		return errors.New("repository is closed")
+	}
+
+	line, err := encodeWALRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	offset, err := r.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := r.wal.Write(line); err != nil {
+		r.rollbackWAL(offset)
+		return fmt.Errorf("write wal: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		r.rollbackWAL(offset)
+		return fmt.Errorf("sync wal: %w", err)
+	}
+
+	r.apply(rec)
+	r.walRecords++
+
+	if r.walRecords >= r.snapshotEvery {
+		// запись уже в журнале, так что неудачная компакция данные не теряет
+		if err := r.writeSnapshot(); err != nil {
+			slog.Error("users wal compaction failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// rollbackWAL убирает частично записанную запись, чтобы следующая
+// не оказалась склеена с мусором.
+func (r *FileUserRepository) rollbackWAL(offset int64) {
+	if err := r.truncateWAL(offset); err != nil {
+		slog.Error("users wal rollback failed", "offset", offset, "error", err)
+	}
+}
+
+func (r *FileUserRepository) apply(rec walRecord) {
+	switch rec.Op {
+	case walOpPut:
+		if rec.User == nil {
+			return
+		}
+		r.storage[rec.ID] = fromStored(*rec.User)
+		if rec.ID >= r.nextID {
+			r.nextID = rec.ID + 1
+		}
+	case walOpDelete:
+		delete(r.storage, rec.ID)
+	case walOpPublished:
+		_ = r.outbox.MarkPublished(rec.EventIDs...)
+		return
+	}
+	r.outbox.Add(rec.Events...)
+}
+
+// writeSnapshot атомарно (tmp + rename) записывает текущее состояние
+// и обнуляет журнал. Вызывать под r.mu.
+func (r *FileUserRepository) writeSnapshot() error {
+	snap := snapshot{
+		NextID: r.nextID,
+		Users:  make([]storedUser, 0, len(r.storage)),
+	}
+	for _, u := range r.storage {
+		snap.Users = append(snap.Users, *toStored(u))
+	}
+	sort.Slice(snap.Users, func(i, j int) bool {
+		return snap.Users[i].ID < snap.Users[j].ID
+	})
+	snap.Outbox, _ = r.outbox.PendingEvents(0)
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.dir, snapshotFileName)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	// если упадём до обрезки журнала, его записи просто проиграются поверх снапшота
+	if err := r.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if _, err := r.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+
+	r.walRecords = 0
+	return nil
+}
+
+func (r *FileUserRepository) loadSnapshot() (bool, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	for _, u := range snap.Users {
+		r.storage[u.ID] = fromStored(u)
+	}
+	r.nextID = snap.NextID
+	r.outbox.Add(snap.Outbox...)
+	return true, nil
+}
+
+// replayWAL применяет записи журнала. Недописанный или повреждённый хвост
+// (падение посреди записи) отрезается, всё до него считается валидным.
+func (r *FileUserRepository) replayWAL() error {
+	if _, err := r.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r.wal)
+	var offset int64
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			if line != "" {
+				slog.Warn("users wal: dropping incomplete record", "offset", offset)
+				return r.truncateWAL(offset)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read wal: %w", err)
+		}
+
+		rec, err := decodeWALRecord(line)
+		if err != nil {
+			slog.Warn("users wal: dropping corrupted tail", "offset", offset, "error", err)
+			return r.truncateWAL(offset)
+		}
+
+		r.apply(rec)
+		r.walRecords++
+		offset += int64(len(line))
+	}
+
+	_, err := r.wal.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (r *FileUserRepository) truncateWAL(offset int64) error {
+	if err := r.wal.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+	_, err := r.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// Формат строки журнала: "<crc32 в hex> <json>\n".
+func encodeWALRecord(rec walRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)), nil
+}
+
+func decodeWALRecord(line string) (walRecord, error) {
+	var rec walRecord
+
+	line = strings.TrimSuffix(line, "\n")
+	sum, payload, ok := strings.Cut(line, " ")
+	if !ok {
+		return rec, errors.New("malformed record")
+	}
+
+	var expected uint32
+	if _, err := fmt.Sscanf(sum, "%08x", &expected); err != nil {
+		return rec, fmt.Errorf("malformed checksum: %w", err)
+	}
+	if crc32.ChecksumIEEE([]byte(payload)) != expected {
+		return rec, errors.New("checksum mismatch")
+	}
+
+	if err := json.Unmarshal([]byte(payload), &rec); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func toStored(u model.User) *storedUser {
+	return &storedUser{
+		ID:           u.ID,
+		Email:        u.Email,
+		Name:         u.Name,
+		PasswordHash: u.PasswordHash,
+		Roles:        u.Roles,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+func fromStored(u storedUser) model.User {
+	return model.User{
+		ID:           u.ID,
+		Email:        u.Email,
+		Name:         u.Name,
+		PasswordHash: u.PasswordHash,
+		Roles:        u.Roles,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
@@ -0,0 +1,218 @@
+package repository_test
+
+import (
+	"common/events"
+	"context"
+	"os"
+	"path/filepath"
+	"service_users/internal/model"
+	"service_users/internal/repository"
+	"testing"
+)
+
+func TestFileUserRepository_SeedsEmptyStore(t *testing.T) {
+	repo, err := repository.NewFileUserRepository(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	defer repo.Close()
+
+	users, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("expected 3 seeded users, got %d", len(users))
+	}
+}
+
+func TestFileUserRepository_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := repository.NewFileUserRepository(dir, 0)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+
+	id, err := repo.Create(&model.CreateUserRequest{
+		Email:        "bob@example.com",
+		Name:         "Bob",
+		PasswordHash: "hash",
+		Roles:        []string{"user"},
+	})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Update(&model.UpdateUserRequest{ID: id, Name: "Bobby"}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := repo.Delete(2); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	repo.Close()
+
+	reopened, err := repository.NewFileUserRepository(dir, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	u, err := reopened.GetByID(id)
+	if err != nil {
+		t.Fatalf("expected user after reopen, got error: %v", err)
+	}
+	if u.Name != "Bobby" || u.PasswordHash != "hash" {
+		t.Errorf("unexpected user after reopen: %+v", u)
+	}
+	if _, err := reopened.GetByID(2); err != model.ErrUserNotFound {
+		t.Errorf("expected deleted user to stay deleted, got: %v", err)
+	}
+
+	// email по-прежнему уникален
+	if _, err := reopened.Create(&model.CreateUserRequest{Email: "bob@example.com", Name: "Other"}); err != model.ErrUniqueEmailConflict {
+		t.Fatalf("expected ErrUniqueEmailConflict, got: %v", err)
+	}
+
+	// ID не переиспользуются
+	nextID, err := reopened.Create(&model.CreateUserRequest{Email: "carol@example.com", Name: "Carol"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if nextID <= id {
+		t.Errorf("expected id greater than %d, got %d", id, nextID)
+	}
+}
+
+func TestFileUserRepository_CompactsIntoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := repository.NewFileUserRepository(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := repo.Create(&model.CreateUserRequest{Email: email, Name: "User"}); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+	repo.Close()
+
+	info, err := os.Stat(filepath.Join(dir, "users.wal"))
+	if err != nil {
+		t.Fatalf("stat wal: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("expected the record after compaction to stay in wal")
+	}
+
+	reopened, err := repository.NewFileUserRepository(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	users, _ := reopened.GetAll()
+	if len(users) != 6 {
+		t.Fatalf("expected 6 users, got %d", len(users))
+	}
+}
+
+func TestFileUserRepository_DropsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := repository.NewFileUserRepository(dir, 0)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	id, err := repo.Create(&model.CreateUserRequest{Email: "dave@example.com", Name: "Dave"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	repo.Close()
+
+	// имитируем падение посреди записи
+	f, err := os.OpenFile(filepath.Join(dir, "users.wal"), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open wal: %v", err)
+	}
+	if _, err := f.WriteString(`1234abcd {"op":"put","id":99,"us`); err != nil {
+		t.Fatalf("write wal: %v", err)
+	}
+	f.Close()
+
+	reopened, err := repository.NewFileUserRepository(dir, 0)
+	if err != nil {
+		t.Fatalf("expected torn tail to be dropped, got error: %v", err)
+	}
+
+	if _, err := reopened.GetByID(id); err != nil {
+		t.Fatalf("expected committed user to survive, got: %v", err)
+	}
+	if _, err := reopened.GetByID(99); err != model.ErrUserNotFound {
+		t.Fatalf("expected torn record to be ignored, got: %v", err)
+	}
+
+	// после обрезки хвоста журнал снова пригоден для записи
+	newID, err := reopened.Create(&model.CreateUserRequest{Email: "erin@example.com", Name: "Erin"})
+	if err != nil {
+		t.Fatalf("create after recovery failed: %v", err)
+	}
+	reopened.Close()
+
+	again, err := repository.NewFileUserRepository(dir, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer again.Close()
+
+	if _, err := again.GetByID(newID); err != nil {
+		t.Fatalf("expected user created after recovery, got: %v", err)
+	}
+}
+
+func TestFileUserRepository_OutboxSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := repository.NewFileUserRepository(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+
+	created, _ := events.New(context.Background(), events.SourceUsers, "", events.UserCreated{Email: "eve@example.com", Name: "Eve"})
+	deleted, _ := events.New(context.Background(), events.SourceUsers, "1", events.UserDeleted{Email: "alice@example.com"})
+
+	if _, err := repo.Create(&model.CreateUserRequest{Email: "eve@example.com", Name: "Eve"}, created); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := repo.Delete(1, deleted); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	// snapshotEvery = 2, так что часть состояния уже в снапшоте
+	if err := repo.MarkPublished(created.ID); err != nil {
+		t.Fatalf("mark published failed: %v", err)
+	}
+	repo.Close()
+
+	reopened, err := repository.NewFileUserRepository(dir, 2)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	pending, err := reopened.PendingEvents(10)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != deleted.ID {
+		t.Fatalf("expected only %s pending, got: %+v", deleted.ID, pending)
+	}
+
+	if err := reopened.Snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	pending, _ = reopened.PendingEvents(10)
+	if len(pending) != 1 {
+		t.Fatalf("expected pending event to stay in snapshot, got: %+v", pending)
+	}
+}
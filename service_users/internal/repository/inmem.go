@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"common/events"
 	"service_users/internal/model"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -11,6 +13,7 @@ type UserRepository struct {
 	mu      sync.RWMutex
 	storage map[int]model.User
 	nextID  int
+	outbox  events.MemoryOutbox
 }
 
 func NewUserRepository() *UserRepository {
@@ -19,39 +22,44 @@ func NewUserRepository() *UserRepository {
 		nextID:  4,
 	}
 
-	now := time.Now()
-
-	r.storage[1] = model.User{
-		ID:           1,
-		Name:         "Alice",
-		Email:        "alice@example.com",
-		PasswordHash: "",               // временно пусто
-		Roles:        []string{"user"}, // по умолчанию обычный пользователь
-		CreatedAt:    now,
-		UpdatedAt:    now,
+	for _, u := range seedUsers(time.Now()) {
+		r.storage[u.ID] = u
 	}
 
-	r.storage[2] = model.User{
-		ID:           2,
-		Name:         "John",
-		Email:        "john@example.com",
-		PasswordHash: "",
-		Roles:        []string{"user"},
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	}
+	return r
+}
 
-	r.storage[3] = model.User{
-		ID:           3,
-		Name:         "Andrew",
-		Email:        "andrew@example.com",
-		PasswordHash: "",
-		Roles:        []string{"admin"}, // допустим, ты админ :)
-		CreatedAt:    now,
-		UpdatedAt:    now,
+// seedUsers - стартовый набор пользователей, общий для всех реализаций репозитория.
+func seedUsers(now time.Time) []model.User {
+	return []model.User{
+		{
+			ID:           1,
+			Name:         "Alice",
+			Email:        "alice@example.com",
+			PasswordHash: "",               // временно пусто
+			Roles:        []string{"user"}, // по умолчанию обычный пользователь
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		{
+			ID:           2,
+			Name:         "John",
+			Email:        "john@example.com",
+			PasswordHash: "",
+			Roles:        []string{"user"},
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		{
+			ID:           3,
+			Name:         "Andrew",
+			Email:        "andrew@example.com",
+			PasswordHash: "",
+			Roles:        []string{"admin"}, // допустим, ты админ :)
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
 	}
-
-	return r
 }
 
 
@@ -82,7 +90,20 @@ func (r *UserRepository) GetAll() ([]model.User, error) {
 	return res, nil
 }
 
-func (r *UserRepository) Create(req *model.CreateUserRequest) (int, error) {
+func (r *UserRepository) List(q model.UserListQuery) ([]model.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]model.User, 0, len(r.storage))
+	for _, u := range r.storage {
+		all = append(all, u)
+	}
+
+	items, total := listUsers(all, q)
+	return items, total, nil
+}
+
+func (r *UserRepository) Create(req *model.CreateUserRequest, evs ...events.Event) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -104,11 +125,14 @@ func (r *UserRepository) Create(req *model.CreateUserRequest) (int, error) {
 
 	r.nextID++
 	r.storage[newUser.ID] = *newUser
+
+	events.FillAggregateID(evs, strconv.Itoa(newUser.ID))
+	r.outbox.Add(evs...)
 	return newUser.ID, nil
 }
 
 
-func (r *UserRepository) Update(user *model.UpdateUserRequest) error {
+func (r *UserRepository) Update(user *model.UpdateUserRequest, evs ...events.Event) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -126,12 +150,13 @@ func (r *UserRepository) Update(user *model.UpdateUserRequest) error {
 	userDB.Name = user.Name
 	userDB.UpdatedAt = time.Now()
 	r.storage[user.ID] = userDB
+	r.outbox.Add(evs...)
 
 	return nil
 }
 
 
-func (r *UserRepository) Delete(id int) error {
+func (r *UserRepository) Delete(id int, evs ...events.Event) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -141,6 +166,7 @@ func (r *UserRepository) Delete(id int) error {
 	}
 
 	delete(r.storage, id)
+	r.outbox.Add(evs...)
 	return nil
 }
 
@@ -156,4 +182,12 @@ func (r *UserRepository) GetByEmail(email string) (*model.User, error) {
 	}
 
 	return nil, model.ErrUserNotFound
-}
\ No newline at end of file
+}
+
+func (r *UserRepository) PendingEvents(limit int) ([]events.Event, error) {
+	return r.outbox.PendingEvents(limit)
+}
+
+func (r *UserRepository) MarkPublished(ids ...string) error {
+	return r.outbox.MarkPublished(ids...)
+}
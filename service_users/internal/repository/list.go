@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"common/pagination"
+	"service_users/internal/model"
+)
+
+// listUsers - общая для in-memory и файлового репозитория реализация List:
+// фильтр, сортировка и keyset-пагинация поверх уже загруженных пользователей.
+func listUsers(all []model.User, q model.UserListQuery) ([]model.User, int) {
+	filtered := make([]model.User, 0, len(all))
+	for _, u := range all {
+		if q.Filter.Match(u) {
+			filtered = append(filtered, u)
+		}
+	}
+
+	return pagination.Slice(filtered, q.Sort, q.After, q.Limit), len(filtered)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"service_users/internal/model"
+	"sync"
+	"time"
+)
+
+// TokenStore хранит refresh-токены и список отозванных access-токенов в памяти.
+// После рестарта все refresh-токены пропадают (пользователю придётся
+// залогиниться заново), а отозванные access-токены доживают свой короткий TTL.
+type TokenStore struct {
+	mu      sync.Mutex
+	refresh map[string]*model.RefreshToken // hash -> токен
+	revoked map[string]time.Time           // jti -> когда истекает сам токен
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		refresh: make(map[string]*model.RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *TokenStore) SaveRefreshToken(token model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(time.Now())
+	s.refresh[token.Hash] = &token
+	return nil
+}
+
+func (s *TokenStore) GetRefreshToken(hash string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return nil, model.ErrInvalidRefreshToken
+	}
+	tokenCopy := *t
+	return &tokenCopy, nil
+}
+
+func (s *TokenStore) UseRefreshToken(hash string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return nil, model.ErrInvalidRefreshToken
+	}
+	before := *t
+	t.Used = true
+	return &before, nil
+}
+
+func (s *TokenStore) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refresh {
+		if t.FamilyID == familyID {
+			s.revokeLocked(t)
+		}
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeUserTokens(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refresh {
+		if t.UserID == userID {
+			s.revokeLocked(t)
+		}
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *TokenStore) IsAccessTokenRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *TokenStore) RevokedAccessTokens() ([]model.RevokedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	res := make([]model.RevokedToken, 0, len(s.revoked))
+	for jti, exp := range s.revoked {
+		if now.Before(exp) {
+			res = append(res, model.RevokedToken{JTI: jti, ExpiresAt: exp})
+		}
+	}
+	return res, nil
+}
+
+func (s *TokenStore) revokeLocked(t *model.RefreshToken) {
+	t.Revoked = true
+	if t.AccessJTI != "" {
+		s.revoked[t.AccessJTI] = t.AccessExpiresAt
+	}
+}
+
+// pruneLocked выкидывает записи, которые уже истекли сами по себе.
+func (s *TokenStore) pruneLocked(now time.Time) {
+	for hash, t := range s.refresh {
+		if now.After(t.ExpiresAt) {
+			delete(s.refresh, hash)
+		}
+	}
+	for jti, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, jti)
+		}
+	}
+}
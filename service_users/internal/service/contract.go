@@ -1,13 +1,52 @@
 package service
 
-import "service_users/internal/model"
+import (
+	"common/events"
+	"context"
+	"service_users/internal/model"
+	"time"
+)
 
 type UserRepository interface {
 	GetByID(id int) (*model.User, error)
 	GetAll() ([]model.User, error)
-	Create(req *model.CreateUserRequest) (int, error)
-	Update(req *model.UpdateUserRequest) error
-	Delete(id int) error 
+	// List возвращает не больше q.Limit пользователей после q.After и общее
+	// число пользователей, подходящих под фильтр (без учёта курсора).
+	List(q model.UserListQuery) ([]model.User, int, error)
+	// События пишутся атомарно с изменением. У событий Create без AggregateID
+	// репозиторий проставляет id созданного пользователя.
+	Create(req *model.CreateUserRequest, evs ...events.Event) (int, error)
+	Update(req *model.UpdateUserRequest, evs ...events.Event) error
+	Delete(id int, evs ...events.Event) error 
 
 	GetByEmail(email string) (*model.User, error)
-}
\ No newline at end of file
+
+	events.Outbox
+}
+
+// OrdersClient - service_orders. Заказы удаляемого пользователя отменяются
+// или обезличиваются по политике service_orders.
+type OrdersClient interface {
+	// CheckUserDeletion ничего не меняет; ErrUserHasActiveOrders - удаление
+	// заблокировано.
+	CheckUserDeletion(ctx context.Context, userID int) error
+	// ApplyUserDeletion применяет политику к заказам уже удалённого
+	// пользователя. Повторный вызов безопасен.
+	ApplyUserDeletion(ctx context.Context, userID int) error
+}
+
+type TokenStore interface {
+	SaveRefreshToken(token model.RefreshToken) error
+	GetRefreshToken(hash string) (*model.RefreshToken, error)
+	// UseRefreshToken атомарно помечает токен использованным и возвращает
+	// его состояние до пометки - так повторное использование видно сразу.
+	UseRefreshToken(hash string) (*model.RefreshToken, error)
+	// RevokeFamily отзывает все refresh-токены семейства и выданные с ними access-токены.
+	RevokeFamily(familyID string) error
+	RevokeUserTokens(userID int) error
+
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	// RevokedAccessTokens - отозванные access-токены, которые ещё не истекли.
+	RevokedAccessTokens() ([]model.RevokedToken, error)
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"service_users/internal/model"
+	"sync"
+	"time"
+)
+
+// signingKey - пара Ed25519. Храним только seed, приватный ключ из него
+// восстанавливается детерминированно.
+type signingKey struct {
+	ID         string     `json:"kid"`
+	Seed       []byte     `json:"seed"`
+	CreatedAt  time.Time  `json:"createdAt"`           // с этого момента ключ в JWKS
+	ActiveFrom time.Time  `json:"activeFrom"`          // с этого момента ключ подписывает
+	RetiredAt  *time.Time `json:"retiredAt,omitempty"` // когда подписывать начал следующий; nil у последнего
+
+	private ed25519.PrivateKey
+}
+
+// KeyManager выдаёт ключ для подписи access-токенов и публичные ключи для проверки.
+// Новый ключ сначала publish времени только публикуется в JWKS, чтобы gateway
+// успел его закэшировать, и лишь потом начинает подписывать. Старый ключ
+// после этого ещё overlap времени остаётся в JWKS, чтобы уже выданные токены
+// продолжали проходить проверку.
+type KeyManager struct {
+	mu      sync.RWMutex
+	keys    []*signingKey // по ActiveFrom; последний - текущий или следующий
+	overlap time.Duration
+	publish time.Duration
+	path    string // "" - ключи живут только в памяти
+}
+
+// NewKeyManager поднимает ключи из path (если файл есть) или генерирует новый.
+// Первый ключ подписывает сразу: ждать публикации некому.
+func NewKeyManager(path string, overlap, publish time.Duration) (*KeyManager, error) {
+	m := &KeyManager{overlap: overlap, publish: publish, path: path}
+
+	if path != "" {
+		if err := m.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.currentLocked(time.Now()) == nil {
+		if _, err := m.rotate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Rotate публикует новый ключ; подписывать он начнёт через publish, тогда
+// же текущий ключ выйдет из подписи.
+func (m *KeyManager) Rotate() (string, error) {
+	return m.rotate(m.publish)
+}
+
+func (m *KeyManager) rotate(publish time.Duration) (string, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	kid, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// новый набор собирается в копии: если записать его не удалось, в
+	// памяти остаются те же ключи, что и на диске
+	now := time.Now()
+	activeFrom := now.Add(publish)
+	keys := make([]*signingKey, 0, len(m.keys)+1)
+	for i, k := range m.keys {
+		if i == len(m.keys)-1 && k.RetiredAt == nil {
+			retired := *k
+			retired.RetiredAt = &activeFrom
+			k = &retired
+		}
+		if m.verifiableLocked(k, now) {
+			keys = append(keys, k)
+		}
+	}
+	keys = append(keys, &signingKey{
+		ID:         kid,
+		Seed:       seed,
+		CreatedAt:  now,
+		ActiveFrom: activeFrom,
+		private:    ed25519.NewKeyFromSeed(seed),
+	})
+
+	if err := m.save(keys); err != nil {
+		return "", err
+	}
+	m.keys = keys
+
+	return kid, nil
+}
+
+// RotateEvery меняет ключ подписи, как только текущему исполняется interval:
+// следующий ключ публикуется на publish раньше. Возраст считается от
+// ActiveFrom последнего ключа, так что рестарт сервиса график не сбивает.
+// interval должен быть больше publish.
+func (m *KeyManager) RotateEvery(ctx context.Context, interval time.Duration) {
+	for {
+		m.mu.RLock()
+		wait := time.Until(m.keys[len(m.keys)-1].ActiveFrom.Add(interval - m.publish))
+		m.mu.RUnlock()
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		kid, err := m.Rotate()
+		if err != nil {
+			slog.ErrorContext(ctx, "signing key rotation failed", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		slog.InfoContext(ctx, "signing key published", "kid", kid, "activeIn", m.publish)
+	}
+}
+
+// JWKS возвращает все ключи, которыми ещё можно проверять токены, и
+// следующий ключ, если он уже опубликован.
+func (m *KeyManager) JWKS() model.JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	res := model.JWKS{Keys: make([]model.JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		if !m.verifiableLocked(k, now) {
+			continue
+		}
+		res.Keys = append(res.Keys, model.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey)),
+			Kid: k.ID,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+	return res
+}
+
+func (m *KeyManager) signingKey() (string, ed25519.PrivateKey) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cur := m.currentLocked(time.Now())
+	return cur.ID, cur.private
+}
+
+func (m *KeyManager) publicKey(kid string) (ed25519.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.ID == kid && m.verifiableLocked(k, now) {
+			return k.private.Public().(ed25519.PublicKey), nil
+		}
+	}
+	return nil, model.ErrUnknownSigningKey
+}
+
+// currentLocked - последний ключ, который уже подписывает; nil, если такого нет.
+func (m *KeyManager) currentLocked(now time.Time) *signingKey {
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		k := m.keys[i]
+		if k.ActiveFrom.After(now) {
+			continue
+		}
+		if k.RetiredAt != nil && !now.Before(*k.RetiredAt) {
+			return nil
+		}
+		return k
+	}
+	return nil
+}
+
+func (m *KeyManager) verifiableLocked(k *signingKey, now time.Time) bool {
+	return k.RetiredAt == nil || now.Before(k.RetiredAt.Add(m.overlap))
+}
+
+func (m *KeyManager) load() error {
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var keys []*signingKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("decode signing keys: %w", err)
+	}
+	for _, k := range keys {
+		if len(k.Seed) != ed25519.SeedSize {
+			return fmt.Errorf("signing key %s: invalid seed", k.ID)
+		}
+		k.private = ed25519.NewKeyFromSeed(k.Seed)
+		// файлы до появления activeFrom: ключ подписывал с момента создания
+		if k.ActiveFrom.IsZero() {
+			k.ActiveFrom = k.CreatedAt
+		}
+	}
+
+	now := time.Now()
+	for _, k := range keys {
+		if m.verifiableLocked(k, now) {
+			m.keys = append(m.keys, k)
+		}
+	}
+	return nil
+}
+
+// save пишет ключи через временный файл, чтобы не оставить полузаписанный
+// JSON, и дожидается записи на диск: ключ, которым уже подписаны токены, не
+// должен пропасть при сбое питания.
+func (m *KeyManager) save(keys []*signingKey) error {
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(m.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create signing keys: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write signing keys: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync signing keys: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("rename signing keys: %w", err)
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
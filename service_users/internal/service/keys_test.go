@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"service_users/internal/model"
+	"service_users/internal/repository"
+	"service_users/internal/service"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeys(t *testing.T) *service.KeyManager {
+	t.Helper()
+
+	keys, err := service.NewKeyManager("", time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	return keys
+}
+
+func TestKeyManager_RotationKeepsOldTokensValidDuringOverlap(t *testing.T) {
+	keys := newTestKeys(t)
+	svc := service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), keys)
+
+	before := loginTestUser(t, svc, "rotate@example.com")
+
+	if _, err := keys.Rotate(); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Fatalf("expected old and new key in JWKS, got %d", n)
+	}
+
+	if _, err := svc.ParseToken(before.AccessToken); err != nil {
+		t.Fatalf("expected token signed by retired key to be valid within overlap, got: %v", err)
+	}
+
+	after, err := svc.Refresh(context.Background(), before.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if _, err := svc.ParseToken(after.AccessToken); err != nil {
+		t.Fatalf("expected token signed by new key to be valid, got: %v", err)
+	}
+}
+
+func TestKeyManager_RetiredKeyExpiresAfterOverlap(t *testing.T) {
+	keys, err := service.NewKeyManager("", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	svc := service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), keys)
+
+	tokens := loginTestUser(t, svc, "expired-key@example.com")
+
+	if _, err := keys.Rotate(); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 1 {
+		t.Fatalf("expected only the new key in JWKS, got %d", n)
+	}
+	if _, err := svc.ParseToken(tokens.AccessToken); err == nil {
+		t.Fatalf("expected token signed by dropped key to be rejected")
+	}
+}
+
+func TestKeyManager_PersistsKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing_keys.json")
+
+	keys, err := service.NewKeyManager(path, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	svc := service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), keys)
+	tokens := loginTestUser(t, svc, "persist@example.com")
+
+	reopened, err := service.NewKeyManager(path, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen key manager: %v", err)
+	}
+
+	got := reopened.JWKS().Keys
+	want := keys.JWKS().Keys
+	if len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("expected same key after reopen, got %+v want %+v", got, want)
+	}
+
+	svc = service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), reopened)
+	if _, err := svc.ParseToken(tokens.AccessToken); err != nil {
+		t.Fatalf("expected token to survive restart, got: %v", err)
+	}
+}
+
+func TestKeyManager_JWKSFormat(t *testing.T) {
+	jwks := newTestKeys(t).JWKS()
+
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(jwks.Keys))
+	}
+	k := jwks.Keys[0]
+	want := model.JWK{Kty: "OKP", Crv: "Ed25519", Alg: "EdDSA", Use: "sig", Kid: k.Kid, X: k.X}
+	if k != want || k.Kid == "" || len(k.X) != 43 {
+		t.Errorf("unexpected JWK: %+v", k)
+	}
+}
+
+// tokenKid достаёт kid из заголовка JWT.
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+
+	header, _, _ := strings.Cut(token, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("failed to decode token header: %v", err)
+	}
+	var h struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(raw, &h); err != nil {
+		t.Fatalf("failed to parse token header: %v", err)
+	}
+	return h.Kid
+}
+
+func TestKeyManager_NewKeySignsOnlyAfterPublish(t *testing.T) {
+	keys, err := service.NewKeyManager("", time.Hour, 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	svc := service.NewUserService(repository.NewUserRepository(), stubOrdersClient{}, repository.NewTokenStore(), keys)
+	oldKid := keys.JWKS().Keys[0].Kid
+	// логин медленный (bcrypt), дальше токены выдаёт быстрый Refresh
+	tokens := loginTestUser(t, svc, "publish@example.com")
+
+	newKid, err := keys.Rotate()
+	if err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Fatalf("expected next key published next to the current one, got %d keys", n)
+	}
+
+	// пока gateway мог не увидеть новый ключ, подписывает старый
+	tokens, err = svc.Refresh(context.Background(), tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if kid := tokenKid(t, tokens.AccessToken); kid != oldKid {
+		t.Fatalf("expected token signed by current key %s, got: %s", oldKid, kid)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	tokens, err = svc.Refresh(context.Background(), tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if kid := tokenKid(t, tokens.AccessToken); kid != newKid {
+		t.Fatalf("expected token signed by new key %s after publish, got: %s", newKid, kid)
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Fatalf("expected retired key to stay in JWKS during overlap, got %d keys", n)
+	}
+}
+
+func TestKeyManager_FailedSaveKeepsKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing_keys.json")
+
+	keys, err := service.NewKeyManager(path, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	before := keys.JWKS()
+
+	// временный файл не создать - запись падает
+	if err := os.MkdirAll(filepath.Join(path+".tmp", "busy"), 0o700); err != nil {
+		t.Fatalf("failed to block temp file: %v", err)
+	}
+	if _, err := keys.Rotate(); err == nil {
+		t.Fatalf("expected rotate to fail when keys cannot be saved")
+	}
+
+	if got := keys.JWKS(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("expected keys in memory unchanged after failed save, got %+v want %+v", got, before)
+	}
+	reopened, err := service.NewKeyManager(path, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen key manager: %v", err)
+	}
+	if got := reopened.JWKS(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("expected keys on disk unchanged after failed save, got %+v want %+v", got, before)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"common/events"
+	"common/tracing"
+	"context"
+	"service_users/internal/model"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tracedRepository - репозиторий со спаном на каждую операцию сервиса.
+// Спаны - дочерние к ctx запроса; outbox relay ходит в репозиторий напрямую.
+type tracedRepository struct {
+	ctx context.Context
+	UserRepository
+}
+
+func (s *UserService) store(ctx context.Context) tracedRepository {
+	return tracedRepository{ctx: ctx, UserRepository: s.repository}
+}
+
+func (r tracedRepository) span(op string) func(error) {
+	_, span := tracing.Start(r.ctx, "users.repository."+op)
+	return func(err error) { tracing.End(span, err) }
+}
+
+func (r tracedRepository) GetByID(id int) (*model.User, error) {
+	end := r.span("GetByID")
+	user, err := r.UserRepository.GetByID(id)
+	end(err)
+	return user, err
+}
+
+func (r tracedRepository) GetByEmail(email string) (*model.User, error) {
+	end := r.span("GetByEmail")
+	user, err := r.UserRepository.GetByEmail(email)
+	end(err)
+	return user, err
+}
+
+func (r tracedRepository) GetAll() ([]model.User, error) {
+	end := r.span("GetAll")
+	users, err := r.UserRepository.GetAll()
+	end(err)
+	return users, err
+}
+
+func (r tracedRepository) List(q model.UserListQuery) ([]model.User, int, error) {
+	end := r.span("List")
+	users, total, err := r.UserRepository.List(q)
+	end(err)
+	return users, total, err
+}
+
+func (r tracedRepository) Create(req *model.CreateUserRequest, evs ...events.Event) (int, error) {
+	end := r.span("Create")
+	id, err := r.UserRepository.Create(req, evs...)
+	end(err)
+	return id, err
+}
+
+func (r tracedRepository) Update(req *model.UpdateUserRequest, evs ...events.Event) error {
+	end := r.span("Update")
+	err := r.UserRepository.Update(req, evs...)
+	end(err)
+	return err
+}
+
+func (r tracedRepository) Delete(id int, evs ...events.Event) error {
+	end := r.span("Delete")
+	err := r.UserRepository.Delete(id, evs...)
+	end(err)
+	return err
+}
+
+// hashPassword и checkPassword - bcrypt в своих спанах: это самая долгая
+// часть регистрации и входа.
+func hashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	tracing.End(span, err)
+	return hashed, err
+}
+
+func checkPassword(ctx context.Context, hash, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	// неверный пароль - не сбой
+	span.End()
+	return err
+}
@@ -1,21 +1,31 @@
 package service
 
 import (
+	"common/events"
+	"common/pagination"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"service_users/internal/model"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
 	repository UserRepository
-	jwtSecret  []byte
-	tokenTTL   time.Duration
+	orders     OrdersClient
+	tokens     TokenStore
+	keys       *KeyManager
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
 type userClaims struct {
@@ -26,28 +36,60 @@ type userClaims struct {
 }
 
 type AuthInfo struct {
-	UserID int
-	Email  string
-	Roles  []string
+	UserID    int
+	Email     string
+	Roles     []string
+	TokenID   string // jti access-токена
+	ExpiresAt time.Time
 }
 
-func NewUserService(r UserRepository) *UserService {
+func NewUserService(r UserRepository, orders OrdersClient, tokens TokenStore, keys *KeyManager) *UserService {
 	return &UserService{
 		repository: r,
-		jwtSecret:  []byte("super-secret-key"), // TODO: брать из env
-		tokenTTL:   24 * time.Hour,
+		orders:     orders,
+		tokens:     tokens,
+		keys:       keys,
+		accessTTL:  15 * time.Minute,
+		refreshTTL: 30 * 24 * time.Hour,
 	}
 }
 
-func (s *UserService) GetUser(id int) (*model.User, error) {
-	return s.repository.GetByID(id)
+func (s *UserService) GetUser(ctx context.Context, id int) (*model.User, error) {
+	return s.store(ctx).GetByID(id)
 }
 
-func (s *UserService) GetAllUsers() ([]model.User, error) {
-	return s.repository.GetAll()
+func (s *UserService) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	return s.store(ctx).GetAll()
 }
 
-func (s *UserService) CreateUser(req model.CreateUserRequest) (int, error) {
+// ListUsers отдаёт страницу пользователей. Репозиторий просим на одного больше,
+// чтобы понять, есть ли следующая страница.
+func (s *UserService) ListUsers(ctx context.Context, filter model.UserFilter, page pagination.Request) (*model.UserPage, error) {
+	p, err := pagination.Parse(page, model.UserSortFields, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.store(ctx).List(model.UserListQuery{
+		Filter: filter,
+		Sort:   p.Sort,
+		Limit:  p.Limit + 1,
+		After:  p.After,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.UserPage{Items: users, Total: total}
+	if len(users) > p.Limit {
+		res.Items = users[:p.Limit]
+		res.NextCursor = p.NextCursor(res.Items[p.Limit-1])
+	}
+
+	return res, nil
+}
+
+func (s *UserService) CreateUser(ctx context.Context, req model.CreateUserRequest) (int, error) {
 	if req.Name == "" || req.Email == "" {
 		return 0, model.ErrMissingRequiredFields
 	}
@@ -55,27 +97,68 @@ func (s *UserService) CreateUser(req model.CreateUserRequest) (int, error) {
 		return 0, model.ErrInvalidEmail
 	}
 
-	return s.repository.Create(&req)
+	ev, err := events.New(ctx, events.SourceUsers, "", events.UserCreated{
+		Email: req.Email,
+		Name:  req.Name,
+		Roles: req.Roles,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.store(ctx).Create(&req, ev)
 }
 
-func (s *UserService) UpdateUser(req model.UpdateUserRequest) error {
+func (s *UserService) UpdateUser(ctx context.Context, req model.UpdateUserRequest) error {
 	if req.Name == "" {
 		return model.ErrMissingRequiredFields
 	}
 
-	_, err := s.repository.GetByID(req.ID)
+	_, err := s.store(ctx).GetByID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	ev, err := events.New(ctx, events.SourceUsers, strconv.Itoa(req.ID), events.UserUpdated{Name: req.Name})
 	if err != nil {
 		return err
 	}
 
-	return s.repository.Update(&req)
+	return s.store(ctx).Update(&req, ev)
 }
 
-func (s *UserService) DeleteUser(id int) error {
-	return s.repository.Delete(id)
+func (s *UserService) DeleteUser(ctx context.Context, id int) error {
+	user, err := s.store(ctx).GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	// если service_orders против, пользователь остаётся; заказы он поменяет
+	// только по user.deleted, то есть когда удаление уже прошло
+	if err := s.orders.CheckUserDeletion(ctx, id); err != nil {
+		return err
+	}
+
+	ev, err := events.New(ctx, events.SourceUsers, strconv.Itoa(id), events.UserDeleted{Email: user.Email})
+	if err != nil {
+		return err
+	}
+
+	return s.store(ctx).Delete(id, ev)
 }
 
-func (s *UserService) Register(req model.RegisterRequest) (int, error) {
+// HandleUserDeleted - подписчик на user.deleted: отменяет и обезличивает
+// заказы удалённого пользователя. Ошибка оставляет событие в outbox, и relay
+// повторит его позже.
+func (s *UserService) HandleUserDeleted(ctx context.Context, ev events.Event) error {
+	id, err := strconv.Atoi(ev.AggregateID)
+	if err != nil {
+		return fmt.Errorf("user.deleted %s: bad user id %q", ev.ID, ev.AggregateID)
+	}
+	return s.orders.ApplyUserDeletion(ctx, id)
+}
+
+func (s *UserService) Register(ctx context.Context, req model.RegisterRequest) (int, error) {
 	if req.Name == "" || req.Email == "" || req.Password == "" {
 		return 0, model.ErrMissingRequiredFields
 	}
@@ -86,13 +169,13 @@ func (s *UserService) Register(req model.RegisterRequest) (int, error) {
 		return 0, model.ErrInvalidPassword
 	}
 
-	if _, err := s.repository.GetByEmail(req.Email); err == nil {
+	if _, err := s.store(ctx).GetByEmail(req.Email); err == nil {
 		return 0, model.ErrUniqueEmailConflict
 	} else if err != model.ErrUserNotFound {
 		return 0, err
 	}
 
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, err := hashPassword(ctx, req.Password)
 	if err != nil {
 		return 0, err
 	}
@@ -104,80 +187,223 @@ func (s *UserService) Register(req model.RegisterRequest) (int, error) {
 		Roles:        []string{"user"},
 	}
 
-	return s.repository.Create(&createReq)
+	ev, err := events.New(ctx, events.SourceUsers, "", events.UserRegistered{
+		Email: createReq.Email,
+		Name:  createReq.Name,
+		Roles: createReq.Roles,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.store(ctx).Create(&createReq, ev)
 }
 
-func (s *UserService) Login(req model.LoginRequest) (string, error) {
+func (s *UserService) Login(ctx context.Context, req model.LoginRequest) (*model.TokenPair, error) {
 	if req.Email == "" || req.Password == "" {
-		return "", model.ErrMissingRequiredFields
+		return nil, model.ErrMissingRequiredFields
 	}
 
-	user, err := s.repository.GetByEmail(req.Email)
+	user, err := s.store(ctx).GetByEmail(req.Email)
 	if err != nil {
 		if err == model.ErrUserNotFound {
-			return "", model.ErrInvalidCredentials
+			slog.WarnContext(ctx, "login failed", "reason", "unknown email")
+			return nil, model.ErrInvalidCredentials
 		}
-		return "", err
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return "", model.ErrInvalidCredentials
+	if err := checkPassword(ctx, user.PasswordHash, req.Password); err != nil {
+		slog.WarnContext(ctx, "login failed", "reason", "wrong password", "user_id", user.ID)
+		return nil, model.ErrInvalidCredentials
 	}
 
-	now := time.Now()
-	claims := userClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Roles:  user.Roles,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   strconv.Itoa(user.ID),
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(now),
-		},
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(s.jwtSecret)
+	return s.issueTokens(user, familyID)
+}
+
+// Refresh меняет refresh-токен на новую пару (ротация). Повторное предъявление
+// уже использованного токена считается утечкой: всё семейство отзывается.
+func (s *UserService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, model.ErrMissingRequiredFields
+	}
+
+	stored, err := s.tokens.UseRefreshToken(hashToken(refreshToken))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if stored.Revoked {
+		return nil, model.ErrInvalidRefreshToken
+	}
+	if stored.Used {
+		if err := s.tokens.RevokeFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrRefreshTokenReused
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, model.ErrInvalidRefreshToken
 	}
 
-	return signed, nil
+	user, err := s.store(ctx).GetByID(stored.UserID)
+	if err != nil {
+		if err == model.ErrUserNotFound {
+			_ = s.tokens.RevokeFamily(stored.FamilyID)
+			return nil, model.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(user, stored.FamilyID)
+}
+
+// Logout отзывает текущий access-токен и, если передан, семейство refresh-токена.
+func (s *UserService) Logout(info *AuthInfo, refreshToken string) error {
+	if err := s.tokens.RevokeAccessToken(info.TokenID, info.ExpiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.tokens.GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if stored.UserID != info.UserID {
+		return model.ErrInvalidRefreshToken
+	}
+
+	return s.tokens.RevokeFamily(stored.FamilyID)
+}
+
+// LogoutAll завершает все сессии пользователя на всех устройствах.
+func (s *UserService) LogoutAll(info *AuthInfo) error {
+	if err := s.tokens.RevokeAccessToken(info.TokenID, info.ExpiresAt); err != nil {
+		return err
+	}
+
+	return s.tokens.RevokeUserTokens(info.UserID)
+}
+
+// RevokedTokens - отозванные, но ещё не истёкшие access-токены.
+func (s *UserService) RevokedTokens() (*model.RevokedTokens, error) {
+	tokens, err := s.tokens.RevokedAccessTokens()
+	if err != nil {
+		return nil, err
+	}
+	return &model.RevokedTokens{Tokens: tokens}, nil
 }
 
 func (s *UserService) ParseToken(tokenStr string) (*AuthInfo, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &userClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		if _, ok := t.Method.(*jwt.SigningMethodEd25519); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
-		return s.jwtSecret, nil
-	})
+		kid, _ := t.Header["kid"].(string)
+		return s.keys.publicKey(kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
 	if err != nil {
 		return nil, err
 	}
 
 	claims, ok := token.Claims.(*userClaims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.ID == "" || claims.ExpiresAt == nil {
 		return nil, errors.New("invalid token")
 	}
 
+	revoked, err := s.tokens.IsAccessTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, model.ErrTokenRevoked
+	}
+
 	return &AuthInfo{
-		UserID: claims.UserID,
-		Email:  claims.Email,
-		Roles:  claims.Roles,
+		UserID:    claims.UserID,
+		Email:     claims.Email,
+		Roles:     claims.Roles,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func (s *UserService) issueTokens(user *model.User, familyID string) (*model.TokenPair, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessExpiresAt := now.Add(s.accessTTL)
+	claims := userClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Roles:  user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(user.ID),
+			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	kid, key := s.keys.signingKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tokens.SaveRefreshToken(model.RefreshToken{
+		Hash:            hashToken(refreshToken),
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExpiresAt,
+		ExpiresAt:       now.Add(s.refreshTTL),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  signed,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
 	}, nil
 }
 
-func (s *UserService) GetCurrentUser(userID int) (*model.User, error) {
-	return s.repository.GetByID(userID)
+// JWKS отдаёт публичные ключи, по которым другие сервисы проверяют access-токены.
+func (s *UserService) JWKS() model.JWKS {
+	return s.keys.JWKS()
 }
 
-func (s *UserService) UpdateProfile(userID int, req model.UpdateProfileRequest) (*model.User, error) {
+func (s *UserService) GetCurrentUser(ctx context.Context, userID int) (*model.User, error) {
+	return s.store(ctx).GetByID(userID)
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID int, req model.UpdateProfileRequest) (*model.User, error) {
 	if req.Name == "" {
 		return nil, model.ErrMissingRequiredFields
 	}
 
-	_, err := s.repository.GetByID(userID)
+	_, err := s.store(ctx).GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
@@ -187,11 +413,16 @@ func (s *UserService) UpdateProfile(userID int, req model.UpdateProfileRequest)
 		Name: req.Name,
 	}
 
-	if err := s.repository.Update(&updateReq); err != nil {
+	ev, err := events.New(ctx, events.SourceUsers, strconv.Itoa(userID), events.UserUpdated{Name: req.Name})
+	if err != nil {
 		return nil, err
 	}
 
-	return s.repository.GetByID(userID)
+	if err := s.store(ctx).Update(&updateReq, ev); err != nil {
+		return nil, err
+	}
+
+	return s.store(ctx).GetByID(userID)
 }
 
 // Helpers
@@ -199,4 +430,25 @@ func (s *UserService) UpdateProfile(userID int, req model.UpdateProfileRequest)
 func isEmailValid(e string) bool {
     emailRegex := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
     return emailRegex.MatchString(e)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
\ No newline at end of file
@@ -1,20 +1,42 @@
 package service_test
 
 import (
+	"common/events"
+	"common/pagination"
+	"context"
 	"service_users/internal/model"
 	"service_users/internal/repository"
 	"service_users/internal/service"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// stubOrdersClient - service_orders, который разрешает или блокирует удаление.
+type stubOrdersClient struct {
+	err     error
+	applied *[]int // куда записать пользователей из ApplyUserDeletion
+}
+
+func (s stubOrdersClient) CheckUserDeletion(ctx context.Context, userID int) error { return s.err }
+
+func (s stubOrdersClient) ApplyUserDeletion(ctx context.Context, userID int) error {
+	if s.applied != nil {
+		*s.applied = append(*s.applied, userID)
+	}
+	return nil
+}
+
 func TestUserService_GetExistingUser_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	user, err := svc.GetUser(1)
+	user, err := svc.GetUser(context.Background(), 1)
 
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -32,9 +54,9 @@ func TestUserService_GetExistingUser_Success(t *testing.T) {
 
 func TestUserService_GetUserWithNotExistingId_NotFound(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	user, err := svc.GetUser(999)
+	user, err := svc.GetUser(context.Background(), 999)
 
 	if err == nil {
 		t.Fatalf("expected error, got nil")
@@ -49,9 +71,9 @@ func TestUserService_GetUserWithNotExistingId_NotFound(t *testing.T) {
 
 func TestUserService_GetUserWithWrongId_ErrInvalidId(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	user, err := svc.GetUser(999)
+	user, err := svc.GetUser(context.Background(), 999)
 
 	if err == nil {
 		t.Fatalf("expected error, got nil")
@@ -66,9 +88,9 @@ func TestUserService_GetUserWithWrongId_ErrInvalidId(t *testing.T) {
 
 func TestUserService_GetAllUsers_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	_, err := svc.GetAllUsers()
+	_, err := svc.GetAllUsers(context.Background())
 	
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -77,14 +99,14 @@ func TestUserService_GetAllUsers_Success(t *testing.T) {
 
 func TestUserService_CreateUser_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.CreateUserRequest{
 		Name:  "Bob",
 		Email: "bob@example.com",
 	}
 
-	id, err := svc.CreateUser(req)
+	id, err := svc.CreateUser(context.Background(), req)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -93,7 +115,7 @@ func TestUserService_CreateUser_Success(t *testing.T) {
 	}
 
 	// проверим, что юзер реально появился
-	user, err := svc.GetUser(id)
+	user, err := svc.GetUser(context.Background(), id)
 	if err != nil {
 		t.Fatalf("expected user, got error: %v", err)
 	}
@@ -104,7 +126,7 @@ func TestUserService_CreateUser_Success(t *testing.T) {
 
 func TestUserService_CreateUser_MissingFields(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	tt := []struct {
 		name string
@@ -132,7 +154,7 @@ func TestUserService_CreateUser_MissingFields(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			id, err := svc.CreateUser(tc.req)
+			id, err := svc.CreateUser(context.Background(), tc.req)
 			if err != model.ErrMissingRequiredFields {
 				t.Fatalf("expected ErrMissingRequiredFields, got: %v", err)
 			}
@@ -145,14 +167,14 @@ func TestUserService_CreateUser_MissingFields(t *testing.T) {
 
 func TestUserService_CreateUser_InvalidEmail(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.CreateUserRequest{
 		Name:  "Test",
 		Email: "invalid-email",
 	}
 
-	id, err := svc.CreateUser(req)
+	id, err := svc.CreateUser(context.Background(), req)
 	if err != model.ErrInvalidEmail {
 		t.Fatalf("expected ErrInvalidEmail, got: %v", err)
 	}
@@ -163,7 +185,7 @@ func TestUserService_CreateUser_InvalidEmail(t *testing.T) {
 
 func TestUserService_CreateUser_EmailConflict(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	// в репозитории уже есть alice@example.com
 	req := model.CreateUserRequest{
@@ -171,7 +193,7 @@ func TestUserService_CreateUser_EmailConflict(t *testing.T) {
 		Email: "alice@example.com",
 	}
 
-	id, err := svc.CreateUser(req)
+	id, err := svc.CreateUser(context.Background(), req)
 	if err != model.ErrUniqueEmailConflict {
 		t.Fatalf("expected ErrUniqueEmailConflict, got: %v", err)
 	}
@@ -182,18 +204,18 @@ func TestUserService_CreateUser_EmailConflict(t *testing.T) {
 
 func TestUserService_UpdateUser_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.UpdateUserRequest{
 		ID:   1,
 		Name: "New Alice",
 	}
 
-	if err := svc.UpdateUser(req); err != nil {
+	if err := svc.UpdateUser(context.Background(), req); err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	user, err := svc.GetUser(1)
+	user, err := svc.GetUser(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("GetUser error: %v", err)
 	}
@@ -204,14 +226,14 @@ func TestUserService_UpdateUser_Success(t *testing.T) {
 
 func TestUserService_UpdateUser_MissingName(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.UpdateUserRequest{
 		ID:   1,
 		Name: "",
 	}
 
-	err := svc.UpdateUser(req)
+	err := svc.UpdateUser(context.Background(), req)
 	if err != model.ErrMissingRequiredFields {
 		t.Fatalf("expected ErrMissingRequiredFields, got: %v", err)
 	}
@@ -219,14 +241,14 @@ func TestUserService_UpdateUser_MissingName(t *testing.T) {
 
 func TestUserService_UpdateUser_NotFound(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.UpdateUserRequest{
 		ID:   999,
 		Name: "Ghost",
 	}
 
-	err := svc.UpdateUser(req)
+	err := svc.UpdateUser(context.Background(), req)
 	if err != model.ErrUserNotFound {
 		t.Fatalf("expected ErrUserNotFound, got: %v", err)
 	}
@@ -234,26 +256,26 @@ func TestUserService_UpdateUser_NotFound(t *testing.T) {
 
 func TestUserService_DeleteUser(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	// сначала убеждаемся, что юзер есть
-	if _, err := svc.GetUser(1); err != nil {
+	if _, err := svc.GetUser(context.Background(), 1); err != nil {
 		t.Fatalf("expected user 1 to exist, got error: %v", err)
 	}
 
-	if err := svc.DeleteUser(1); err != nil {
+	if err := svc.DeleteUser(context.Background(), 1); err != nil {
 		t.Fatalf("expected no error on delete, got: %v", err)
 	}
 
 	// теперь должен быть not found
-	if _, err := svc.GetUser(1); err != model.ErrUserNotFound {
+	if _, err := svc.GetUser(context.Background(), 1); err != model.ErrUserNotFound {
 		t.Fatalf("expected ErrUserNotFound after delete, got: %v", err)
 	}
 }
 
 func TestUserService_Register_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.RegisterRequest{
 		Email:    "reguser@example.com",
@@ -261,7 +283,7 @@ func TestUserService_Register_Success(t *testing.T) {
 		Password: "secret123",
 	}
 
-	id, err := svc.Register(req)
+	id, err := svc.Register(context.Background(), req)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -269,7 +291,7 @@ func TestUserService_Register_Success(t *testing.T) {
 		t.Fatalf("expected non-zero id, got 0")
 	}
 
-	u, err := svc.GetUser(id)
+	u, err := svc.GetUser(context.Background(), id)
 	if err != nil {
 		t.Fatalf("expected user after register, got error: %v", err)
 	}
@@ -293,7 +315,7 @@ func TestUserService_Register_Success(t *testing.T) {
 
 func TestUserService_Register_MissingFields(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	tests := []struct {
 		name string
@@ -328,7 +350,7 @@ func TestUserService_Register_MissingFields(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			id, err := svc.Register(tc.req)
+			id, err := svc.Register(context.Background(), tc.req)
 			if err != model.ErrMissingRequiredFields {
 				t.Fatalf("expected ErrMissingRequiredFields, got: %v", err)
 			}
@@ -341,7 +363,7 @@ func TestUserService_Register_MissingFields(t *testing.T) {
 
 func TestUserService_Register_InvalidEmail(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.RegisterRequest{
 		Name:     "Test",
@@ -349,7 +371,7 @@ func TestUserService_Register_InvalidEmail(t *testing.T) {
 		Password: "secret123",
 	}
 
-	id, err := svc.Register(req)
+	id, err := svc.Register(context.Background(), req)
 	if err != model.ErrInvalidEmail {
 		t.Fatalf("expected ErrInvalidEmail, got: %v", err)
 	}
@@ -360,7 +382,7 @@ func TestUserService_Register_InvalidEmail(t *testing.T) {
 
 func TestUserService_Register_ShortPassword(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.RegisterRequest{
 		Name:     "Test",
@@ -368,7 +390,7 @@ func TestUserService_Register_ShortPassword(t *testing.T) {
 		Password: "123", // меньше 6 символов
 	}
 
-	id, err := svc.Register(req)
+	id, err := svc.Register(context.Background(), req)
 	if err != model.ErrInvalidPassword {
 		t.Fatalf("expected ErrInvalidPassword, got: %v", err)
 	}
@@ -379,7 +401,7 @@ func TestUserService_Register_ShortPassword(t *testing.T) {
 
 func TestUserService_Register_EmailConflict(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	// сначала регистрируем нового пользователя
 	first := model.RegisterRequest{
@@ -387,7 +409,7 @@ func TestUserService_Register_EmailConflict(t *testing.T) {
 		Email:    "same@example.com",
 		Password: "secret123",
 	}
-	_, err := svc.Register(first)
+	_, err := svc.Register(context.Background(), first)
 	if err != nil {
 		t.Fatalf("unexpected error on first register: %v", err)
 	}
@@ -398,7 +420,7 @@ func TestUserService_Register_EmailConflict(t *testing.T) {
 		Email:    "same@example.com",
 		Password: "anotherpass",
 	}
-	id, err := svc.Register(second)
+	id, err := svc.Register(context.Background(), second)
 	if err != model.ErrUniqueEmailConflict {
 		t.Fatalf("expected ErrUniqueEmailConflict, got: %v", err)
 	}
@@ -409,7 +431,7 @@ func TestUserService_Register_EmailConflict(t *testing.T) {
 
 func TestUserService_Login_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	regReq := model.RegisterRequest{
 		Email:    "login@example.com",
@@ -417,7 +439,7 @@ func TestUserService_Login_Success(t *testing.T) {
 		Password: "secret123",
 	}
 
-	id, err := svc.Register(regReq)
+	id, err := svc.Register(context.Background(), regReq)
 	if err != nil {
 		t.Fatalf("unexpected error on register: %v", err)
 	}
@@ -431,16 +453,16 @@ func TestUserService_Login_Success(t *testing.T) {
 		Password: "secret123",
 	}
 
-	token, err := svc.Login(loginReq)
+	tokens, err := svc.Login(context.Background(), loginReq)
 	if err != nil {
 		t.Fatalf("expected no error on login, got: %v", err)
 	}
-	if token == "" {
-		t.Fatalf("expected non-empty token")
+	if tokens == nil || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("expected non-empty tokens, got: %+v", tokens)
 	}
 
 	// проверяем, что токен нормально парсится
-	info, err := svc.ParseToken(token)
+	info, err := svc.ParseToken(tokens.AccessToken)
 	if err != nil {
 		t.Fatalf("expected token to be parsed, got error: %v", err)
 	}
@@ -457,7 +479,7 @@ func TestUserService_Login_Success(t *testing.T) {
 
 func TestUserService_Login_InvalidPassword(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	regReq := model.RegisterRequest{
 		Email:    "login2@example.com",
@@ -465,7 +487,7 @@ func TestUserService_Login_InvalidPassword(t *testing.T) {
 		Password: "secret123",
 	}
 
-	_, err := svc.Register(regReq)
+	_, err := svc.Register(context.Background(), regReq)
 	if err != nil {
 		t.Fatalf("unexpected error on register: %v", err)
 	}
@@ -475,36 +497,36 @@ func TestUserService_Login_InvalidPassword(t *testing.T) {
 		Password: "wrong-password",
 	}
 
-	token, err := svc.Login(badLogin)
+	token, err := svc.Login(context.Background(), badLogin)
 	if err != model.ErrInvalidCredentials {
 		t.Fatalf("expected ErrInvalidCredentials, got: %v", err)
 	}
-	if token != "" {
-		t.Fatalf("expected empty token on error, got: %s", token)
+	if token != nil {
+		t.Fatalf("expected no tokens on error, got: %+v", token)
 	}
 }
 
 func TestUserService_Login_UserNotFound(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.LoginRequest{
 		Email:    "no_such_user@example.com",
 		Password: "secret123",
 	}
 
-	token, err := svc.Login(req)
+	token, err := svc.Login(context.Background(), req)
 	if err != model.ErrInvalidCredentials {
 		t.Fatalf("expected ErrInvalidCredentials, got: %v", err)
 	}
-	if token != "" {
-		t.Fatalf("expected empty token on error, got: %s", token)
+	if token != nil {
+		t.Fatalf("expected no tokens on error, got: %+v", token)
 	}
 }
 
 func TestUserService_Login_MissingFields(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	tests := []struct {
 		name string
@@ -532,12 +554,12 @@ func TestUserService_Login_MissingFields(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			token, err := svc.Login(tc.req)
+			token, err := svc.Login(context.Background(), tc.req)
 			if err != model.ErrMissingRequiredFields {
 				t.Fatalf("expected ErrMissingRequiredFields, got: %v", err)
 			}
-			if token != "" {
-				t.Fatalf("expected empty token on error, got: %s", token)
+			if token != nil {
+				t.Fatalf("expected no tokens on error, got: %+v", token)
 			}
 		})
 	}
@@ -545,7 +567,7 @@ func TestUserService_Login_MissingFields(t *testing.T) {
 
 func TestUserService_ParseToken_InvalidSignature(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	// создаём токен с другим секретом
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -568,9 +590,9 @@ func TestUserService_ParseToken_InvalidSignature(t *testing.T) {
 
 func TestUserService_GetCurrentUser_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	u, err := svc.GetCurrentUser(1)
+	u, err := svc.GetCurrentUser(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -584,9 +606,9 @@ func TestUserService_GetCurrentUser_Success(t *testing.T) {
 
 func TestUserService_UpdateProfile_Success(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
-	if _, err := svc.GetUser(1); err != nil {
+	if _, err := svc.GetUser(context.Background(), 1); err != nil {
 		t.Fatalf("expected user 1 to exist, got error: %v", err)
 	}
 
@@ -594,7 +616,7 @@ func TestUserService_UpdateProfile_Success(t *testing.T) {
 		Name: "Updated Name",
 	}
 
-	u, err := svc.UpdateProfile(1, req)
+	u, err := svc.UpdateProfile(context.Background(), 1, req)
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -605,13 +627,13 @@ func TestUserService_UpdateProfile_Success(t *testing.T) {
 
 func TestUserService_UpdateProfile_MissingName(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.UpdateProfileRequest{
 		Name: "",
 	}
 
-	u, err := svc.UpdateProfile(1, req)
+	u, err := svc.UpdateProfile(context.Background(), 1, req)
 	if err != model.ErrMissingRequiredFields {
 		t.Fatalf("expected ErrMissingRequiredFields, got: %v", err)
 	}
@@ -622,17 +644,321 @@ func TestUserService_UpdateProfile_MissingName(t *testing.T) {
 
 func TestUserService_UpdateProfile_UserNotFound(t *testing.T) {
 	repo := repository.NewUserRepository()
-	svc := service.NewUserService(repo)
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
 
 	req := model.UpdateProfileRequest{
 		Name: "Ghost",
 	}
 
-	u, err := svc.UpdateProfile(999, req)
+	u, err := svc.UpdateProfile(context.Background(), 999, req)
 	if err != model.ErrUserNotFound {
 		t.Fatalf("expected ErrUserNotFound, got: %v", err)
 	}
 	if u != nil {
 		t.Fatalf("expected nil user on error, got: %+v", u)
 	}
-}
\ No newline at end of file
+}
+func loginTestUser(t *testing.T, svc *service.UserService, email string) *model.TokenPair {
+	t.Helper()
+
+	if _, err := svc.Register(context.Background(), model.RegisterRequest{Email: email, Name: "Session User", Password: "secret123"}); err != nil {
+		t.Fatalf("unexpected error on register: %v", err)
+	}
+	tokens, err := svc.Login(context.Background(), model.LoginRequest{Email: email, Password: "secret123"})
+	if err != nil {
+		t.Fatalf("unexpected error on login: %v", err)
+	}
+	return tokens
+}
+
+func TestUserService_Refresh_RotatesTokens(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	first := loginTestUser(t, svc, "refresh@example.com")
+
+	second, err := svc.Refresh(context.Background(), first.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected no error on refresh, got: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatalf("expected new token pair after refresh")
+	}
+	if _, err := svc.ParseToken(second.AccessToken); err != nil {
+		t.Fatalf("expected refreshed access token to be valid, got: %v", err)
+	}
+
+	if _, err := svc.Refresh(context.Background(), second.RefreshToken); err != nil {
+		t.Fatalf("expected rotated refresh token to work, got: %v", err)
+	}
+}
+
+func TestUserService_Refresh_ReuseRevokesFamily(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	first := loginTestUser(t, svc, "reuse@example.com")
+	second, err := svc.Refresh(context.Background(), first.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected error on refresh: %v", err)
+	}
+
+	// повторно предъявляем уже использованный токен
+	if _, err := svc.Refresh(context.Background(), first.RefreshToken); err != model.ErrRefreshTokenReused {
+		t.Fatalf("expected ErrRefreshTokenReused, got: %v", err)
+	}
+
+	if _, err := svc.Refresh(context.Background(), second.RefreshToken); err != model.ErrInvalidRefreshToken {
+		t.Fatalf("expected whole family to be revoked, got: %v", err)
+	}
+	if _, err := svc.ParseToken(second.AccessToken); err != model.ErrTokenRevoked {
+		t.Fatalf("expected access token of the family to be revoked, got: %v", err)
+	}
+}
+
+func TestUserService_Refresh_UnknownToken(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	if _, err := svc.Refresh(context.Background(), "no-such-token"); err != model.ErrInvalidRefreshToken {
+		t.Fatalf("expected ErrInvalidRefreshToken, got: %v", err)
+	}
+}
+
+func TestUserService_Logout_RevokesSession(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	tokens := loginTestUser(t, svc, "logout@example.com")
+	info, err := svc.ParseToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	if err := svc.Logout(info, tokens.RefreshToken); err != nil {
+		t.Fatalf("expected no error on logout, got: %v", err)
+	}
+
+	if _, err := svc.ParseToken(tokens.AccessToken); err != model.ErrTokenRevoked {
+		t.Fatalf("expected ErrTokenRevoked, got: %v", err)
+	}
+	if _, err := svc.Refresh(context.Background(), tokens.RefreshToken); err != model.ErrInvalidRefreshToken {
+		t.Fatalf("expected refresh token to be revoked, got: %v", err)
+	}
+
+	// отозванный jti уходит в список для gateway
+	revoked, err := svc.RevokedTokens()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(revoked.Tokens) != 1 || revoked.Tokens[0].JTI != info.TokenID {
+		t.Fatalf("expected revoked jti %s, got: %+v", info.TokenID, revoked.Tokens)
+	}
+}
+
+func TestUserService_LogoutAll_RevokesEverySession(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	phone := loginTestUser(t, svc, "everywhere@example.com")
+	laptop, err := svc.Login(context.Background(), model.LoginRequest{Email: "everywhere@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("unexpected error on second login: %v", err)
+	}
+
+	info, err := svc.ParseToken(phone.AccessToken)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if err := svc.LogoutAll(info); err != nil {
+		t.Fatalf("expected no error on logout-all, got: %v", err)
+	}
+
+	if _, err := svc.ParseToken(laptop.AccessToken); err != model.ErrTokenRevoked {
+		t.Fatalf("expected other session access token to be revoked, got: %v", err)
+	}
+	if _, err := svc.Refresh(context.Background(), laptop.RefreshToken); err != model.ErrInvalidRefreshToken {
+		t.Fatalf("expected other session refresh token to be revoked, got: %v", err)
+	}
+}
+
+func TestUserService_ListUsers_WalksAllPages(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	for _, name := range []string{"Bob", "Carol", "Dave", "Alice"} {
+		if _, err := svc.CreateUser(context.Background(), model.CreateUserRequest{Name: name, Email: strings.ToLower(name) + "2@example.com"}); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	var names []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("pagination does not terminate")
+		}
+		page, err := svc.ListUsers(context.Background(), model.UserFilter{}, pagination.Request{Limit: 2, Sort: "-name", Cursor: cursor})
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		if page.Total != 7 {
+			t.Fatalf("expected total 7, got %d", page.Total)
+		}
+		for _, u := range page.Items {
+			names = append(names, u.Name)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+
+	// два Alice различаются id, который служит tie-breaker'ом
+	want := []string{"John", "Dave", "Carol", "Bob", "Andrew", "Alice", "Alice"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+}
+
+func TestUserService_ListUsers_Filters(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	tests := []struct {
+		name   string
+		filter model.UserFilter
+		want   int
+	}{
+		{name: "by role", filter: model.UserFilter{Role: "admin"}, want: 1},
+		{name: "by email prefix", filter: model.UserFilter{EmailPrefix: "JO"}, want: 1},
+		{name: "by name substring", filter: model.UserFilter{NameContains: "r"}, want: 1},
+		{name: "no match", filter: model.UserFilter{Role: "admin", NameContains: "alice"}, want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := svc.ListUsers(context.Background(), tc.filter, pagination.Request{})
+			if err != nil {
+				t.Fatalf("list failed: %v", err)
+			}
+			if page.Total != tc.want || len(page.Items) != tc.want {
+				t.Fatalf("expected %d users, got total %d, items %d", tc.want, page.Total, len(page.Items))
+			}
+		})
+	}
+}
+
+func TestUserService_ListUsers_InvalidParams(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	first, err := svc.ListUsers(context.Background(), model.UserFilter{}, pagination.Request{Limit: 1, Sort: "name"})
+	if err != nil || first.NextCursor == nil {
+		t.Fatalf("expected first page with cursor, got %+v (%v)", first, err)
+	}
+
+	tests := []struct {
+		name string
+		req  pagination.Request
+		want error
+	}{
+		{name: "limit too big", req: pagination.Request{Limit: 1000}, want: model.ErrInvalidLimit},
+		{name: "unknown sort field", req: pagination.Request{Sort: "password"}, want: model.ErrInvalidSort},
+		{name: "garbage cursor", req: pagination.Request{Cursor: "%%%"}, want: model.ErrInvalidCursor},
+		{name: "cursor from other sort", req: pagination.Request{Sort: "-name", Cursor: *first.NextCursor}, want: model.ErrInvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := svc.ListUsers(context.Background(), model.UserFilter{}, tc.req); err != tc.want {
+				t.Fatalf("expected %v, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestUserService_WritesEventsToOutbox(t *testing.T) {
+	repo := repository.NewUserRepository()
+	svc := service.NewUserService(repo, stubOrdersClient{}, repository.NewTokenStore(), newTestKeys(t))
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-42")
+
+	id, err := svc.Register(ctx, model.RegisterRequest{Email: "events@example.com", Name: "Events", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, id); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// неудачная операция событий не оставляет
+	if err := svc.DeleteUser(ctx, 999); err != model.ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got: %v", err)
+	}
+
+	pending, err := repo.PendingEvents(0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 events, got: %d", len(pending))
+	}
+
+	registered, deleted := pending[0], pending[1]
+	if registered.Type != events.TypeUserRegistered || deleted.Type != events.TypeUserDeleted {
+		t.Fatalf("unexpected event types: %s, %s", registered.Type, deleted.Type)
+	}
+	if registered.AggregateID != strconv.Itoa(id) || deleted.AggregateID != strconv.Itoa(id) {
+		t.Fatalf("expected aggregate id %d, got: %s, %s", id, registered.AggregateID, deleted.AggregateID)
+	}
+	if registered.RequestID != "req-42" {
+		t.Fatalf("expected request id to be propagated, got: %q", registered.RequestID)
+	}
+
+	var payload events.UserDeleted
+	if err := deleted.Decode(&payload); err != nil || payload.Email != "events@example.com" {
+		t.Fatalf("unexpected payload: %+v, %v", payload, err)
+	}
+}
+
+func TestUserService_DeleteUser_BlockedByOrders(t *testing.T) {
+	repo := repository.NewUserRepository()
+	orders := stubOrdersClient{err: model.ErrUserHasActiveOrders}
+	svc := service.NewUserService(repo, orders, repository.NewTokenStore(), newTestKeys(t))
+
+	if err := svc.DeleteUser(context.Background(), 1); err != model.ErrUserHasActiveOrders {
+		t.Fatalf("expected ErrUserHasActiveOrders, got: %v", err)
+	}
+
+	if _, err := svc.GetUser(context.Background(), 1); err != nil {
+		t.Fatalf("expected user to stay after blocked deletion, got: %v", err)
+	}
+	if pending, _ := repo.PendingEvents(0); len(pending) != 0 {
+		t.Fatalf("expected no events, got: %+v", pending)
+	}
+}
+
+// Заказы меняются только по user.deleted, то есть после удаления пользователя.
+func TestUserService_DeleteUser_AppliesOrdersAfterDelete(t *testing.T) {
+	repo := repository.NewUserRepository()
+	var applied []int
+	svc := service.NewUserService(repo, stubOrdersClient{applied: &applied}, repository.NewTokenStore(), newTestKeys(t))
+
+	broker := events.NewLocalBroker()
+	broker.Subscribe(events.TypeUserDeleted, svc.HandleUserDeleted)
+	relay := events.NewRelay(repo, broker, time.Hour)
+
+	if err := svc.DeleteUser(context.Background(), 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("expected orders to wait for user.deleted, got: %v", applied)
+	}
+
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != 1 {
+		t.Fatalf("expected orders of user 1 to be handled once, got: %v", applied)
+	}
+}